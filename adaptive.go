@@ -0,0 +1,148 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+)
+
+// ---------- 并发参数（支持 -c auto） ----------
+
+// concurrencyValue 是 -c 参数的取值：固定并发数或自适应（auto）
+type concurrencyValue struct {
+	n    int
+	auto bool
+}
+
+func (v *concurrencyValue) String() string {
+	if v == nil {
+		return ""
+	}
+	if v.auto {
+		return "auto"
+	}
+	return strconv.Itoa(v.n)
+}
+
+func (v *concurrencyValue) Set(s string) error {
+	if s == "auto" {
+		v.auto = true
+		return nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 1 {
+		return fmt.Errorf("并发数必须为正整数或 auto")
+	}
+	v.n, v.auto = n, false
+	return nil
+}
+
+// concurrencyFlag 注册 -c 参数，用法与 flag.Int 一致
+func concurrencyFlag(name string, value int, usage string) *concurrencyValue {
+	v := &concurrencyValue{n: value}
+	flag.Var(v, name, usage)
+	return v
+}
+
+// ---------- 自适应并发闸门 ----------
+
+const (
+	autoConcurrencyStart = 2  // 自适应模式的起始并发
+	autoConcurrencyMax   = 32 // 自适应模式的并发上限
+)
+
+// concurrencyGate 控制同时处理的域名数。固定模式下等价于信号量；
+// 自适应模式下每累计一个窗口的操作结果就调整一次上限：
+// 出现 429 或错误率偏高时减半，错误率很低时加一。
+type concurrencyGate struct {
+	mu       sync.Mutex
+	cond     *sync.Cond
+	limit    int
+	min, max int
+	adaptive bool
+	inFlight int
+
+	// 当前窗口内的统计
+	total, failed, throttled int
+}
+
+func newConcurrencyGate(v *concurrencyValue) *concurrencyGate {
+	g := &concurrencyGate{limit: v.n, min: v.n, max: v.n}
+	if v.auto {
+		g.limit, g.min, g.max, g.adaptive = autoConcurrencyStart, 1, autoConcurrencyMax, true
+	}
+	g.cond = sync.NewCond(&g.mu)
+	return g
+}
+
+// Acquire 阻塞直到有空闲的并发槽位
+func (g *concurrencyGate) Acquire() {
+	g.mu.Lock()
+	for g.inFlight >= g.limit {
+		g.cond.Wait()
+	}
+	g.inFlight++
+	g.mu.Unlock()
+}
+
+// Release 归还槽位并反馈本次处理的结果：total 为操作数（DNS + API 查询），
+// failed 为失败数，throttled 为被限流（HTTP 429）的次数
+func (g *concurrencyGate) Release(total, failed, throttled int) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.inFlight--
+	if g.adaptive {
+		g.total += total
+		g.failed += failed
+		g.throttled += throttled
+		g.adjust()
+	}
+	g.cond.Broadcast()
+}
+
+// adjust 在窗口样本足够时按 AIMD 规则调整并发上限（调用方需持有锁）
+func (g *concurrencyGate) adjust() {
+	window := g.limit * 2
+	if g.throttled == 0 && g.total < window {
+		return
+	}
+	old := g.limit
+	errRate := 0.0
+	if g.total > 0 {
+		errRate = float64(g.failed) / float64(g.total)
+	}
+	switch {
+	case g.throttled > 0 || errRate > 0.2:
+		g.limit /= 2
+		if g.limit < g.min {
+			g.limit = g.min
+		}
+	case errRate < 0.05 && g.limit < g.max:
+		g.limit++
+	}
+	if g.limit != old {
+		fmt.Fprintf(os.Stderr, "自适应并发: %d -> %d（错误率 %.0f%%，429 次数 %d）\n", old, g.limit, errRate*100, g.throttled)
+	}
+	g.total, g.failed, g.throttled = 0, 0, 0
+}
+
+// ---------- API 状态码错误 ----------
+
+// APIStatusError 表示 IP 信息 API 返回了非 200 状态码
+type APIStatusError struct {
+	StatusCode int
+}
+
+func (e *APIStatusError) Error() string {
+	return fmt.Sprintf("API 返回非 200 状态码: %d", e.StatusCode)
+}
+
+// isThrottled 判断错误链中是否包含 HTTP 429
+func isThrottled(err error) bool {
+	var se *APIStatusError
+	return errors.As(err, &se) && se.StatusCode == http.StatusTooManyRequests
+}
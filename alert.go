@@ -0,0 +1,391 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ---------- 告警规则 ----------
+
+// AlertRule 是配置文件顶层 alerts 中的一条规则。配置了 alerts 时，syslog 与运行后钩子
+// 只在规则开始触发或恢复时通知，而不是每轮对每个被污染的域名都通知一次。
+type AlertRule struct {
+	Name      string `yaml:"name"`
+	Domain    string `yaml:"domain"`    // 为空时针对整轮结果，否则针对单个域名
+	Condition string `yaml:"condition"` // 如 "pollution_rate > 30%"、"polluted"
+	For       int    `yaml:"for"`       // 连续满足多少轮后触发，默认 1
+
+	cond alertCondition // 由 normalizeConfig 编译
+}
+
+// alertCondition 是编译后的条件；只写指标名时等价于 "指标 > 0"
+type alertCondition struct {
+	metric string
+	op     string
+	value  float64
+}
+
+// 整轮结果与单个域名各自可用的指标
+var (
+	runAlertMetrics    = []string{"pollution_rate", "polluted", "rebinding", "inconclusive"}
+	domainAlertMetrics = []string{"polluted", "rebinding", "inconclusive", "anomaly_score", "ip_count", "suspicious"}
+)
+
+var alertConditionRe = regexp.MustCompile(`^([a-z_]+)\s*(?:(>=|<=|==|!=|>|<)\s*(-?[0-9.]+)\s*%?)?$`)
+
+// parseAlertCondition 解析 "指标 [运算符 数值]"，数值后的 % 可省略
+func parseAlertCondition(s string, domainScope bool) (alertCondition, error) {
+	m := alertConditionRe.FindStringSubmatch(strings.TrimSpace(s))
+	if m == nil {
+		return alertCondition{}, fmt.Errorf("无法解析条件 %q（应为“指标 运算符 数值”，如 pollution_rate > 30%%）", s)
+	}
+	c := alertCondition{metric: m[1], op: ">", value: 0}
+	if m[2] != "" {
+		v, err := strconv.ParseFloat(m[3], 64)
+		if err != nil {
+			return alertCondition{}, fmt.Errorf("条件 %q 中的数值无效", s)
+		}
+		c.op, c.value = m[2], v
+	}
+	allowed := runAlertMetrics
+	if domainScope {
+		allowed = domainAlertMetrics
+	}
+	for _, name := range allowed {
+		if name == c.metric {
+			return c, nil
+		}
+	}
+	scope := "整轮结果"
+	if domainScope {
+		scope = "单个域名"
+	}
+	return alertCondition{}, fmt.Errorf("%s没有指标 %q（可选 %s）", scope, c.metric, strings.Join(allowed, "、"))
+}
+
+func (c alertCondition) holds(v float64) bool {
+	switch c.op {
+	case ">":
+		return v > c.value
+	case ">=":
+		return v >= c.value
+	case "<":
+		return v < c.value
+	case "<=":
+		return v <= c.value
+	case "==":
+		return v == c.value
+	case "!=":
+		return v != c.value
+	}
+	return false
+}
+
+// validateAlerts 编译 alerts 中的条件，并检查名称与域名
+func validateAlerts(cfg *Config) []string {
+	domains := make(map[string]bool, len(cfg.Domains))
+	for _, dc := range cfg.Domains {
+		domains[dc.Name] = true
+	}
+	seen := make(map[string]bool)
+	var problems []string
+	for i := range cfg.Alerts {
+		rule := &cfg.Alerts[i]
+		if rule.Name == "" {
+			problems = append(problems, fmt.Sprintf("alerts 第 %d 项缺少 name", i+1))
+			continue
+		}
+		if seen[rule.Name] {
+			problems = append(problems, fmt.Sprintf("alerts 中的 %s 重复", rule.Name))
+			continue
+		}
+		seen[rule.Name] = true
+		if rule.For < 0 {
+			problems = append(problems, fmt.Sprintf("alerts %s: for 不能为负数", rule.Name))
+		}
+		if rule.Domain != "" {
+			name, err := normalizeDomainName(rule.Domain)
+			if err == nil && !domains[name] {
+				err = fmt.Errorf("域名 %s 不在 domains 中", rule.Domain)
+			}
+			if err != nil {
+				problems = append(problems, fmt.Sprintf("alerts %s: %v", rule.Name, err))
+				continue
+			}
+			rule.Domain = name
+		}
+		cond, err := parseAlertCondition(rule.Condition, rule.Domain != "")
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("alerts %s: %v", rule.Name, err))
+			continue
+		}
+		rule.cond = cond
+	}
+	return problems
+}
+
+// alertMetric 计算规则所用指标的当前值；维护中的域名不参与计算，
+// 针对的域名不在本轮结果中或处于维护中时返回 false
+func alertMetric(rule AlertRule, results []DomainResult) (float64, bool) {
+	results = unmuted(results)
+	if rule.Domain == "" {
+		if len(results) == 0 {
+			return 0, false
+		}
+		switch rule.cond.metric {
+		case "pollution_rate":
+			return float64(countPolluted(results)) / float64(len(results)) * 100, true
+		case "polluted":
+			return float64(countPolluted(results)), true
+		case "rebinding":
+			return float64(countRebinding(results)), true
+		case "inconclusive":
+			return float64(countFailed(results)), true
+		}
+		return 0, false
+	}
+	for _, r := range results {
+		if r.Domain != rule.Domain {
+			continue
+		}
+		switch rule.cond.metric {
+		case "polluted":
+			return boolMetric(r.IsPolluted), true
+		case "rebinding":
+			return boolMetric(r.Rebinding), true
+		case "inconclusive":
+			return boolMetric(verdictOf(r) == verdictError), true
+		case "anomaly_score":
+			max := 0
+			for _, ip := range r.IPResults {
+				if ip.AnomalyScore > max {
+					max = ip.AnomalyScore
+				}
+			}
+			return float64(max), true
+		case "ip_count":
+			return float64(len(r.IPResults)), true
+		case "suspicious":
+			return float64(len(r.Suspicious)), true
+		}
+	}
+	return 0, false
+}
+
+func boolMetric(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// ---------- 告警状态 ----------
+
+// 告警事件的状态
+const (
+	alertFiring   = "firing"
+	alertResolved = "resolved"
+)
+
+// alertEvent 是一次告警状态变化，只在规则开始触发或恢复时产生
+type alertEvent struct {
+	Rule      string  `json:"rule"`
+	Status    string  `json:"status"`
+	Domain    string  `json:"domain,omitempty"`
+	Condition string  `json:"condition"`
+	Value     float64 `json:"value"`
+	Runs      int     `json:"consecutive_runs"`
+	Message   string  `json:"message"`
+}
+
+// alertState 是单条规则跨轮次的状态
+type alertState struct {
+	Consecutive int       `json:"consecutive"`
+	Firing      bool      `json:"firing"`
+	Since       time.Time `json:"since,omitempty"`
+}
+
+// alertEngine 逐轮评估告警规则。守护模式下状态保存在内存中；单次运行时可用 -alert-state
+// 指定状态文件，使 cron 调度的多次运行也能判断“连续 N 轮”。nil 表示未配置 alerts。
+type alertEngine struct {
+	mu    sync.Mutex
+	rules []AlertRule
+	state map[string]*alertState
+	path  string
+}
+
+// newAlertEngine 创建告警引擎并读取状态文件；没有规则时返回 nil
+func newAlertEngine(rules []AlertRule, statePath string) (*alertEngine, error) {
+	if len(rules) == 0 {
+		return nil, nil
+	}
+	e := &alertEngine{rules: rules, state: make(map[string]*alertState), path: statePath}
+	if statePath == "" {
+		return e, nil
+	}
+	data, err := os.ReadFile(statePath)
+	if os.IsNotExist(err) {
+		return e, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取告警状态文件失败: %w", err)
+	}
+	if err := json.Unmarshal(data, &e.state); err != nil {
+		return nil, fmt.Errorf("解析告警状态文件 %s 失败: %w", statePath, err)
+	}
+	return e, nil
+}
+
+// reloadAlertEngine 在守护模式重新加载配置后更新告警规则：沿用仍然存在的规则（按 name）的状态，
+// 丢弃已删除规则的状态；原来没有规则或新配置中没有规则时与 newAlertEngine 相同
+func reloadAlertEngine(e *alertEngine, rules []AlertRule, statePath string) (*alertEngine, error) {
+	if e == nil || len(rules) == 0 {
+		return newAlertEngine(rules, statePath)
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	names := make(map[string]bool, len(rules))
+	for _, rule := range rules {
+		names[rule.Name] = true
+	}
+	for name := range e.state {
+		if !names[name] {
+			delete(e.state, name)
+		}
+	}
+	e.rules = rules
+	return e, nil
+}
+
+// Evaluate 用本轮结果更新各规则的状态，返回状态发生变化的事件。单个域名的规则使用 results，
+// 整轮规则使用 latest：守护模式按分组调度时，latest 是合并了各组最近一轮的结果，否则与 results 相同
+func (e *alertEngine) Evaluate(results, latest []DomainResult) ([]alertEvent, error) {
+	if e == nil {
+		return nil, nil
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	var events []alertEvent
+	now := time.Now()
+	for _, rule := range e.rules {
+		src := results
+		if rule.Domain == "" {
+			src = latest
+		}
+		v, ok := alertMetric(rule, src)
+		if !ok {
+			continue // 该域名本轮未检测（如被 profile 筛掉）或处于维护中，保持原状态
+		}
+		st := e.state[rule.Name]
+		if st == nil {
+			st = &alertState{}
+			e.state[rule.Name] = st
+		}
+		need := rule.For
+		if need < 1 {
+			need = 1
+		}
+		ev := alertEvent{Rule: rule.Name, Domain: rule.Domain, Condition: rule.Condition, Value: v}
+		if rule.cond.holds(v) {
+			st.Consecutive++
+			if !st.Firing && st.Consecutive >= need {
+				st.Firing, st.Since = true, now
+				ev.Status, ev.Runs = alertFiring, st.Consecutive
+				ev.Message = fmt.Sprintf("%s 触发: %s（当前值 %g，连续 %d 轮）", alertSubject(rule), rule.Condition, v, st.Consecutive)
+				events = append(events, ev)
+			}
+			continue
+		}
+		st.Consecutive = 0
+		if st.Firing {
+			st.Firing = false
+			ev.Status = alertResolved
+			ev.Message = fmt.Sprintf("%s 恢复: %s 不再满足（当前值 %g，持续 %s）", alertSubject(rule), rule.Condition, v, now.Sub(st.Since).Round(time.Second))
+			st.Since = time.Time{}
+			events = append(events, ev)
+		}
+	}
+	return events, e.save()
+}
+
+// save 将状态写入状态文件（未指定时不保存）
+func (e *alertEngine) save() error {
+	if e.path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(e.state, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(e.path, data, 0644); err != nil {
+		return fmt.Errorf("写入告警状态文件失败: %w", err)
+	}
+	return nil
+}
+
+func alertSubject(rule AlertRule) string {
+	if rule.Domain != "" {
+		return fmt.Sprintf("告警 %s（%s）", rule.Name, rule.Domain)
+	}
+	return "告警 " + rule.Name
+}
+
+// countFiring 统计事件中开始触发的告警数
+func countFiring(events []alertEvent) int {
+	n := 0
+	for _, ev := range events {
+		if ev.Status == alertFiring {
+			n++
+		}
+	}
+	return n
+}
+
+// alertSection 生成报告中本轮告警状态变化的段落；只用于文本报告
+func alertSection(events []alertEvent) string {
+	if len(events) == 0 || *format != "text" {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("告警:\n")
+	for _, ev := range events {
+		tag := "触发"
+		if ev.Status == alertResolved {
+			tag = "恢复"
+		}
+		b.WriteString(fmt.Sprintf("  [%s] %s\n", tag, ev.Message))
+	}
+	b.WriteString("\n")
+	return b.String()
+}
+
+// WriteMetrics 以 Prometheus gauge 输出各规则当前是否处于触发状态
+func (e *alertEngine) WriteMetrics(w io.Writer) {
+	if e == nil {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	names := make([]string, 0, len(e.rules))
+	for _, rule := range e.rules {
+		names = append(names, rule.Name)
+	}
+	sort.Strings(names)
+	fmt.Fprintln(w, "# HELP dnscheck_alert_firing 告警规则当前是否处于触发状态（1 为触发）")
+	fmt.Fprintln(w, "# TYPE dnscheck_alert_firing gauge")
+	for _, name := range names {
+		v := 0
+		if st := e.state[name]; st != nil && st.Firing {
+			v = 1
+		}
+		fmt.Fprintf(w, "dnscheck_alert_firing{rule=%q} %d\n", name, v)
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ---------- LLC 别名表 ----------
+
+// llcAliases 将不同 API 对同一运营商的不同写法映射为统一名称。
+// 键为 aliasKey 规整后的写法，值为统一名称；nil 表示不做映射。
+type llcAliases map[string]string
+
+// loadLLCAliases 读取别名文件，格式为“统一名称: [写法...]”：
+//
+//	Cloudflare:
+//	  - CLOUDFLARENET
+//	  - Cloudflare, Inc.
+//
+// 比较时忽略大小写、空白和标点，统一名称本身也视为一种写法。path 为空时返回 nil。
+func loadLLCAliases(path string) (llcAliases, error) {
+	if path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取 LLC 别名文件失败: %w", err)
+	}
+	var raw map[string][]string
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("解析 LLC 别名文件 %s 失败: %w", path, err)
+	}
+
+	aliases := make(llcAliases)
+	var problems []string
+	add := func(spelling, canonical string) {
+		key := aliasKey(spelling)
+		if key == "" {
+			return
+		}
+		if prev, ok := aliases[key]; ok && prev != canonical {
+			problems = append(problems, fmt.Sprintf("%q 同时映射到 %q 和 %q", spelling, prev, canonical))
+			return
+		}
+		aliases[key] = canonical
+	}
+	for canonical, spellings := range raw {
+		add(canonical, canonical)
+		for _, s := range spellings {
+			add(s, canonical)
+		}
+	}
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("LLC 别名文件 %s 有冲突: %s", path, strings.Join(problems, "; "))
+	}
+	return aliases, nil
+}
+
+// Resolve 返回 API 结果统一后的 LLC：优先使用别名表；启用 -carrier-names 时再按内置的运营商对照表
+// 依次根据名称与 ASN 统一，都没有对应时原样返回
+func (a llcAliases) Resolve(llc string, asn uint32) string {
+	if canonical, ok := a[aliasKey(llc)]; ok {
+		return canonical
+	}
+	if *carrierMap {
+		if id := carrierByName(llc); id != "" {
+			return id
+		}
+		if id := carrierByASN(asn); id != "" {
+			return id
+		}
+	}
+	return llc
+}
+
+// aliasKey 去除空白和标点并转为小写，使 "Cloudflare, Inc." 与 "cloudflare inc" 视为同一写法
+func aliasKey(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(unicode.ToLower(r))
+		}
+	}
+	return b.String()
+}
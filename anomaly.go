@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// ---------- DNS 响应异常评分 ----------
+
+// maxNormalAnswers 是单个应答中同类型记录数的正常上限，超过视为异常膨胀
+const maxNormalAnswers = 16
+
+// 各类异常的权重，评分为命中异常的权重之和
+const (
+	anomalyQuestionMismatch = 3 // 问题部分与查询不一致
+	anomalyUnexpectedType   = 3 // 应答中出现未查询的记录类型
+	anomalyAuthoritative    = 2 // 递归解析器返回权威应答
+	anomalyPremature        = 3 // 迭代解析中上级区域的服务器直接给出应答
+	anomalyNotAuthoritative = 2 // 迭代解析中权威服务器的应答未设置 AA 位
+	anomalyBadEDNS          = 2 // EDNS（OPT 记录）异常
+	anomalyAnswerSpike      = 2 // 应答记录数异常多
+	anomalyZeroTTL          = 1 // 记录 TTL 为 0
+)
+
+// responseAnomaly 是在单个原始响应中发现的一项异常；IP 非空时只与该地址的 A 记录相关
+type responseAnomaly struct {
+	Reason string
+	Score  int
+	IP     string
+}
+
+// scoreResponse 检查原始响应中常见于伪造应答的特征。
+// recursive 表示 server 是递归解析器，此时权威应答（AA 位）本身就是异常；迭代解析时权威服务器设置 AA 是正常的。
+func scoreResponse(qname string, qtype dnsmessage.Type, resp *dnsmessage.Message, recursive bool) []responseAnomaly {
+	var found []responseAnomaly
+	add := func(score int, ip, format string, a ...interface{}) {
+		found = append(found, responseAnomaly{Reason: fmt.Sprintf(format, a...), Score: score, IP: ip})
+	}
+
+	if len(resp.Questions) != 1 || !strings.EqualFold(resp.Questions[0].Name.String(), dnsFQDN(qname)) || resp.Questions[0].Type != qtype {
+		add(anomalyQuestionMismatch, "", "问题部分与查询不一致")
+	}
+	if recursive && resp.Header.Authoritative {
+		add(anomalyAuthoritative, "", "递归解析器返回了权威应答（AA 位）")
+	}
+
+	unexpected := map[dnsmessage.Type]bool{}
+	matching := 0
+	for _, rr := range resp.Answers {
+		switch t := rr.Header.Type; {
+		case t == qtype:
+			matching++
+		case t != dnsmessage.TypeCNAME && !unexpected[t]:
+			unexpected[t] = true
+			add(anomalyUnexpectedType, "", "查询 %s 却返回了 %s 记录", typeName(qtype), typeName(t))
+		}
+		if rr.Header.TTL != 0 {
+			continue
+		}
+		if a, ok := rr.Body.(*dnsmessage.AResource); ok {
+			ip := net.IPv4(a.A[0], a.A[1], a.A[2], a.A[3]).String()
+			add(anomalyZeroTTL, ip, "A 记录 TTL 为 0")
+		} else {
+			add(anomalyZeroTTL, "", "%s 记录 %s 的 TTL 为 0", typeName(rr.Header.Type), rr.Header.Name)
+		}
+	}
+	if matching > maxNormalAnswers {
+		add(anomalyAnswerSpike, "", "应答包含 %d 条 %s 记录，远多于正常水平", matching, typeName(qtype))
+	}
+
+	// 查询不带 EDNS，符合 RFC 6891 的服务器不会在响应中加入 OPT 记录
+	opts := 0
+	for _, rr := range resp.Additionals {
+		if rr.Header.Type != dnsmessage.TypeOPT {
+			continue
+		}
+		opts++
+		if rr.Header.Name.String() != "." {
+			add(anomalyBadEDNS, "", "OPT 记录的名称不是根域（%s）", rr.Header.Name)
+		}
+	}
+	switch {
+	case opts > 1:
+		add(anomalyBadEDNS, "", "响应包含 %d 条 OPT 记录", opts)
+	case opts == 1:
+		add(anomalyBadEDNS, "", "查询未使用 EDNS，响应却包含 OPT 记录")
+	}
+	return found
+}
+
+// scoreDelegation 检查迭代解析中给出最终应答的那次查询：根服务器只会返回委派，
+// 直接给出 A 记录说明应答是在通往根服务器的链路上伪造的；权威服务器的应答应当设置 AA 位
+func scoreDelegation(zone string, resp *dnsmessage.Message) []responseAnomaly {
+	var found []responseAnomaly
+	if zone == "." {
+		found = append(found, responseAnomaly{Reason: "根服务器直接给出了 A 记录（正常只返回委派）", Score: anomalyPremature})
+	}
+	if !resp.Header.Authoritative {
+		found = append(found, responseAnomaly{Reason: fmt.Sprintf("%s 层服务器的应答未设置 AA 位", zone), Score: anomalyNotAuthoritative})
+	}
+	return found
+}
+
+// typeName 返回记录类型的常见写法，如 "A" 而不是 "TypeA"
+func typeName(t dnsmessage.Type) string {
+	return strings.TrimPrefix(t.String(), "Type")
+}
+
+// applyAnomalies 将产生最终应答的那次查询中发现的异常附加到各 IP 上。
+// 只有原始解析模式（-resolver）才有查询记录，系统解析器下不做评分。
+func applyAnomalies(ipResults []IPCheckResult, trace []resolveStep) {
+	if len(trace) == 0 {
+		return
+	}
+	final := trace[len(trace)-1].Anomalies
+	for i := range ipResults {
+		for _, a := range final {
+			if a.IP != "" && a.IP != ipResults[i].IP {
+				continue
+			}
+			ipResults[i].Anomalies = append(ipResults[i].Anomalies, a.Reason)
+			ipResults[i].AnomalyScore += a.Score
+		}
+	}
+}
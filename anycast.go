@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ---------- Anycast / 多 POP 合并（-anycast） ----------
+
+// answerKey 返回 IP 所属的逻辑应答：同一 ASN 的 IP 视为同一个应答（anycast 或同一 CDN 的不同 POP），
+// ASN 未知时按 LLC 合并，两者都未知时只代表该 IP 本身
+func answerKey(asn uint32, llc, ip string) string {
+	switch {
+	case asn != 0:
+		return fmt.Sprintf("AS%d", asn)
+	case llc != "":
+		return "LLC " + llc
+	}
+	return ip
+}
+
+// answerKeys 返回一组 IP 的逻辑应答集合
+func answerKeys(ips []IPCheckResult) map[string]bool {
+	keys := make(map[string]bool, len(ips))
+	for _, ip := range ips {
+		keys[answerKey(ip.ASN, ip.ActualLLC, ip.IP)] = true
+	}
+	return keys
+}
+
+// answerCount 返回用于比较的应答数：启用 -anycast 时为逻辑应答数，否则为 IP 数
+func answerCount(ips []IPCheckResult) int {
+	if !*anycastMode {
+		return len(ips)
+	}
+	return len(answerKeys(ips))
+}
+
+// historyAnswerCount 与 answerCount 相同，用于历史记录中的 IP
+func historyAnswerCount(ips []historyIP) int {
+	if !*anycastMode {
+		return len(ips)
+	}
+	keys := make(map[string]bool, len(ips))
+	for _, ip := range ips {
+		keys[answerKey(ip.ASN, ip.LLC, ip.IP)] = true
+	}
+	return len(keys)
+}
+
+// collapseAnswers 将同一逻辑应答、且判定相同的 IP 合并为一条用于报告显示的结果。
+// 查询失败、私有或保留地址以及带有响应异常的 IP 保持单独显示
+func collapseAnswers(res DomainResult) []IPCheckResult {
+	var out []IPCheckResult
+	index := make(map[string]int)
+	count := make(map[string]int)
+	for _, ip := range res.IPResults {
+		if ip.Error != nil || ip.Private || ip.Source == sourceBogon || len(ip.Anomalies) > 0 {
+			out = append(out, ip)
+			continue
+		}
+		key := fmt.Sprintf("%s|%v|%s|%v|%v|%v|%v", answerKey(ip.ASN, ip.ActualLLC, ip.IP), res.ipMatched(ip), ip.Forbidden, ip.NotPinned,
+			ip.Probe.unreachable(), ip.Ports.suspicious(), ip.Page.mismatch())
+		if i, ok := index[key]; ok {
+			out[i].IP += ", " + ip.IP
+			count[key]++
+			continue
+		}
+		index[key] = len(out)
+		count[key] = 1
+		out = append(out, ip)
+	}
+	for key, i := range index {
+		if n := count[key]; n > 1 {
+			out[i].IP = fmt.Sprintf("%s（%s 的 %d 个 IP）", out[i].IP, strings.SplitN(key, "|", 2)[0], n)
+		}
+	}
+	return out
+}
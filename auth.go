@@ -0,0 +1,318 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ---------- 接口认证（守护模式 -api-tokens / -tls-client-ca） ----------
+
+// 守护模式的接口可以静音告警、立即触发检测、重新加载配置，部署在监控主机上时不能不加认证地暴露。
+// -api-tokens 指定的文件列出可用的令牌（Authorization: Bearer）及其权限；-tls-client-ca 启用双向 TLS，
+// 按客户端证书的 CN 授予权限。两者都未配置时不做认证，与之前相同。/healthz 与 /readyz 始终不需要认证，供探针使用。
+// 按项目运行（-projects）时各项目共用同一份令牌，令牌可以用 projects 限定只能访问哪些项目
+
+// 接口权限
+const (
+	scopeRead   = "read"   // 查询状态、指标、可用性、历史记录与静音，订阅 /api/stream
+	scopeCheck  = "check"  // 通过 /api/check 立即触发检测
+	scopeManage = "manage" // 添加、解除静音，通过 /api/reload 重新加载配置
+)
+
+var apiScopes = []string{scopeRead, scopeCheck, scopeManage}
+
+// APIToken 是令牌文件中的一项。token 与 token_sha256 二选一；只写 client_cn 时只用于双向 TLS
+type APIToken struct {
+	Name     string   `yaml:"name"`         // 用于日志与错误信息
+	Token    string   `yaml:"token"`        // 明文令牌
+	Hash     string   `yaml:"token_sha256"` // 令牌的 SHA-256（十六进制），文件中无需保存明文
+	ClientCN string   `yaml:"client_cn"`    // 双向 TLS 时客户端证书的 CN
+	Scopes   []string `yaml:"scopes"`       // read、check、manage，"*" 表示全部
+	Projects []string `yaml:"projects"`     // 只能访问这些项目（-projects），为空表示不限
+}
+
+// apiPrincipal 是一个已认证的调用方
+type apiPrincipal struct {
+	name     string
+	scopes   map[string]bool
+	projects map[string]bool // nil 表示不限项目
+}
+
+// allows 判断调用方能否访问项目 id；id 为空表示不属于任何项目的接口，只有不限项目的调用方可以访问
+func (p *apiPrincipal) allows(id string) bool {
+	return p.projects == nil || (id != "" && p.projects[id])
+}
+
+// apiAuth 校验请求的令牌或客户端证书。nil 表示不认证
+type apiAuth struct {
+	tokens  map[[sha256.Size]byte]*apiPrincipal
+	certs   map[string]*apiPrincipal // 键为客户端证书的 CN
+	anyCert bool                     // 启用了双向 TLS 且令牌文件中没有 client_cn：任何受信任的证书都拥有全部权限
+}
+
+// loadAPIAuth 读取令牌文件；path 为空时只按 mtls 决定是否认证客户端证书
+func loadAPIAuth(path string, mtls bool) (*apiAuth, error) {
+	if path == "" && !mtls {
+		return nil, nil
+	}
+	a := &apiAuth{tokens: make(map[[sha256.Size]byte]*apiPrincipal), certs: make(map[string]*apiPrincipal)}
+	if path == "" {
+		a.anyCert = true
+		return a, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取令牌文件失败: %w", err)
+	}
+	var entries []APIToken
+	if err := yaml.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("解析令牌文件 %s 失败: %w", path, err)
+	}
+	for i, e := range entries {
+		p, err := newAPIPrincipal(e)
+		if err != nil {
+			return nil, fmt.Errorf("令牌文件 %s 第 %d 项: %w", path, i+1, err)
+		}
+		if e.Token != "" || e.Hash != "" {
+			sum := sha256.Sum256([]byte(e.Token))
+			if e.Hash != "" {
+				raw, err := hex.DecodeString(e.Hash)
+				if err != nil || len(raw) != sha256.Size {
+					return nil, fmt.Errorf("令牌文件 %s 第 %d 项: token_sha256 必须是 64 位十六进制", path, i+1)
+				}
+				copy(sum[:], raw)
+			}
+			if _, dup := a.tokens[sum]; dup {
+				return nil, fmt.Errorf("令牌文件 %s 第 %d 项: 令牌与其他项重复", path, i+1)
+			}
+			a.tokens[sum] = p
+		}
+		if e.ClientCN != "" {
+			a.certs[e.ClientCN] = p
+		}
+	}
+	a.anyCert = mtls && len(a.certs) == 0
+	return a, nil
+}
+
+// newAPIPrincipal 校验令牌文件中的一项
+func newAPIPrincipal(e APIToken) (*apiPrincipal, error) {
+	switch {
+	case e.Name == "":
+		return nil, fmt.Errorf("缺少 name")
+	case e.Token != "" && e.Hash != "":
+		return nil, fmt.Errorf("%s: token 与 token_sha256 只能写一个", e.Name)
+	case e.Token == "" && e.Hash == "" && e.ClientCN == "":
+		return nil, fmt.Errorf("%s: 需要 token、token_sha256 或 client_cn", e.Name)
+	case len(e.Scopes) == 0:
+		return nil, fmt.Errorf("%s: 缺少 scopes", e.Name)
+	}
+	p := &apiPrincipal{name: e.Name, scopes: make(map[string]bool)}
+	for _, s := range e.Scopes {
+		if s == "*" {
+			for _, all := range apiScopes {
+				p.scopes[all] = true
+			}
+			continue
+		}
+		known := false
+		for _, k := range apiScopes {
+			known = known || k == s
+		}
+		if !known {
+			return nil, fmt.Errorf("%s: 未知的权限 %q（可选 %s 或 *）", e.Name, s, strings.Join(apiScopes, "、"))
+		}
+		p.scopes[s] = true
+	}
+	if len(e.Projects) > 0 {
+		p.projects = make(map[string]bool, len(e.Projects))
+		for _, id := range e.Projects {
+			p.projects[id] = true
+		}
+	}
+	return p, nil
+}
+
+// authenticate 返回请求的调用方；没有提供凭据或凭据无效时返回错误
+func (a *apiAuth) authenticate(r *http.Request) (*apiPrincipal, error) {
+	if h := r.Header.Get("Authorization"); h != "" {
+		token := strings.TrimSpace(strings.TrimPrefix(h, "Bearer "))
+		if token == h {
+			return nil, fmt.Errorf("只支持 Bearer 令牌")
+		}
+		sum := sha256.Sum256([]byte(token))
+		// 逐个做常数时间比较，避免通过响应时间猜出令牌
+		var found *apiPrincipal
+		for known, p := range a.tokens {
+			if subtle.ConstantTimeCompare(known[:], sum[:]) == 1 {
+				found = p
+			}
+		}
+		if found == nil {
+			return nil, fmt.Errorf("令牌无效")
+		}
+		return found, nil
+	}
+	if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 {
+		cn := r.TLS.VerifiedChains[0][0].Subject.CommonName
+		if a.anyCert {
+			p := &apiPrincipal{name: cn, scopes: make(map[string]bool)}
+			for _, s := range apiScopes {
+				p.scopes[s] = true
+			}
+			return p, nil
+		}
+		if p, ok := a.certs[cn]; ok {
+			return p, nil
+		}
+		return nil, fmt.Errorf("客户端证书 %s 未授权", cn)
+	}
+	return nil, fmt.Errorf("需要认证")
+}
+
+// apiGuard 保存当前生效的认证设置，重新加载配置时替换；按项目运行时各项目共用一个
+type apiGuard struct {
+	file string // -api-tokens
+	mtls bool   // 指定了 -tls-client-ca
+
+	mu   sync.RWMutex
+	auth *apiAuth // nil 表示不认证
+}
+
+// newAPIGuard 读取令牌文件并返回认证设置
+func newAPIGuard(file string, mtls bool) (*apiGuard, error) {
+	g := &apiGuard{file: file, mtls: mtls}
+	auth, err := g.load()
+	if err != nil {
+		return nil, err
+	}
+	g.auth = auth
+	return g, nil
+}
+
+// load 重新读取令牌文件，不替换当前设置；与 set 分开，使配置有误时令牌也保持不变
+func (g *apiGuard) load() (*apiAuth, error) {
+	return loadAPIAuth(g.file, g.mtls)
+}
+
+func (g *apiGuard) set(auth *apiAuth) {
+	g.mu.Lock()
+	g.auth = auth
+	g.mu.Unlock()
+}
+
+// enabled 判断是否启用了认证
+func (g *apiGuard) enabled() bool {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.auth != nil
+}
+
+// anyProject 用于列出项目等接口：任何调用方都可以访问，由处理函数按 principalFrom 过滤
+const anyProject = "*"
+
+type principalKey struct{}
+
+// principalFrom 返回 wrap 认证过的调用方；未启用认证时为 nil
+func principalFrom(r *http.Request) *apiPrincipal {
+	p, _ := r.Context().Value(principalKey{}).(*apiPrincipal)
+	return p
+}
+
+// wrap 为项目 project 的接口加上认证：GET 与 HEAD 请求需要 read 权限，其他方法需要 write 权限
+func (g *apiGuard) wrap(project string, h http.HandlerFunc, read, write string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		g.mu.RLock()
+		auth := g.auth
+		g.mu.RUnlock()
+		if auth == nil {
+			h(w, r)
+			return
+		}
+		scope := write
+		if r.Method == http.MethodGet || r.Method == http.MethodHead {
+			scope = read
+		}
+		p, err := auth.authenticate(r)
+		if err != nil {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="dnscheck"`)
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": err.Error()})
+			return
+		}
+		if !p.scopes[scope] {
+			writeJSON(w, http.StatusForbidden, map[string]string{"error": fmt.Sprintf("%s 没有 %s 权限", p.name, scope)})
+			return
+		}
+		if project != anyProject && !p.allows(project) {
+			msg := fmt.Sprintf("%s 无权访问项目 %s", p.name, project)
+			if project == "" {
+				msg = fmt.Sprintf("%s 只能访问指定项目的接口", p.name)
+			}
+			writeJSON(w, http.StatusForbidden, map[string]string{"error": msg})
+			return
+		}
+		h(w, r.WithContext(context.WithValue(r.Context(), principalKey{}, p)))
+	}
+}
+
+// guard 为 d 的接口加上认证，见 apiGuard.wrap
+func (d *daemon) guard(h http.HandlerFunc, read, write string) http.HandlerFunc {
+	return d.api.wrap(d.project, h, read, write)
+}
+
+// serverTLSConfig 根据 -tls-cert、-tls-key 与 -tls-client-ca 生成 TLS 配置；未指定证书时返回 nil（明文 HTTP）。
+// 指定客户端 CA 时校验客户端证书，但不强制提供，使探针与使用令牌的客户端仍可访问
+func serverTLSConfig(certFile, keyFile, clientCA string) (*tls.Config, error) {
+	if certFile == "" && keyFile == "" {
+		if clientCA != "" {
+			return nil, fmt.Errorf("-tls-client-ca 需要同时指定 -tls-cert 与 -tls-key")
+		}
+		return nil, nil
+	}
+	if certFile == "" || keyFile == "" {
+		return nil, fmt.Errorf("-tls-cert 与 -tls-key 必须同时指定")
+	}
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("读取 TLS 证书失败: %w", err)
+	}
+	cfg := &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+	if clientCA != "" {
+		pem, err := os.ReadFile(clientCA)
+		if err != nil {
+			return nil, fmt.Errorf("读取客户端 CA 失败: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("%s 中没有有效的 PEM 证书", clientCA)
+		}
+		cfg.ClientCAs = pool
+		cfg.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+	return cfg, nil
+}
+
+// isLoopbackListen 判断监听地址是否只在本机可访问
+func isLoopbackListen(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
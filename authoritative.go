@@ -0,0 +1,239 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// ---------- 与权威服务器直接比较（-verify-authoritative） ----------
+
+// maxAuthServers 是每个域名最多直接查询的权威服务器地址数
+const maxAuthServers = 6
+
+// 比较结论
+const (
+	authMatch       = "match"       // 递归应答中的 IP 都出现在权威应答中
+	authPartial     = "partial"     // 有交集但递归应答中有权威应答之外的 IP（也可能是按地域返回不同应答的 CDN）
+	authMismatch    = "mismatch"    // 递归应答与权威应答没有任何交集
+	authUnavailable = "unavailable" // 没有得到权威应答，无法比较
+)
+
+// authAnswer 是单个权威服务器的应答
+type authAnswer struct {
+	Server string   `json:"server"`
+	IPs    []string `json:"ips,omitempty"`
+	CNAME  string   `json:"cname,omitempty"`
+	RCode  string   `json:"rcode,omitempty"`
+	Error  string   `json:"error,omitempty"`
+}
+
+// authCheck 是单个域名的权威应答及其与递归应答的比较
+type authCheck struct {
+	Zone    string       `json:"zone,omitempty"`
+	Servers []authAnswer `json:"servers,omitempty"`
+	IPs     []string     `json:"ips"`             // 权威应答（跟随 CNAME 后）的全部 IP
+	Extra   []string     `json:"extra,omitempty"` // 递归应答中不在权威应答里的 IP
+	Verdict string       `json:"verdict"`
+	Error   string       `json:"error,omitempty"`
+}
+
+func (a authCheck) String() string {
+	names := map[string]string{
+		authMatch:       "一致",
+		authPartial:     "部分不同",
+		authMismatch:    "不一致",
+		authUnavailable: "无法比较",
+	}
+	s := fmt.Sprintf("（%s）", names[a.Verdict])
+	if a.Zone != "" {
+		s += fmt.Sprintf(" 区域 %s，%d 个权威服务器", a.Zone, len(a.Servers))
+	}
+	if len(a.IPs) > 0 {
+		s += "，权威应答 " + strings.Join(a.IPs, ", ")
+	} else if authNXDomain(a.Servers) {
+		s += "，权威应答 NXDOMAIN"
+	}
+	if len(a.Extra) > 0 {
+		s += "，不在权威应答中: " + strings.Join(a.Extra, ", ")
+	}
+	if a.Error != "" {
+		s += "；" + a.Error
+	}
+	return s
+}
+
+// verifyAuthoritative 在指定 -verify-authoritative 时，从根服务器跟随委派找到域名所在区域的权威服务器，
+// 直接向每个服务器查询并与递归应答比较。权威应答是不依赖第三方 API 的基准：
+// 两者没有任何交集时判定为污染；部分不同只记录，因为 CDN 可能按查询来源返回不同的应答
+func verifyAuthoritative(ctx context.Context, res *DomainResult) {
+	if !*verifyAuth || res.ASCIIDomain == "" {
+		return
+	}
+	check := &authCheck{IPs: []string{}}
+	res.Authority = check
+	target := dnsFQDN(strings.ToLower(res.ASCIIDomain))
+	r := &iterativeResolver{}
+	var steps []resolveStep
+	zone, servers, err := r.delegation(ctx, target, &steps)
+	if err != nil {
+		check.Verdict, check.Error = authUnavailable, err.Error()
+		return
+	}
+	check.Zone = zone
+	if len(servers) > maxAuthServers {
+		servers = servers[:maxAuthServers]
+	}
+
+	check.Servers = make([]authAnswer, len(servers))
+	var wg sync.WaitGroup
+	for i, server := range servers {
+		wg.Add(1)
+		go func(i int, server string) {
+			defer wg.Done()
+			check.Servers[i] = queryAuthoritative(ctx, zone, server, target)
+		}(i, server)
+	}
+	wg.Wait()
+
+	// 合并各服务器的应答；权威服务器给出 CNAME 时继续迭代解析目标名称
+	seen := make(map[string]bool)
+	cnames := make(map[string]bool)
+	for _, a := range check.Servers {
+		for _, ip := range a.IPs {
+			if !seen[ip] {
+				seen[ip] = true
+				check.IPs = append(check.IPs, ip)
+			}
+		}
+		if a.CNAME != "" && !cnames[a.CNAME] {
+			cnames[a.CNAME] = true
+			ips, err := r.resolve(ctx, strings.ToLower(a.CNAME), 1, &steps)
+			if err != nil {
+				check.Error = fmt.Sprintf("解析 CNAME %s 失败: %v", a.CNAME, err)
+				continue
+			}
+			for _, ip := range ips {
+				if s := ip.String(); !seen[s] {
+					seen[s] = true
+					check.IPs = append(check.IPs, s)
+				}
+			}
+		}
+	}
+	sort.Strings(check.IPs)
+
+	// 私有地址（如 localhost）不在公网权威服务器中，不参与比较
+	var recursive []string
+	for _, ip := range res.IPResults {
+		if !ip.Private {
+			recursive = append(recursive, ip.IP)
+		}
+	}
+	nxdomain := len(check.IPs) == 0 && authNXDomain(check.Servers)
+	switch {
+	case len(check.IPs) == 0 && !nxdomain:
+		check.Verdict = authUnavailable
+		return
+	case len(recursive) == 0:
+		// 递归解析失败或只有私有地址时只记录权威应答
+		check.Verdict = authUnavailable
+		return
+	}
+	check.Extra = stringsMissing(recursive, check.IPs)
+	switch {
+	case len(check.Extra) == 0:
+		check.Verdict = authMatch
+	case len(check.Extra) < len(recursive):
+		check.Verdict = authPartial
+	default:
+		check.Verdict = authMismatch
+		res.IsPolluted = true
+		if nxdomain {
+			res.Summary = "权威服务器返回 NXDOMAIN，递归解析却得到了 IP"
+		} else {
+			res.Summary = "递归应答与权威服务器的应答没有交集"
+		}
+	}
+}
+
+// authNXDomain 判断应答的权威服务器是否都返回 NXDOMAIN
+func authNXDomain(answers []authAnswer) bool {
+	n := 0
+	for _, a := range answers {
+		if a.Error != "" {
+			continue
+		}
+		if a.RCode != dnsmessage.RCodeNameError.String() {
+			return false
+		}
+		n++
+	}
+	return n > 0
+}
+
+// delegation 从根服务器开始跟随委派，返回 target 所在的权威区域及其 NS 地址
+func (r *iterativeResolver) delegation(ctx context.Context, target string, steps *[]resolveStep) (string, []string, error) {
+	zone, servers := ".", rootServers
+	for len(*steps) < maxResolveSteps {
+		var resp *dnsmessage.Message
+		var step resolveStep
+		for i, server := range servers {
+			if i >= maxServerTries {
+				break
+			}
+			step, resp = rawQuery(ctx, zone, net.JoinHostPort(server, "53"), target, dnsmessage.TypeA)
+			*steps = append(*steps, step)
+			if step.Err == nil {
+				break
+			}
+		}
+		if resp == nil {
+			return "", nil, fmt.Errorf("在 %s 层查询 %s 失败: %v", zone, target, step.Err)
+		}
+		child, nsNames := referral(resp, zone, target)
+		if child == "" {
+			return zone, servers, nil
+		}
+		addrs, err := r.nsAddresses(ctx, resp, nsNames, 0, steps)
+		if err != nil {
+			return "", nil, fmt.Errorf("在 %s 层获取 %s 的权威服务器地址失败: %v", zone, child, err)
+		}
+		zone, servers = child, addrs
+	}
+	return "", nil, fmt.Errorf("查找 %s 的权威服务器时查询次数超过上限 %d", target, maxResolveSteps)
+}
+
+// queryAuthoritative 直接向一个权威服务器查询 target 的 A 记录
+func queryAuthoritative(ctx context.Context, zone, server, target string) authAnswer {
+	a := authAnswer{Server: server}
+	step, resp := rawQuery(ctx, zone, net.JoinHostPort(server, "53"), target, dnsmessage.TypeA)
+	if step.Err != nil {
+		a.Error = step.Err.Error()
+		return a
+	}
+	a.RCode = resp.Header.RCode.String()
+	for _, ip := range answerIPv4(resp) {
+		a.IPs = append(a.IPs, ip.String())
+	}
+	if len(a.IPs) == 0 {
+		a.CNAME = answerCNAME(resp, target)
+	}
+	return a
+}
+
+// countAuthMismatch 统计递归应答与权威应答没有交集的域名数
+func countAuthMismatch(results []DomainResult) int {
+	n := 0
+	for _, r := range results {
+		if r.Authority != nil && r.Authority.Verdict == authMismatch {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ---------- 干净解析可用性（SLO） ----------
+
+// sloWindow 是计算可用性的滚动窗口
+type sloWindow struct {
+	Name string
+	Span time.Duration
+}
+
+// sloWindows 是守护模式下统计的窗口，按从短到长排列
+var sloWindows = []sloWindow{
+	{"24h", 24 * time.Hour},
+	{"7d", 7 * 24 * time.Hour},
+	{"30d", 30 * 24 * time.Hour},
+}
+
+// windowAvailability 是某个窗口内的可用性：被判定为污染的检测视同一次不可用，
+// 无法判定的检测（解析或 IP 归属查询失败）既不算可用也不算不可用，不计入 Runs
+type windowAvailability struct {
+	Window       string  `json:"window"`
+	Runs         int     `json:"runs"`
+	Clean        int     `json:"clean"`
+	Inconclusive int     `json:"inconclusive"`
+	Availability float64 `json:"availability"` // 0~1，窗口内没有可判定的检测时为 0 且 Runs 为 0
+}
+
+// domainAvailability 是单个域名在各窗口的可用性，Windows 与 sloWindows 一一对应
+type domainAvailability struct {
+	Domain  string               `json:"domain"`
+	Windows []windowAvailability `json:"windows"`
+}
+
+// computeAvailability 根据历史记录计算 domains 中每个域名在各窗口的干净解析可用性；
+// 已从配置中移除的域名不再列出
+func computeAvailability(store historyStore, domains []string, now time.Time) ([]domainAvailability, error) {
+	byDomain := make(map[string]*domainAvailability, len(domains))
+	out := make([]domainAvailability, len(domains))
+	for i, name := range domains {
+		out[i] = domainAvailability{Domain: name, Windows: make([]windowAvailability, len(sloWindows))}
+		for j, w := range sloWindows {
+			out[i].Windows[j].Window = w.Name
+		}
+		byDomain[name] = &out[i]
+	}
+	for j, w := range sloWindows {
+		counts, err := store.Counts(now.Add(-w.Span))
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range counts {
+			da, ok := byDomain[c.Domain]
+			if !ok {
+				continue
+			}
+			wa := &da.Windows[j]
+			wa.Inconclusive = c.Inconclusive
+			wa.Runs = c.Runs - c.Inconclusive
+			if wa.Runs <= 0 {
+				wa.Runs = 0
+				continue
+			}
+			wa.Clean = wa.Runs - c.Polluted
+			wa.Availability = float64(wa.Clean) / float64(wa.Runs)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Domain < out[j].Domain })
+	return out, nil
+}
+
+// resultDomains 返回结果中的域名
+func resultDomains(results []DomainResult) []string {
+	names := make([]string, 0, len(results))
+	for _, r := range results {
+		names = append(names, r.Domain)
+	}
+	return names
+}
+
+// writeAvailability 在报告中写入各域名的可用性，按最短的有记录窗口排序，可用性最低的在前
+func writeAvailability(b *strings.Builder, stats []domainAvailability) {
+	if len(stats) == 0 {
+		return
+	}
+	sorted := append([]domainAvailability(nil), stats...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].sortKey() < sorted[j].sortKey()
+	})
+	names := make([]string, len(sloWindows))
+	for i, w := range sloWindows {
+		names[i] = fmt.Sprintf("%8s", w.Name)
+	}
+	b.WriteString("干净解析可用性（污染视为不可用）:\n")
+	b.WriteString(fmt.Sprintf("  %-30s %s\n", "", strings.Join(names, " ")))
+	for _, da := range sorted {
+		cols := make([]string, len(da.Windows))
+		for i, w := range da.Windows {
+			if w.Runs == 0 {
+				cols[i] = fmt.Sprintf("%8s", "-")
+				continue
+			}
+			cols[i] = fmt.Sprintf("%7.2f%%", w.Availability*100)
+		}
+		b.WriteString(fmt.Sprintf("  %-30s %s\n", da.Domain, strings.Join(cols, " ")))
+	}
+	b.WriteString("\n")
+}
+
+// sortKey 返回最短的有记录窗口的可用性，没有任何记录时返回 2 以排在最后
+func (da domainAvailability) sortKey() float64 {
+	for _, w := range da.Windows {
+		if w.Runs > 0 {
+			return w.Availability
+		}
+	}
+	return 2
+}
+
+// writeAvailabilityMetrics 以 Prometheus gauge 输出各域名在各窗口的可用性
+func writeAvailabilityMetrics(w io.Writer, stats []domainAvailability) {
+	if len(stats) == 0 {
+		return
+	}
+	fmt.Fprintln(w, "# HELP dnscheck_availability_ratio 各窗口内干净解析的检测占比（污染视为不可用）")
+	fmt.Fprintln(w, "# TYPE dnscheck_availability_ratio gauge")
+	for _, da := range stats {
+		for _, wa := range da.Windows {
+			if wa.Runs > 0 {
+				fmt.Fprintf(w, "dnscheck_availability_ratio{domain=%q,window=%q} %g\n", da.Domain, wa.Window, wa.Availability)
+			}
+		}
+	}
+	fmt.Fprintln(w, "# HELP dnscheck_availability_checks 各窗口内可判定的检测次数（不含无法判定的检测）")
+	fmt.Fprintln(w, "# TYPE dnscheck_availability_checks gauge")
+	for _, da := range stats {
+		for _, wa := range da.Windows {
+			fmt.Fprintf(w, "dnscheck_availability_checks{domain=%q,window=%q} %d\n", da.Domain, wa.Window, wa.Runs)
+		}
+	}
+}
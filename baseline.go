@@ -0,0 +1,253 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ---------- 基线快照（dnscheck baseline / -against） ----------
+
+// baselineSnapshot 是一次已知正常的完整解析结果。-against 指定基线后，
+// 域名解析到基线中没有的 IP 即判定为污染，与配置文件中的预期相互独立
+type baselineSnapshot struct {
+	CapturedAt time.Time        `json:"captured_at"`
+	RunID      string           `json:"run_id,omitempty"`
+	Domains    []baselineDomain `json:"domains"`
+
+	ips  map[string]map[string]bool // 域名 → IP 集合，由 loadBaseline 建立
+	keys map[string]map[string]bool // 域名 → 逻辑应答集合（-anycast），由 loadBaseline 建立
+}
+
+// baselineDomain 是基线中单个域名的应答；比较时使用 IP，-anycast 时还使用 ASN 与 LLC
+type baselineDomain struct {
+	Domain string   `json:"domain"`
+	IPs    []string `json:"ips"`
+	LLCs   []string `json:"llcs,omitempty"`
+	ASNs   []uint32 `json:"asns,omitempty"`
+}
+
+// newBaseline 由一轮检测结果生成基线；没有解析到 IP 的域名不写入基线
+func newBaseline(runID string, at time.Time, results []domainJSON) *baselineSnapshot {
+	b := &baselineSnapshot{CapturedAt: at, RunID: runID, Domains: []baselineDomain{}}
+	for _, r := range results {
+		if len(r.IPs) == 0 {
+			continue
+		}
+		d := baselineDomain{Domain: r.Domain}
+		llcs := make(map[string]bool)
+		asns := make(map[uint32]bool)
+		for _, ip := range r.IPs {
+			d.IPs = append(d.IPs, ip.IP)
+			if ip.LLC != "" && !llcs[ip.LLC] {
+				llcs[ip.LLC] = true
+				d.LLCs = append(d.LLCs, ip.LLC)
+			}
+			if ip.ASN != 0 && !asns[ip.ASN] {
+				asns[ip.ASN] = true
+				d.ASNs = append(d.ASNs, ip.ASN)
+			}
+		}
+		sort.Strings(d.IPs)
+		b.Domains = append(b.Domains, d)
+	}
+	sort.Slice(b.Domains, func(i, j int) bool { return b.Domains[i].Domain < b.Domains[j].Domain })
+	return b
+}
+
+// loadBaseline 读取基线文件；path 为空时返回 nil
+func loadBaseline(path string) (*baselineSnapshot, error) {
+	if path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取基线文件失败: %w", err)
+	}
+	var b baselineSnapshot
+	if err := json.Unmarshal(data, &b); err != nil {
+		return nil, fmt.Errorf("解析基线文件 %s 失败: %w", path, err)
+	}
+	b.ips = make(map[string]map[string]bool, len(b.Domains))
+	b.keys = make(map[string]map[string]bool, len(b.Domains))
+	for _, d := range b.Domains {
+		name, err := normalizeDomainName(d.Domain)
+		if err != nil {
+			return nil, fmt.Errorf("基线文件 %s: %w", path, err)
+		}
+		set := make(map[string]bool, len(d.IPs))
+		for _, ip := range d.IPs {
+			set[ip] = true
+		}
+		b.ips[name] = set
+		keys := make(map[string]bool, len(d.ASNs)+len(d.LLCs))
+		for _, asn := range d.ASNs {
+			keys[answerKey(asn, "", "")] = true
+		}
+		for _, llc := range d.LLCs {
+			keys[answerKey(0, llc, "")] = true
+		}
+		b.keys[name] = keys
+	}
+	return &b, nil
+}
+
+// Save 将基线写入文件
+func (b *baselineSnapshot) Save(path string) error {
+	data, _ := json.MarshalIndent(b, "", "  ")
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("写入基线文件失败: %w", err)
+	}
+	return nil
+}
+
+// Apply 将域名结果与基线比较：解析到基线中没有的 IP 时判定为污染。启用 -anycast 时，
+// 与基线中某个 IP 属于同一 ASN（ASN 未知时为同一 LLC）的新 IP 视为同一逻辑应答，不算不符。
+// 基线中没有该域名或本轮没有解析到 IP 时不做判断；nil 表示未指定 -against
+func (b *baselineSnapshot) Apply(res *DomainResult) {
+	if b == nil {
+		return
+	}
+	known, ok := b.ips[res.Domain]
+	if !ok {
+		return
+	}
+	for _, ip := range res.IPResults {
+		if *anycastMode && (ip.ASN != 0 || ip.ActualLLC != "") && b.keys[res.Domain][answerKey(ip.ASN, ip.ActualLLC, ip.IP)] {
+			continue
+		}
+		if !known[ip.IP] {
+			res.OffBaseline = append(res.OffBaseline, ip.IP)
+		}
+	}
+	if len(res.OffBaseline) > 0 {
+		res.IsPolluted = true
+		res.Summary = fmt.Sprintf("%d 个 IP 不在基线中", len(res.OffBaseline))
+	}
+}
+
+// runBaseline 实现 `dnscheck baseline save|load`
+func runBaseline(args []string) {
+	if len(args) > 0 {
+		switch args[0] {
+		case "save":
+			runBaselineSave(args[1:])
+			return
+		case "load":
+			runBaselineLoad(args[1:])
+			return
+		}
+	}
+	fmt.Fprintln(os.Stderr, "用法: dnscheck baseline save [参数] <基线.json>  或  dnscheck baseline load <基线.json>")
+	os.Exit(2)
+}
+
+// runBaselineSave 按当前配置检测一轮并保存为基线；-from 指定时改为从已有的 -format json 结果导入。
+// 除 -from 外接受与单次运行相同的参数（-f、-api、-resolver、-profile 等），-against 被忽略
+func runBaselineSave(args []string) {
+	from := flag.String("from", "", "从 -format json 的结果文件导入，而不是重新检测")
+	if err := flag.CommandLine.Parse(args); err != nil {
+		os.Exit(2)
+	}
+	if flag.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "用法: dnscheck baseline save [-from 结果.json] [参数] <基线.json>")
+		os.Exit(2)
+	}
+
+	var b *baselineSnapshot
+	if *from != "" {
+		run, err := loadRunJSON(*from)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+		b = newBaseline(run.RunID, run.StartedAt, run.Results)
+	} else {
+		run, err := captureRun()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+		if run.Polluted > 0 {
+			fmt.Fprintf(os.Stderr, "警告: 本轮有 %d 个域名被判定为污染，基线仍会包含它们的应答，请确认解析环境正常\n", run.Polluted)
+		}
+		b = newBaseline(run.RunID, run.StartedAt, run.Results)
+	}
+	if err := b.Save(flag.Arg(0)); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("基线已保存至 %s：%d 个域名\n", flag.Arg(0), len(b.Domains))
+}
+
+// captureRun 按命令行参数与配置文件检测一轮，返回 JSON 形式的结果
+func captureRun() (runJSON, error) {
+	config, c, err := newConfiguredChecker()
+	if err != nil {
+		return runJSON{}, err
+	}
+	startedAt := time.Now()
+	results := c.Run(context.Background(), config)
+	return newRunJSON(newRunID(startedAt), startedAt, results), nil
+}
+
+// newConfiguredChecker 按命令行参数加载配置文件并创建检测器
+func newConfiguredChecker() (*Config, *checker, error) {
+	config, err := loadConfigWithFallback(*configFile)
+	if err != nil {
+		return nil, nil, fmt.Errorf("加载配置文件失败: %w", err)
+	}
+	if err := applyProfile(config, *profileName, flag.CommandLine); err != nil {
+		return nil, nil, err
+	}
+	if err := validateFlags(); err != nil {
+		return nil, nil, err
+	}
+	aliases, err := loadLLCAliases(*aliasFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	ipMap, err := loadIPMapping(*ipMapFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	c := newChecker()
+	c.aliases = aliases
+	c.ipMap = ipMap
+	if c.sigs, err = loadDetectionSignatures(); err != nil {
+		return nil, nil, err
+	}
+	if c.geo, err = loadGeoDB(*geoipFile); err != nil {
+		return nil, nil, err
+	}
+	c.useProviders(config)
+	return config, c, nil
+}
+
+// runBaselineLoad 读取并检查基线文件，列出其中的域名与 IP
+func runBaselineLoad(args []string) {
+	fs := flag.NewFlagSet("baseline load", flag.ExitOnError)
+	_ = fs.Parse(args)
+	if fs.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "用法: dnscheck baseline load <基线.json>")
+		os.Exit(2)
+	}
+	b, err := loadBaseline(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("基线 %s：采集于 %s，%d 个域名\n", fs.Arg(0), b.CapturedAt.Local().Format("2006-01-02 15:04:05"), len(b.Domains))
+	for _, d := range b.Domains {
+		line := fmt.Sprintf("  %s: %s", d.Domain, strings.Join(d.IPs, ", "))
+		if len(d.LLCs) > 0 {
+			line += fmt.Sprintf("（%s）", strings.Join(d.LLCs, "、"))
+		}
+		fmt.Println(line)
+	}
+}
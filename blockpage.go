@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// ---------- CNAME 拦截页特征（blockpage_cnames） ----------
+
+// 过滤型解析器与运营商常把被拦截的域名 CNAME 到自己的拦截页或强制安全搜索的主机名，
+// 最终的 IP 归属不一定异常（拦截页可能托管在预期的 CDN 上），因此单独按 CNAME 链判断
+
+// sinkhole 是一个已知的拦截页 CNAME 目标，内置的列表见特征库（signatures.go）
+type sinkhole struct {
+	Target string `json:"target"` // 匹配该域名及其子域名
+	Name   string `json:"name"`   // 拦截服务的说明
+}
+
+// compileSinkholes 编译配置文件中的 blockpage_cnames，检测时与特征库中的列表一起匹配
+func compileSinkholes(targets []string) ([]sinkhole, error) {
+	var list []sinkhole
+	for _, t := range targets {
+		name, err := normalizeDomainName(t)
+		if err != nil {
+			return nil, fmt.Errorf("blockpage_cnames: %w", err)
+		}
+		list = append(list, sinkhole{Target: name, Name: "配置的拦截页"})
+	}
+	return list, nil
+}
+
+// matchSinkhole 返回 CNAME 链中第一个命中拦截页特征的目标及其说明，未命中时返回空字符串
+func matchSinkhole(cnames []string, lists ...[]sinkhole) string {
+	for _, c := range cnames {
+		for _, list := range lists {
+			for _, s := range list {
+				if c == s.Target || strings.HasSuffix(c, "."+s.Target) {
+					return fmt.Sprintf("%s（%s）", c, s.Name)
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// applySinkholes 记录 CNAME 链；经过已知的拦截页时，无论 IP 归属是否符合预期都判定为污染
+func applySinkholes(res *DomainResult, cnames []string, lists ...[]sinkhole) {
+	res.CNAMEs = cnames
+	if hit := matchSinkhole(cnames, lists...); hit != "" {
+		res.IsPolluted = true
+		res.Blockpage = hit
+		res.Summary = "CNAME 指向已知的拦截页 " + hit
+	}
+}
+
+// cnameChain 返回解析过程中经过的 CNAME 目标（小写、无末尾点）。原始解析模式下取自各次查询的应答；
+// 系统解析器不返回应答记录，另外查询一次规范名称，只能得到链的终点
+func (c *checker) cnameChain(ctx context.Context, host string, trace []resolveStep) []string {
+	var chain []string
+	seen := make(map[string]bool)
+	add := func(name string) {
+		name = strings.ToLower(strings.TrimSuffix(name, "."))
+		if name != "" && name != host && !seen[name] {
+			seen[name] = true
+			chain = append(chain, name)
+		}
+	}
+	for _, s := range trace {
+		for _, name := range s.CNAMEs {
+			add(name)
+		}
+	}
+	if _, ok := c.resolver.(systemResolver); ok {
+		var r net.Resolver
+		if dnsCapture != nil {
+			r.PreferGo, r.Dial = true, captureDial
+		}
+		if name, err := r.LookupCNAME(ctx, host); err == nil {
+			add(name)
+		}
+	}
+	return chain
+}
+
+// answerCNAMEs 提取应答部分中全部 CNAME 记录的目标
+func answerCNAMEs(resp *dnsmessage.Message) []string {
+	var names []string
+	for _, rr := range resp.Answers {
+		if c, ok := rr.Body.(*dnsmessage.CNAMEResource); ok {
+			names = append(names, c.CNAME.String())
+		}
+	}
+	return names
+}
+
+// countBlockpage 统计 CNAME 链经过已知拦截页的域名数
+func countBlockpage(results []DomainResult) int {
+	n := 0
+	for _, r := range results {
+		if r.Blockpage != "" {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ---------- API 端点熔断器 ----------
+
+// errCircuitOpen 表示端点处于熔断状态，本次请求被跳过
+var errCircuitOpen = errors.New("端点已熔断，跳过请求")
+
+type breakerState int
+
+const (
+	breakerClosed   breakerState = iota // 正常放行
+	breakerOpen                         // 熔断中，冷却期内跳过该端点
+	breakerHalfOpen                     // 冷却结束，放行一个探测请求
+)
+
+func (s breakerState) String() string {
+	switch s {
+	case breakerOpen:
+		return "熔断"
+	case breakerHalfOpen:
+		return "半开"
+	default:
+		return "正常"
+	}
+}
+
+// Code 返回状态的英文标识，用于 HTTP/JSON 等机器可读的输出
+func (s breakerState) Code() string {
+	switch s {
+	case breakerOpen:
+		return "open"
+	case breakerHalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+// breakerTransition 记录一次状态变化，用于运行结束时的汇总
+type breakerTransition struct {
+	At       time.Time
+	Endpoint string
+	From, To breakerState
+}
+
+type endpointBreaker struct {
+	state     breakerState
+	failures  int // 连续失败次数
+	openedAt  time.Time
+	probing   bool // 半开状态下是否已有探测请求在途
+	skipped   int  // 因熔断被跳过的请求数
+	openCount int  // 进入熔断状态的次数
+}
+
+// breakerSet 按端点维护熔断器。连续失败达到阈值后熔断，冷却期后半开探测，
+// 探测成功则恢复，失败则重新熔断。threshold <= 0 时禁用熔断。
+type breakerSet struct {
+	mu          sync.Mutex
+	threshold   int
+	cooldown    time.Duration
+	endpoints   map[string]*endpointBreaker
+	transitions []breakerTransition
+}
+
+func newBreakerSet(threshold int, cooldown time.Duration) *breakerSet {
+	return &breakerSet{
+		threshold: threshold,
+		cooldown:  cooldown,
+		endpoints: make(map[string]*endpointBreaker),
+	}
+}
+
+func (s *breakerSet) get(endpoint string) *endpointBreaker {
+	b, ok := s.endpoints[endpoint]
+	if !ok {
+		b = &endpointBreaker{}
+		s.endpoints[endpoint] = b
+	}
+	return b
+}
+
+// setState 切换状态并记录、打印状态变化（调用方需持有锁）
+func (s *breakerSet) setState(endpoint string, b *endpointBreaker, to breakerState) {
+	if b.state == to {
+		return
+	}
+	t := breakerTransition{At: time.Now(), Endpoint: endpoint, From: b.state, To: to}
+	s.transitions = append(s.transitions, t)
+	fmt.Fprintf(os.Stderr, "熔断器 %s: %s -> %s\n", endpoint, t.From, t.To)
+	b.state = to
+	if to == breakerOpen {
+		b.openedAt = t.At
+		b.openCount++
+	}
+}
+
+// Allow 判断是否允许向该端点发出请求
+func (s *breakerSet) Allow(endpoint string) bool {
+	if s == nil || s.threshold <= 0 {
+		return true
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b := s.get(endpoint)
+	switch b.state {
+	case breakerOpen:
+		if time.Since(b.openedAt) < s.cooldown {
+			b.skipped++
+			return false
+		}
+		s.setState(endpoint, b, breakerHalfOpen)
+		b.probing = true
+		return true
+	case breakerHalfOpen:
+		if b.probing {
+			b.skipped++
+			return false
+		}
+		b.probing = true
+		return true
+	default:
+		return true
+	}
+}
+
+// Record 记录一次请求结果并更新熔断状态。只有说明端点本身不可用的错误（见 isEndpointFailure）计为失败，
+// 其余错误说明端点正常应答，与成功同样处理
+func (s *breakerSet) Record(endpoint string, err error) {
+	if s == nil || s.threshold <= 0 {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b := s.get(endpoint)
+	b.probing = false
+	if !isEndpointFailure(err) {
+		b.failures = 0
+		s.setState(endpoint, b, breakerClosed)
+		return
+	}
+	b.failures++
+	if b.state == breakerHalfOpen || b.failures >= s.threshold {
+		s.setState(endpoint, b, breakerOpen)
+	}
+}
+
+// isEndpointFailure 判断 API 请求错误是否说明端点本身不可用：超时、连接错误、5xx 与 429。
+// 针对单个 IP 的 4xx（如未收录或保留地址返回 404）与响应解析失败不计入，以免少数异常 IP 让正常的端点熔断
+func isEndpointFailure(err error) bool {
+	switch classifyAPIError(err) {
+	case retryAPITimeout, retryAPI5xx, retryAPI429:
+		return true
+	case retryAPIParse:
+		return false
+	}
+	var statusErr *APIStatusError
+	if err == nil || errors.As(err, &statusErr) {
+		return false
+	}
+	// 其余为连接被拒绝、域名解析失败、读取响应体失败等传输错误
+	return true
+}
+
+// States 返回各端点当前的熔断状态；从未请求过的端点视为正常
+func (s *breakerSet) States(endpoints []string) map[string]breakerState {
+	states := make(map[string]breakerState, len(endpoints))
+	for _, endpoint := range endpoints {
+		states[endpoint] = breakerClosed
+	}
+	if s == nil {
+		return states
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for endpoint, b := range s.endpoints {
+		states[endpoint] = b.state
+	}
+	return states
+}
+
+// Summary 生成熔断器汇总；没有发生过状态变化时返回空字符串
+func (s *breakerSet) Summary() string {
+	if s == nil {
+		return ""
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.transitions) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("API 熔断汇总:\n")
+	endpoints := make([]string, 0, len(s.endpoints))
+	for endpoint := range s.endpoints {
+		endpoints = append(endpoints, endpoint)
+	}
+	sort.Strings(endpoints)
+	for _, endpoint := range endpoints {
+		eb := s.endpoints[endpoint]
+		if eb.openCount == 0 {
+			continue
+		}
+		b.WriteString(fmt.Sprintf("  %s: 熔断 %d 次，跳过请求 %d 次，当前状态 %s\n", endpoint, eb.openCount, eb.skipped, eb.state))
+	}
+	b.WriteString("  状态变化:\n")
+	for _, t := range s.transitions {
+		b.WriteString(fmt.Sprintf("    %s %s: %s -> %s\n", t.At.Format("15:04:05"), t.Endpoint, t.From, t.To))
+	}
+	return b.String()
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// ---------- 内部事件总线 ----------
+
+// 事件类型。检测流程只负责发布事件，报告之外的输出（通知、导出、存储、指标、实时推送）
+// 都以订阅者的形式挂在总线上，新增输出无需改动检测主流程。
+const (
+	busRunStart   = "run_start"   // 一轮检测开始
+	busResolution = "resolution"  // 一个域名的 DNS 解析完成
+	busLookup     = "lookup"      // 一个 IP 的归属查询完成（包括在本地完成分类的 IP）
+	busVerdict    = "verdict"     // 一个域名的判定完成
+	busRunResults = "run_results" // 一轮的全部结果已收集并标注维护状态，尚未生成报告
+	busRunFinish  = "run_finish"  // 报告已生成，供通知与导出使用
+)
+
+// runInfo 标识一轮检测，随 context 传入 checker.Run，使逐个域名发布的事件能带上所属的轮次
+type runInfo struct {
+	ID      string
+	Captive string // 检测前发现的强制门户，非空时本轮结果不可信，见 captive.go
+	Hijack  string // 检测期间发现的透明 DNS 劫持，见 hijack.go
+	Proxy   string // 检测期间发现的透明 DNS 代理，见 hijack.go
+	Group   string // 守护模式下所属的 schedules 名称
+	Shard   string // -shard 指定的分片，如 "3/10"，未分片时为空
+	Started time.Time
+	Domains int // 本轮检测的域名数
+	Meta    runMeta
+}
+
+type runInfoKey struct{}
+
+func withRunInfo(ctx context.Context, info runInfo) context.Context {
+	return context.WithValue(ctx, runInfoKey{}, info)
+}
+
+// runInfoFrom 返回 ctx 中的轮次信息，没有时返回零值
+func runInfoFrom(ctx context.Context) runInfo {
+	info, _ := ctx.Value(runInfoKey{}).(runInfo)
+	return info
+}
+
+// runOutcome 是一轮检测的产出，随 run_results 与 run_finish 事件发布；
+// run_results 时只有 Results、Latest 与 Prev，其余字段在 run_finish 时才填写
+type runOutcome struct {
+	Results []DomainResult
+	Latest  []DomainResult // 守护模式下合并了各组最近一轮的结果，否则与 Results 相同
+	Prev    []DomainResult // 合并本轮之前的结果，用于判断判定是否变化
+	Alerts  *alertEngine   // 未配置 alerts 时为 nil
+	Events  []alertEvent   // 本轮的告警状态变化
+	Report  string
+}
+
+// busEvent 是总线上的一条事件，按 Kind 使用其中的部分字段
+type busEvent struct {
+	Kind   string
+	Run    runInfo
+	At     time.Time
+	Domain string
+
+	IPs     []net.IP      // resolution：解析到的地址
+	Latency time.Duration // resolution：解析耗时
+	Err     error         // resolution：解析错误
+
+	IP *IPCheckResult // lookup：单个 IP 的查询结果
+
+	Result *DomainResult // verdict：域名的判定结果
+
+	Outcome *runOutcome // run_results / run_finish
+}
+
+// subscriber 处理总线上的事件；返回的错误只输出到标准错误，不影响其他订阅者
+type subscriber interface {
+	Handle(ev busEvent) error
+}
+
+// subscriberFunc 让普通函数可以作为订阅者
+type subscriberFunc func(ev busEvent) error
+
+func (f subscriberFunc) Handle(ev busEvent) error {
+	return f(ev)
+}
+
+// onEvent 返回只处理 kind 类事件的订阅者
+func onEvent(kind string, fn func(ev busEvent) error) subscriber {
+	return subscriberFunc(func(ev busEvent) error {
+		if ev.Kind != kind {
+			return nil
+		}
+		return fn(ev)
+	})
+}
+
+// eventBus 按订阅顺序同步地把事件交给各订阅者，发布方在所有订阅者处理完后才继续。
+// resolution、lookup 与 verdict 事件会从多个协程同时发布，订阅者需要自行处理并发。nil 表示不发布
+type eventBus struct {
+	mu   sync.RWMutex
+	subs []subscriber
+}
+
+func newEventBus() *eventBus {
+	return &eventBus{}
+}
+
+// Subscribe 注册订阅者
+func (b *eventBus) Subscribe(s subscriber) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.subs = append(b.subs, s)
+}
+
+// Publish 发布事件，At 为空时使用当前时间
+func (b *eventBus) Publish(ev busEvent) {
+	if b == nil {
+		return
+	}
+	if ev.At.IsZero() {
+		ev.At = time.Now()
+	}
+	b.mu.RLock()
+	subs := b.subs
+	b.mu.RUnlock()
+	for _, s := range subs {
+		if err := s.Handle(ev); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+		}
+	}
+}
+
+// ---------- 订阅者 ----------
+
+// metricsSubscriber 根据解析与查询事件更新累积指标
+func metricsSubscriber(m *checkMetrics) subscriber {
+	return subscriberFunc(func(ev busEvent) error {
+		switch ev.Kind {
+		case busResolution:
+			m.ObserveDNS(ev.Latency)
+		case busLookup:
+			if ev.IP.Source != sourceAPI {
+				m.IncAvoided(ev.IP.Source)
+			}
+		}
+		return nil
+	})
+}
+
+// registerOutputs 注册单次运行与守护模式共用的输出：syslog、Zabbix、InfluxDB、对象存储与运行后钩子。
+// 各输出在未启用时什么也不做；syslogW 非空时复用该连接，否则每轮按 syslogTo 建立连接。
+// syslogTo 与 hookCmd 通常取自 -syslog 与 -post-run-cmd，按项目运行时由各项目分别指定
+func registerOutputs(bus *eventBus, syslogW *syslogWriter, syslogTo, hookCmd string) {
+	if syslogTo != "" {
+		bus.Subscribe(syslogOutput{addr: syslogTo, w: syslogW})
+	}
+	bus.Subscribe(onEvent(busRunFinish, func(ev busEvent) error {
+		return exportZabbix(ev.Outcome.Results)
+	}))
+	bus.Subscribe(onEvent(busRunFinish, func(ev busEvent) error {
+		return exportInflux(ev.Outcome.Results, ev.Run)
+	}))
+	bus.Subscribe(onEvent(busRunFinish, func(ev busEvent) error {
+		return uploadReport(ev.Outcome.Report, ev.Run.ID, ev.Run.Started)
+	}))
+	bus.Subscribe(onEvent(busRunFinish, func(ev busEvent) error {
+		out := ev.Outcome
+		return runPostRunHook(hookCmd, ev.Run.ID, ev.Run.Started, out.Results, out.Alerts, out.Events, orDefault(*hookTimeout, *timeout))
+	}))
+}
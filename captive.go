@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+)
+
+// ---------- 强制门户预检（-captive-check） ----------
+
+// captiveTimeout 是强制门户预检的超时时间
+const captiveTimeout = 3 * time.Second
+
+// detectCaptivePortal 在检测前请求 -captive-check 地址（generate_204 类地址，正常时返回 HTTP 204），
+// 返回强制门户的描述；没有发现强制门户、未启用或请求失败时返回空字符串。
+// 处于强制门户（酒店、机场 Wi-Fi 等）之后时，所有域名都会被拦截，本轮结果不可信
+func detectCaptivePortal(ctx context.Context, target string) string {
+	if target == "" {
+		return ""
+	}
+	ctx, cancel := context.WithTimeout(ctx, captiveTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return ""
+	}
+	client := &http.Client{
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		// 网络不通时无法判断，不当作强制门户；各域名的检测会各自报告失败
+		fmt.Fprintf(os.Stderr, "警告: 强制门户预检失败: %v\n", err)
+		return ""
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	switch {
+	case resp.StatusCode == http.StatusNoContent:
+		return ""
+	case resp.StatusCode >= 300 && resp.StatusCode < 400:
+		return fmt.Sprintf("%s 被重定向到 %s", target, resp.Header.Get("Location"))
+	}
+	return fmt.Sprintf("%s 返回 HTTP %d 而不是 204", target, resp.StatusCode)
+}
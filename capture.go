@@ -0,0 +1,326 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// ---------- DNS 报文记录（-capture） ----------
+
+// dnsCapture 记录本轮收发的 DNS 报文，由 main 与 serve 根据 -capture 打开；nil 表示不记录
+var dnsCapture *captureWriter
+
+// captureWriter 将 DNS 报文写入 PCAP 文件（扩展名为 .pcap）或 JSON Lines 文件（其他扩展名）。
+// PCAP 中每条报文都写成一个 UDP 数据包（TCP 与 DoH 传输的报文也是），Wireshark 可直接按 DNS 解码
+type captureWriter struct {
+	mu    sync.Mutex
+	path  string
+	file  *os.File
+	w     *bufio.Writer
+	pcap  bool
+	count int
+	err   error // 第一次写入失败的错误，之后不再写入
+}
+
+// captureRecord 是 JSON Lines 格式中的一条报文
+type captureRecord struct {
+	Time      time.Time `json:"time"`
+	Transport string    `json:"transport"` // udp、tcp、https 或 system（系统解析器）
+	Direction string    `json:"direction"` // query 或 response
+	Local     string    `json:"local,omitempty"`
+	Server    string    `json:"server"`
+	ID        uint16    `json:"id"`
+	Name      string    `json:"name,omitempty"`
+	Type      string    `json:"type,omitempty"`
+	RCode     string    `json:"rcode,omitempty"`
+	Answers   []string  `json:"answers,omitempty"`
+	Wire      []byte    `json:"wire"` // 原始报文（base64）
+}
+
+// openCapture 创建记录文件；path 为空时返回 nil
+func openCapture(path string) (*captureWriter, error) {
+	if path == "" {
+		return nil, nil
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("创建 DNS 报文记录文件失败: %w", err)
+	}
+	c := &captureWriter{path: path, file: f, w: bufio.NewWriter(f), pcap: strings.HasSuffix(strings.ToLower(path), ".pcap")}
+	if c.pcap {
+		// PCAP 文件头：微秒时间戳，链路类型 LINKTYPE_RAW（101，直接是 IPv4/IPv6 报文）
+		var hdr [24]byte
+		binary.LittleEndian.PutUint32(hdr[0:], 0xa1b2c3d4)
+		binary.LittleEndian.PutUint16(hdr[4:], 2)
+		binary.LittleEndian.PutUint16(hdr[6:], 4)
+		binary.LittleEndian.PutUint32(hdr[16:], 65535)
+		binary.LittleEndian.PutUint32(hdr[20:], 101)
+		c.w.Write(hdr[:])
+	}
+	return c, nil
+}
+
+// Record 记录一条报文。outbound 为 true 表示本机发出的查询，此时 local 为源地址；否则为收到的响应，
+// remote 是报文的来源地址（对 UDP 而言可能不是查询的服务器，如伪造来源的注入报文）
+func (c *captureWriter) Record(transport string, local, remote net.Addr, outbound bool, msg []byte) {
+	if c == nil {
+		return
+	}
+	now := time.Now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return
+	}
+	c.count++
+	if c.pcap {
+		c.err = c.writePacket(now, local, remote, outbound, msg)
+		return
+	}
+	rec := captureRecord{Time: now, Transport: transport, Direction: "response", Server: addrString(remote), Local: addrString(local), Wire: msg}
+	if outbound {
+		rec.Direction = "query"
+	}
+	var m dnsmessage.Message
+	if err := m.Unpack(msg); err == nil {
+		rec.ID = m.Header.ID
+		if len(m.Questions) > 0 {
+			rec.Name = m.Questions[0].Name.String()
+			rec.Type = strings.TrimPrefix(m.Questions[0].Type.String(), "Type")
+		}
+		if m.Header.Response {
+			rec.RCode = m.Header.RCode.String()
+			for _, a := range m.Answers {
+				rec.Answers = append(rec.Answers, formatCapturedRR(a))
+			}
+		}
+	}
+	data, err := json.Marshal(rec)
+	if err != nil {
+		c.err = err
+		return
+	}
+	if _, err := c.w.Write(append(data, '\n')); err != nil {
+		c.err = err
+	}
+}
+
+// writePacket 写入一条 PCAP 记录：IPv4 或 IPv6 头 + UDP 头 + DNS 报文
+func (c *captureWriter) writePacket(at time.Time, local, remote net.Addr, outbound bool, msg []byte) error {
+	lip, lport := addrIPPort(local)
+	rip, rport := addrIPPort(remote)
+	v4 := rip.To4() != nil
+	// 未绑定具体地址的本地端（0.0.0.0 或 [::]）与对端的地址族可能不同，按对端的地址族写入
+	if lip.IsUnspecified() || (lip.To4() != nil) != v4 {
+		lip = net.IPv6unspecified
+		if v4 {
+			lip = net.IPv4zero
+		}
+	}
+	if v4 {
+		lip, rip = lip.To4(), rip.To4()
+	} else {
+		lip, rip = lip.To16(), rip.To16()
+	}
+	src, sport, dst, dport := rip, rport, lip, lport
+	if outbound {
+		src, sport, dst, dport = lip, lport, rip, rport
+	}
+	if len(msg) > 65507 {
+		msg = msg[:65507]
+	}
+
+	udp := make([]byte, 8+len(msg))
+	binary.BigEndian.PutUint16(udp[0:], uint16(sport))
+	binary.BigEndian.PutUint16(udp[2:], uint16(dport))
+	binary.BigEndian.PutUint16(udp[4:], uint16(len(udp)))
+	copy(udp[8:], msg)
+	pseudo := append(append([]byte{}, src...), dst...)
+	pseudo = append(pseudo, 0, 17, byte(len(udp)>>8), byte(len(udp)))
+	sum := checksum(append(pseudo, udp...))
+	if sum == 0 {
+		sum = 0xffff // UDP 中校验和为 0 表示未计算
+	}
+	binary.BigEndian.PutUint16(udp[6:], sum)
+
+	var ip []byte
+	if v4 {
+		ip = make([]byte, 20)
+		ip[0] = 0x45
+		binary.BigEndian.PutUint16(ip[2:], uint16(20+len(udp)))
+		ip[8], ip[9] = 64, 17
+		copy(ip[12:], src)
+		copy(ip[16:], dst)
+		binary.BigEndian.PutUint16(ip[10:], checksum(ip))
+	} else {
+		ip = make([]byte, 40)
+		ip[0] = 0x60
+		binary.BigEndian.PutUint16(ip[4:], uint16(len(udp)))
+		ip[6], ip[7] = 17, 64
+		copy(ip[8:], src)
+		copy(ip[24:], dst)
+	}
+
+	var rec [16]byte
+	binary.LittleEndian.PutUint32(rec[0:], uint32(at.Unix()))
+	binary.LittleEndian.PutUint32(rec[4:], uint32(at.Nanosecond()/1000))
+	binary.LittleEndian.PutUint32(rec[8:], uint32(len(ip)+len(udp)))
+	binary.LittleEndian.PutUint32(rec[12:], uint32(len(ip)+len(udp)))
+	for _, b := range [][]byte{rec[:], ip, udp} {
+		if _, err := c.w.Write(b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close 写出缓冲区并关闭文件，返回记录的报文数
+func (c *captureWriter) Close() (int, error) {
+	if c == nil {
+		return 0, nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	err := c.err
+	if ferr := c.w.Flush(); err == nil {
+		err = ferr
+	}
+	if cerr := c.file.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return c.count, fmt.Errorf("写入 DNS 报文记录 %s 失败: %w", c.path, err)
+	}
+	return c.count, nil
+}
+
+// checksum 计算 IP/UDP 使用的 16 位反码和校验和
+func checksum(b []byte) uint16 {
+	var sum uint32
+	for i := 0; i+1 < len(b); i += 2 {
+		sum += uint32(b[i])<<8 | uint32(b[i+1])
+	}
+	if len(b)%2 == 1 {
+		sum += uint32(b[len(b)-1]) << 8
+	}
+	for sum>>16 != 0 {
+		sum = sum&0xffff + sum>>16
+	}
+	return ^uint16(sum)
+}
+
+// addrIPPort 取出 UDP/TCP 地址中的 IP 与端口，其他地址（如 DoH 请求失败前没有连接）返回未指定地址
+func addrIPPort(addr net.Addr) (net.IP, int) {
+	switch a := addr.(type) {
+	case *net.UDPAddr:
+		if a.IP != nil {
+			return a.IP, a.Port
+		}
+	case *net.TCPAddr:
+		if a.IP != nil {
+			return a.IP, a.Port
+		}
+	}
+	return net.IPv4zero, 0
+}
+
+func addrString(addr net.Addr) string {
+	if addr == nil {
+		return ""
+	}
+	return addr.String()
+}
+
+// formatCapturedRR 将应答记录格式化为 "A 1.2.3.4"、"CNAME example.com." 等形式
+func formatCapturedRR(rr dnsmessage.Resource) string {
+	typ := strings.TrimPrefix(rr.Header.Type.String(), "Type")
+	switch b := rr.Body.(type) {
+	case *dnsmessage.AResource:
+		return typ + " " + net.IP(b.A[:]).String()
+	case *dnsmessage.AAAAResource:
+		return typ + " " + net.IP(b.AAAA[:]).String()
+	case *dnsmessage.CNAMEResource:
+		return typ + " " + b.CNAME.String()
+	case *dnsmessage.NSResource:
+		return typ + " " + b.NS.String()
+	}
+	return typ
+}
+
+// ---------- 记录系统解析器的报文 ----------
+
+// captureDial 供 net.Resolver 使用：启用 -capture 时系统解析器改用 Go 内置的实现（仍读取 /etc/resolv.conf），
+// 以便记录它收发的报文
+func captureDial(ctx context.Context, network, address string) (net.Conn, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, network, address)
+	if err != nil {
+		return nil, err
+	}
+	// 解析器按连接是否实现 net.PacketConn 决定报文是否带长度前缀，UDP 连接需要保留这一点
+	if uc, ok := conn.(*net.UDPConn); ok {
+		return &capturedUDPConn{UDPConn: uc}, nil
+	}
+	return &capturedConn{Conn: conn}, nil
+}
+
+// capturedUDPConn 记录 UDP 连接上的每一个报文
+type capturedUDPConn struct {
+	*net.UDPConn
+}
+
+func (c *capturedUDPConn) Write(b []byte) (int, error) {
+	n, err := c.UDPConn.Write(b)
+	if n > 0 {
+		dnsCapture.Record("system", c.LocalAddr(), c.RemoteAddr(), true, append([]byte(nil), b[:n]...))
+	}
+	return n, err
+}
+
+func (c *capturedUDPConn) Read(b []byte) (int, error) {
+	n, err := c.UDPConn.Read(b)
+	if n > 0 {
+		dnsCapture.Record("system", c.LocalAddr(), c.RemoteAddr(), false, append([]byte(nil), b[:n]...))
+	}
+	return n, err
+}
+
+// capturedConn 记录 TCP 连接上的 DNS 报文，报文带 2 字节长度前缀，按前缀拆分
+type capturedConn struct {
+	net.Conn
+	rbuf, wbuf []byte
+}
+
+func (c *capturedConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	c.record(&c.wbuf, b[:n], true)
+	return n, err
+}
+
+func (c *capturedConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	c.record(&c.rbuf, b[:n], false)
+	return n, err
+}
+
+func (c *capturedConn) record(buf *[]byte, data []byte, outbound bool) {
+	*buf = append(*buf, data...)
+	for len(*buf) >= 2 {
+		n := int(binary.BigEndian.Uint16(*buf))
+		if len(*buf) < 2+n {
+			return
+		}
+		dnsCapture.Record("system", c.LocalAddr(), c.RemoteAddr(), outbound, append([]byte(nil), (*buf)[2:2+n]...))
+		*buf = (*buf)[2+n:]
+	}
+}
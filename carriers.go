@@ -0,0 +1,92 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+)
+
+// ---------- 运营商名称对照表（-carrier-names） ----------
+
+// 国内 API 返回“电信”“阿里云”这样的中文名称，国外 API 返回 CHINANET、ALIBABA-CN-NET 这样的网络名，
+// 同一个配置换一个 API 就匹配不上。启用 -carrier-names 后，两种写法以及已知的 ASN 都统一为同一个英文标识，
+// expected_llcs 中写任意一种写法都能匹配。-llc-aliases 中的别名优先
+
+// carrier 是一个运营商或云服务商
+type carrier struct {
+	ID    string   // 统一的英文标识，统一后的 LLC 即为它
+	Names []string // 各 API 的常见写法；含中文的写法按包含匹配，其余按前缀匹配（均忽略大小写、空白和标点）
+	ASNs  []uint32
+}
+
+// knownCarriers 是内置的对照表
+var knownCarriers = []carrier{
+	{ID: "ChinaTelecom", Names: []string{"中国电信", "电信", "China Telecom", "CHINANET", "ChinaTelecom", "CN2"}, ASNs: []uint32{4134, 4809, 4812, 23764}},
+	{ID: "ChinaUnicom", Names: []string{"中国联通", "联通", "网通", "China Unicom", "CHINA169", "ChinaUnicom", "UNICOM", "CNCGROUP"}, ASNs: []uint32{4837, 9929, 17621, 17816}},
+	{ID: "ChinaMobile", Names: []string{"中国移动", "移动", "China Mobile", "ChinaMobile", "CMNET"}, ASNs: []uint32{9808, 56040, 56041, 56044, 58453}},
+	{ID: "CERNET", Names: []string{"教育网", "CERNET"}, ASNs: []uint32{4538}},
+	{ID: "Alibaba", Names: []string{"阿里云", "阿里巴巴", "Alibaba", "Aliyun"}, ASNs: []uint32{37963, 45102}},
+	{ID: "Tencent", Names: []string{"腾讯云", "腾讯", "Tencent"}, ASNs: []uint32{45090, 132203}},
+	{ID: "Baidu", Names: []string{"百度云", "百度", "Baidu"}, ASNs: []uint32{38365, 55967}},
+	{ID: "Huawei", Names: []string{"华为云", "华为", "Huawei"}, ASNs: []uint32{55990, 136907}},
+}
+
+// carrierByName 返回 LLC 对应的运营商标识，没有对应时返回空字符串。多个写法命中时取最长的一个
+func carrierByName(llc string) string {
+	key := aliasKey(llc)
+	if key == "" {
+		return ""
+	}
+	best, bestLen := "", 0
+	for _, c := range knownCarriers {
+		for _, name := range c.Names {
+			k := aliasKey(name)
+			hit := strings.HasPrefix(key, k)
+			if !isASCII(k) {
+				hit = strings.Contains(key, k)
+			}
+			if hit && len(k) > bestLen {
+				best, bestLen = c.ID, len(k)
+			}
+		}
+	}
+	return best
+}
+
+// carrierByASN 返回 ASN 对应的运营商标识，没有对应时返回空字符串
+func carrierByASN(asn uint32) string {
+	for _, c := range knownCarriers {
+		for _, a := range c.ASNs {
+			if a == asn && asn != 0 {
+				return c.ID
+			}
+		}
+	}
+	return ""
+}
+
+// carrierExact 返回与 value 完全一致（忽略大小写、空白和标点）的写法或标识所属的运营商，用于 expected_llcs。
+// 预期只做完全一致的对照，避免 "China" 这样的前缀被当成某个运营商
+func carrierExact(value string) string {
+	key := aliasKey(value)
+	for _, c := range knownCarriers {
+		if key == aliasKey(c.ID) {
+			return c.ID
+		}
+		for _, name := range c.Names {
+			if key == aliasKey(name) {
+				return c.ID
+			}
+		}
+	}
+	return ""
+}
+
+// isASCII 判断 s 是否只包含 ASCII 字符
+func isASCII(s string) bool {
+	for _, r := range s {
+		if r > unicode.MaxASCII {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ---------- 本地优先分类 ----------
+
+// IP 归属信息的来源。除 sourceAPI 外都在本地得出，不消耗 API 配额。
+const (
+	sourceAPI      = "api"
+	sourceCache    = "cache"          // 本进程内的查询缓存（-ip-cache-ttl）
+	sourceExpected = "expected_cidrs" // 属于域名的 expected_cidrs，直接视为符合预期
+	sourceMapping  = "mapping"        // 静态映射文件（-ip-map）
+	sourceBogon    = "bogon"          // 保留地址，不可能是合法的公网应答
+	sourceFakeIP   = "signature"      // 特征库中污染时注入的伪造 IP，见 signatures.go
+	sourcePrivate  = "private"        // 私有地址，见 private.go
+)
+
+// localSources 是报告中列出本地分类来源的顺序
+var localSources = []string{sourceMapping, sourceCache, sourceExpected, sourceBogon, sourceFakeIP, sourcePrivate}
+
+var localSourceNames = map[string]string{
+	sourceMapping:  "映射文件",
+	sourceCache:    "缓存",
+	sourceExpected: "预期网段",
+	sourceBogon:    "保留地址",
+	sourceFakeIP:   "伪造 IP",
+	sourcePrivate:  "私有地址",
+}
+
+// bogonNets 是除私有地址之外不应出现在公网应答中的保留地址段
+var bogonNets = mustParseCIDRs(
+	"0.0.0.0/8",
+	"100.64.0.0/10", // 运营商级 NAT
+	"192.0.0.0/24",
+	"192.0.2.0/24", "198.51.100.0/24", "203.0.113.0/24", // 文档示例地址
+	"198.18.0.0/15", // 基准测试，也常被代理软件用作 fake-ip
+	"224.0.0.0/4",   // 组播
+	"240.0.0.0/4",   // 保留（含广播地址）
+)
+
+// isBogonIP 判断 ip 是否属于 bogonNets
+func isBogonIP(ip net.IP) bool {
+	for _, n := range bogonNets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// compileExpectedCIDRs 编译域名的 expected_cidrs
+func compileExpectedCIDRs(dc *DomainConfig) error {
+	dc.accepted = nil
+	for _, s := range dc.ExpectedCIDRs {
+		n, err := parseIPOrCIDR(s)
+		if err != nil {
+			return fmt.Errorf("expected_cidrs: %w", err)
+		}
+		dc.accepted = append(dc.accepted, n)
+	}
+	return nil
+}
+
+// classifyLocal 依次尝试各个本地分类步骤，能在本地得出结论时返回归属信息与来源；
+// 返回的来源为空表示需要查询 API
+func (c *checker) classifyLocal(dc DomainConfig, ip net.IP) (ipInfo, string) {
+	if !dc.AllowPrivate && isPrivateIP(ip) {
+		return ipInfo{}, sourcePrivate
+	}
+	if isBogonIP(ip) {
+		return ipInfo{}, sourceBogon
+	}
+	if c.sigs.isFakeIP(ip) {
+		return ipInfo{}, sourceFakeIP
+	}
+	for _, n := range dc.accepted {
+		if n.Contains(ip) {
+			return ipInfo{}, sourceExpected
+		}
+	}
+	if info, ok := c.ipMap.Lookup(ip); ok {
+		return info, sourceMapping
+	}
+	if info, ok := c.cache.Get(ip.String()); ok {
+		return info, sourceCache
+	}
+	return ipInfo{}, ""
+}
+
+// countLocalSources 统计各本地分类来源的 IP 数
+func countLocalSources(results []DomainResult) (map[string]int, int) {
+	counts := make(map[string]int)
+	total := 0
+	for _, r := range results {
+		for _, ip := range r.IPResults {
+			if ip.Source != "" && ip.Source != sourceAPI {
+				counts[ip.Source]++
+				total++
+			}
+		}
+	}
+	return counts, total
+}
+
+// writeLocalSources 在报告头部写入本地分类省下的 API 查询数
+func writeLocalSources(b *strings.Builder, results []DomainResult) {
+	counts, total := countLocalSources(results)
+	if total == 0 {
+		return
+	}
+	var parts []string
+	for _, s := range localSources {
+		if counts[s] > 0 {
+			parts = append(parts, fmt.Sprintf("%s %d", localSourceNames[s], counts[s]))
+		}
+	}
+	b.WriteString(fmt.Sprintf("本地分类（未查询 API）: %d 个 IP（%s）\n", total, strings.Join(parts, "，")))
+}
+
+// ---------- 进程内查询缓存 ----------
+
+// ipCache 缓存 API 的查询结果，在同一轮的多个域名之间以及守护模式的多轮之间共享；nil 表示禁用
+type ipCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[string]ipCacheEntry
+}
+
+type ipCacheEntry struct {
+	info ipInfo
+	at   time.Time
+}
+
+// newIPCache 创建缓存，ttl 不大于 0 时返回 nil
+func newIPCache(ttl time.Duration) *ipCache {
+	if ttl <= 0 {
+		return nil
+	}
+	return &ipCache{ttl: ttl, entries: make(map[string]ipCacheEntry)}
+}
+
+// Get 返回未过期的缓存条目
+func (c *ipCache) Get(ip string) (ipInfo, bool) {
+	if c == nil {
+		return ipInfo{}, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[ip]
+	if !ok {
+		return ipInfo{}, false
+	}
+	if time.Since(e.at) >= c.ttl {
+		delete(c.entries, ip)
+		return ipInfo{}, false
+	}
+	return e.info, true
+}
+
+// Put 记录一次成功的查询结果
+func (c *ipCache) Put(ip string, info ipInfo) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[ip] = ipCacheEntry{info: info, at: time.Now()}
+}
+
+// ---------- 合并进行中的相同查询 ----------
+
+// lookupGroup 合并同一 IP 同时进行的 API 查询：多个域名同时解析到同一个 CDN IP 时只发出一次请求，
+// 其余调用等待并共享结果。ipCache 只在查询完成后才生效，无法覆盖这种情况
+type lookupGroup struct {
+	mu    sync.Mutex
+	calls map[string]*lookupCall
+}
+
+type lookupCall struct {
+	done    chan struct{}
+	cancel  context.CancelFunc
+	waiters int // 仍在等待结果的调用方数
+	info    ipInfo
+	err     error
+}
+
+func newLookupGroup() *lookupGroup {
+	return &lookupGroup{calls: make(map[string]*lookupCall)}
+}
+
+// Do 查询 ip：没有进行中的查询时在后台以 base 派生的上下文调用 fn，已有时共享那次查询的结果，
+// shared 表示结果来自另一次调用。base 不应带有单个域名的时限（见 runContextFrom），
+// 否则发起查询的域名超时会连带其他域名；每个调用方只在自己的 ctx 结束前等待，所有调用方都不再等待时取消查询
+func (g *lookupGroup) Do(ctx, base context.Context, ip string, fn func(context.Context) (ipInfo, error)) (info ipInfo, err error, shared bool) {
+	g.mu.Lock()
+	call, shared := g.calls[ip]
+	if !shared {
+		fctx, cancel := context.WithCancel(base)
+		call = &lookupCall{done: make(chan struct{}), cancel: cancel}
+		g.calls[ip] = call
+		go func() {
+			info, err := fn(fctx)
+			cancel()
+			g.mu.Lock()
+			call.info, call.err = info, err
+			if g.calls[ip] == call {
+				delete(g.calls, ip)
+			}
+			g.mu.Unlock()
+			close(call.done)
+		}()
+	}
+	call.waiters++
+	g.mu.Unlock()
+
+	select {
+	case <-call.done:
+		return call.info, call.err, shared
+	case <-ctx.Done():
+	}
+	g.mu.Lock()
+	call.waiters--
+	if call.waiters == 0 {
+		// 之后查询同一 IP 的调用方重新发起查询，而不是等待这次已取消的查询
+		call.cancel()
+		if g.calls[ip] == call {
+			delete(g.calls, ip)
+		}
+	}
+	g.mu.Unlock()
+	return ipInfo{}, ctx.Err(), shared
+}
@@ -0,0 +1,418 @@
+// Package client 是 dnscheck 守护模式（dnscheck serve）HTTP 接口的 Go 客户端，
+// 与守护进程 /api/openapi.json 提供的 OpenAPI 文档一一对应。
+//
+//	c := client.New("http://127.0.0.1:8080")
+//	page, err := c.History(ctx, client.HistoryQuery{Domain: "www.google.com", Verdict: "polluted"})
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Client 调用一个守护进程的接口，可以被多个 goroutine 同时使用
+type Client struct {
+	BaseURL    string       // 守护进程地址，如 http://127.0.0.1:8080
+	HTTPClient *http.Client // 为 nil 时使用 http.DefaultClient；/api/stream 是长连接，不要设置 Timeout
+	Token      string       // 守护进程启用 -api-tokens 时的 Bearer 令牌；使用双向 TLS 时在 HTTPClient 中配置证书
+	Project    string       // 守护进程按项目运行（-projects）时的项目 ID，设置后各方法访问该项目的接口
+}
+
+// New 返回访问 baseURL 的客户端
+func New(baseURL string) *Client {
+	return &Client{BaseURL: strings.TrimSuffix(baseURL, "/")}
+}
+
+// APIError 是守护进程返回的非 2xx 响应
+type APIError struct {
+	StatusCode int
+	Message    string // 响应中的 error 字段，没有时为空
+
+	body string // 原始响应体，探针接口返回 503 时从中解码状态
+}
+
+func (e *APIError) Error() string {
+	if e.Message != "" {
+		return fmt.Sprintf("守护进程返回 HTTP %d: %s", e.StatusCode, e.Message)
+	}
+	return fmt.Sprintf("守护进程返回 HTTP %d", e.StatusCode)
+}
+
+// ---------- 响应结构 ----------
+
+// Status 是 /healthz 与 /readyz 的响应
+type Status struct {
+	Status      string            `json:"status"`
+	Running     bool              `json:"running"`
+	Runs        int               `json:"runs"`
+	LastStart   *time.Time        `json:"last_run_start,omitempty"`
+	LastSuccess *time.Time        `json:"last_success,omitempty"`
+	LastError   string            `json:"last_error,omitempty"`
+	Providers   map[string]string `json:"providers,omitempty"` // 只在 /readyz 中出现
+	Projects    map[string]Status `json:"projects,omitempty"`  // 按项目运行且未设置 Client.Project 时各项目的状态
+}
+
+// Project 是 /api/projects 中的一项
+type Project struct {
+	ID          string     `json:"id"`
+	Config      string     `json:"config"`
+	Domains     int        `json:"domains"`
+	Schedule    string     `json:"schedule"`
+	Runs        int        `json:"runs"`
+	LastSuccess *time.Time `json:"last_success,omitempty"`
+	LastError   string     `json:"last_error,omitempty"`
+}
+
+// WindowAvailability 是某个窗口内的干净解析可用性
+type WindowAvailability struct {
+	Window       string  `json:"window"`
+	Runs         int     `json:"runs"`
+	Clean        int     `json:"clean"`
+	Inconclusive int     `json:"inconclusive"` // 无法判定的检测次数，不计入 Runs
+	Availability float64 `json:"availability"`
+}
+
+// DomainAvailability 是单个域名在 24h、7d、30d 窗口的可用性
+type DomainAvailability struct {
+	Domain  string               `json:"domain"`
+	Windows []WindowAvailability `json:"windows"`
+}
+
+// Mute 是一条临时静音，Domain 为 "*" 表示全部域名
+type Mute struct {
+	Domain string    `json:"domain"`
+	Until  time.Time `json:"until"`
+}
+
+// HistoryIP 是历史记录中单个 IP 的结果
+type HistoryIP struct {
+	IP    string `json:"ip"`
+	LLC   string `json:"llc,omitempty"`
+	ASN   uint32 `json:"asn,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// HistoryRecord 是某一轮检测中单个域名的历史结果
+type HistoryRecord struct {
+	RunID     string      `json:"run_id"`
+	CheckedAt time.Time   `json:"checked_at"`
+	Domain    string      `json:"domain"`
+	Polluted  bool        `json:"polluted"`
+	Verdict   string      `json:"verdict"` // clean、polluted、rebinding 或 error
+	Summary   string      `json:"summary"`
+	IPs       []HistoryIP `json:"ips"`
+}
+
+// HistoryPage 是 /api/history 的一页结果；NextOffset 为 nil 表示没有下一页
+type HistoryPage struct {
+	Results    []HistoryRecord `json:"results"`
+	Limit      int             `json:"limit"`
+	Offset     int             `json:"offset"`
+	NextOffset *int            `json:"next_offset,omitempty"`
+}
+
+// HistoryQuery 是 /api/history 的查询条件，零值字段表示不限制
+type HistoryQuery struct {
+	Domain  string
+	From    time.Time
+	To      time.Time
+	Verdict string // polluted（含 rebinding）、clean、rebinding 或 error
+	Limit   int    // 0 表示使用服务端默认值（100）
+	Offset  int
+}
+
+// StreamRun 是 run_start 与 run_finish 事件的数据
+type StreamRun struct {
+	RunID      string    `json:"run_id"`
+	Group      string    `json:"group,omitempty"`
+	At         time.Time `json:"at"`
+	Domains    int       `json:"domains"`
+	Polluted   *int      `json:"polluted,omitempty"` // 只在 run_finish 中出现
+	DurationMs float64   `json:"duration_ms,omitempty"`
+}
+
+// StreamVerdict 是 verdict 事件的数据
+type StreamVerdict struct {
+	RunID    string    `json:"run_id"`
+	Group    string    `json:"group,omitempty"`
+	At       time.Time `json:"at"`
+	Domain   string    `json:"domain"`
+	Verdict  string    `json:"verdict"` // clean、polluted、rebinding 或 error
+	Polluted bool      `json:"polluted"`
+	Summary  string    `json:"summary"`
+	Muted    string    `json:"muted,omitempty"`
+}
+
+// AlertEvent 是 alert 事件的数据
+type AlertEvent struct {
+	Rule      string  `json:"rule"`
+	Status    string  `json:"status"` // firing 或 resolved
+	Domain    string  `json:"domain,omitempty"`
+	Condition string  `json:"condition"`
+	Value     float64 `json:"value"`
+	Runs      int     `json:"consecutive_runs"`
+	Message   string  `json:"message"`
+}
+
+// Event 是 /api/stream 推送的一条事件。Type 为 run_start、verdict、alert 或 run_finish，
+// 可用 Run、Verdict、Alert 解码对应的数据
+type Event struct {
+	Type string
+	Data json.RawMessage
+}
+
+// Run 解码 run_start 与 run_finish 事件的数据
+func (e Event) Run() (StreamRun, error) {
+	var v StreamRun
+	err := json.Unmarshal(e.Data, &v)
+	return v, err
+}
+
+// Verdict 解码 verdict 事件的数据
+func (e Event) Verdict() (StreamVerdict, error) {
+	var v StreamVerdict
+	err := json.Unmarshal(e.Data, &v)
+	return v, err
+}
+
+// Alert 解码 alert 事件的数据
+func (e Event) Alert() (AlertEvent, error) {
+	var v AlertEvent
+	err := json.Unmarshal(e.Data, &v)
+	return v, err
+}
+
+// ---------- 接口 ----------
+
+// Healthz 调用存活探针。调度器卡死时同时返回响应内容与 *APIError（503）
+func (c *Client) Healthz(ctx context.Context) (*Status, error) {
+	var s Status
+	err := c.do(ctx, http.MethodGet, "/healthz", nil, nil, &s)
+	return &s, err
+}
+
+// Readyz 调用就绪探针。未就绪时同时返回响应内容（原因见 Status）与 *APIError（503）
+func (c *Client) Readyz(ctx context.Context) (*Status, error) {
+	var s Status
+	err := c.do(ctx, http.MethodGet, "/readyz", nil, nil, &s)
+	return &s, err
+}
+
+// Metrics 返回 Prometheus 文本格式的指标
+func (c *Client) Metrics(ctx context.Context) (string, error) {
+	resp, err := c.send(ctx, http.MethodGet, "/metrics", nil, nil)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	return string(data), err
+}
+
+// Availability 返回各域名的干净解析可用性（需要守护进程配置 -history）
+func (c *Client) Availability(ctx context.Context) ([]DomainAvailability, error) {
+	var list []DomainAvailability
+	err := c.do(ctx, http.MethodGet, "/availability", nil, nil, &list)
+	return list, err
+}
+
+// Mutes 返回当前的静音
+func (c *Client) Mutes(ctx context.Context) ([]Mute, error) {
+	var list []Mute
+	err := c.do(ctx, http.MethodGet, "/mute", nil, nil, &list)
+	return list, err
+}
+
+// Mute 将 domain（"*" 表示全部域名）静音 d
+func (c *Client) Mute(ctx context.Context, domain string, d time.Duration) (*Mute, error) {
+	var m Mute
+	form := url.Values{"domain": {domain}, "duration": {d.String()}}
+	err := c.do(ctx, http.MethodPost, "/mute", nil, form, &m)
+	return &m, err
+}
+
+// Unmute 解除 domain 的静音
+func (c *Client) Unmute(ctx context.Context, domain string) error {
+	return c.do(ctx, http.MethodDelete, "/mute", url.Values{"domain": {domain}}, nil, nil)
+}
+
+// Check 让调度组立即检测一轮；group 为空时触发全部调度组。返回已触发的调度组名称
+func (c *Client) Check(ctx context.Context, group string) ([]string, error) {
+	form := url.Values{}
+	if group != "" {
+		form.Set("group", group)
+	}
+	var out struct {
+		Groups []string `json:"groups"`
+	}
+	err := c.do(ctx, http.MethodPost, "/api/check", nil, form, &out)
+	return out.Groups, err
+}
+
+// Reload 让守护进程重新加载配置，效果同 SIGHUP。重新加载在后台进行，结果见守护进程日志
+func (c *Client) Reload(ctx context.Context) error {
+	return c.do(ctx, http.MethodPost, "/api/reload", nil, url.Values{}, nil)
+}
+
+// History 按条件查询一页历史结果（需要守护进程配置 -history）
+func (c *Client) History(ctx context.Context, q HistoryQuery) (*HistoryPage, error) {
+	values := url.Values{}
+	if q.Domain != "" {
+		values.Set("domain", q.Domain)
+	}
+	if !q.From.IsZero() {
+		values.Set("from", q.From.Format(time.RFC3339))
+	}
+	if !q.To.IsZero() {
+		values.Set("to", q.To.Format(time.RFC3339))
+	}
+	if q.Verdict != "" {
+		values.Set("verdict", q.Verdict)
+	}
+	if q.Limit > 0 {
+		values.Set("limit", strconv.Itoa(q.Limit))
+	}
+	if q.Offset > 0 {
+		values.Set("offset", strconv.Itoa(q.Offset))
+	}
+	var page HistoryPage
+	err := c.do(ctx, http.MethodGet, "/api/history", values, nil, &page)
+	return &page, err
+}
+
+// Stream 订阅 /api/stream，每收到一条事件调用一次 fn，直到 ctx 结束、连接断开或 fn 返回错误。
+// ctx 结束时返回 ctx.Err()
+func (c *Client) Stream(ctx context.Context, fn func(Event) error) error {
+	resp, err := c.send(ctx, http.MethodGet, "/api/stream", nil, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	sc := bufio.NewScanner(resp.Body)
+	sc.Buffer(make([]byte, 64*1024), 1<<20)
+	var ev Event
+	var data []string
+	for sc.Scan() {
+		line := sc.Text()
+		switch {
+		case line == "":
+			// 空行结束一条事件；只有注释（保活）的事件没有数据
+			if len(data) > 0 {
+				ev.Data = json.RawMessage(strings.Join(data, "\n"))
+				if err := fn(ev); err != nil {
+					return err
+				}
+			}
+			ev, data = Event{}, nil
+		case strings.HasPrefix(line, ":"):
+		case strings.HasPrefix(line, "event:"):
+			ev.Type = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			data = append(data, strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		}
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if err := sc.Err(); err != nil {
+		return fmt.Errorf("读取事件流失败: %w", err)
+	}
+	return io.ErrUnexpectedEOF
+}
+
+// Projects 列出按项目运行的守护进程中调用方可以访问的项目，不受 Client.Project 影响
+func (c *Client) Projects(ctx context.Context) ([]Project, error) {
+	var projects []Project
+	err := c.do(ctx, http.MethodGet, "/api/projects", nil, nil, &projects)
+	return projects, err
+}
+
+// OpenAPI 返回守护进程提供的 OpenAPI 文档
+func (c *Client) OpenAPI(ctx context.Context) (json.RawMessage, error) {
+	var doc json.RawMessage
+	err := c.do(ctx, http.MethodGet, "/api/openapi.json", nil, nil, &doc)
+	return doc, err
+}
+
+// ---------- 请求 ----------
+
+// do 发送请求并将 JSON 响应解码到 out（可为 nil）。非 2xx 响应返回 *APIError，
+// 响应体仍会尽量解码到 out，以便探针接口返回 503 时取得原因
+func (c *Client) do(ctx context.Context, method, path string, query, form url.Values, out interface{}) error {
+	resp, err := c.send(ctx, method, path, query, form)
+	if apiErr, ok := err.(*APIError); ok && out != nil && resp != nil {
+		_ = json.Unmarshal([]byte(apiErr.body), out)
+	}
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("解析守护进程响应失败: %w", err)
+	}
+	return nil
+}
+
+// send 发送请求；非 2xx 响应读取并关闭响应体，返回 *APIError
+func (c *Client) send(ctx context.Context, method, path string, query, form url.Values) (*http.Response, error) {
+	endpoint := strings.TrimSuffix(c.BaseURL, "/") + c.projectPath(path)
+	if len(query) > 0 {
+		endpoint += "?" + query.Encode()
+	}
+	var body io.Reader
+	if form != nil {
+		body = strings.NewReader(form.Encode())
+	}
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, body)
+	if err != nil {
+		return nil, fmt.Errorf("无效的守护进程地址: %w", err)
+	}
+	if form != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+	hc := c.HTTPClient
+	if hc == nil {
+		hc = http.DefaultClient
+	}
+	resp, err := hc.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("连接守护进程失败: %w", err)
+	}
+	if resp.StatusCode/100 == 2 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	apiErr := &APIError{StatusCode: resp.StatusCode, body: string(data)}
+	var e struct {
+		Error string `json:"error"`
+	}
+	if json.Unmarshal(data, &e) == nil {
+		apiErr.Message = e.Error
+	}
+	return resp, apiErr
+}
+
+// projectPath 把接口路径换成 Project 下的路径，如 /api/history 换成 /api/projects/{id}/history。
+// 未设置 Project 时，以及接口文档与项目列表，路径不变
+func (c *Client) projectPath(path string) string {
+	if c.Project == "" || path == "/api/openapi.json" || path == "/api/projects" {
+		return path
+	}
+	return "/api/projects/" + url.PathEscape(c.Project) + "/" + strings.TrimPrefix(strings.TrimPrefix(path, "/api"), "/")
+}
@@ -0,0 +1,325 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"html/template"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ---------- 前后对比报告（dnscheck compare） ----------
+
+// comparison 是两次结果的对比，按变化类型分组；分组与退化的判断与 dnscheck diff 相同（见 diffRuns）
+type comparison struct {
+	Before compareSide
+	After  compareSide
+
+	NewPollution []compareRow // 由干净变为污染（包括新增的被污染域名）
+	Resolved     []compareRow // 由污染变为干净
+	Changed      []compareRow // 判定不变，IP 集合或严重程度变化
+	Added        []compareRow // 只在变更后出现的干净域名
+	Removed      []compareRow // 只在变更前出现的域名
+	Unchanged    int
+}
+
+// compareSide 是对比的一方
+type compareSide struct {
+	File     string
+	RunID    string
+	Host     string
+	Started  time.Time
+	Domains  int
+	Polluted int
+}
+
+// compareRow 是单个域名的对比；Before / After 在域名只出现在一方时为 nil
+type compareRow struct {
+	Diff   domainDiff
+	Before *domainJSON
+	After  *domainJSON
+}
+
+// compareCell 是对比报告中某个域名一方的判定
+type compareCell struct {
+	Verdict  string
+	Severity string
+	Summary  string
+	IPs      []compareIP
+}
+
+// compareIP 是对比报告中的一个 IP；Class 为 "removed" 或 "added" 表示该 IP 只出现在这一方
+type compareIP struct {
+	IP      string
+	LLC     string
+	Matched bool
+	Class   string
+}
+
+// runCompare 实现 `dnscheck compare before.json after.json -format html -o compare.html`：
+// 生成便于阅读的前后对比报告，用于切换解析器或 VPN 配置前后的验证。需要作为门禁时使用 dnscheck diff
+func runCompare(args []string) {
+	fs := flag.NewFlagSet("compare", flag.ExitOnError)
+	format := fs.String("format", "text", "输出格式：text、html 或 json（与 dnscheck diff 相同）")
+	output := fs.String("o", "", "输出文件（为空则写到标准输出）")
+	fs.BoolVar(anycastMode, "anycast", false, "同一 ASN（未知时为同一 LLC）内的 IP 变化不列出")
+	// 允许选项出现在文件名之后，如 dnscheck compare before.json after.json -format html
+	var files []string
+	for rest := args; ; {
+		_ = fs.Parse(rest)
+		if fs.NArg() == 0 {
+			break
+		}
+		files = append(files, fs.Arg(0))
+		rest = fs.Args()[1:]
+	}
+	if len(files) != 2 {
+		fmt.Fprintln(os.Stderr, "用法: dnscheck compare <变更前.json> <变更后.json> [-format text|html|json] [-o 输出文件]")
+		os.Exit(2)
+	}
+	before, err := loadRunJSON(files[0])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(2)
+	}
+	after, err := loadRunJSON(files[1])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(2)
+	}
+
+	c := compareRuns(files[0], before, files[1], after)
+	var data []byte
+	switch *format {
+	case "text":
+		data = []byte(c.Text())
+	case "html":
+		data, err = renderCompareHTML(c)
+	case "json":
+		data, err = json.MarshalIndent(diffRuns(before, after), "", "  ")
+		data = append(data, '\n')
+	default:
+		fmt.Fprintf(os.Stderr, "不支持的格式 %q（可选 text、html、json）\n", *format)
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "生成对比报告失败: %v\n", err)
+		os.Exit(1)
+	}
+	if *output == "" {
+		os.Stdout.Write(data)
+		return
+	}
+	if err := writeReportToFile(string(data), *output); err != nil {
+		fmt.Fprintf(os.Stderr, "写入对比报告失败: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Fprintf(os.Stderr, "对比报告已保存至: %s\n", *output)
+}
+
+// compareRuns 对比两次结果
+func compareRuns(beforeFile string, before runJSON, afterFile string, after runJSON) comparison {
+	c := comparison{Before: newCompareSide(beforeFile, before), After: newCompareSide(afterFile, after)}
+	byDomain := func(run runJSON) map[string]*domainJSON {
+		m := make(map[string]*domainJSON, len(run.Results))
+		for i := range run.Results {
+			m[run.Results[i].Domain] = &run.Results[i]
+		}
+		return m
+	}
+	old, cur := byDomain(before), byDomain(after)
+	d := diffRuns(before, after)
+	for _, dd := range d.Domains {
+		row := compareRow{Diff: dd, Before: old[dd.Domain], After: cur[dd.Domain]}
+		switch {
+		case dd.Change == diffRemoved:
+			c.Removed = append(c.Removed, row)
+		case dd.Change == diffAdded && dd.ToVerdict == "clean":
+			c.Added = append(c.Added, row)
+		case dd.Change == diffAdded, dd.FromVerdict == "clean" && dd.ToVerdict != "clean":
+			c.NewPollution = append(c.NewPollution, row)
+		case dd.FromVerdict != "" && dd.FromVerdict != "clean" && dd.ToVerdict == "clean":
+			c.Resolved = append(c.Resolved, row)
+		default:
+			c.Changed = append(c.Changed, row)
+		}
+	}
+	// 两边都有、且不在 diffRuns 结果中的域名没有变化
+	for domain := range cur {
+		if old[domain] != nil {
+			c.Unchanged++
+		}
+	}
+	for _, dd := range d.Domains {
+		if dd.Change == diffChanged {
+			c.Unchanged--
+		}
+	}
+	return c
+}
+
+func newCompareSide(file string, run runJSON) compareSide {
+	s := compareSide{File: filepath.Base(file), RunID: run.RunID, Host: run.Host, Started: run.StartedAt, Domains: len(run.Results)}
+	for _, r := range run.Results {
+		if r.Polluted {
+			s.Polluted++
+		}
+	}
+	return s
+}
+
+// BeforeSide 返回变更前的判定，标出变更后不再出现的 IP；域名只出现在变更后时返回 nil
+func (r compareRow) BeforeSide() *compareCell {
+	return newCompareCell(r.Before, r.Diff.RemovedIPs, "removed")
+}
+
+// AfterSide 返回变更后的判定，标出新出现的 IP；域名只出现在变更前时返回 nil
+func (r compareRow) AfterSide() *compareCell {
+	return newCompareCell(r.After, r.Diff.AddedIPs, "added")
+}
+
+func newCompareCell(r *domainJSON, changed []string, class string) *compareCell {
+	if r == nil {
+		return nil
+	}
+	marked := make(map[string]bool, len(changed))
+	for _, ip := range changed {
+		marked[ip] = true
+	}
+	c := &compareCell{Verdict: r.verdict(), Severity: r.Severity, Summary: r.Summary}
+	for _, ip := range r.IPs {
+		cip := compareIP{IP: ip.IP, LLC: ip.LLC, Matched: ip.Matched || ip.Error != ""}
+		if marked[ip.IP] {
+			cip.Class = class
+		}
+		c.IPs = append(c.IPs, cip)
+	}
+	return c
+}
+
+func (s compareSide) String() string {
+	str := fmt.Sprintf("%s（%s", s.File, s.RunID)
+	if s.Host != "" {
+		str += "，主机 " + s.Host
+	}
+	if !s.Started.IsZero() {
+		str += "，" + s.Started.Local().Format("2006-01-02 15:04:05")
+	}
+	return str + fmt.Sprintf("）: %d 个域名，%d 个被污染", s.Domains, s.Polluted)
+}
+
+// Text 生成文本格式的对比报告
+func (c comparison) Text() string {
+	var b strings.Builder
+	b.WriteString("DNS 污染检测对比报告\n=================\n")
+	b.WriteString("变更前: " + c.Before.String() + "\n")
+	b.WriteString("变更后: " + c.After.String() + "\n")
+	b.WriteString("=================\n\n")
+	sections := []struct {
+		title string
+		rows  []compareRow
+	}{
+		{"新增污染", c.NewPollution},
+		{"已解除的污染", c.Resolved},
+		{"IP 集合或严重程度变化", c.Changed},
+		{"新增的域名", c.Added},
+		{"已移除的域名", c.Removed},
+	}
+	for _, sec := range sections {
+		if len(sec.rows) == 0 {
+			continue
+		}
+		b.WriteString(fmt.Sprintf("%s（%d）:\n", sec.title, len(sec.rows)))
+		for _, row := range sec.rows {
+			b.WriteString("  " + row.Diff.Domain)
+			if row.Diff.FromVerdict != "" || row.Diff.ToVerdict != "" {
+				b.WriteString(fmt.Sprintf(": %s → %s", orDash(row.Diff.FromVerdict), orDash(row.Diff.ToVerdict)))
+				if row.Diff.FromSeverity != row.Diff.ToSeverity {
+					b.WriteString(fmt.Sprintf("（%s → %s）", orDash(row.Diff.FromSeverity), orDash(row.Diff.ToSeverity)))
+				}
+			}
+			b.WriteString("\n")
+			if row.After != nil && row.After.Summary != "" {
+				b.WriteString("    汇总: " + row.After.Summary + "\n")
+			}
+			for _, ip := range row.Diff.RemovedIPs {
+				b.WriteString("    - " + ip + "\n")
+			}
+			for _, ip := range row.Diff.AddedIPs {
+				b.WriteString("    + " + ip + "\n")
+			}
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString(fmt.Sprintf("没有变化的域名: %d 个\n", c.Unchanged))
+	return b.String()
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+var compareHTML = template.Must(template.New("compare").Funcs(template.FuncMap{
+	"time": func(t time.Time) string { return t.Local().Format("2006-01-02 15:04:05") },
+}).Parse(`<!DOCTYPE html>
+<html lang="zh-CN">
+<head>
+<meta charset="utf-8">
+<title>dnscheck 对比报告</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; margin-bottom: 2em; width: 100%; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: left; vertical-align: top; }
+th { background: #f0f0f0; }
+td.side { width: 40%; }
+.polluted, .rebinding { color: #b00; font-weight: bold; }
+.clean { color: #070; font-weight: bold; }
+.removed { background: #fde2e2; text-decoration: line-through; }
+.added { background: #e2f5e2; }
+.unmatched { color: #b00; }
+.summary { color: #555; font-size: 90%; }
+</style>
+</head>
+<body>
+<h1>dnscheck 对比报告</h1>
+<table>
+<tr><th></th><th>文件</th><th>轮次</th><th>主机</th><th>开始时间</th><th>域名数</th><th>被污染</th></tr>
+{{with .Before}}<tr><th>变更前</th><td>{{.File}}</td><td>{{.RunID}}</td><td>{{.Host}}</td><td>{{time .Started}}</td><td>{{.Domains}}</td><td>{{.Polluted}}</td></tr>{{end}}
+{{with .After}}<tr><th>变更后</th><td>{{.File}}</td><td>{{.RunID}}</td><td>{{.Host}}</td><td>{{time .Started}}</td><td>{{.Domains}}</td><td>{{.Polluted}}</td></tr>{{end}}
+</table>
+<p>新增污染 {{len .NewPollution}} 个，已解除的污染 {{len .Resolved}} 个，IP 集合或严重程度变化 {{len .Changed}} 个，新增域名 {{len .Added}} 个，移除域名 {{len .Removed}} 个，没有变化 {{.Unchanged}} 个。</p>
+{{define "side"}}{{with .}}<span class="{{.Verdict}}">{{.Verdict}}</span>{{with .Severity}}（{{.}}）{{end}}{{with .Summary}}<div class="summary">{{.}}</div>{{end}}
+{{range .IPs}}<span class="{{.Class}}{{if not .Matched}} unmatched{{end}}">{{.IP}}{{if .LLC}}（{{.LLC}}）{{end}}</span><br>{{end}}{{else}}—{{end}}{{end}}
+{{define "rows"}}<table>
+<tr><th>域名</th><th>变更前</th><th>变更后</th></tr>
+{{range .}}<tr><td>{{.Diff.Domain}}</td><td class="side">{{template "side" .BeforeSide}}</td><td class="side">{{template "side" .AfterSide}}</td></tr>
+{{end}}</table>
+{{end}}
+{{if .NewPollution}}<h2>新增污染</h2>
+{{template "rows" .NewPollution}}{{end}}
+{{if .Resolved}}<h2>已解除的污染</h2>
+{{template "rows" .Resolved}}{{end}}
+{{if .Changed}}<h2>IP 集合或严重程度变化</h2>
+{{template "rows" .Changed}}{{end}}
+{{if .Added}}<h2>新增的域名</h2>
+{{template "rows" .Added}}{{end}}
+{{if .Removed}}<h2>已移除的域名</h2>
+{{template "rows" .Removed}}{{end}}
+</body>
+</html>
+`))
+
+// renderCompareHTML 将对比结果渲染为独立的 HTML 页面
+func renderCompareHTML(c comparison) ([]byte, error) {
+	var b strings.Builder
+	if err := compareHTML.Execute(&b, c); err != nil {
+		return nil, err
+	}
+	return []byte(b.String()), nil
+}
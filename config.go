@@ -0,0 +1,164 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ---------- 配置校验与规范化 ----------
+
+// UnmarshalYAML 在解码域名条目时记录其所在行号，便于校验时给出定位信息
+func (d *DomainConfig) UnmarshalYAML(value *yaml.Node) error {
+	type plain DomainConfig
+	if err := value.Decode((*plain)(d)); err != nil {
+		return err
+	}
+	d.Line = value.Line
+	return nil
+}
+
+// ConfigError 汇总配置中发现的全部问题，一次性报告给用户
+type ConfigError struct {
+	Source   string
+	Problems []string
+}
+
+func (e *ConfigError) Error() string {
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("配置 %s 中存在 %d 个问题:", e.Source, len(e.Problems)))
+	for _, p := range e.Problems {
+		b.WriteString("\n  ")
+		b.WriteString(p)
+	}
+	return b.String()
+}
+
+// normalizeConfig 展开 subdomains 后规范化并校验所有域名：转小写、去除首尾空白和末尾的点，
+// 拒绝误填的 URL、端口或路径，检测（规范化后的）重复条目，并校验预期的匹配方式
+func normalizeConfig(cfg *Config, source string) error {
+	cerr := &ConfigError{Source: source}
+	cerr.Problems = append(cerr.Problems, expandSubdomains(cfg)...)
+	problem := func(line int, format string, args ...interface{}) {
+		cerr.Problems = append(cerr.Problems, fmt.Sprintf("第 %d 行: %s", line, fmt.Sprintf(format, args...)))
+	}
+
+	seen := make(map[string]int) // punycode 形式 -> 首次出现的行号
+	for i := range cfg.Domains {
+		dc := &cfg.Domains[i]
+		name, err := normalizeDomainName(dc.Name)
+		if err != nil {
+			problem(dc.Line, "%v", err)
+			continue
+		}
+		dc.Name = name
+
+		expected, err := resolveExpectations(dc.ExpectedLlcs, dc.Match, dc.CaseInsensitive)
+		if err != nil {
+			problem(dc.Line, "%v", err)
+			continue
+		}
+		dc.ExpectedLlcs = expected
+
+		if err := validateHosting(dc.Hosting); err != nil {
+			problem(dc.Line, "%v", err)
+			continue
+		}
+		if dc.DNSTimeout < 0 || dc.APITimeout < 0 {
+			problem(dc.Line, "dns_timeout 与 api_timeout 不能为负数")
+			continue
+		}
+		if dc.forbidden, err = newBlocklist(dc); err != nil {
+			problem(dc.Line, "%v", err)
+			continue
+		}
+		if err := validatePinning(dc); err != nil {
+			problem(dc.Line, "%v", err)
+			continue
+		}
+		if err := compileExpectedCIDRs(dc); err != nil {
+			problem(dc.Line, "%v", err)
+			continue
+		}
+		if dc.Rules != nil {
+			if len(dc.ExpectedLlcs) > 0 {
+				problem(dc.Line, "rules 与 expected_llcs 不能同时使用")
+				continue
+			}
+			if err := dc.Rules.compile(dc.Match, dc.CaseInsensitive); err != nil {
+				problem(dc.Line, "rules: %v", err)
+				continue
+			}
+		}
+
+		ascii, err := toASCIIDomain(name)
+		if err != nil {
+			problem(dc.Line, "%v", err)
+			continue
+		}
+		if err := validateContentCheck(dc, ascii); err != nil {
+			problem(dc.Line, "%v", err)
+			continue
+		}
+		if first, ok := seen[ascii]; ok {
+			problem(dc.Line, "域名 %s 与第 %d 行重复", name, first)
+			continue
+		}
+		seen[ascii] = dc.Line
+	}
+
+	sinkholes, err := compileSinkholes(cfg.Sinkholes)
+	if err != nil {
+		cerr.Problems = append(cerr.Problems, err.Error())
+	}
+	for i := range cfg.Domains {
+		cfg.Domains[i].sinkholes = sinkholes
+	}
+
+	providers, err := compileProviders(cfg.Providers)
+	if err != nil {
+		cerr.Problems = append(cerr.Problems, err.Error())
+	}
+	cfg.providers = providers
+	cerr.Problems = append(cerr.Problems, validateProfiles(cfg)...)
+	cerr.Problems = append(cerr.Problems, validateAlerts(cfg)...)
+	cerr.Problems = append(cerr.Problems, validateMaintenance(cfg)...)
+	cerr.Problems = append(cerr.Problems, validateSchedules(cfg)...)
+	cerr.Problems = append(cerr.Problems, validateRetry(cfg)...)
+
+	if cfg.Script != "" {
+		script, err := newVerdictScript(cfg.Script)
+		if err != nil {
+			cerr.Problems = append(cerr.Problems, err.Error())
+		}
+		cfg.script = script
+	}
+
+	if len(cerr.Problems) > 0 {
+		return cerr
+	}
+	return nil
+}
+
+// normalizeDomainName 将单个域名规范化为小写、无末尾点的主机名
+func normalizeDomainName(raw string) (string, error) {
+	name := strings.TrimSpace(raw)
+	if name == "" {
+		return "", fmt.Errorf("域名为空")
+	}
+	if strings.Contains(name, "://") || strings.ContainsAny(name, "/?#") {
+		return "", fmt.Errorf("%q 看起来是 URL，请只填写主机名（如 www.example.com）", raw)
+	}
+	if strings.Contains(name, ":") {
+		return "", fmt.Errorf("%q 包含端口或非法字符 ':'，请只填写主机名", raw)
+	}
+	if strings.ContainsAny(name, " \t") {
+		return "", fmt.Errorf("%q 包含空白字符", raw)
+	}
+	name = strings.ToLower(strings.TrimSuffix(name, "."))
+	if name == "" || strings.HasPrefix(name, ".") || strings.Contains(name, "..") {
+		return "", fmt.Errorf("%q 不是合法的主机名", raw)
+	}
+	return name, nil
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// ---------- 页面内容校验（expected_body_contains / expected_title） ----------
+
+// maxContentBytes 是内容校验读取的页面大小上限
+const maxContentBytes = 1 << 20
+
+var titlePattern = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title>`)
+
+// pageCheck 是对单个 IP 返回页面的内容校验结果
+type pageCheck struct {
+	Status   int      `json:"status,omitempty"`
+	Title    string   `json:"title,omitempty"`
+	BadTitle bool     `json:"title_mismatch,omitempty"` // 标题不包含 expected_title
+	Missing  []string `json:"missing,omitempty"`        // 页面中没有找到的 expected_body_contains 关键字
+	Error    string   `json:"error,omitempty"`          // 请求失败，此时不做判断
+}
+
+func (p pageCheck) String() string {
+	if p.Error != "" {
+		return "请求失败: " + p.Error
+	}
+	s := "符合"
+	if p.mismatch() {
+		s = "不符合"
+	}
+	s += fmt.Sprintf("（HTTP %d", p.Status)
+	if p.Title != "" {
+		s += fmt.Sprintf("，标题 %q", p.Title)
+	}
+	if len(p.Missing) > 0 {
+		s += "，缺少关键字 " + strings.Join(p.Missing, "、")
+	}
+	return s + "）"
+}
+
+// mismatch 判断页面是否与预期不符；请求失败或未校验（nil）时返回 false
+func (p *pageCheck) mismatch() bool {
+	return p != nil && p.Error == "" && (p.BadTitle || len(p.Missing) > 0)
+}
+
+// contentURL 返回内容校验请求的地址：content_url，未配置时为 https://域名/
+func contentURL(dc DomainConfig, host string) string {
+	if dc.ContentURL != "" {
+		return dc.ContentURL
+	}
+	return "https://" + host + "/"
+}
+
+// validateContentCheck 检查 content_url：必须是 http/https 地址，且主机为该域名本身，
+// 这样请求才会连接到解析到的 IP
+func validateContentCheck(dc *DomainConfig, host string) error {
+	if dc.ContentURL == "" {
+		return nil
+	}
+	u, err := url.Parse(dc.ContentURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return fmt.Errorf("content_url %q 不是有效的 http/https 地址", dc.ContentURL)
+	}
+	if !strings.EqualFold(u.Hostname(), host) {
+		return fmt.Errorf("content_url 的主机 %s 与域名 %s 不一致", u.Hostname(), host)
+	}
+	return nil
+}
+
+// verifyContent 在配置了 expected_body_contains 或 expected_title 时，通过每个解析到的 IP 请求页面
+// （私有地址只在 allow_private 时请求），确认返回的是真实站点而不是仿冒页或拦截页。
+// 有 IP 返回的页面不符合预期时判定为污染；请求失败只记录，不改变判定
+func verifyContent(ctx context.Context, dc DomainConfig, res *DomainResult) {
+	if len(dc.ExpectedBody) == 0 && dc.ExpectedTitle == "" {
+		return
+	}
+	target := contentURL(dc, res.ASCIIDomain)
+	var wg sync.WaitGroup
+	for i := range res.IPResults {
+		ipRes := &res.IPResults[i]
+		ip := net.ParseIP(ipRes.IP)
+		if ip == nil || (ipRes.Private && !dc.AllowPrivate) || ipRes.Source == sourceBogon {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			page := fetchPage(ctx, target, res.ASCIIDomain, ip, dc)
+			ipRes.Page = &page
+		}()
+	}
+	wg.Wait()
+
+	n := 0
+	for _, ip := range res.IPResults {
+		if ip.Page.mismatch() {
+			n++
+		}
+	}
+	if n > 0 {
+		res.IsPolluted = true
+		res.Summary = fmt.Sprintf("%d 个 IP 返回的页面内容不符合预期", n)
+	}
+}
+
+// fetchPage 通过指定 IP 请求页面并与预期比较；关键字区分大小写，标题按包含关系、忽略大小写比较
+func fetchPage(ctx context.Context, target, host string, ip net.IP, dc DomainConfig) pageCheck {
+	var page pageCheck
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		page.Error = err.Error()
+		return page
+	}
+	req.Header.Set("User-Agent", "dnscheck")
+	resp, err := pinnedClient(host, ip, func(*url.URL) {}).Do(req)
+	if err != nil {
+		var uerr *url.Error
+		if errors.As(err, &uerr) {
+			err = uerr.Err
+		}
+		page.Error = err.Error()
+		return page
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxContentBytes))
+	if err != nil {
+		page.Error = fmt.Sprintf("读取页面失败: %v", err)
+		return page
+	}
+
+	page.Status = resp.StatusCode
+	if m := titlePattern.FindSubmatch(body); m != nil {
+		page.Title = strings.TrimSpace(html.UnescapeString(string(m[1])))
+	}
+	if dc.ExpectedTitle != "" {
+		page.BadTitle = !strings.Contains(strings.ToLower(page.Title), strings.ToLower(dc.ExpectedTitle))
+	}
+	for _, kw := range dc.ExpectedBody {
+		if !strings.Contains(string(body), kw) {
+			page.Missing = append(page.Missing, kw)
+		}
+	}
+	return page
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+)
+
+// ---------- 定时任务模式（-cron-state） ----------
+
+// cronState 是 -cron-state 状态文件的内容：上一轮各域名的判定
+type cronState struct {
+	RunID    string            `json:"run_id"`
+	Finished time.Time         `json:"finished_at"`
+	Verdicts map[string]string `json:"verdicts"` // 域名 → clean / polluted / rebinding
+}
+
+// loadCronState 读取状态文件；文件不存在（第一次运行）时返回 nil
+func loadCronState(path string) (*cronState, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取状态文件失败: %w", err)
+	}
+	var st cronState
+	if err := json.Unmarshal(data, &st); err != nil {
+		return nil, fmt.Errorf("解析状态文件 %s 失败: %w", path, err)
+	}
+	return &st, nil
+}
+
+// cronVerdict 返回用于比较的判定：使用去抖后的正式判定（见 flap.go），避免间歇性注入使每轮都产生报告
+func cronVerdict(r DomainResult) string {
+	if r.Rebinding {
+		return "rebinding"
+	}
+	return debouncedVerdict(r)
+}
+
+// cronChanges 列出判定与上一轮不同的域名，以及新增、移除的域名；没有状态文件时返回 nil
+func cronChanges(prev *cronState, results []DomainResult) []string {
+	if prev == nil {
+		return nil
+	}
+	var changes []string
+	seen := make(map[string]bool, len(results))
+	for _, r := range results {
+		seen[r.Domain] = true
+		now := cronVerdict(r)
+		before, ok := prev.Verdicts[r.Domain]
+		switch {
+		case !ok:
+			changes = append(changes, fmt.Sprintf("%s: 新增（%s）", r.Domain, now))
+		case before != now:
+			changes = append(changes, fmt.Sprintf("%s: %s → %s", r.Domain, before, now))
+		}
+	}
+	for domain, before := range prev.Verdicts {
+		if !seen[domain] {
+			changes = append(changes, fmt.Sprintf("%s: 已移除（原为 %s）", domain, before))
+		}
+	}
+	sort.Strings(changes)
+	return changes
+}
+
+// saveCronState 原子地写入状态文件（先写临时文件再重命名），中途被中断也不会留下损坏的状态文件
+func saveCronState(path string, run runInfo, results []DomainResult) error {
+	st := cronState{RunID: run.ID, Finished: time.Now(), Verdicts: make(map[string]string, len(results))}
+	for _, r := range results {
+		st.Verdicts[r.Domain] = cronVerdict(r)
+	}
+	data, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("写入状态文件失败: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("写入状态文件失败: %w", err)
+	}
+	return nil
+}
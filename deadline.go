@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ---------- 检测时限（-run-deadline / -domain-budget） ----------
+
+type runContextKey struct{}
+
+// withRunContext 在 ctx 中记录整轮检测的上下文。多个域名共享的工作（如合并的 API 查询）使用它，
+// 只受 -run-deadline 约束，不随发起该工作的域名超出 -domain-budget 而取消
+func withRunContext(ctx context.Context) context.Context {
+	return context.WithValue(ctx, runContextKey{}, ctx)
+}
+
+// runContextFrom 返回 withRunContext 记录的整轮上下文，没有时返回 ctx 本身
+func runContextFrom(ctx context.Context) context.Context {
+	if run, ok := ctx.Value(runContextKey{}).(context.Context); ok {
+		return run
+	}
+	return ctx
+}
+
+// withinBudget 在 -domain-budget 与 -run-deadline 的时限内执行 check。超出时限时不再等待，
+// 直接返回检测超时的结果；check 收到取消后不再发出新的 API 请求，进行中的请求仍受各自的超时限制
+func (c *checker) withinBudget(ctx context.Context, dc DomainConfig, check func(context.Context) DomainResult) DomainResult {
+	if c.budget <= 0 && c.deadline <= 0 {
+		return check(ctx)
+	}
+	dctx := ctx
+	if c.budget > 0 {
+		var cancel context.CancelFunc
+		dctx, cancel = context.WithTimeout(ctx, c.budget)
+		defer cancel()
+	}
+	done := make(chan DomainResult, 1)
+	go func() { done <- check(dctx) }()
+	select {
+	case res := <-done:
+		return res
+	case <-dctx.Done():
+	}
+	c.stats.IncTimeout()
+	summary := fmt.Sprintf("检测超时: 超出单个域名时限 -domain-budget %s", c.budget)
+	switch err := ctx.Err(); {
+	case errors.Is(err, context.DeadlineExceeded) && c.deadline > 0:
+		summary = fmt.Sprintf("检测超时: 超出整轮时限 -run-deadline %s", c.deadline)
+	case err != nil:
+		summary = "检测已取消"
+	}
+	return DomainResult{
+		Domain:   dc.Name,
+		Expected: dc.ExpectedLlcs,
+		Summary:  summary,
+		Failed:   true,
+	}
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// ---------- API 响应解码 ----------
+
+// 响应格式，见 ProviderConfig.Format
+const (
+	formatJSON = "json"
+	formatXML  = "xml"
+	formatCSV  = "csv"
+	formatText = "text"
+)
+
+// decodeResponse 按格式将响应体解码为与 JSON 相同的通用结构（map[string]interface{}、[]interface{}、string、float64），
+// 以便统一用 FieldMapping 的路径提取字段
+func decodeResponse(format string, body []byte, delimiter string, header bool) (interface{}, error) {
+	switch format {
+	case "", formatJSON:
+		var raw interface{}
+		if err := json.Unmarshal(body, &raw); err != nil {
+			return nil, fmt.Errorf("JSON 解析失败: %w", err)
+		}
+		return raw, nil
+	case formatXML:
+		raw, err := decodeXML(body)
+		if err != nil {
+			return nil, fmt.Errorf("XML 解析失败: %w", err)
+		}
+		return raw, nil
+	case formatCSV:
+		raw, err := decodeCSV(body, delimiter, header)
+		if err != nil {
+			return nil, fmt.Errorf("CSV 解析失败: %w", err)
+		}
+		return raw, nil
+	case formatText:
+		return decodeText(body), nil
+	}
+	return nil, fmt.Errorf("不支持的响应格式 %q", format)
+}
+
+// validateFormat 检查 providers 中的 format 与 delimiter
+func validateFormat(format, delimiter string) error {
+	switch format {
+	case "", formatJSON, formatXML, formatCSV, formatText:
+	default:
+		return fmt.Errorf("format 只能是 json、xml、csv 或 text，而不是 %q", format)
+	}
+	if delimiter != "" && utf8.RuneCountInString(delimiter) != 1 {
+		return fmt.Errorf("delimiter 必须是单个字符，而不是 %q", delimiter)
+	}
+	return nil
+}
+
+// decodeXML 将 XML 文档转换为通用结构，结果对应根元素的内容（路径中不包含根元素名）：
+// 子元素按名称成为对象键，同名子元素合并为数组，属性以 "@名称" 为键，
+// 只有文本的元素成为字符串，同时有子元素和文本时文本以 "#text" 为键
+func decodeXML(body []byte) (interface{}, error) {
+	dec := xml.NewDecoder(bytes.NewReader(body))
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return nil, fmt.Errorf("没有根元素")
+		}
+		if err != nil {
+			return nil, err
+		}
+		if start, ok := tok.(xml.StartElement); ok {
+			return decodeXMLElement(dec, start)
+		}
+	}
+}
+
+func decodeXMLElement(dec *xml.Decoder, start xml.StartElement) (interface{}, error) {
+	m := make(map[string]interface{})
+	for _, a := range start.Attr {
+		m["@"+a.Name.Local] = a.Value
+	}
+	var text strings.Builder
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			child, err := decodeXMLElement(dec, t)
+			if err != nil {
+				return nil, err
+			}
+			name := t.Name.Local
+			switch prev := m[name].(type) {
+			case nil:
+				m[name] = child
+			case []interface{}:
+				m[name] = append(prev, child)
+			default:
+				m[name] = []interface{}{prev, child}
+			}
+		case xml.CharData:
+			text.Write(t)
+		case xml.EndElement:
+			s := strings.TrimSpace(text.String())
+			if len(m) == 0 {
+				return s, nil
+			}
+			if s != "" {
+				m["#text"] = s
+			}
+			return m, nil
+		}
+	}
+}
+
+// decodeCSV 解析第一条数据记录。header 为 true 时第一行是列名，结果为以列名为键的对象；
+// 否则结果为字段数组，用 [n] 取第 n 列（从 0 开始）
+func decodeCSV(body []byte, delimiter string, header bool) (interface{}, error) {
+	r := csv.NewReader(bytes.NewReader(body))
+	r.FieldsPerRecord = -1
+	r.TrimLeadingSpace = true
+	if delimiter != "" {
+		r.Comma, _ = utf8.DecodeRuneInString(delimiter)
+	}
+	first, err := r.Read()
+	if err == io.EOF {
+		return nil, fmt.Errorf("响应为空")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !header {
+		return stringsToValues(first), nil
+	}
+	row, err := r.Read()
+	if err == io.EOF {
+		return nil, fmt.Errorf("只有列名，没有数据行")
+	}
+	if err != nil {
+		return nil, err
+	}
+	m := make(map[string]interface{}, len(first))
+	for i, name := range first {
+		if i < len(row) {
+			m[strings.TrimSpace(name)] = strings.TrimSpace(row[i])
+		}
+	}
+	return m, nil
+}
+
+func stringsToValues(fields []string) []interface{} {
+	out := make([]interface{}, len(fields))
+	for i, f := range fields {
+		out[i] = strings.TrimSpace(f)
+	}
+	return out
+}
+
+// decodeText 解析每行一个 "键=值" 或 "键: 值" 的纯文本响应，忽略空行和 # 开头的注释。
+// 整个响应只有一行且不含分隔符时（如只返回运营商名称的接口），该行以 "value" 为键。
+func decodeText(body []byte) map[string]interface{} {
+	m := make(map[string]interface{})
+	var plain []string
+	for _, line := range strings.Split(string(body), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if i := strings.Index(line, "="); i > 0 {
+			m[strings.TrimSpace(line[:i])] = strings.TrimSpace(line[i+1:])
+			continue
+		}
+		if i := strings.Index(line, ": "); i > 0 {
+			m[strings.TrimSpace(line[:i])] = strings.TrimSpace(line[i+2:])
+			continue
+		}
+		plain = append(plain, line)
+	}
+	if len(m) == 0 && len(plain) == 1 {
+		m["value"] = plain[0]
+	}
+	return m
+}
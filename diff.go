@@ -0,0 +1,201 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+)
+
+// ---------- 结果比较（dnscheck diff） ----------
+
+// diffJSON 是 dnscheck diff 的输出：只列出有变化的域名
+type diffJSON struct {
+	Old          string       `json:"old_run_id"`
+	New          string       `json:"new_run_id"`
+	Regressions  int          `json:"regressions"`
+	Improvements int          `json:"improvements"`
+	Domains      []domainDiff `json:"domains"`
+}
+
+// 域名在两次结果之间的变化类型
+const (
+	diffChanged = "changed"
+	diffAdded   = "added"   // 只出现在新结果中
+	diffRemoved = "removed" // 只出现在旧结果中
+)
+
+// domainDiff 是单个域名的变化。Regression 表示严重程度上升（如 clean 变为 polluted），
+// 新增的域名按原严重程度为 ok 计算
+type domainDiff struct {
+	Domain       string   `json:"domain"`
+	Change       string   `json:"change"`
+	FromVerdict  string   `json:"from_verdict,omitempty"`
+	ToVerdict    string   `json:"to_verdict,omitempty"`
+	FromSeverity string   `json:"from_severity,omitempty"`
+	ToSeverity   string   `json:"to_severity,omitempty"`
+	AddedIPs     []string `json:"added_ips,omitempty"`
+	RemovedIPs   []string `json:"removed_ips,omitempty"`
+	Regression   bool     `json:"regression"`
+}
+
+// runDiff 实现 `dnscheck diff old.json new.json`：比较两次 -format json 的结果，
+// 以 JSON 输出差异。有退化时退出码为 1，无退化为 0，参数或文件错误为 2，便于在变更解析器或网络前后作为门禁
+func runDiff(args []string) {
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	fs.BoolVar(anycastMode, "anycast", false, "同一 ASN（未知时为同一 LLC）内的 IP 变化不列出")
+	_ = fs.Parse(args)
+	if fs.NArg() != 2 {
+		fmt.Fprintln(os.Stderr, "用法: dnscheck diff <旧结果.json> <新结果.json>")
+		os.Exit(2)
+	}
+	oldRun, err := loadRunJSON(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(2)
+	}
+	newRun, err := loadRunJSON(fs.Arg(1))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(2)
+	}
+
+	d := diffRuns(oldRun, newRun)
+	data, _ := json.MarshalIndent(d, "", "  ")
+	fmt.Println(string(data))
+	if d.Regressions > 0 {
+		os.Exit(1)
+	}
+}
+
+// loadRunJSON 读取 -format json 的报告或运行后钩子的输入
+func loadRunJSON(path string) (runJSON, error) {
+	var run runJSON
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return run, fmt.Errorf("读取结果文件失败: %w", err)
+	}
+	if err := json.Unmarshal(data, &run); err != nil {
+		return run, fmt.Errorf("解析结果文件 %s 失败: %w", path, err)
+	}
+	return run, nil
+}
+
+// diffRuns 按域名比较两次结果
+func diffRuns(oldRun, newRun runJSON) diffJSON {
+	d := diffJSON{Old: oldRun.RunID, New: newRun.RunID, Domains: []domainDiff{}}
+	before := make(map[string]domainJSON, len(oldRun.Results))
+	for _, r := range oldRun.Results {
+		before[r.Domain] = r
+	}
+	seen := make(map[string]bool, len(newRun.Results))
+	for _, r := range newRun.Results {
+		seen[r.Domain] = true
+		prev, ok := before[r.Domain]
+		if !ok {
+			dd := domainDiff{Domain: r.Domain, Change: diffAdded, ToVerdict: r.verdict(), ToSeverity: r.Severity, AddedIPs: r.ipList()}
+			dd.Regression = severityRank(r.Severity) > severityOK
+			d.add(dd)
+			continue
+		}
+		dd := domainDiff{
+			Domain:     r.Domain,
+			Change:     diffChanged,
+			AddedIPs:   r.ipsOutside(prev),
+			RemovedIPs: prev.ipsOutside(r),
+		}
+		if prev.verdict() != r.verdict() || prev.Severity != r.Severity {
+			dd.FromVerdict, dd.ToVerdict = prev.verdict(), r.verdict()
+			dd.FromSeverity, dd.ToSeverity = prev.Severity, r.Severity
+		}
+		if dd.FromVerdict == "" && len(dd.AddedIPs) == 0 && len(dd.RemovedIPs) == 0 {
+			continue
+		}
+		from, to := severityRank(prev.Severity), severityRank(r.Severity)
+		dd.Regression = to > from
+		if to < from {
+			d.Improvements++
+		}
+		d.add(dd)
+	}
+	for _, r := range oldRun.Results {
+		if !seen[r.Domain] {
+			d.add(domainDiff{Domain: r.Domain, Change: diffRemoved, FromVerdict: r.verdict(), FromSeverity: r.Severity, RemovedIPs: r.ipList()})
+		}
+	}
+	sort.Slice(d.Domains, func(i, j int) bool { return d.Domains[i].Domain < d.Domains[j].Domain })
+	return d
+}
+
+func (d *diffJSON) add(dd domainDiff) {
+	if dd.Regression {
+		d.Regressions++
+	}
+	d.Domains = append(d.Domains, dd)
+}
+
+// verdict 返回与 verdictOf 相同的判定名称
+func (r domainJSON) verdict() string {
+	switch {
+	case r.Rebinding:
+		return "rebinding"
+	case r.Polluted:
+		return "polluted"
+	case r.Verdict == verdictError:
+		return verdictError
+	}
+	return "clean"
+}
+
+// ipList 返回结果中的全部 IP
+func (r domainJSON) ipList() []string {
+	ips := make([]string, 0, len(r.IPs))
+	for _, ip := range r.IPs {
+		ips = append(ips, ip.IP)
+	}
+	return ips
+}
+
+// ipsOutside 返回 r 中不在 other 里的 IP。启用 -anycast 时，
+// 与 other 中某个 IP 属于同一逻辑应答（见 anycast.go）的 IP 不算在内
+func (r domainJSON) ipsOutside(other domainJSON) []string {
+	if !*anycastMode {
+		return stringsMissing(r.ipList(), other.ipList())
+	}
+	keys := make(map[string]bool, len(other.IPs))
+	for _, ip := range other.IPs {
+		keys[answerKey(ip.ASN, ip.LLC, ip.IP)] = true
+	}
+	var out []string
+	for _, ip := range r.IPs {
+		if !keys[answerKey(ip.ASN, ip.LLC, ip.IP)] {
+			out = append(out, ip.IP)
+		}
+	}
+	return out
+}
+
+// severityRank 将结果文件中的严重程度转换为可比较的等级；无法识别时按 ok 处理
+func severityRank(name string) severity {
+	sev, err := parseSeverity(name)
+	if err != nil {
+		return severityOK
+	}
+	return sev
+}
+
+// stringsMissing 返回 a 中不在 b 里的元素，保持 a 的顺序
+func stringsMissing(a, b []string) []string {
+	in := make(map[string]bool, len(b))
+	for _, s := range b {
+		in[s] = true
+	}
+	var out []string
+	for _, s := range a {
+		if !in[s] {
+			out = append(out, s)
+		}
+	}
+	return out
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// ---------- 原始 DNS 查询 ----------
+
+// newDNSQuery 构造一个带随机 ID、期望递归的单问题查询
+func newDNSQuery(name string, qtype dnsmessage.Type) (dnsmessage.Message, error) {
+	qname, err := dnsmessage.NewName(dnsFQDN(name))
+	if err != nil {
+		return dnsmessage.Message{}, fmt.Errorf("无效的查询名 %q: %w", name, err)
+	}
+	return dnsmessage.Message{
+		Header:    dnsmessage.Header{ID: randomDNSID(), RecursionDesired: true},
+		Questions: []dnsmessage.Question{{Name: qname, Type: qtype, Class: dnsmessage.ClassINET}},
+	}, nil
+}
+
+// exchangeUDP 通过 UDP 向 server（host:port）发送查询并等待 ID 匹配的响应。
+// 来源地址不是 server 或 ID 不匹配的报文会被忽略，直到超时。
+func exchangeUDP(server string, query dnsmessage.Message, timeout time.Duration) (*dnsmessage.Message, error) {
+	raddr, err := net.ResolveUDPAddr("udp", server)
+	if err != nil {
+		return nil, err
+	}
+	packed, err := query.Pack()
+	if err != nil {
+		return nil, fmt.Errorf("编码 DNS 查询失败: %w", err)
+	}
+	conn, err := net.ListenUDP("udp", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if err := conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return nil, err
+	}
+	if _, err := conn.WriteToUDP(packed, raddr); err != nil {
+		return nil, err
+	}
+	dnsCapture.Record("udp", conn.LocalAddr(), raddr, true, packed)
+
+	buf := make([]byte, 65535)
+	for {
+		n, from, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			return nil, err
+		}
+		// 来源地址不对的报文也记录下来，伪造来源的注入报文正是需要保留的证据
+		dnsCapture.Record("udp", conn.LocalAddr(), from, false, append([]byte(nil), buf[:n]...))
+		if !from.IP.Equal(raddr.IP) || from.Port != raddr.Port {
+			continue
+		}
+		var resp dnsmessage.Message
+		if err := resp.Unpack(buf[:n]); err != nil {
+			continue
+		}
+		if resp.Header.ID != query.Header.ID || !resp.Header.Response {
+			continue
+		}
+		return &resp, nil
+	}
+}
+
+// exchangeTCP 通过 TCP 发送查询，用于 UDP 响应被截断（TC 位）的情况
+func exchangeTCP(server string, query dnsmessage.Message, timeout time.Duration) (*dnsmessage.Message, error) {
+	packed, err := query.Pack()
+	if err != nil {
+		return nil, fmt.Errorf("编码 DNS 查询失败: %w", err)
+	}
+	conn, err := net.DialTimeout("tcp", server, timeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if err := conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return nil, err
+	}
+	framed := make([]byte, 2+len(packed))
+	binary.BigEndian.PutUint16(framed, uint16(len(packed)))
+	copy(framed[2:], packed)
+	if _, err := conn.Write(framed); err != nil {
+		return nil, err
+	}
+	dnsCapture.Record("tcp", conn.LocalAddr(), conn.RemoteAddr(), true, packed)
+	var length [2]byte
+	if _, err := io.ReadFull(conn, length[:]); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, binary.BigEndian.Uint16(length[:]))
+	if _, err := io.ReadFull(conn, buf); err != nil {
+		return nil, err
+	}
+	dnsCapture.Record("tcp", conn.LocalAddr(), conn.RemoteAddr(), false, buf)
+	var resp dnsmessage.Message
+	if err := resp.Unpack(buf); err != nil {
+		return nil, fmt.Errorf("解析 DNS 响应失败: %w", err)
+	}
+	if resp.Header.ID != query.Header.ID {
+		return nil, fmt.Errorf("DNS 响应 ID 不匹配")
+	}
+	return &resp, nil
+}
+
+// exchangeDNS 先用 UDP 查询，响应被截断时改用 TCP 重试
+func exchangeDNS(server string, query dnsmessage.Message, timeout time.Duration) (*dnsmessage.Message, error) {
+	resp, err := exchangeUDP(server, query, timeout)
+	if err != nil || !resp.Header.Truncated {
+		return resp, err
+	}
+	return exchangeTCP(server, query, timeout)
+}
+
+// dnsFQDN 确保名称以 . 结尾
+func dnsFQDN(name string) string {
+	if len(name) == 0 || name[len(name)-1] != '.' {
+		return name + "."
+	}
+	return name
+}
+
+// randomDNSID 返回随机的 16 位查询 ID
+func randomDNSID() uint16 {
+	var b [2]byte
+	fillRandom(b[:])
+	return binary.BigEndian.Uint16(b[:])
+}
+
+// fillRandom 用密码学安全的随机数填充 b
+func fillRandom(b []byte) {
+	_, _ = rand.Read(b)
+}
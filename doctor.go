@@ -0,0 +1,233 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"runtime"
+	"strings"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// ---------- 运行环境诊断（doctor 子命令） ----------
+
+// 诊断结论
+const (
+	doctorOK   = "正常"
+	doctorWarn = "警告"
+	doctorFail = "失败"
+)
+
+// doctorProbeAddrs 是不提供 DNS 服务的文档保留地址（RFC 5737），正常情况下发往它们的查询不会有任何响应；
+// 收到响应说明 UDP/53 流量被中间设备透明劫持
+var doctorProbeAddrs = []string{"192.0.2.1:53", "198.51.100.1:53", "203.0.113.1:53"}
+
+// doctorPublicResolvers 用于检查是否能直接访问公共 DNS
+var doctorPublicResolvers = []string{"1.1.1.1:53", "8.8.8.8:53", "223.5.5.5:53"}
+
+// doctor 收集各项检查的结果
+type doctor struct {
+	warnings int
+	failures int
+}
+
+func (d *doctor) report(status, format string, a ...interface{}) {
+	switch status {
+	case doctorWarn:
+		d.warnings++
+	case doctorFail:
+		d.failures++
+	}
+	fmt.Printf("  [%s] %s\n", status, fmt.Sprintf(format, a...))
+}
+
+// runDoctor 实现 `dnscheck doctor`：在正式检测前检查运行环境，
+// 包括配置文件、API 可达性、系统解析器、UDP/53 是否被透明劫持以及应答是否有伪造特征。存在失败项时以状态码 1 退出。
+func runDoctor(args []string) {
+	fs := flag.NewFlagSet("doctor", flag.ExitOnError)
+	cfgPath := fs.String("f", flag.Lookup("f").DefValue, "配置文件路径")
+	api := fs.String("api", flag.Lookup("api").DefValue, "IP 信息查询 API 地址（支持多个，用逗号分隔）")
+	probeTimeout := fs.Duration("timeout", 3*time.Second, "每项检查的超时时间")
+	geoip := fs.String("geoip", flag.Lookup("geoip").DefValue, "本地 IP 国家数据库（CSV）")
+	_ = fs.Parse(args)
+
+	d := &doctor{}
+
+	fmt.Println("配置文件:")
+	cfg := d.checkConfig(*cfgPath)
+
+	fmt.Println("IP 信息 API:")
+	apis, providers := splitList(*api), map[string]*ProviderConfig(nil)
+	if cfg != nil {
+		providers = cfg.providers
+		if len(cfg.Providers) > 0 && !flagSet(fs, "api") {
+			apis = providerURLs(cfg.Providers)
+		}
+	}
+	d.checkAPIs(apis, providers, *probeTimeout)
+
+	fmt.Println("系统解析器:")
+	d.checkSystemResolver(*probeTimeout)
+
+	fmt.Println("公共 DNS 与 UDP/53 劫持:")
+	d.checkPublicResolvers(*probeTimeout)
+	d.checkInterception(*probeTimeout)
+
+	fmt.Println("0x20 大小写随机化与报文一致性:")
+	d.checkSpoofing(*probeTimeout)
+
+	fmt.Println("GeoIP 数据库:")
+	d.checkGeoDB(*geoip)
+
+	fmt.Printf("\n诊断完成: %d 个失败，%d 个警告\n", d.failures, d.warnings)
+	if d.failures > 0 {
+		os.Exit(1)
+	}
+}
+
+// checkGeoDB 检查 -geoip 数据库能否加载。未指定时只使用在线 API 的国家信息
+func (d *doctor) checkGeoDB(path string) {
+	if path == "" {
+		d.report(doctorOK, "未指定 -geoip，国家不符提示只使用 API 返回的国家，跳过")
+		return
+	}
+	db, err := loadGeoDB(path)
+	if err != nil {
+		d.report(doctorFail, "%v", err)
+		return
+	}
+	if len(db.ranges) == 0 {
+		d.report(doctorWarn, "%s 中没有任何地址范围", path)
+		return
+	}
+	d.report(doctorOK, "%s: 共 %d 个地址范围", path, len(db.ranges))
+}
+
+// checkConfig 加载并校验配置文件，失败时返回 nil
+func (d *doctor) checkConfig(path string) *Config {
+	cfg, err := loadConfigWithFallback(path)
+	if err != nil {
+		d.report(doctorFail, "%v", err)
+		return nil
+	}
+	d.report(doctorOK, "共 %d 个域名", len(cfg.Domains))
+	return cfg
+}
+
+// checkAPIs 用一个固定 IP 依次查询每个 API 端点，报告耗时和返回的 LLC
+func (d *doctor) checkAPIs(apis []string, providers map[string]*ProviderConfig, timeout time.Duration) {
+	if len(apis) == 0 {
+		d.report(doctorFail, "没有配置 API 地址")
+		return
+	}
+	up := 0
+	for _, baseURL := range apis {
+		start := time.Now()
+		info, err := queryIPInfoFromAPI(context.Background(), "1.1.1.1", baseURL, providers[baseURL], timeout)
+		elapsed := time.Since(start).Round(time.Millisecond)
+		if err != nil {
+			d.report(doctorWarn, "%s 不可用（%s）: %v", baseURL, elapsed, err)
+			continue
+		}
+		up++
+		extra := ""
+		if info.ASN == 0 {
+			extra = "，未返回 ASN（forbidden_asns 和 rules 中的 asn 条件无法生效）"
+		}
+		d.report(doctorOK, "%s 可用（%s），LLC=%s%s", baseURL, elapsed, info.LLC, extra)
+	}
+	if up == 0 {
+		d.report(doctorFail, "所有 API 端点均不可用")
+	}
+}
+
+// checkSystemResolver 列出系统配置的解析器，并用系统解析器解析一个常见域名
+func (d *doctor) checkSystemResolver(timeout time.Duration) {
+	if servers := systemNameservers(); len(servers) > 0 {
+		d.report(doctorOK, "/etc/resolv.conf 中的解析器: %s", strings.Join(servers, ", "))
+	} else if runtime.GOOS != "windows" {
+		d.report(doctorWarn, "无法从 /etc/resolv.conf 读取解析器")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	var r net.Resolver
+	start := time.Now()
+	ips, err := r.LookupIP(ctx, "ip4", "example.com")
+	if err != nil {
+		d.report(doctorFail, "系统解析器无法解析 example.com: %v", err)
+		return
+	}
+	d.report(doctorOK, "系统解析器可用，example.com 解析到 %d 个地址（%s）", len(ips), time.Since(start).Round(time.Millisecond))
+}
+
+// systemNameservers 读取 /etc/resolv.conf 中的 nameserver
+func systemNameservers() []string {
+	f, err := os.Open("/etc/resolv.conf")
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	var servers []string
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) >= 2 && fields[0] == "nameserver" {
+			servers = append(servers, fields[1])
+		}
+	}
+	return servers
+}
+
+// checkPublicResolvers 检查能否直接通过 UDP/53 访问公共 DNS
+func (d *doctor) checkPublicResolvers(timeout time.Duration) {
+	reachable := 0
+	for _, server := range doctorPublicResolvers {
+		query, err := newDNSQuery("example.com", dnsmessage.TypeA)
+		if err != nil {
+			d.report(doctorFail, "%v", err)
+			return
+		}
+		start := time.Now()
+		resp, err := exchangeUDP(server, query, timeout)
+		if err != nil {
+			d.report(doctorWarn, "%s 无响应: %v", server, err)
+			continue
+		}
+		reachable++
+		elapsed := time.Since(start).Round(time.Millisecond)
+		if resp.Header.RCode != dnsmessage.RCodeSuccess || len(resp.Answers) == 0 {
+			// example.com 必然存在，公共 DNS 返回错误或空应答说明响应很可能被篡改
+			d.report(doctorWarn, "%s 可达（%s），但 example.com 返回 %s、%d 条应答，响应可能被篡改", server, elapsed, resp.Header.RCode, len(resp.Answers))
+			continue
+		}
+		d.report(doctorOK, "%s 可达（%s）", server, elapsed)
+	}
+	if reachable == 0 {
+		d.report(doctorWarn, "无法直接访问任何公共 DNS，UDP/53 可能被防火墙拦截")
+	}
+}
+
+// checkInterception 向不提供 DNS 服务的保留地址发送查询，收到响应即说明存在透明劫持
+func (d *doctor) checkInterception(timeout time.Duration) {
+	intercepted := 0
+	for _, addr := range doctorProbeAddrs {
+		query, err := newDNSQuery("example.com", dnsmessage.TypeA)
+		if err != nil {
+			d.report(doctorFail, "%v", err)
+			return
+		}
+		if _, err := exchangeUDP(addr, query, timeout); err == nil {
+			intercepted++
+			d.report(doctorFail, "%s 不提供 DNS 服务却返回了响应，UDP/53 被透明劫持", addr)
+		}
+	}
+	if intercepted == 0 {
+		d.report(doctorOK, "未发现 UDP/53 透明劫持（%d 个保留地址均无响应）", len(doctorProbeAddrs))
+	}
+}
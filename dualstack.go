@@ -0,0 +1,223 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// ---------- 双栈一致性（-dual-stack） ----------
+
+// 双栈比较结论
+const (
+	dualConsistent = "consistent" // 两个协议族的判定相同
+	dualMismatch   = "mismatch"   // 一个协议族指向预期的提供方，另一个不是，常见的部分污染
+	dualNoAAAA     = "no_aaaa"    // 没有 AAAA 记录，无法比较
+	dualError      = "error"      // AAAA 查询失败
+)
+
+// dualCheck 是同一域名 A 记录与 AAAA 记录的判定比较。Owners 为各协议族 IP 的归属（ASN 与 LLC）
+type dualCheck struct {
+	Verdict string   `json:"verdict"`
+	IPv4    string   `json:"ipv4"`           // A 记录的判定：clean 或 polluted
+	IPv6    string   `json:"ipv6,omitempty"` // AAAA 记录的判定，没有 AAAA 记录或查询失败时为空
+	Owners4 []string `json:"ipv4_owners,omitempty"`
+	Owners6 []string `json:"ipv6_owners,omitempty"`
+	IPs     []dualIP `json:"ipv6_ips,omitempty"`
+	Error   string   `json:"error,omitempty"`
+}
+
+// dualIP 是一个 AAAA 记录的 IP 及其归属
+type dualIP struct {
+	IP      string `json:"ip"`
+	LLC     string `json:"llc,omitempty"`
+	ASN     uint32 `json:"asn,omitempty"`
+	Matched bool   `json:"matched"`
+	Error   string `json:"error,omitempty"`
+}
+
+func (d dualCheck) String() string {
+	names := map[string]string{
+		dualConsistent: "一致",
+		dualMismatch:   "不一致",
+		dualNoAAAA:     "没有 AAAA 记录",
+		dualError:      "AAAA 查询失败",
+	}
+	family := func(verdict string, owners []string) string {
+		s := "正常"
+		if verdict == "polluted" {
+			s = "不符合预期"
+		}
+		if len(owners) > 0 {
+			s += "（" + strings.Join(owners, "、") + "）"
+		}
+		return s
+	}
+	str := fmt.Sprintf("（%s）: IPv4 %s", names[d.Verdict], family(d.IPv4, d.Owners4))
+	switch {
+	case d.Error != "":
+		str += "；AAAA 查询失败: " + d.Error
+	case d.IPv6 != "":
+		ips := make([]string, 0, len(d.IPs))
+		for _, ip := range d.IPs {
+			ips = append(ips, ip.IP)
+		}
+		str += fmt.Sprintf("；IPv6 %s: %s", family(d.IPv6, d.Owners6), strings.Join(ips, ", "))
+	}
+	return str
+}
+
+// checkDualStack 在 -dual-stack 时解析域名的 AAAA 记录，按与 A 记录相同的预期判断每个 IPv6 地址，
+// 比较两个协议族的判定。A 记录正常而 AAAA 记录不符合预期时判定为污染（使用 IPv6 的客户端会被引到别处）；
+// A 记录解析失败的域名不比较
+func (c *checker) checkDualStack(ctx context.Context, dc DomainConfig, res *DomainResult) {
+	if !c.dual || res.ASCIIDomain == "" || len(res.IPResults) == 0 {
+		return
+	}
+	d := &dualCheck{IPv4: verdictOf(*res), Owners4: ipOwners(*res)}
+	if d.IPv4 == "rebinding" {
+		d.IPv4 = "polluted"
+	}
+	res.DualStack = d
+
+	dnsCtx, cancel := context.WithTimeout(ctx, orDefault(dc.DNSTimeout, c.dnsTimeout))
+	defer cancel()
+	ips, err := lookupIPv6(dnsCtx, c.resolver, res.ASCIIDomain)
+	switch {
+	case err != nil:
+		d.Verdict, d.Error = dualError, err.Error()
+		return
+	case len(ips) == 0:
+		d.Verdict = dualNoAAAA
+		return
+	}
+
+	// 与 A 记录相同的判定流程，只是不计入自适应并发的统计
+	var v6 DomainResult
+	if len(dc.pinned) > 0 {
+		ipResults := pinnedIPResults(dc.pinned, ips)
+		markPinnedPrivate(ipResults, dc.AllowPrivate)
+		v6 = aggregatePinnedResult(dc.Name, dc.ExpectedIPs, ipResults)
+	} else {
+		ipResults, _, _, _ := c.lookupIPs(ctx, dc, ips, orDefault(dc.APITimeout, c.apiTimeout))
+		v6 = aggregateDomainResult(dc.Name, dc.ExpectedLlcs, dc.ExpectedCIDRs, dc.Rules, ipResults, c.strict)
+	}
+	applyBlocklist(&v6, dc.forbidden)
+	applyPrivateIPs(&v6)
+	d.IPv6, d.Owners6 = "clean", ipOwners(v6)
+	if v6.IsPolluted {
+		d.IPv6 = "polluted"
+	}
+	for _, ip := range v6.IPResults {
+		j := dualIP{IP: ip.IP, LLC: ip.ActualLLC, ASN: ip.ASN, Matched: v6.ipMatched(ip)}
+		if ip.Error != nil {
+			j.Error = ip.Error.Error()
+		}
+		d.IPs = append(d.IPs, j)
+	}
+
+	d.Verdict = dualConsistent
+	if d.IPv4 != d.IPv6 {
+		d.Verdict = dualMismatch
+	}
+	if d.IPv4 == "clean" && d.IPv6 == "polluted" {
+		res.IsPolluted = true
+		res.Summary = fmt.Sprintf("A 记录符合预期，但 AAAA 记录不符合预期（%s）", v6.Summary)
+	}
+}
+
+// ipOwners 返回各 IP 的归属，去重后按出现顺序排列，如 "AS15169 GOOGLE"；
+// 没有归属信息的 IP 按本地分类的来源显示
+func ipOwners(res DomainResult) []string {
+	seen := make(map[string]bool)
+	var owners []string
+	for _, ip := range res.IPResults {
+		var owner string
+		switch {
+		case ip.ASN != 0 && ip.ActualLLC != "":
+			owner = fmt.Sprintf("AS%d %s", ip.ASN, ip.ActualLLC)
+		case ip.ActualLLC != "":
+			owner = ip.ActualLLC
+		case ip.ASN != 0:
+			owner = fmt.Sprintf("AS%d", ip.ASN)
+		case localSourceNames[ip.Source] != "":
+			owner = localSourceNames[ip.Source]
+		case len(res.PinnedIPs) > 0 && !ip.NotPinned:
+			owner = "固定 IP"
+		default:
+			continue
+		}
+		if !seen[owner] {
+			seen[owner] = true
+			owners = append(owners, owner)
+		}
+	}
+	return owners
+}
+
+// lookupIPv6 解析 host 的 AAAA 记录。指定了递归解析器（或传输方式）时直接向其查询；
+// 多个解析器时依次尝试；系统解析器与迭代解析都使用系统解析器
+func lookupIPv6(ctx context.Context, r dnsResolver, host string) ([]net.IP, error) {
+	switch r := r.(type) {
+	case directResolver:
+		step, resp := rawQuery(ctx, "", r.server, dnsFQDN(host), dnsmessage.TypeAAAA)
+		return answerIPv6(r.server, step, resp)
+	case transportResolver:
+		step, resp := r.query(ctx, dnsFQDN(host), dnsmessage.TypeAAAA)
+		return answerIPv6(r.spec.String(), step, resp)
+	case *fanoutResolver:
+		var errs []string
+		for _, m := range r.members {
+			ips, err := lookupIPv6(ctx, m.dnsResolver, host)
+			if err == nil {
+				return ips, nil
+			}
+			errs = append(errs, fmt.Sprintf("%s: %v", m.name, err))
+		}
+		return nil, fmt.Errorf("所有解析器均失败: %s", strings.Join(errs, "；"))
+	}
+	var sys net.Resolver
+	if dnsCapture != nil {
+		sys.PreferGo, sys.Dial = true, captureDial
+	}
+	ips, err := sys.LookupIP(ctx, "ip6", host)
+	if dnsErr, ok := err.(*net.DNSError); ok && dnsErr.IsNotFound {
+		return nil, nil // 没有 AAAA 记录
+	}
+	return ips, err
+}
+
+// answerIPv6 提取 AAAA 记录；NXDOMAIN 与没有 AAAA 记录一样返回空列表
+func answerIPv6(server string, step resolveStep, resp *dnsmessage.Message) ([]net.IP, error) {
+	if step.Err != nil {
+		return nil, step.Err
+	}
+	switch resp.Header.RCode {
+	case dnsmessage.RCodeSuccess:
+	case dnsmessage.RCodeNameError:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("%s 返回 %s", server, resp.Header.RCode)
+	}
+	var ips []net.IP
+	for _, rr := range resp.Answers {
+		if a, ok := rr.Body.(*dnsmessage.AAAAResource); ok {
+			ips = append(ips, net.IP(append([]byte(nil), a.AAAA[:]...)))
+		}
+	}
+	return ips, nil
+}
+
+// countDualStackMismatch 统计双栈判定不一致的域名数
+func countDualStackMismatch(results []DomainResult) int {
+	n := 0
+	for _, r := range results {
+		if r.DualStack != nil && r.DualStack.Verdict == dualMismatch {
+			n++
+		}
+	}
+	return n
+}
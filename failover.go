@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// ---------- 备用解析器（-fallback-resolver） ----------
+
+// 单个解析器偶尔 SERVFAIL 或超时，不应直接把域名判定为污染。主解析器（-resolver）失败时依次查询备用解析器，
+// 采用第一个成功的应答，并记录最终由哪个解析器应答。与 -strategy sequential 不同，主解析器成功时从不查询备用解析器
+
+// failover 记录主解析器失败后依次查询备用解析器的过程
+type failover struct {
+	Primary  string         `json:"primary_error"`      // 主解析器的错误
+	Answers  []fanoutAnswer `json:"answers"`            // 依次查询的备用解析器，成功后不再继续
+	Answered string         `json:"answered,omitempty"` // 最终应答的备用解析器，全部失败时为空
+}
+
+func (f failover) String() string {
+	parts := make([]string, 0, len(f.Answers))
+	for _, a := range f.Answers {
+		if a.Error != "" {
+			parts = append(parts, fmt.Sprintf("%s → 失败: %s", a.Resolver, a.Error))
+		} else {
+			parts = append(parts, fmt.Sprintf("%s → %s（%.1fms）", a.Resolver, strings.Join(a.IPs, ", "), a.LatencyMs))
+		}
+	}
+	result := "备用解析器均失败"
+	if f.Answered != "" {
+		result = "由 " + f.Answered + " 应答"
+	}
+	return fmt.Sprintf("主解析器失败（%s），%s: %s", f.Primary, result, strings.Join(parts, "；"))
+}
+
+// newFallbackResolvers 解析逗号分隔的 -fallback-resolver，每一项的写法与单独使用 -resolver 时相同
+func newFallbackResolvers(spec string) ([]namedResolver, error) {
+	var list []namedResolver
+	for _, item := range splitList(spec) {
+		r, err := newDNSResolver(item, false, "")
+		if err != nil {
+			return nil, fmt.Errorf("-fallback-resolver: %w", err)
+		}
+		list = append(list, namedResolver{name: item, dnsResolver: r})
+	}
+	return list, nil
+}
+
+// resolveFallback 在主解析器失败（primaryErr）后依次查询备用解析器，每个解析器单独使用 timeout。
+// 返回第一个成功的应答及其查询记录；全部失败时返回原来的 trace 与合并后的错误
+func (c *checker) resolveFallback(ctx context.Context, host string, trace []resolveStep, primaryErr error, timeout time.Duration) ([]net.IP, []resolveStep, *failover, error) {
+	fo := &failover{Primary: primaryErr.Error()}
+	errs := []string{"主解析器: " + primaryErr.Error()}
+	for _, r := range c.backups {
+		fbCtx, cancel := context.WithTimeout(ctx, timeout)
+		start := time.Now()
+		ips, steps, err := r.LookupIPv4(fbCtx, host)
+		cancel()
+		ans := fanoutAnswer{Resolver: r.name, LatencyMs: float64(time.Since(start)) / float64(time.Millisecond)}
+		if err != nil {
+			ans.Error = err.Error()
+			fo.Answers = append(fo.Answers, ans)
+			errs = append(errs, fmt.Sprintf("%s: %v", r.name, err))
+			continue
+		}
+		for _, ip := range ips {
+			ans.IPs = append(ans.IPs, ip.String())
+		}
+		fo.Answers = append(fo.Answers, ans)
+		fo.Answered = r.name
+		return ips, steps, fo, nil
+	}
+	return nil, trace, fo, fmt.Errorf("主解析器与备用解析器均失败: %s", strings.Join(errs, "；"))
+}
+
+// countFailover 统计主解析器失败、由备用解析器应答的域名数
+func countFailover(results []DomainResult) int {
+	n := 0
+	for _, r := range results {
+		if r.Failover != nil && r.Failover.Answered != "" {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,287 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// ---------- 多解析器并发查询（-resolver 列表与 -strategy） ----------
+
+// 多个解析器的查询策略
+const (
+	strategyRace       = "race"       // 并发查询，采用最先成功的应答，不再等待其余解析器
+	strategyAll        = "all"        // 并发查询并等待全部应答，采用各应答 IP 的并集
+	strategySequential = "sequential" // 按顺序逐个查询，采用第一个成功的应答
+)
+
+// validateStrategy 检查 -strategy
+func validateStrategy(s string) error {
+	switch s {
+	case strategyRace, strategyAll, strategySequential:
+		return nil
+	}
+	return fmt.Errorf("无效的 -strategy 参数 %q（可选 race、all、sequential）", s)
+}
+
+// namedResolver 是解析器列表中的一项，name 为其在 -resolver 中的写法
+type namedResolver struct {
+	name string
+	dnsResolver
+}
+
+// fanoutResolver 按 strategy 查询多个解析器，由逗号分隔的 -resolver 创建
+type fanoutResolver struct {
+	strategy string
+	members  []namedResolver
+}
+
+// fanoutAnswer 是列表中一个解析器的应答
+type fanoutAnswer struct {
+	Resolver  string   `json:"resolver"`
+	IPs       []string `json:"ips,omitempty"`
+	LatencyMs float64  `json:"latency_ms,omitempty"`
+	Error     string   `json:"error,omitempty"`
+	Pending   bool     `json:"pending,omitempty"` // race 策略下采用最快应答时尚未返回，结果未知
+	Skipped   bool     `json:"skipped,omitempty"` // sequential 策略下前面的解析器已成功，没有查询
+}
+
+// fanoutResult 记录一次多解析器查询的全部应答；Fastest 为最先成功的解析器，判定使用的 IP 见 strategy
+type fanoutResult struct {
+	Strategy string         `json:"strategy"`
+	Fastest  string         `json:"fastest,omitempty"`
+	Answers  []fanoutAnswer `json:"answers"`
+}
+
+func (f fanoutResult) String() string {
+	parts := make([]string, 0, len(f.Answers))
+	for _, a := range f.Answers {
+		switch {
+		case a.Skipped:
+			parts = append(parts, a.Resolver+" → 未查询")
+		case a.Pending:
+			parts = append(parts, a.Resolver+" → 未等待")
+		case a.Error != "":
+			parts = append(parts, fmt.Sprintf("%s → 失败: %s", a.Resolver, a.Error))
+		default:
+			ips := strings.Join(a.IPs, ", ")
+			if ips == "" {
+				ips = "无 IPv4 地址"
+			}
+			parts = append(parts, fmt.Sprintf("%s → %s（%.1fms）", a.Resolver, ips, a.LatencyMs))
+		}
+	}
+	fastest := ""
+	if f.Fastest != "" {
+		fastest = "，最快 " + f.Fastest
+	}
+	return fmt.Sprintf("（%s%s）: %s", f.Strategy, fastest, strings.Join(parts, "；"))
+}
+
+// newFanoutResolver 为逗号分隔的 -resolver 创建多解析器查询；每一项与单独使用 -resolver 时相同
+func newFanoutResolver(spec string, qnameMin bool, strategy string) (*fanoutResolver, error) {
+	if err := validateStrategy(strategy); err != nil {
+		return nil, err
+	}
+	f := &fanoutResolver{strategy: strategy}
+	iterative := false
+	seen := make(map[string]bool)
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" || seen[item] {
+			continue
+		}
+		seen[item] = true
+		iterative = iterative || item == "iterative"
+		r, err := newDNSResolver(item, qnameMin && item == "iterative", "")
+		if err != nil {
+			return nil, err
+		}
+		f.members = append(f.members, namedResolver{name: item, dnsResolver: r})
+	}
+	if qnameMin && !iterative {
+		return nil, fmt.Errorf("-qname-min 需要配合 -resolver iterative 使用")
+	}
+	if len(f.members) < 2 {
+		return nil, fmt.Errorf("-resolver 列表至少需要两个不同的解析器")
+	}
+	return f, nil
+}
+
+// LookupIPv4 实现 dnsResolver，丢弃各解析器的应答记录
+func (f *fanoutResolver) LookupIPv4(ctx context.Context, host string) ([]net.IP, []resolveStep, error) {
+	ips, steps, _, err := f.Lookup(ctx, host)
+	return ips, steps, err
+}
+
+// memberAnswer 是一个解析器的查询结果
+type memberAnswer struct {
+	index int
+	ips   []net.IP
+	steps []resolveStep
+	err   error
+	took  time.Duration
+}
+
+// Lookup 按策略查询各解析器，返回判定使用的 IP、最快成功应答的查询记录以及全部应答。
+// 所有解析器都失败时返回错误
+func (f *fanoutResolver) Lookup(ctx context.Context, host string) ([]net.IP, []resolveStep, *fanoutResult, error) {
+	res := &fanoutResult{Strategy: f.strategy, Answers: make([]fanoutAnswer, len(f.members))}
+	for i, m := range f.members {
+		res.Answers[i] = fanoutAnswer{Resolver: m.name, Pending: true}
+	}
+	query := func(i int) memberAnswer {
+		start := time.Now()
+		ips, steps, err := f.members[i].LookupIPv4(ctx, host)
+		return memberAnswer{index: i, ips: ips, steps: steps, err: err, took: time.Since(start)}
+	}
+
+	var used []memberAnswer // 判定采用的应答，第一个为最快成功的应答
+	var errs []string
+	record := func(a memberAnswer) {
+		ans := &res.Answers[a.index]
+		ans.Pending = false
+		ans.LatencyMs = float64(a.took) / float64(time.Millisecond)
+		if a.err != nil {
+			ans.Error = a.err.Error()
+			errs = append(errs, fmt.Sprintf("%s: %v", f.members[a.index].name, a.err))
+			return
+		}
+		for _, ip := range a.ips {
+			ans.IPs = append(ans.IPs, ip.String())
+		}
+		used = append(used, a)
+	}
+
+	if f.strategy == strategySequential {
+		for i := range f.members {
+			if len(used) > 0 {
+				res.Answers[i].Pending, res.Answers[i].Skipped = false, true
+				continue
+			}
+			record(query(i))
+		}
+	} else {
+		// 缓冲足够大，race 提前返回后其余查询不会阻塞
+		answers := make(chan memberAnswer, len(f.members))
+		for i := range f.members {
+			go func(i int) { answers <- query(i) }(i)
+		}
+		for range f.members {
+			record(<-answers)
+			if f.strategy == strategyRace && len(used) > 0 {
+				break
+			}
+		}
+	}
+
+	if len(used) == 0 {
+		return nil, nil, res, fmt.Errorf("所有解析器均失败: %s", strings.Join(errs, "；"))
+	}
+	res.Fastest = f.members[used[0].index].name
+	ips := used[0].ips
+	if f.strategy == strategyAll {
+		ips = unionIPs(used)
+	}
+	return ips, used[0].steps, res, nil
+}
+
+// unionIPs 合并各应答的 IP，按首次出现的顺序去重
+func unionIPs(answers []memberAnswer) []net.IP {
+	seen := make(map[string]bool)
+	var ips []net.IP
+	for _, a := range answers {
+		for _, ip := range a.ips {
+			if !seen[ip.String()] {
+				seen[ip.String()] = true
+				ips = append(ips, ip)
+			}
+		}
+	}
+	return ips
+}
+
+// transportResolver 通过 udp://、tcp:// 或 DoH 向指定的递归解析器查询
+type transportResolver struct {
+	spec transportSpec
+}
+
+func (t transportResolver) LookupIPv4(ctx context.Context, host string) ([]net.IP, []resolveStep, error) {
+	step, resp := t.query(ctx, dnsFQDN(host), dnsmessage.TypeA)
+	steps := []resolveStep{step}
+	if step.Err != nil {
+		return nil, steps, step.Err
+	}
+	if resp.Header.RCode != dnsmessage.RCodeSuccess {
+		return nil, steps, fmt.Errorf("%s 返回 %s", t.spec, resp.Header.RCode)
+	}
+	return answerIPv4(resp), steps, nil
+}
+
+// query 与 rawQuery 相同，只是通过 t 的传输方式发送
+func (t transportResolver) query(ctx context.Context, qname string, qtype dnsmessage.Type) (resolveStep, *dnsmessage.Message) {
+	step := resolveStep{Server: t.spec.String(), QName: qname, QType: qtype}
+	timeout := 2 * time.Second
+	if deadline, ok := ctx.Deadline(); ok {
+		if remaining := time.Until(deadline); remaining < timeout {
+			timeout = remaining
+		}
+	}
+	query, err := newDNSQuery(qname, qtype)
+	if err != nil {
+		step.Err = err
+		return step, nil
+	}
+	start := time.Now()
+	var resp *dnsmessage.Message
+	switch t.spec.Kind {
+	case "udp":
+		resp, err = exchangeUDP(t.spec.Addr, query, timeout)
+	case "tcp":
+		resp, err = exchangeTCP(t.spec.Addr, query, timeout)
+	default:
+		resp, err = exchangeDoH(ctx, t.spec.Addr, query, timeout)
+	}
+	step.Latency = time.Since(start)
+	if err != nil {
+		step.Err = err
+		return step, nil
+	}
+	step.RCode = resp.Header.RCode
+	step.CNAMEs = answerCNAMEs(resp)
+	step.Anomalies = scoreResponse(qname, qtype, resp, true)
+	return step, resp
+}
+
+// countFanoutDisagreement 统计各解析器成功应答的 IP 集合不完全相同的域名数
+func countFanoutDisagreement(results []DomainResult) int {
+	n := 0
+	for _, r := range results {
+		if r.Resolution == nil {
+			continue
+		}
+		var sets []string
+		for _, a := range r.Resolution.Answers {
+			if a.Error == "" && !a.Pending && !a.Skipped {
+				ips := append([]string(nil), a.IPs...)
+				sort.Strings(ips)
+				sets = append(sets, strings.Join(ips, ","))
+			}
+		}
+		if len(sets) < 2 {
+			continue
+		}
+		for _, s := range sets[1:] {
+			if s != sets[0] {
+				n++
+				break
+			}
+		}
+	}
+	return n
+}
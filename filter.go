@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+)
+
+// ---------- 结果过滤（-only / -min-severity） ----------
+
+// severity 表示单个域名检测结果的严重程度
+type severity int
+
+const (
+	severityOK         severity = iota // 所有 IP 均查询成功且符合预期
+	severityWarning                    // 未判定为污染，但无法判定，或存在查询失败、不符合预期的 IP
+	severitySuspicious                 // 未判定为污染，但应答与历史相比有可疑变化（-learn-days）
+	severityCritical                   // 判定为污染
+)
+
+var severityNames = []string{"ok", "warning", "suspicious", "critical"}
+
+func (s severity) String() string {
+	if int(s) < len(severityNames) {
+		return severityNames[s]
+	}
+	return fmt.Sprintf("severity(%d)", int(s))
+}
+
+func parseSeverity(s string) (severity, error) {
+	for i, name := range severityNames {
+		if s == name {
+			return severity(i), nil
+		}
+	}
+	return 0, fmt.Errorf("无效的严重程度 %q（可选 ok、warning、suspicious、critical）", s)
+}
+
+// domainSeverity 计算域名结果的严重程度
+func domainSeverity(r DomainResult) severity {
+	if r.IsPolluted {
+		return severityCritical
+	}
+	if r.Failed {
+		return severityWarning
+	}
+	if len(r.Suspicious) > 0 || r.GeoHint != "" {
+		return severitySuspicious
+	}
+	for _, ip := range r.IPResults {
+		if !r.ipMatched(ip) {
+			return severityWarning
+		}
+	}
+	return severityOK
+}
+
+// hasErrors 判断域名是否无法判定，或结果中是否有 IP 查询失败
+func hasErrors(r DomainResult) bool {
+	if r.Failed {
+		return true
+	}
+	for _, ip := range r.IPResults {
+		if ip.Error != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// resultFilter 决定哪些域名出现在报告的详细结果中，零值表示不过滤
+type resultFilter struct {
+	only        string // polluted、errors、clean、rebinding 或空
+	minSeverity severity
+}
+
+// newResultFilter 根据 -only 与 -min-severity 参数创建过滤器
+func newResultFilter(only, minSeverity string) (resultFilter, error) {
+	var f resultFilter
+	switch only {
+	case "", "polluted", "errors", "clean", "rebinding":
+		f.only = only
+	default:
+		return f, fmt.Errorf("无效的 -only 参数 %q（可选 polluted、errors、clean、rebinding）", only)
+	}
+	if minSeverity != "" {
+		sev, err := parseSeverity(minSeverity)
+		if err != nil {
+			return f, err
+		}
+		f.minSeverity = sev
+	}
+	return f, nil
+}
+
+// Active 报告过滤器是否会排除任何结果
+func (f resultFilter) Active() bool {
+	return f.only != "" || f.minSeverity > severityOK
+}
+
+// Match 判断域名结果是否应当显示
+func (f resultFilter) Match(r DomainResult) bool {
+	switch f.only {
+	case "polluted":
+		if !r.IsPolluted {
+			return false
+		}
+	case "errors":
+		if !hasErrors(r) {
+			return false
+		}
+	case "clean":
+		if r.IsPolluted || hasErrors(r) {
+			return false
+		}
+	case "rebinding":
+		if !r.Rebinding {
+			return false
+		}
+	}
+	return domainSeverity(r) >= f.minSeverity
+}
+
+// Apply 返回符合条件的结果
+func (f resultFilter) Apply(results []DomainResult) []DomainResult {
+	if !f.Active() {
+		return results
+	}
+	var out []DomainResult
+	for _, r := range results {
+		if f.Match(r) {
+			out = append(out, r)
+		}
+	}
+	return out
+}
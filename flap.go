@@ -0,0 +1,137 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// ---------- 判定去抖（跳变检测） ----------
+
+// flapState 是单个域名跨轮次的判定状态
+type flapState struct {
+	Polluted bool `json:"polluted"` // 正式判定
+	Last     bool `json:"last"`     // 最近一轮的瞬时判定
+	Streak   int  `json:"streak"`   // 瞬时判定连续相同的轮数
+}
+
+// flapTracker 记录各域名连续相同的瞬时判定，只有连续 need 轮相同时才改变正式判定，
+// 避免间歇性注入使域名每轮在污染与正常之间来回跳变。守护模式下状态保存在内存中；
+// 单次运行时可用 -flap-state 指定状态文件。nil 表示不去抖，正式判定即瞬时判定。
+type flapTracker struct {
+	mu    sync.Mutex
+	need  int
+	state map[string]*flapState
+	path  string
+}
+
+// newFlapTracker 创建去抖状态并读取状态文件；need 不大于 1 时返回 nil
+func newFlapTracker(need int, statePath string) (*flapTracker, error) {
+	if need <= 1 {
+		return nil, nil
+	}
+	t := &flapTracker{need: need, state: make(map[string]*flapState), path: statePath}
+	if statePath == "" {
+		return t, nil
+	}
+	data, err := os.ReadFile(statePath)
+	if os.IsNotExist(err) {
+		return t, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取去抖状态文件失败: %w", err)
+	}
+	if err := json.Unmarshal(data, &t.state); err != nil {
+		return nil, fmt.Errorf("解析去抖状态文件 %s 失败: %w", statePath, err)
+	}
+	return t, nil
+}
+
+// Apply 用本轮的瞬时判定更新状态，并写入各结果的 Debounced 与 Streak。
+// 第一次见到的域名直接采用瞬时判定作为正式判定
+func (t *flapTracker) Apply(results []DomainResult) error {
+	if t == nil {
+		for i := range results {
+			results[i].Debounced = results[i].IsPolluted
+		}
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for i := range results {
+		r := &results[i]
+		st := t.state[r.Domain]
+		if verdictOf(*r) == verdictError {
+			// 无法判定的一轮不改变正式判定，也不计入连续轮数
+			if st != nil {
+				r.Debounced, r.Streak = st.Polluted, st.Streak
+			}
+			continue
+		}
+		if st == nil {
+			st = &flapState{Polluted: r.IsPolluted, Last: r.IsPolluted}
+			t.state[r.Domain] = st
+		}
+		if st.Last == r.IsPolluted {
+			st.Streak++
+		} else {
+			st.Last, st.Streak = r.IsPolluted, 1
+		}
+		if st.Polluted != r.IsPolluted && st.Streak >= t.need {
+			st.Polluted = r.IsPolluted
+		}
+		r.Debounced, r.Streak = st.Polluted, st.Streak
+	}
+	return t.save()
+}
+
+// save 将状态写入状态文件（未指定时不保存）
+func (t *flapTracker) save() error {
+	if t.path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(t.state, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(t.path, data, 0644); err != nil {
+		return fmt.Errorf("写入去抖状态文件失败: %w", err)
+	}
+	return nil
+}
+
+// flapping 报告域名的瞬时判定是否与正式判定不同，即正在等待确认
+func (r DomainResult) flapping() bool {
+	return r.Debounced != r.IsPolluted && verdictOf(r) != verdictError
+}
+
+// countFlapping 统计瞬时判定尚待确认的域名数
+func countFlapping(results []DomainResult) int {
+	n := 0
+	for _, r := range results {
+		if r.flapping() {
+			n++
+		}
+	}
+	return n
+}
+
+// countDebounced 统计正式判定为污染的域名数
+func countDebounced(results []DomainResult) int {
+	n := 0
+	for _, r := range results {
+		if r.Debounced {
+			n++
+		}
+	}
+	return n
+}
+
+// debouncedVerdict 返回正式判定的名称，与 verdictOf 的 clean、polluted 对应
+func debouncedVerdict(r DomainResult) string {
+	if r.Debounced {
+		return "polluted"
+	}
+	return "clean"
+}
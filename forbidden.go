@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// ---------- 黑名单（forbidden_llcs / forbidden_asns / forbidden_ips） ----------
+
+// blocklist 是单个域名的黑名单：任一解析结果命中即判定为污染，与 expected_llcs 无关。
+// nil 表示未配置黑名单。
+type blocklist struct {
+	llcs []Expectation
+	asns map[uint32]bool
+	nets []*net.IPNet
+}
+
+// newBlocklist 根据域名配置构建黑名单，未配置任何黑名单时返回 nil。
+// forbidden_llcs 与 expected_llcs 一样继承域名级别的 match / case_insensitive 设置。
+func newBlocklist(dc *DomainConfig) (*blocklist, error) {
+	if len(dc.ForbiddenLlcs) == 0 && len(dc.ForbiddenASNs) == 0 && len(dc.ForbiddenIPs) == 0 {
+		return nil, nil
+	}
+	llcs, err := resolveExpectations(dc.ForbiddenLlcs, dc.Match, dc.CaseInsensitive)
+	if err != nil {
+		return nil, err
+	}
+	b := &blocklist{llcs: llcs, asns: make(map[uint32]bool)}
+	for _, s := range dc.ForbiddenASNs {
+		asn, err := parseASN(s)
+		if err != nil {
+			return nil, err
+		}
+		b.asns[asn] = true
+	}
+	for _, s := range dc.ForbiddenIPs {
+		n, err := parseIPOrCIDR(s)
+		if err != nil {
+			return nil, err
+		}
+		b.nets = append(b.nets, n)
+	}
+	return b, nil
+}
+
+// parseIPOrCIDR 解析单个 IP 或 CIDR，单个 IP 视为 /32（IPv6 为 /128）
+func parseIPOrCIDR(s string) (*net.IPNet, error) {
+	s = strings.TrimSpace(s)
+	if strings.Contains(s, "/") {
+		_, n, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, fmt.Errorf("无效的 CIDR %q", s)
+		}
+		return n, nil
+	}
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return nil, fmt.Errorf("无效的 IP %q", s)
+	}
+	if v4 := ip.To4(); v4 != nil {
+		return &net.IPNet{IP: v4, Mask: net.CIDRMask(32, 32)}, nil
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(128, 128)}, nil
+}
+
+// Check 返回 IP 命中黑名单的原因，未命中时返回空字符串。
+// IP 黑名单总是检查；LLC 和 ASN 黑名单只在查询成功时检查。
+func (b *blocklist) Check(r IPCheckResult) string {
+	if b == nil {
+		return ""
+	}
+	if ip := net.ParseIP(r.IP); ip != nil {
+		for _, n := range b.nets {
+			if n.Contains(ip) {
+				return fmt.Sprintf("IP 属于 %s", n)
+			}
+		}
+	}
+	if r.Error != nil {
+		return ""
+	}
+	if r.ASN != 0 && b.asns[r.ASN] {
+		return fmt.Sprintf("ASN AS%d", r.ASN)
+	}
+	for _, e := range b.llcs {
+		if e.Matches(r.ActualLLC) {
+			return fmt.Sprintf("LLC %s", e)
+		}
+	}
+	return ""
+}
+
+// applyBlocklist 标记命中黑名单的 IP；有任何命中时，无论预期是否满足都判定为污染
+func applyBlocklist(res *DomainResult, b *blocklist) {
+	hits := 0
+	for i := range res.IPResults {
+		if reason := b.Check(res.IPResults[i]); reason != "" {
+			res.IPResults[i].Forbidden = reason
+			hits++
+		}
+	}
+	if hits > 0 {
+		res.IsPolluted = true
+		res.Summary = fmt.Sprintf("%d 个 IP 命中黑名单", hits)
+	}
+}
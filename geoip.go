@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strings"
+)
+
+// ---------- 国家不符提示（-geoip / -home-country / hosting） ----------
+
+// 预期的提供方都在境外的域名突然解析到本国的 IP（或者反过来），是成本很低的污染迹象：
+// 国家可以从 API、-ip-map 或本地数据库得到，API 不可用时仍然能给出提示。只作为提示，不改变判定
+
+// hosting 的取值
+const (
+	hostingForeign  = "foreign"  // 预期的提供方都在境外
+	hostingDomestic = "domestic" // 预期的提供方都在本国
+)
+
+// validateHosting 检查域名的 hosting
+func validateHosting(h string) error {
+	switch h {
+	case "", hostingForeign, hostingDomestic:
+		return nil
+	}
+	return fmt.Errorf("无效的 hosting %q（可选 foreign、domestic）", h)
+}
+
+// validateHomeCountry 检查 -home-country
+func validateHomeCountry(cc string) error {
+	if cc == "" {
+		return nil
+	}
+	valid := len(cc) == 2
+	for _, r := range cc {
+		valid = valid && (r >= 'A' && r <= 'Z' || r >= 'a' && r <= 'z')
+	}
+	if !valid {
+		return fmt.Errorf("无效的 -home-country %q（应为两位国家代码，如 CN）", cc)
+	}
+	return nil
+}
+
+// geoRange 是数据库中的一段地址，start 与 end 均为 16 字节形式
+type geoRange struct {
+	start, end net.IP
+	country    string
+}
+
+// geoDB 是按起始地址排序的国家数据库，nil 表示未配置
+type geoDB struct {
+	ranges []geoRange
+}
+
+// loadGeoDB 读取 -geoip 指定的 CSV。每行为 "cidr,国家" 或 "起始IP,结束IP,国家"
+// （DB-IP、IP2Location 等免费国家库的常见格式，字段可带引号），# 开头的行为注释。path 为空时返回 nil
+func loadGeoDB(path string) (*geoDB, error) {
+	if path == "" {
+		return nil, nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取 GeoIP 数据库失败: %w", err)
+	}
+	defer f.Close()
+
+	db := &geoDB{}
+	sc := bufio.NewScanner(f)
+	for line := 1; sc.Scan(); line++ {
+		text := strings.TrimSpace(sc.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.Split(text, ",")
+		for i := range fields {
+			fields[i] = strings.Trim(strings.TrimSpace(fields[i]), `"`)
+		}
+		var r geoRange
+		switch len(fields) {
+		case 2:
+			_, n, err := net.ParseCIDR(fields[0])
+			if err != nil {
+				return nil, fmt.Errorf("GeoIP 数据库 %s 第 %d 行: 无效的 CIDR %q", path, line, fields[0])
+			}
+			r.start, r.end = n.IP.To16(), lastIP(n)
+		case 3:
+			r.start, r.end = net.ParseIP(fields[0]).To16(), net.ParseIP(fields[1]).To16()
+			if r.start == nil || r.end == nil || bytes.Compare(r.start, r.end) > 0 {
+				return nil, fmt.Errorf("GeoIP 数据库 %s 第 %d 行: 无效的地址范围", path, line)
+			}
+		default:
+			return nil, fmt.Errorf("GeoIP 数据库 %s 第 %d 行: 应为 cidr,国家 或 起始IP,结束IP,国家", path, line)
+		}
+		r.country = strings.ToUpper(fields[len(fields)-1])
+		db.ranges = append(db.ranges, r)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("读取 GeoIP 数据库失败: %w", err)
+	}
+	sort.Slice(db.ranges, func(i, j int) bool { return bytes.Compare(db.ranges[i].start, db.ranges[j].start) < 0 })
+	return db, nil
+}
+
+// lastIP 返回网段的最后一个地址（16 字节形式）
+func lastIP(n *net.IPNet) net.IP {
+	ip := append(net.IP(nil), n.IP.To16()...)
+	mask := n.Mask
+	if len(mask) == net.IPv4len {
+		mask = append(net.CIDRMask(96, 128)[:12], mask...)
+	}
+	for i := range ip {
+		ip[i] |= ^mask[i]
+	}
+	return ip
+}
+
+// Country 返回 ip 所在的国家，查不到或未配置数据库时返回空字符串
+func (db *geoDB) Country(ip net.IP) string {
+	if db == nil || ip == nil {
+		return ""
+	}
+	ip = ip.To16()
+	i := sort.Search(len(db.ranges), func(i int) bool { return bytes.Compare(db.ranges[i].start, ip) > 0 })
+	if i > 0 && bytes.Compare(ip, db.ranges[i-1].end) <= 0 {
+		return db.ranges[i-1].country
+	}
+	return ""
+}
+
+// checkCountry 在设置了 -home-country 且域名配置了 hosting 时，检查解析到的 IP 所在国家是否与预期相反。
+// 国家优先使用 API 或 -ip-map 给出的结果，没有时查询本地数据库；私有地址与查不到国家的 IP 不参与比较
+func (c *checker) checkCountry(dc DomainConfig, res *DomainResult) {
+	if c.home == "" || dc.Hosting == "" {
+		return
+	}
+	var hits []string
+	for _, ip := range res.IPResults {
+		if ip.Private {
+			continue
+		}
+		cc := ip.Country
+		if cc == "" {
+			cc = c.geo.Country(net.ParseIP(ip.IP))
+		}
+		if cc == "" || (cc == c.home) != (dc.Hosting == hostingForeign) {
+			continue
+		}
+		hits = append(hits, fmt.Sprintf("%s（%s）", ip.IP, cc))
+	}
+	if len(hits) == 0 {
+		return
+	}
+	if dc.Hosting == hostingForeign {
+		res.GeoHint = fmt.Sprintf("预期的提供方都在境外，但解析到位于 %s 的 IP: %s", c.home, strings.Join(hits, ", "))
+	} else {
+		res.GeoHint = fmt.Sprintf("预期的提供方都在 %s，但解析到境外的 IP: %s", c.home, strings.Join(hits, ", "))
+	}
+}
+
+// countGeoHints 统计有国家不符提示的域名数
+func countGeoHints(results []DomainResult) int {
+	n := 0
+	for _, r := range results {
+		if r.GeoHint != "" {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ---------- 容器健康检查（healthcheck 子命令） ----------
+
+// llcCacheEntry 是磁盘缓存中单个 IP 的 LLC 记录
+type llcCacheEntry struct {
+	LLC       string    `json:"llc"`
+	ASN       uint32    `json:"asn,omitempty"`
+	Country   string    `json:"country,omitempty"`
+	FetchedAt time.Time `json:"fetched_at"`
+}
+
+// runHealthcheck 实现 `dnscheck healthcheck -domain example.com -expect Cloudflare`：
+// 只检测单个域名，不输出报告，仅以退出码表示结果（0 正常，1 被污染，3 解析或查询失败、无法判定）。
+// IP 的 LLC 查询结果缓存在磁盘上，频繁的探针调用通常无需访问 API。
+func runHealthcheck(args []string) {
+	fs := flag.NewFlagSet("healthcheck", flag.ExitOnError)
+	domain := fs.String("domain", "", "待检测的域名")
+	expect := fs.String("expect", "", "预期的 LLC（支持前缀匹配，多个用逗号分隔）")
+	api := fs.String("api", flag.Lookup("api").DefValue, "IP 信息查询 API 地址（支持多个，用逗号分隔）")
+	hcTimeout := fs.Duration("timeout", 900*time.Millisecond, "整个检查的超时时间")
+	cachePath := fs.String("cache", filepath.Join(os.TempDir(), "dnscheck-healthcheck-cache.json"), "LLC 缓存文件路径")
+	cacheTTL := fs.Duration("cache-ttl", time.Hour, "LLC 缓存有效期")
+	aliasPath := fs.String("llc-aliases", "", "LLC 别名文件")
+	verbose := fs.Bool("v", false, "将检查过程输出到标准错误")
+	_ = fs.Parse(args)
+
+	if *domain == "" || *expect == "" {
+		fmt.Fprintln(os.Stderr, "用法: dnscheck healthcheck -domain <域名> -expect <LLC>[,<LLC>...]")
+		os.Exit(2)
+	}
+	logf := func(format string, a ...interface{}) {
+		if *verbose {
+			fmt.Fprintf(os.Stderr, format+"\n", a...)
+		}
+	}
+
+	aliases, err := loadLLCAliases(*aliasPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	ok, err := healthcheck(*domain, splitList(*expect), splitList(*api), aliases, *hcTimeout, *cachePath, *cacheTTL, logf)
+	if err != nil {
+		logf("无法判定: %v", err)
+		os.Exit(3)
+	}
+	if !ok {
+		os.Exit(1)
+	}
+}
+
+// healthcheck 执行单个域名的宽松模式检查，返回是否至少有一个 IP 符合预期；解析或全部查询失败时返回错误
+func healthcheck(domain string, expected, apis []string, aliases llcAliases, timeout time.Duration, cachePath string, cacheTTL time.Duration, logf func(string, ...interface{})) (bool, error) {
+	deadline := time.Now().Add(timeout)
+	ctx, cancel := context.WithDeadline(context.Background(), deadline)
+	defer cancel()
+
+	host, err := toASCIIDomain(strings.ToLower(strings.TrimSuffix(domain, ".")))
+	if err != nil {
+		return false, err
+	}
+	var r net.Resolver
+	ips, err := r.LookupIP(ctx, "ip4", host)
+	if err != nil {
+		return false, fmt.Errorf("DNS 解析失败: %w", err)
+	}
+
+	cache := loadLLCCache(cachePath)
+	dirty := false
+	ipResults := make([]IPCheckResult, 0, len(ips))
+	for _, ip := range ips {
+		key := ip.String()
+		if e, ok := cache[key]; ok && time.Since(e.FetchedAt) < cacheTTL {
+			logf("%s: LLC=%s（缓存）", key, e.LLC)
+			ipResults = append(ipResults, IPCheckResult{IP: key, ActualLLC: aliases.Resolve(e.LLC, e.ASN), RawLLC: e.LLC, ASN: e.ASN, Country: e.Country})
+			continue
+		}
+		var info ipInfo
+		err := fmt.Errorf("没有可用的 API")
+		for _, baseURL := range apis {
+			remaining := time.Until(deadline)
+			if remaining <= 0 {
+				err = context.DeadlineExceeded
+				break
+			}
+			if info, err = queryIPInfoFromAPI(ctx, key, baseURL, nil, remaining); err == nil {
+				break
+			}
+		}
+		if err == nil {
+			cache[key] = llcCacheEntry{LLC: info.LLC, ASN: info.ASN, Country: info.Country, FetchedAt: time.Now()}
+			dirty = true
+		}
+		logf("%s: LLC=%s 错误=%v", key, info.LLC, err)
+		ipResults = append(ipResults, IPCheckResult{IP: key, ActualLLC: aliases.Resolve(info.LLC, info.ASN), RawLLC: info.LLC, ASN: info.ASN, Country: info.Country, Error: err})
+	}
+	if dirty {
+		saveLLCCache(cachePath, cache)
+	}
+
+	res := aggregateDomainResult(domain, prefixExpectations(expected), nil, nil, ipResults, false)
+	logf("%s: %s", domain, res.Summary)
+	if res.Failed {
+		return false, fmt.Errorf("%s", res.Summary)
+	}
+	return !res.IsPolluted, nil
+}
+
+// loadLLCCache 读取磁盘缓存；文件不存在或损坏时返回空缓存
+func loadLLCCache(path string) map[string]llcCacheEntry {
+	cache := make(map[string]llcCacheEntry)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return cache
+	}
+	_ = json.Unmarshal(data, &cache)
+	return cache
+}
+
+// saveLLCCache 原子地写回磁盘缓存（先写临时文件再重命名），失败时静默忽略
+func saveLLCCache(path string, cache map[string]llcCacheEntry) {
+	data, err := json.Marshal(cache)
+	if err != nil {
+		return
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return
+	}
+	_ = os.Rename(tmp, path)
+}
+
+// splitList 按逗号拆分并去除空白和空项
+func splitList(s string) []string {
+	var out []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
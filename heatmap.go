@@ -0,0 +1,202 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ---------- 解析器 × 域名热力图（-transports） ----------
+
+// 热力图单元格的状态
+const (
+	cellClean    = "clean"    // 应答与主解析器中符合预期的 IP 有交集
+	cellPolluted = "polluted" // 应答包含主解析器判定为不符合预期的 IP
+	cellDiffers  = "differs"  // 应答与主解析器没有交集，IP 归属未知
+	cellError    = "error"    // 查询失败
+)
+
+// heatmapSymbols 是文本报告中各状态的符号；没有结果的单元格为空格
+var heatmapSymbols = map[string]string{cellClean: ".", cellPolluted: "X", cellDiffers: "?", cellError: "!"}
+
+// heatmap 是各解析器（列）与各域名（行）组合的判定。第一列是主解析器（-resolver）的判定，
+// 其余各列是 -transports 中的解析器；某一列整列被污染时，说明问题出在该解析器而不是个别域名
+type heatmap struct {
+	Columns  []string
+	Rows     []heatmapRow
+	Polluted []int // 各列被污染的行数
+	Answered []int // 各列有结果的行数
+}
+
+type heatmapRow struct {
+	Label string
+	Cells []heatmapCell
+}
+
+// heatmapCell 是一个组合的判定；State 为空表示该解析器没有这个域名的结果
+type heatmapCell struct {
+	State  string
+	Detail string // 应答的 IP、RCode 或错误
+}
+
+// Class 返回单元格在 HTML 报告中的 CSS 类名
+func (c heatmapCell) Class() string {
+	if c.State == "" {
+		return "cell-none"
+	}
+	return "cell-" + c.State
+}
+
+// Label 返回单元格在 HTML 报告中显示的文字
+func (c heatmapCell) Label() string {
+	switch c.State {
+	case cellClean:
+		return "正常"
+	case cellPolluted:
+		return "污染"
+	case cellDiffers:
+		return "不一致"
+	case cellError:
+		return "失败"
+	}
+	return ""
+}
+
+// resultHeatmap 为本轮检测结果生成热力图；未启用 -transports 时返回 nil
+func resultHeatmap(results []DomainResult) *heatmap {
+	for _, r := range results {
+		if r.Transport != nil {
+			return collectHeatmap(newRunJSON("", time.Time{}, results).Results)
+		}
+	}
+	return nil
+}
+
+// collectHeatmap 根据 -transports 的应答生成热力图；没有任何结果启用 -transports 时返回 nil。
+// 合并报告中同一域名有多个来源时，每个来源一行
+func collectHeatmap(results []domainJSON) *heatmap {
+	h := &heatmap{Columns: []string{"主解析器（-resolver）"}}
+	index := make(map[string]int)
+	for _, r := range results {
+		if r.Transport == nil {
+			continue
+		}
+		for _, a := range r.Transport.Answers {
+			if _, ok := index[a.Transport]; !ok {
+				index[a.Transport] = len(h.Columns)
+				h.Columns = append(h.Columns, a.Transport)
+			}
+		}
+	}
+	if len(h.Columns) == 1 {
+		return nil
+	}
+	h.Polluted = make([]int, len(h.Columns))
+	h.Answered = make([]int, len(h.Columns))
+	for _, r := range results {
+		if r.Transport == nil {
+			continue
+		}
+		row := heatmapRow{Label: r.Domain, Cells: make([]heatmapCell, len(h.Columns))}
+		if r.Source != "" {
+			row.Label += " @ " + r.Source
+		}
+		row.Cells[0] = heatmapCell{State: cellClean, Detail: strings.Join(r.ipList(), ", ")}
+		if r.Polluted || r.Rebinding {
+			row.Cells[0].State = cellPolluted
+		}
+		for _, a := range r.Transport.Answers {
+			row.Cells[index[a.Transport]] = transportCell(r, a)
+		}
+		for i, c := range row.Cells {
+			if c.State == "" {
+				continue
+			}
+			h.Answered[i]++
+			if c.State == cellPolluted {
+				h.Polluted[i]++
+			}
+		}
+		h.Rows = append(h.Rows, row)
+	}
+	return h
+}
+
+// transportCell 用主解析器的判定评估另一个解析器的应答：包含不符合预期的 IP 为污染，包含符合预期的 IP 为正常；
+// 其余 IP 没有查询归属，与主解析器没有交集时只能标为不一致
+func transportCell(r domainJSON, a transportAnswer) heatmapCell {
+	if a.Error != "" {
+		return heatmapCell{State: cellError, Detail: a.Error}
+	}
+	cell := heatmapCell{Detail: strings.Join(a.IPs, ", ")}
+	if len(a.IPs) == 0 {
+		cell.Detail = a.RCode
+	}
+	matched := make(map[string]bool, len(r.IPs))
+	for _, ip := range r.IPs {
+		if ip.Error == "" {
+			matched[ip.IP] = ip.Matched
+		}
+	}
+	clean := false
+	for _, ip := range a.IPs {
+		ok, known := matched[ip]
+		if known && !ok {
+			cell.State = cellPolluted
+			return cell
+		}
+		clean = clean || known
+	}
+	switch {
+	case clean:
+		cell.State = cellClean
+	case len(a.IPs) == 0 && len(r.IPs) == 0:
+		// 与主解析器同样没有 IP（如都是 NXDOMAIN），沿用主解析器的判定
+		cell.State = cellClean
+		if r.Polluted {
+			cell.State = cellPolluted
+		}
+	default:
+		cell.State = cellDiffers
+	}
+	return cell
+}
+
+// writeHeatmap 写入文本报告中的热力图；只列出至少有一个解析器不正常的域名
+func writeHeatmap(b *strings.Builder, h *heatmap) {
+	if h == nil {
+		return
+	}
+	b.WriteString("解析器热力图（X 污染，? 与主解析器的应答没有交集，! 查询失败，. 正常）:\n")
+	for i, col := range h.Columns {
+		b.WriteString(fmt.Sprintf("  [%d] 被污染 %d/%d  %s\n", i, h.Polluted[i], h.Answered[i], col))
+	}
+	width := len(fmt.Sprint(len(h.Columns)-1)) + 1
+	header := make([]string, len(h.Columns))
+	for i := range h.Columns {
+		header[i] = fmt.Sprintf("%*d", width, i)
+	}
+	b.WriteString(fmt.Sprintf("  %-30s%s\n", "", strings.Join(header, "")))
+	hidden := 0
+	for _, row := range h.Rows {
+		cells := make([]string, len(row.Cells))
+		normal := true
+		for i, c := range row.Cells {
+			symbol, ok := heatmapSymbols[c.State]
+			if !ok {
+				symbol = " "
+			}
+			cells[i] = fmt.Sprintf("%*s", width, symbol)
+			normal = normal && (c.State == cellClean || c.State == "")
+		}
+		if normal {
+			hidden++
+			continue
+		}
+		b.WriteString(fmt.Sprintf("  %-30s%s\n", row.Label, strings.Join(cells, "")))
+	}
+	if hidden > 0 {
+		b.WriteString(fmt.Sprintf("  （另有 %d 个域名在各解析器上均正常，未列出）\n", hidden))
+	}
+	b.WriteString("\n")
+}
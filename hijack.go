@@ -0,0 +1,146 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// ---------- 透明 DNS 劫持检测（-hijack-check） ----------
+
+// 向不存在的解析器发送的查询
+const (
+	hijackQName   = "example.com"
+	hijackTimeout = 2 * time.Second
+)
+
+// detectDNSInterception 向一个不存在 DNS 服务的地址（默认 192.0.2.1，RFC 5737 保留的文档地址，不会被路由）
+// 发送查询。正常网络中查询会超时；如果仍然收到了来自该地址的应答，说明路径上的设备在拦截并代答 53 端口的流量。
+// 返回发现的劫持描述，未发现或未启用时返回空字符串
+func detectDNSInterception(target string) string {
+	if target == "" {
+		return ""
+	}
+	server := target
+	if _, _, err := net.SplitHostPort(target); err != nil {
+		server = net.JoinHostPort(target, "53")
+	}
+	query, err := newDNSQuery(hijackQName, dnsmessage.TypeA)
+	if err != nil {
+		return ""
+	}
+	resp, err := exchangeUDP(server, query, hijackTimeout)
+	if err != nil {
+		return ""
+	}
+	answer := resp.Header.RCode.String()
+	if ips := answerIPv4(resp); len(ips) > 0 {
+		parts := make([]string, len(ips))
+		for i, ip := range ips {
+			parts[i] = ip.String()
+		}
+		answer = strings.Join(parts, ", ")
+	}
+	return fmt.Sprintf("发往 %s（不存在的解析器）的 %s 查询得到了应答（%s）", server, hijackQName, answer)
+}
+
+// validateHijackCheck 检查 -hijack-check 的地址
+func validateHijackCheck(target string) error {
+	if target == "" {
+		return nil
+	}
+	host := target
+	if h, _, err := net.SplitHostPort(target); err == nil {
+		host = h
+	}
+	if net.ParseIP(host) == nil {
+		return fmt.Errorf("-hijack-check 必须是 IP 地址（可带端口），如 192.0.2.1")
+	}
+	return nil
+}
+
+// ---------- 透明 DNS 代理检测（-proxy-check） ----------
+
+// whoamiQName 的 A 记录是向 Akamai 权威服务器发起查询的解析器的出口地址
+const whoamiQName = "whoami.akamai.net"
+
+// detectTransparentProxy 向几个不同运营方的解析器查询 whoamiQName。正常情况下各自返回自己的出口地址；
+// 如果所有应答都相同，说明发往这些地址的 53 端口流量被同一个解析器透明代理。
+// 返回发现的代理描述，未发现、应答不足两个或未启用时返回空字符串
+func detectTransparentProxy(servers []string) string {
+	type reply struct {
+		server string
+		egress string
+	}
+	replies := make(chan reply, len(servers))
+	for _, server := range servers {
+		go func(server string) {
+			r := reply{server: server}
+			if query, err := newDNSQuery(whoamiQName, dnsmessage.TypeA); err == nil {
+				if resp, err := exchangeUDP(server, query, hijackTimeout); err == nil {
+					if ips := answerIPv4(resp); len(ips) > 0 {
+						r.egress = ips[0].String()
+					}
+				}
+			}
+			replies <- r
+		}(server)
+	}
+	egress := make(map[string]bool)
+	var answered []string
+	for range servers {
+		r := <-replies
+		if r.egress != "" {
+			egress[r.egress] = true
+			answered = append(answered, r.server)
+		}
+	}
+	if len(answered) < 2 || len(egress) != 1 {
+		return ""
+	}
+	sort.Strings(answered)
+	for ip := range egress {
+		return fmt.Sprintf("向 %s 查询 %s 得到相同的解析器出口地址 %s", strings.Join(answered, "、"), whoamiQName, ip)
+	}
+	return ""
+}
+
+// parseProxyCheck 解析 -proxy-check 的解析器列表，未指定端口时使用 53；启用时至少需要两个地址
+func parseProxyCheck(spec string) ([]string, error) {
+	if spec == "" {
+		return nil, nil
+	}
+	var servers []string
+	for _, f := range strings.Split(spec, ",") {
+		f = strings.TrimSpace(f)
+		if f == "" {
+			continue
+		}
+		if err := validateHijackCheck(f); err != nil {
+			return nil, fmt.Errorf("无效的 -proxy-check 地址 %q", f)
+		}
+		if _, _, err := net.SplitHostPort(f); err != nil {
+			f = net.JoinHostPort(f, "53")
+		}
+		servers = append(servers, f)
+	}
+	if len(servers) < 2 {
+		return nil, fmt.Errorf("-proxy-check 至少需要两个解析器地址")
+	}
+	return servers, nil
+}
+
+// startInterceptionChecks 与本轮检测并行执行透明劫持与透明代理检测，返回的函数等待两者完成并写入 run
+func startInterceptionChecks() func(run *runInfo) {
+	servers, _ := parseProxyCheck(*proxyCheck) // 已由 validateFlags 检查
+	hijack, proxy := make(chan string, 1), make(chan string, 1)
+	go func() { hijack <- detectDNSInterception(*hijackAddr) }()
+	go func() { proxy <- detectTransparentProxy(servers) }()
+	return func(run *runInfo) {
+		run.Hijack, run.Proxy = <-hijack, <-proxy
+	}
+}
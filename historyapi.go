@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// ---------- 历史记录查询接口（守护模式 /api/history） ----------
+
+// 每页记录数的默认值与上限
+const (
+	historyPageDefault = 100
+	historyPageMax     = 1000
+)
+
+// historyPage 是 /api/history 的一页结果；NextOffset 为空表示没有下一页
+type historyPage struct {
+	Results    []historyRecord `json:"results"`
+	Limit      int             `json:"limit"`
+	Offset     int             `json:"offset"`
+	NextOffset *int            `json:"next_offset,omitempty"`
+}
+
+// handleHistory 按 domain、from、to、verdict 查询历史结果，以 limit / offset 分页，按时间倒序返回
+func (d *daemon) handleHistory(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "只支持 GET"})
+		return
+	}
+	if d.history == nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "未配置 -history，无法查询历史记录"})
+		return
+	}
+	f, err := parseHistoryFilter(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	page := historyPage{Limit: f.Limit, Offset: f.Offset}
+	// 多取一条以判断是否还有下一页
+	f.Limit++
+	records, err := d.history.Query(f)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	if len(records) > page.Limit {
+		records = records[:page.Limit]
+		next := page.Offset + page.Limit
+		page.NextOffset = &next
+	}
+	if records == nil {
+		records = []historyRecord{}
+	}
+	page.Results = records
+	writeJSON(w, http.StatusOK, page)
+}
+
+// parseHistoryFilter 解析 /api/history 的查询参数
+func parseHistoryFilter(r *http.Request) (historyFilter, error) {
+	q := r.URL.Query()
+	f := historyFilter{Limit: historyPageDefault}
+	if v := q.Get("domain"); v != "" {
+		name, err := normalizeDomainName(v)
+		if err != nil {
+			return f, err
+		}
+		f.Domain = name
+	}
+	var err error
+	if f.From, err = parseQueryTime(q.Get("from")); err != nil {
+		return f, fmt.Errorf("from: %w", err)
+	}
+	if f.To, err = parseQueryTime(q.Get("to")); err != nil {
+		return f, fmt.Errorf("to: %w", err)
+	}
+	switch v := q.Get("verdict"); v {
+	case "", verdictPolluted, verdictClean, verdictRebinding, verdictError:
+		f.Verdict = v
+	default:
+		return f, fmt.Errorf("无效的 verdict %q（可选 polluted、clean、rebinding、error）", v)
+	}
+	if v := q.Get("limit"); v != "" {
+		if f.Limit, err = strconv.Atoi(v); err != nil || f.Limit < 1 || f.Limit > historyPageMax {
+			return f, fmt.Errorf("limit 必须为 1~%d 的整数", historyPageMax)
+		}
+	}
+	if v := q.Get("offset"); v != "" {
+		if f.Offset, err = strconv.Atoi(v); err != nil || f.Offset < 0 {
+			return f, fmt.Errorf("offset 必须为非负整数")
+		}
+	}
+	return f, nil
+}
+
+// parseQueryTime 解析 RFC 3339 时间、日期（本地时区零点）或 Unix 秒；空字符串返回零值
+func parseQueryTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", s, time.Local); err == nil {
+		return t, nil
+	}
+	if sec, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Unix(sec, 0), nil
+	}
+	return time.Time{}, fmt.Errorf("无法解析时间 %q（可用 RFC 3339、2006-01-02 或 Unix 秒）", s)
+}
@@ -0,0 +1,301 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// ---------- 外部命令钩子 ----------
+
+// runHookCommand 通过 sh -c 执行命令，将 input 写入标准输入并返回标准输出。
+// 命令以非零状态退出时，错误信息中附带其标准错误的内容。
+// ctx 取消时只能终止 sh 本身，WaitDelay 保证仍占用输出管道的子进程不会让调用方继续等待。
+func runHookCommand(ctx context.Context, command string, input []byte, env []string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.WaitDelay = time.Second
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Env = append(os.Environ(), env...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%w: %s", err, msg)
+		}
+		return nil, err
+	}
+	return stdout.Bytes(), nil
+}
+
+// enrichRequest 是富化钩子的输入
+type enrichRequest struct {
+	Domain  string `json:"domain"`
+	IP      string `json:"ip"`
+	LLC     string `json:"llc,omitempty"`
+	ASN     uint32 `json:"asn,omitempty"`
+	Country string `json:"country,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// enrichResponse 是富化钩子的输出，未出现的字段保留 API 的查询结果
+type enrichResponse struct {
+	LLC     *string `json:"llc"`
+	ASN     *uint32 `json:"asn"`
+	Country *string `json:"country"`
+}
+
+// enrichIPInfo 对单个 IP 调用 -enrich-cmd 指定的命令，用其输出覆盖 API 的查询结果。
+// 钩子给出 LLC 时，即使 API 查询失败也视为查询成功；钩子本身失败时该 IP 记为查询失败。
+func enrichIPInfo(ctx context.Context, command, domain, ip string, info ipInfo, fetchErr error, timeout time.Duration) (ipInfo, error) {
+	req := enrichRequest{Domain: domain, IP: ip, LLC: info.LLC, ASN: info.ASN, Country: info.Country}
+	if fetchErr != nil {
+		req.Error = fetchErr.Error()
+	}
+	input, _ := json.Marshal(req)
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	out, err := runHookCommand(ctx, command, input, nil)
+	if err != nil {
+		return info, fmt.Errorf("富化钩子执行失败: %w", err)
+	}
+	var resp enrichResponse
+	if err := json.Unmarshal(out, &resp); err != nil {
+		return info, fmt.Errorf("富化钩子输出不是有效的 JSON: %w", err)
+	}
+	if resp.LLC != nil {
+		info.LLC = *resp.LLC
+		if info.LLC != "" {
+			fetchErr = nil
+		}
+	}
+	if resp.ASN != nil {
+		info.ASN = *resp.ASN
+	}
+	if resp.Country != nil {
+		info.Country = strings.ToUpper(*resp.Country)
+	}
+	return info, fetchErr
+}
+
+// runPostRunHook 在一轮检测结束后执行 -post-run-cmd，标准输入为完整结果的 JSON（-redact 时已脱敏），
+// 并通过环境变量传递本轮的 ID 与污染数，便于在脚本中直接判断。
+// alerts 非 nil（配置了告警规则）时只在有告警状态变化的轮次执行。
+// 钩子作为事件总线的订阅者同步执行，超过 timeout 时被终止，以免挂起的脚本卡住守护模式的调度。
+func runPostRunHook(command, runID string, at time.Time, results []DomainResult, alerts *alertEngine, events []alertEvent, timeout time.Duration) error {
+	if command == "" || (alerts != nil && len(events) == 0) {
+		return nil
+	}
+	run := newRunJSON(runID, at, results)
+	run.Alerts = events
+	run.Host = redaction.Host(run.Host)
+	data, err := json.Marshal(run)
+	if err != nil {
+		return fmt.Errorf("编码检测结果失败: %w", err)
+	}
+	input := []byte(redaction.Text(string(data)))
+	env := []string{
+		"DNSCHECK_RUN_ID=" + runID,
+		fmt.Sprintf("DNSCHECK_DOMAINS=%d", len(results)),
+		fmt.Sprintf("DNSCHECK_POLLUTED=%d", countPolluted(results)),
+		fmt.Sprintf("DNSCHECK_INCONCLUSIVE=%d", countFailed(results)),
+		fmt.Sprintf("DNSCHECK_ALERTS=%d", countFiring(events)),
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	out, err := runHookCommand(ctx, command, input, env)
+	if ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("运行后钩子执行超时（%v）: %w", timeout, ctx.Err())
+	}
+	if err != nil {
+		return fmt.Errorf("运行后钩子执行失败: %w", err)
+	}
+	if len(out) > 0 {
+		os.Stderr.Write(out)
+	}
+	return nil
+}
+
+// ---------- 结果 JSON ----------
+
+// buildJSONReport 生成 -format json 的报告：始终包含全部结果，不受 -only / -min-severity 影响
+func buildJSONReport(run runInfo, results []DomainResult, events []alertEvent) string {
+	r := newRunJSON(run.ID, run.Started, results)
+	r.Captive, r.Hijack, r.Proxy, r.Alerts = run.Captive, run.Hijack, run.Proxy, events
+	r.Shard = run.Shard
+	if run.Meta.UUID != "" {
+		r.Meta, r.Host = &run.Meta, run.Meta.Host
+	}
+	data, _ := json.MarshalIndent(r, "", "  ")
+	return string(data) + "\n"
+}
+
+// runJSON 是一轮检测结果的 JSON 表示
+type runJSON struct {
+	RunID     string       `json:"run_id"`
+	StartedAt time.Time    `json:"started_at"`
+	Domains   int          `json:"domains"`
+	Polluted  int          `json:"polluted"`
+	Rebinding int          `json:"rebinding"`
+	Results   []domainJSON `json:"results"`
+	Captive   string       `json:"captive_portal,omitempty"`
+	Hijack    string       `json:"dns_interception,omitempty"`
+	Proxy     string       `json:"dns_proxy,omitempty"`
+	Shard     string       `json:"shard,omitempty"`
+	Host      string       `json:"host,omitempty"`
+	Sources   []runSource  `json:"sources,omitempty"`
+	Meta      *runMeta     `json:"meta,omitempty"`
+	Alerts    []alertEvent `json:"alerts,omitempty"` // 本轮的告警状态变化
+
+	Inconclusive int `json:"inconclusive"` // 无法判定的域名数
+}
+
+type domainJSON struct {
+	Domain       string   `json:"domain"`
+	ASCIIDomain  string   `json:"ascii_domain,omitempty"`
+	Polluted     bool     `json:"polluted"`
+	Rebinding    bool     `json:"rebinding,omitempty"`
+	Severity     string   `json:"severity"`
+	Summary      string   `json:"summary"`
+	Expected     []string `json:"expected,omitempty"`
+	PinnedIPs    []string `json:"expected_ips,omitempty"`
+	CIDRs        []string `json:"expected_cidrs,omitempty"`
+	Rules        string   `json:"rules,omitempty"`
+	DNSLatencyMs float64  `json:"dns_latency_ms"`
+	Trace        []string `json:"trace,omitempty"`
+	SampledFrom  int      `json:"sampled_from,omitempty"`
+	Muted        string   `json:"muted,omitempty"`
+	Debounced    bool     `json:"debounced_polluted"`
+	Streak       int      `json:"streak,omitempty"`
+	Suspicious   []string `json:"suspicious,omitempty"`
+	OffBaseline  []string `json:"off_baseline,omitempty"`
+	Hijacks      []string `json:"hijacks,omitempty"`
+	IPs          []ipJSON `json:"ips"`
+
+	Routes []routeTrace `json:"traceroute,omitempty"`
+
+	Transport *crossCheck `json:"transport,omitempty"`
+	Authority *authCheck  `json:"authoritative,omitempty"`
+
+	Resolution *fanoutResult `json:"resolution,omitempty"` // -resolver 指定多个解析器时各解析器的应答
+	DualStack  *dualCheck    `json:"dual_stack,omitempty"`
+	Failover   *failover     `json:"failover,omitempty"` // 主解析器失败后查询备用解析器的过程
+
+	CNAMEs    []string `json:"cnames,omitempty"`    // 解析经过的 CNAME 目标
+	Blockpage string   `json:"blockpage,omitempty"` // CNAME 链经过的已知拦截页
+
+	Patterns []string `json:"interference,omitempty"` // 命中的运营商干扰特征
+	GeoHint  string   `json:"geo_hint,omitempty"`     // 解析到的 IP 所在国家与 hosting 相反的提示
+	Verdict  string   `json:"verdict"`                // clean、polluted、rebinding 或 error（无法判定）
+
+	Source string `json:"source,omitempty"` // dnscheck merge 合并时结果所属的来源主机
+}
+
+type ipJSON struct {
+	IP        string  `json:"ip"`
+	LLC       string  `json:"llc,omitempty"`
+	RawLLC    string  `json:"raw_llc,omitempty"`
+	ASN       uint32  `json:"asn,omitempty"`
+	Country   string  `json:"country,omitempty"`
+	Matched   bool    `json:"matched"`
+	Forbidden string  `json:"forbidden,omitempty"`
+	Private   bool    `json:"private,omitempty"`
+	Source    string  `json:"source,omitempty"`
+	Error     string  `json:"error,omitempty"`
+	LatencyMs float64 `json:"latency_ms"`
+
+	Anomalies    []string `json:"anomalies,omitempty"`
+	AnomalyScore int      `json:"anomaly_score,omitempty"`
+
+	Probe *probeResult `json:"probe,omitempty"` // -probe 的可达性探测结果
+	Ports *portScan    `json:"ports,omitempty"` // -probe-ports 的端口探测结果
+	Page  *pageCheck   `json:"content,omitempty"`
+
+	Listed []string `json:"threat_lists,omitempty"` // 被列入的 DNSBL 与威胁情报
+}
+
+// newRunJSON 将检测结果转换为 JSON 表示
+func newRunJSON(runID string, at time.Time, results []DomainResult) runJSON {
+	run := runJSON{
+		RunID:     runID,
+		StartedAt: at,
+		Domains:   len(results),
+		Polluted:  countPolluted(results),
+		Rebinding: countRebinding(results),
+		Results:   make([]domainJSON, 0, len(results)),
+
+		Inconclusive: countFailed(results),
+	}
+	run.Host, _ = os.Hostname()
+	for _, r := range results {
+		d := domainJSON{
+			Domain:       r.Domain,
+			Polluted:     r.IsPolluted,
+			Rebinding:    r.Rebinding,
+			Severity:     domainSeverity(r).String(),
+			Summary:      r.Summary,
+			PinnedIPs:    r.PinnedIPs,
+			CIDRs:        r.CIDRs,
+			DNSLatencyMs: float64(r.DNSLatency) / float64(time.Millisecond),
+			IPs:          make([]ipJSON, 0, len(r.IPResults)),
+		}
+		if r.ASCIIDomain != r.Domain {
+			d.ASCIIDomain = r.ASCIIDomain
+		}
+		for _, e := range r.Expected {
+			d.Expected = append(d.Expected, e.String())
+		}
+		if r.Rules != nil {
+			d.Rules = r.Rules.String()
+		}
+		d.SampledFrom = r.SampledFrom
+		d.Muted = r.Muted
+		d.Debounced, d.Streak = r.Debounced, r.Streak
+		d.Suspicious, d.OffBaseline, d.Hijacks = r.Suspicious, r.OffBaseline, r.Hijacks
+		d.Routes, d.Transport, d.Authority = r.Routes, r.Transport, r.Authority
+		d.Resolution, d.DualStack, d.Failover = r.Resolution, r.DualStack, r.Failover
+		d.CNAMEs, d.Blockpage, d.Patterns = r.CNAMEs, r.Blockpage, r.Patterns
+		d.GeoHint = r.GeoHint
+		d.Verdict = verdictOf(r)
+		for _, step := range r.Trace {
+			d.Trace = append(d.Trace, step.String())
+		}
+		for _, ip := range r.IPResults {
+			j := ipJSON{
+				IP:        ip.IP,
+				LLC:       ip.ActualLLC,
+				ASN:       ip.ASN,
+				Country:   ip.Country,
+				Matched:   r.ipMatched(ip),
+				Forbidden: ip.Forbidden,
+				Private:   ip.Private,
+				Source:    ip.Source,
+				LatencyMs: float64(ip.Latency) / float64(time.Millisecond),
+
+				Anomalies:    ip.Anomalies,
+				AnomalyScore: ip.AnomalyScore,
+
+				Probe: ip.Probe,
+				Ports: ip.Ports,
+				Page:  ip.Page,
+
+				Listed: ip.Listed,
+			}
+			if ip.RawLLC != ip.ActualLLC {
+				j.RawLLC = ip.RawLLC
+			}
+			if ip.Error != nil {
+				j.Error = ip.Error.Error()
+			}
+			d.IPs = append(d.IPs, j)
+		}
+		run.Results = append(run.Results, d)
+	}
+	return run
+}
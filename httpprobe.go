@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ---------- HTTP 探测与跳转链分析（-probe http/https） ----------
+
+// fetch 以被检测的域名请求 scheme://host/，连接解析到的 IP，跟随并记录跳转链；
+// 最终页面的标题命中特征库中的拦截页标题时同样记为疑似劫持
+func (p *reachProber) fetch(ctx context.Context, host string, ip net.IP, portals []string, titles []titleSig) probeResult {
+	r := probeResult{Method: p.method}
+	client := pinnedClient(host, ip, func(u *url.URL) { r.Redirects = append(r.Redirects, u.String()) })
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.scheme+"://"+host+"/", nil)
+	if err != nil {
+		r.State, r.Error = reachFailed, err.Error()
+		return r
+	}
+	req.Header.Set("User-Agent", "dnscheck")
+	start := time.Now()
+	resp, err := client.Do(req)
+	r.RTTMs = float64(time.Since(start)) / float64(time.Millisecond)
+	r.Portal = portalReason(r.Redirects, portals)
+	if err != nil {
+		var uerr *url.Error
+		if errors.As(err, &uerr) {
+			err = uerr.Err
+		}
+		if r.State, r.Error = classifyDialError(err); r.State != reachRefused {
+			r.RTTMs = 0
+		}
+		return r
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	resp.Body.Close()
+	r.State, r.Status = reachOK, resp.StatusCode
+	if m := titlePattern.FindSubmatch(body); m != nil && r.Portal == "" {
+		title := strings.TrimSpace(html.UnescapeString(string(m[1])))
+		if name := matchTitle(title, titles); name != "" {
+			r.Portal = fmt.Sprintf("页面标题 %q 符合拦截页特征（%s）", title, name)
+		}
+	}
+	return r
+}
+
+// pinnedClient 返回把对 host 的连接固定到 ip 的 HTTP 客户端，跟随最多 -max-redirects 次跳转，
+// 每一跳的目标交给 onRedirect；超出次数时仍记录下一跳的目标，但不再请求。
+// 跳转到其他主机时按正常方式解析，只有对被检测域名本身的请求连接到该 IP
+func pinnedClient(host string, ip net.IP, onRedirect func(*url.URL)) *http.Client {
+	dialer := &net.Dialer{Timeout: probeTimeout}
+	return &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+				if h, port, err := net.SplitHostPort(addr); err == nil && strings.EqualFold(h, host) {
+					addr = net.JoinHostPort(ip.String(), port)
+				}
+				return dialer.DialContext(ctx, network, addr)
+			},
+			TLSHandshakeTimeout: probeTimeout,
+			DisableKeepAlives:   true,
+		},
+		Timeout: probeTimeout * time.Duration(*maxRedirect+1),
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			onRedirect(req.URL)
+			if len(via) > *maxRedirect {
+				return http.ErrUseLastResponse
+			}
+			return nil
+		},
+	}
+}
+
+// portalReason 检查跳转链：跳转到 portals 中的域名（含子域名）或直接跳转到 IP 地址，
+// 是运营商门户、广告注入等商业劫持的常见特征，与国家级的 DNS 污染不同
+func portalReason(redirects []string, portals []string) string {
+	for _, target := range redirects {
+		u, err := url.Parse(target)
+		if err != nil {
+			continue
+		}
+		h := strings.ToLower(u.Hostname())
+		if net.ParseIP(h) != nil {
+			return "跳转到 IP 地址 " + h
+		}
+		for _, p := range portals {
+			p = strings.ToLower(strings.Trim(p, "."))
+			if h == p || strings.HasSuffix(h, "."+p) {
+				return "跳转到运营商门户或广告页 " + h
+			}
+		}
+	}
+	return ""
+}
+
+// countHijacked 统计 HTTP 探测发现跳转到运营商门户或广告页的域名数
+func countHijacked(results []DomainResult) int {
+	n := 0
+	for _, r := range results {
+		if len(r.Hijacks) > 0 {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"fmt"
+
+	"golang.org/x/net/idna"
+)
+
+// ---------- 国际化域名（IDN） ----------
+
+// toASCIIDomain 将可能包含 Unicode 标签的域名转换为 punycode（ASCII）形式，
+// 纯 ASCII 域名原样返回。解析与 API 查询统一使用该结果。
+func toASCIIDomain(name string) (string, error) {
+	ascii, err := idna.Lookup.ToASCII(name)
+	if err != nil {
+		return "", fmt.Errorf("无效的国际化域名 %s: %w", name, err)
+	}
+	return ascii, nil
+}
+
+// displayDomain 返回报告中展示的域名：若配置中的写法与 punycode 形式不同，则同时显示两者
+func displayDomain(name, ascii string) string {
+	if ascii == "" || ascii == name {
+		return name
+	}
+	return fmt.Sprintf("%s (%s)", name, ascii)
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ---------- InfluxDB 行协议输出 ----------
+
+var (
+	influxKeyEscaper   = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `)
+	influxFieldEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+)
+
+// buildInfluxLines 将检测结果编码为 InfluxDB 行协议：
+//
+//	dnscheck_run      汇总：域名数、污染数、污染率
+//	dnscheck_resolver 每个解析器：查询数、失败数、p50/p95/最大延迟
+//	dnscheck_domain   每个域名：是否污染、IP 数、查询失败数、DNS 解析耗时
+//	dnscheck_ip       每个 IP：LLC、是否符合预期、查询耗时
+//
+// dnscheck_run 与 dnscheck_resolver 始终统计全部结果，filter 只影响域名和 IP 两类数据点
+func buildInfluxLines(results []DomainResult, meta runMeta, at time.Time, filter resultFilter) string {
+	ts := at.UnixNano()
+	var b strings.Builder
+
+	polluted := 0
+	for _, r := range results {
+		if r.IsPolluted {
+			polluted++
+		}
+	}
+	rate := 0.0
+	if len(results) > 0 {
+		rate = float64(polluted) / float64(len(results)) * 100
+	}
+	// 运行元数据作为字段而不是标签写入，避免每轮产生新的序列
+	var metaFields string
+	if meta.UUID != "" {
+		metaFields = fmt.Sprintf(",run_uuid=\"%s\",version=\"%s\",host=\"%s\",egress_ip=\"%s\",config_hash=\"%s\"",
+			meta.UUID, influxFieldEscaper.Replace(meta.Version), influxFieldEscaper.Replace(meta.Host), meta.EgressIP, meta.ConfigHash)
+	}
+	b.WriteString(fmt.Sprintf("dnscheck_run domains=%di,polluted=%di,rebinding=%di,inconclusive=%di,pollution_rate=%g%s %d\n", len(results), polluted, countRebinding(results), countFailed(results), rate, metaFields, ts))
+	for _, l := range collectResolverLatency(results) {
+		b.WriteString(fmt.Sprintf("dnscheck_resolver,resolver=%s queries=%di,failures=%di,p50_ms=%g,p95_ms=%g,max_ms=%g %d\n",
+			influxKeyEscaper.Replace(l.Resolver), l.Queries, l.Failures,
+			float64(l.P50)/float64(time.Millisecond), float64(l.P95)/float64(time.Millisecond), float64(l.Max)/float64(time.Millisecond), ts))
+	}
+
+	for _, r := range filter.Apply(results) {
+		errs := 0
+		for _, ip := range r.IPResults {
+			if ip.Error != nil {
+				errs++
+			}
+		}
+		b.WriteString(fmt.Sprintf("dnscheck_domain,domain=%s polluted=%t,rebinding=%t,inconclusive=%t,ip_count=%di,errors=%di,dns_latency_ms=%g,summary=\"%s\" %d\n",
+			influxKeyEscaper.Replace(r.Domain), r.IsPolluted, r.Rebinding, verdictOf(r) == verdictError, len(r.IPResults), errs,
+			float64(r.DNSLatency)/float64(time.Millisecond), influxFieldEscaper.Replace(r.Summary), ts))
+
+		for _, ip := range r.IPResults {
+			llc := ip.ActualLLC
+			if llc == "" {
+				llc = "unknown"
+			}
+			matched := r.ipMatched(ip)
+			b.WriteString(fmt.Sprintf("dnscheck_ip,domain=%s,ip=%s,llc=%s matched=%t,error=%t,lookup_latency_ms=%g %d\n",
+				influxKeyEscaper.Replace(r.Domain), ip.IP, influxKeyEscaper.Replace(llc), matched, ip.Error != nil,
+				float64(ip.Latency)/float64(time.Millisecond), ts))
+		}
+	}
+	return b.String()
+}
+
+// writeInflux 将行协议数据 POST 到 InfluxDB 写入地址（v1 的 /write?db=... 或 v2 的 /api/v2/write?org=...&bucket=...）
+func writeInflux(url, token, lines string, timeout time.Duration) error {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewBufferString(lines))
+	if err != nil {
+		return fmt.Errorf("无效的 InfluxDB 地址: %w", err)
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	if token != "" {
+		req.Header.Set("Authorization", "Token "+token)
+	}
+	client := http.Client{Timeout: timeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("写入 InfluxDB 失败: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("写入 InfluxDB 失败: 状态码 %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// exportInflux 在指定了 -influx-url 时写入检测结果（-redact 时与报告一样脱敏），未启用时不做任何事
+func exportInflux(results []DomainResult, run runInfo) error {
+	if *influxURL == "" {
+		return nil
+	}
+	meta := redaction.Run(run).Meta
+	return writeInflux(*influxURL, *influxToken, redaction.Text(buildInfluxLines(results, meta, time.Now(), resultFilter{})), *timeout)
+}
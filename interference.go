@@ -0,0 +1,167 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ---------- 运营商干扰特征（-isp-profiles 与特征库的 interference_profiles） ----------
+
+// 各地运营商的干扰方式相对固定：NXDOMAIN 重定向到自己的导航页、CNAME 到广告注入的主机、
+// 用自建的缓存节点代答热门域名。匹配这些特征不改变判定，只用来说明判定的原因
+
+// ispProfile 描述一个运营商（或地区）已知的干扰行为
+type ispProfile struct {
+	Name        string   `json:"name" yaml:"name"`                           // 运营商名称，如 "中国电信"
+	Region      string   `json:"region,omitempty" yaml:"region"`             // 地区，仅用于显示
+	NXDomainIPs []string `json:"nxdomain_ips,omitempty" yaml:"nxdomain_ips"` // 不存在的域名被重定向到的 IP 或网段
+	AdCNAMEs    []string `json:"ad_cnames,omitempty" yaml:"ad_cnames"`       // 广告注入使用的 CNAME 目标，匹配该域名及其子域名
+	CacheRanges []string `json:"cache_ranges,omitempty" yaml:"cache_ranges"` // 运营商缓存节点的网段
+
+	nxdomain []*net.IPNet // 由 compile 根据 nxdomain_ips 构建
+	cache    []*net.IPNet // 由 compile 根据 cache_ranges 构建
+}
+
+// compile 校验并编译干扰特征
+func (p *ispProfile) compile() error {
+	if strings.TrimSpace(p.Name) == "" {
+		return fmt.Errorf("干扰特征缺少 name")
+	}
+	p.nxdomain, p.cache = nil, nil
+	for _, s := range p.NXDomainIPs {
+		n, err := parseIPOrCIDR(s)
+		if err != nil {
+			return fmt.Errorf("%s 的 nxdomain_ips: %w", p.Name, err)
+		}
+		p.nxdomain = append(p.nxdomain, n)
+	}
+	for _, s := range p.CacheRanges {
+		n, err := parseIPOrCIDR(s)
+		if err != nil {
+			return fmt.Errorf("%s 的 cache_ranges: %w", p.Name, err)
+		}
+		p.cache = append(p.cache, n)
+	}
+	for i, s := range p.AdCNAMEs {
+		name, err := normalizeDomainName(s)
+		if err != nil {
+			return fmt.Errorf("%s 的 ad_cnames: %w", p.Name, err)
+		}
+		p.AdCNAMEs[i] = name
+	}
+	return nil
+}
+
+// label 返回显示用的名称，如 "中国电信（CN）"
+func (p *ispProfile) label() string {
+	if p.Region != "" {
+		return fmt.Sprintf("%s（%s）", p.Name, p.Region)
+	}
+	return p.Name
+}
+
+// loadISPProfiles 读取 -isp-profiles 指定的 YAML（或 JSON）文件，内容为干扰特征的列表
+func loadISPProfiles(path string) ([]ispProfile, error) {
+	if path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取干扰特征文件失败: %w", err)
+	}
+	var profiles []ispProfile
+	if err := yaml.Unmarshal(data, &profiles); err != nil {
+		return nil, fmt.Errorf("解析干扰特征文件 %s 失败: %w", path, err)
+	}
+	for i := range profiles {
+		if err := profiles[i].compile(); err != nil {
+			return nil, fmt.Errorf("干扰特征文件 %s 第 %d 条: %w", path, i+1, err)
+		}
+	}
+	return profiles, nil
+}
+
+// loadDetectionSignatures 读取 -signatures 特征库，并追加 -isp-profiles 中的干扰特征
+func loadDetectionSignatures() (*signatureBundle, error) {
+	b, err := loadSignatures(*sigFile)
+	if err != nil {
+		return nil, err
+	}
+	extra, err := loadISPProfiles(*ispFile)
+	if err != nil {
+		return nil, err
+	}
+	b.Profiles = append(b.Profiles, extra...)
+	return b, nil
+}
+
+// applyInterference 将解析结果与各干扰特征比较，命中的特征记入 res.Patterns；
+// 域名已被判定为污染时，汇总后附上命中的特征以说明原因
+func applyInterference(res *DomainResult, profiles []ispProfile) {
+	for i := range profiles {
+		p := &profiles[i]
+		var nx, cached []string
+		for _, ip := range res.IPResults {
+			addr := net.ParseIP(ip.IP)
+			if addr == nil {
+				continue
+			}
+			if containsIP(p.nxdomain, addr) {
+				nx = append(nx, ip.IP)
+			}
+			if containsIP(p.cache, addr) {
+				cached = append(cached, ip.IP)
+			}
+		}
+		if len(nx) > 0 {
+			res.Patterns = append(res.Patterns, fmt.Sprintf("符合 %s 的 NXDOMAIN 重定向特征（%s）", p.label(), strings.Join(nx, ", ")))
+		}
+		for _, c := range res.CNAMEs {
+			if domainUnderAny(c, p.AdCNAMEs) {
+				res.Patterns = append(res.Patterns, fmt.Sprintf("符合 %s 的广告注入 CNAME 特征（%s）", p.label(), c))
+				break
+			}
+		}
+		if len(cached) > 0 {
+			res.Patterns = append(res.Patterns, fmt.Sprintf("属于 %s 的缓存节点（%s）", p.label(), strings.Join(cached, ", ")))
+		}
+	}
+	if res.IsPolluted && len(res.Patterns) > 0 {
+		res.Summary += "；" + strings.Join(res.Patterns, "；")
+	}
+}
+
+// domainUnderAny 判断 name 是否为 parents 中任一域名本身或其子域名
+func domainUnderAny(name string, parents []string) bool {
+	for _, p := range parents {
+		if name == p || strings.HasSuffix(name, "."+p) {
+			return true
+		}
+	}
+	return false
+}
+
+// containsIP 判断 ip 是否属于 nets 中的任一网段
+func containsIP(nets []*net.IPNet, ip net.IP) bool {
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// countInterference 统计命中已知运营商干扰特征的域名数
+func countInterference(results []DomainResult) int {
+	n := 0
+	for _, r := range results {
+		if len(r.Patterns) > 0 {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ---------- 静态 IP 归属映射（-ip-map） ----------
+
+// ipMapEntry 是映射文件中的一条记录
+type ipMapEntry struct {
+	CIDR    string `yaml:"cidr"`
+	LLC     string `yaml:"llc"`
+	ASN     string `yaml:"asn"`
+	Country string `yaml:"country"`
+}
+
+// ipMapping 按最长前缀匹配查找 IP 的归属；nil 表示未配置映射文件
+type ipMapping struct {
+	// 按前缀长度分组，键为网络地址；prefixes 从长到短排列，查找时先命中的即为最长前缀
+	byPrefix map[int]map[string]ipInfo
+	prefixes []int
+	size     int
+}
+
+// loadIPMapping 读取映射文件。扩展名为 .csv 时按“cidr,llc[,asn[,country]]”逐行解析（# 开头为注释），
+// 否则按 YAML 列表解析，每项包含 cidr、llc 以及可选的 asn、country。path 为空时返回 nil。
+func loadIPMapping(path string) (*ipMapping, error) {
+	if path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取 IP 映射文件失败: %w", err)
+	}
+	var entries []ipMapEntry
+	if strings.EqualFold(filepath.Ext(path), ".csv") {
+		entries, err = parseIPMapCSV(string(data))
+	} else {
+		err = yaml.Unmarshal(data, &entries)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("解析 IP 映射文件 %s 失败: %w", path, err)
+	}
+
+	m := &ipMapping{byPrefix: make(map[int]map[string]ipInfo)}
+	for i, e := range entries {
+		if err := m.add(e); err != nil {
+			return nil, fmt.Errorf("IP 映射文件 %s 第 %d 条记录: %w", path, i+1, err)
+		}
+	}
+	for bits := range m.byPrefix {
+		m.prefixes = append(m.prefixes, bits)
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(m.prefixes)))
+	return m, nil
+}
+
+// parseIPMapCSV 解析 CSV 格式的映射文件，字段数可变，忽略空行和 # 开头的注释
+func parseIPMapCSV(data string) ([]ipMapEntry, error) {
+	r := csv.NewReader(strings.NewReader(data))
+	r.Comment = '#'
+	r.FieldsPerRecord = -1
+	r.TrimLeadingSpace = true
+	var entries []ipMapEntry
+	for {
+		rec, err := r.Read()
+		if err == io.EOF {
+			return entries, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(rec) < 2 {
+			return nil, fmt.Errorf("第 %d 条记录至少需要 cidr 和 llc 两列", len(entries)+1)
+		}
+		e := ipMapEntry{CIDR: rec[0], LLC: rec[1]}
+		if len(rec) > 2 {
+			e.ASN = rec[2]
+		}
+		if len(rec) > 3 {
+			e.Country = rec[3]
+		}
+		entries = append(entries, e)
+	}
+}
+
+func (m *ipMapping) add(e ipMapEntry) error {
+	n, err := parseIPOrCIDR(e.CIDR)
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(e.LLC) == "" {
+		return fmt.Errorf("%s 缺少 llc", e.CIDR)
+	}
+	info := ipInfo{LLC: strings.TrimSpace(e.LLC), Country: strings.ToUpper(strings.TrimSpace(e.Country))}
+	if strings.TrimSpace(e.ASN) != "" {
+		if info.ASN, err = parseASN(e.ASN); err != nil {
+			return err
+		}
+	}
+	bits, _ := n.Mask.Size()
+	if len(n.IP) == net.IPv4len {
+		bits += 96 // 与 IPv6 的前缀长度统一到 128 位的空间中
+	}
+	if m.byPrefix[bits] == nil {
+		m.byPrefix[bits] = make(map[string]ipInfo)
+	}
+	key := n.IP.To16().String()
+	if _, dup := m.byPrefix[bits][key]; dup {
+		return fmt.Errorf("%s 重复", e.CIDR)
+	}
+	m.byPrefix[bits][key] = info
+	m.size++
+	return nil
+}
+
+// Lookup 返回包含 ip 的最长前缀记录
+func (m *ipMapping) Lookup(ip net.IP) (ipInfo, bool) {
+	if m == nil {
+		return ipInfo{}, false
+	}
+	ip16 := ip.To16()
+	if ip16 == nil {
+		return ipInfo{}, false
+	}
+	for _, bits := range m.prefixes {
+		key := ip16.Mask(net.CIDRMask(bits, 128)).String()
+		if info, ok := m.byPrefix[bits][key]; ok {
+			return info, true
+		}
+	}
+	return ipInfo{}, false
+}
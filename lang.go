@@ -0,0 +1,376 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// ---------- 报告语言（-lang） ----------
+
+// parseReportLangs 解析 -lang 的值，如 "zh"、"en" 或 "zh,en"；第一种语言用于终端输出与上传。
+// 报告总是先以中文生成，其他语言由中文报告翻译而来（见 translateReport）
+func parseReportLangs(s string) ([]string, error) {
+	var langs []string
+	seen := make(map[string]bool)
+	for _, l := range strings.Split(s, ",") {
+		l = strings.ToLower(strings.TrimSpace(l))
+		if l == "" || seen[l] {
+			continue
+		}
+		if l != "zh" && l != "en" {
+			return nil, fmt.Errorf("-lang 不支持 %q（可选 zh、en，多种语言用逗号分隔）", l)
+		}
+		seen[l] = true
+		langs = append(langs, l)
+	}
+	if len(langs) == 0 {
+		return nil, fmt.Errorf("-lang 不能为空")
+	}
+	return langs, nil
+}
+
+// reportLangs 返回 -lang 指定的语言（已由 validateFlags 检查）
+func reportLangs() []string {
+	langs, _ := parseReportLangs(*reportLang)
+	return langs
+}
+
+// langPath 在生成多种语言时为报告路径加上语言后缀：report.txt → report.en.txt
+func langPath(path, lang string) string {
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + "." + lang + ext
+}
+
+// writeLocalizedReports 按 -lang 写入报告：只有一种语言时写到 path，多种语言时每种语言一个文件（见 langPath）。
+// 返回写入的路径
+func writeLocalizedReports(report, path string) ([]string, error) {
+	langs := reportLangs()
+	if len(langs) == 1 {
+		return []string{path}, writeReportToFile(translateReport(report, langs[0]), path)
+	}
+	paths := make([]string, 0, len(langs))
+	for _, lang := range langs {
+		p := langPath(path, lang)
+		if err := writeReportToFile(translateReport(report, lang), p); err != nil {
+			return paths, err
+		}
+		paths = append(paths, p)
+	}
+	return paths, nil
+}
+
+// translateReport 将中文报告翻译为 lang 指定的语言。翻译按短语进行，对所有报告格式都适用（JSON 与 InfluxDB
+// 行协议中只有取值是中文）；未收录的文字（如判定脚本给出的原因、自定义的告警条件）保持原样
+func translateReport(report, lang string) string {
+	if lang != "en" {
+		return report
+	}
+	for _, p := range enPhrases {
+		report = p.re.ReplaceAllString(report, p.en)
+	}
+	return report
+}
+
+type phrase struct {
+	re *regexp.Regexp
+	en string
+}
+
+// compilePhrases 将成对的正则表达式与替换文本编译为短语表
+func compilePhrases(pairs ...string) []phrase {
+	phrases := make([]phrase, 0, len(pairs)/2)
+	for i := 0; i+1 < len(pairs); i += 2 {
+		phrases = append(phrases, phrase{re: regexp.MustCompile(pairs[i]), en: pairs[i+1]})
+	}
+	return phrases
+}
+
+// enPhrases 是中文报告到英文的短语表，按顺序替换：较长、较具体的短语在前，
+// 通用的词语（如 “正常”、“一致”）与全角标点在最后
+var enPhrases = compilePhrases(
+	// 运行元数据与网络环境警告
+	`运行 (\S+)（dnscheck (\S+)，主机 `, `Run $1 (dnscheck $2, host `,
+	`，出口 IP `, `, egress IP `,
+	`，解析器 `, `, resolvers `,
+	`，配置 `, `, config `,
+	`!!! 检测到强制门户（captive portal）: `, `!!! Captive portal detected: `,
+	`!!! 流量被拦截，本报告的结果不可信，也不评估告警；请先完成网络认证后重新检测`, `!!! Traffic is being intercepted: the results in this report are unreliable and alerts were not evaluated. Complete network authentication and check again`,
+	`!!! 检测到透明 DNS 劫持: `, `!!! Transparent DNS interception detected: `,
+	`!!! 网络中的设备在拦截并代答 DNS 查询，指定的解析器可能根本没有收到查询`, `!!! A device on the network is intercepting and answering DNS queries; the configured resolver may never have received them`,
+	`!!! 检测到透明 DNS 代理: `, `!!! Transparent DNS proxy detected: `,
+	`!!! 发往不同解析器的查询实际由同一个解析器处理，-resolver 与 -transports 中的 UDP/TCP 比较不能说明该解析器本身的行为`, `!!! Queries sent to different resolvers are answered by the same resolver; UDP/TCP comparisons in -resolver and -transports say nothing about that resolver itself`,
+	`发往 (\S+)（不存在的解析器）的 (\S+) 查询得到了应答（`, `the $2 query sent to $1 (a non-existent resolver) was answered (`,
+	`向 (.+?) 查询 (\S+) 得到相同的解析器出口地址 (\S+)`, `querying $2 via $1 returned the same resolver egress address $3`,
+	`(\S+) 被重定向到 (\S+)`, `$1 was redirected to $2`,
+	`(\S+) 返回 HTTP (\d+) 而不是 204`, `$1 returned HTTP $2 instead of 204`,
+	`分片: (\S+)（本报告只包含分到该分片的 (\d+) 个域名）`, `Shard: $1 (this report only covers the $2 domains assigned to this shard)`,
+
+	// 报告头部
+	`DNS 污染检测报告`, `DNS Pollution Report`,
+	`生成时间: `, `Generated at: `,
+	`检测域名总数: `, `Domains checked: `,
+	`私有地址应答域名数: `, `Domains answered with private addresses: `,
+	`被污染域名数: `, `Polluted domains: `,
+	`无法判定的域名数: (\d+)（解析或 IP 归属查询失败，不计入污染）`, `Inconclusive domains: $1 (resolution or IP ownership lookup failed; not counted as polluted)`,
+	`污染率: `, `Pollution rate: `,
+	`污染程度: 正常`, `Pollution level: normal`,
+	`污染程度: `, `Pollution level: `,
+	`轻度污染`, `light pollution`,
+	`中度污染`, `moderate pollution`,
+	`重度污染`, `severe pollution`,
+	`维护中（不告警）的域名数: `, `Domains under maintenance (not alerting): `,
+	`符合已知运营商干扰特征的域名数: `, `Domains matching known ISP interference patterns: `,
+	`解析到的 IP 所在国家与预期相反的域名数: `, `Domains resolving to IPs in an unexpected country: `,
+	`CNAME 指向已知拦截页的域名数: `, `Domains whose CNAME points to a known block page: `,
+	`应答可疑变化的域名数: `, `Domains with suspicious answer changes: `,
+	`传输方式应答不一致的域名数: `, `Domains with inconsistent answers across transports: `,
+	`IPv4 与 IPv6 判定不一致的域名数: `, `Domains whose IPv4 and IPv6 verdicts differ: `,
+	`各解析器应答不一致的域名数: `, `Domains with differing answers across resolvers: `,
+	`主解析器失败、由备用解析器应答的域名数: `, `Domains answered by a fallback resolver: `,
+	`递归应答与权威应答不一致的域名数: `, `Domains whose recursive and authoritative answers differ: `,
+	`疑似运营商劫持的域名数: `, `Domains with suspected ISP hijacking: `,
+	`有 IP 探测不可达的域名数: `, `Domains with unreachable IPs: `,
+	`有 IP 被列入 DNSBL 或威胁情报的域名数: `, `Domains with IPs on DNSBLs or threat feeds: `,
+	`判定待确认的域名数: (\d+)（正式判定中被污染的域名数: (\d+)）`, `Domains pending confirmation: $1 (polluted by confirmed verdict: $2)`,
+	`本地分类（未查询 API）: (\d+) 个 IP（`, `Classified locally (no API query): $1 IPs (`,
+	`映射文件 (\d+)([，）])`, `IP map $1$2`,
+	`缓存 (\d+)([，）])`, `cache $1$2`,
+	`预期网段 (\d+)([，）])`, `expected CIDRs $1$2`,
+	`保留地址 (\d+)([，）])`, `reserved $1$2`,
+	`伪造 IP (\d+)([，）])`, `fake IPs $1$2`,
+	`私有地址 (\d+)([，）])`, `private $1$2`,
+	`被污染的域名:`, `Polluted domains:`,
+	`无法判定的域名:`, `Inconclusive domains:`,
+	`IP 归属查询失败，无法判定`, `IP ownership lookups failed, inconclusive`,
+
+	// 解析器延迟与异常汇总
+	`解析器延迟:`, `Resolver latency:`,
+	`（另有 (\d+) 个解析器未列出）`, `($1 more resolvers not shown)`,
+	`查询 (\d+) 次  p50`, `$1 queries  p50`,
+	`  最大 `, `  max `,
+	`  失败 (\d+) 次`, `  $1 failures`,
+	`异常汇总:`, `Anomaly summary:`,
+	`最常见的非预期 LLC:`, `Most common unexpected LLCs:`,
+	`最常见的非预期 ASN:`, `Most common unexpected ASNs:`,
+	`最常出现的非预期 IP:`, `Most frequent unexpected IPs:`,
+	`返回非预期 IP 最多的解析器:`, `Resolvers returning the most unexpected IPs:`,
+	`(\d+) 次  涉及域名: `, `$1 times  domains: `,
+	`\(空\)`, `(empty)`,
+	` 等 (\d+) 个`, ` and others ($1 in total)`,
+
+	`解析器热力图（X 污染，\? 与主解析器的应答没有交集，! 查询失败，\. 正常）:`, `Resolver heatmap (X polluted, ? no overlap with the primary resolver, ! query failed, . OK):`,
+	`主解析器（-resolver）`, `primary resolver (-resolver)`,
+	` 被污染 (\d+)/(\d+)`, ` polluted $1/$2`,
+	`（另有 (\d+) 个域名在各解析器上均正常，未列出）`, `($1 more domains OK on all resolvers, not shown)`,
+
+	// 详细结果
+	`详细结果（已过滤，显示 (\d+)/(\d+) 个域名）:`, `Details (filtered, showing $1/$2 domains):`,
+	`详细结果:`, `Details:`,
+	`(?m)^域名: `, `Domain: `,
+	`  汇总: `, `  Summary: `,
+	`\(污染: (true|false)\)`, `(polluted: $1)`,
+	`\(污染: 无法判定\)`, `(polluted: inconclusive)`,
+	`  规则: `, `  Rules: `,
+	`  解析路径: `, `  Resolution path: `,
+	`（共 (\d+) 次查询）`, ` ($1 queries in total)`,
+	`（响应异常）`, ` (anomalous response)`,
+	`  维护中: `, `  Under maintenance: `,
+	`维护窗口`, `maintenance window`,
+	`已静音至 `, `muted until `,
+	`  基线: (.+) 不在基线中`, `  Baseline: $1 not in the baseline`,
+	`  可疑变化: `, `  Suspicious changes: `,
+	`AS(\d+)（(.+?)）在过去 (\d+) 次检测中从未出现`, `AS$1 ($2) never seen in the past $3 checks`,
+	`解析到 (\d+) 个逻辑应答，历史均值为 `, `resolved to $1 logical answers, historical mean `,
+	`解析到 (\d+) 个IP，历史均值为 `, `resolved to $1 IPs, historical mean `,
+	`  传输方式比较`, `  Transport comparison`,
+	`  多解析器（(\w+)，最快 `, `  Resolvers ($1, fastest `,
+	`  多解析器（`, `  Resolvers (`,
+	`  解析器切换: 主解析器失败（`, `  Resolver failover: primary failed (`,
+	`），备用解析器均失败: `, `), all fallback resolvers failed: `,
+	`），由 (\S+) 应答: `, `), answered by $1: `,
+	`主解析器与备用解析器均失败: 主解析器: `, `Primary and fallback resolvers all failed: primary: `,
+	` → 未查询`, ` → not queried`,
+	` → 未等待`, ` → not awaited`,
+	` → 无 IPv4 地址`, ` → no IPv4 address`,
+	`所有解析器均失败: `, `all resolvers failed: `,
+	`  权威比较`, `  Authoritative comparison`,
+	`  双栈比较（一致）`, `  Dual-stack comparison (consistent)`,
+	`  双栈比较（不一致）`, `  Dual-stack comparison (mismatch)`,
+	`  双栈比较（没有 AAAA 记录）`, `  Dual-stack comparison (no AAAA records)`,
+	`  双栈比较（AAAA 查询失败）`, `  Dual-stack comparison (AAAA lookup failed)`,
+	`；AAAA 查询失败: `, `; AAAA lookup failed: `,
+	`(IPv[46]) 不符合预期`, `$1 unexpected`,
+	`(IPv[46]) 正常`, `$1 clean`,
+	`  干扰特征: `, `  Interference patterns: `,
+	`  国家不符: `, `  Country mismatch: `,
+	`预期的提供方都在境外，但解析到位于 `, `Expected providers are all foreign, but resolved to IPs located in `,
+	`，但解析到境外的 IP: `, `, but resolved to foreign IPs: `,
+	`预期的提供方都在 `, `Expected providers are all in `,
+	`符合 (.+?) 的 NXDOMAIN 重定向特征`, `matches the $1 NXDOMAIN redirect pattern`,
+	`符合 (.+?) 的广告注入 CNAME 特征`, `matches the $1 ad-injection CNAME pattern`,
+	`属于 (.+?) 的缓存节点`, `belongs to $1 cache nodes`,
+	`CNAME 指向已知的拦截页 `, `CNAME points to a known block page `,
+	`Cisco Umbrella / OpenDNS 拦截页`, `Cisco Umbrella / OpenDNS block page`,
+	`Google 强制安全搜索`, `Google forced SafeSearch`,
+	`YouTube 严格受限模式`, `YouTube strict restricted mode`,
+	`YouTube 中等受限模式`, `YouTube moderate restricted mode`,
+	`Bing 严格安全搜索`, `Bing strict SafeSearch`,
+	`DuckDuckGo 安全搜索`, `DuckDuckGo safe search`,
+	`配置的拦截页`, `configured block page`,
+	`A 记录符合预期，但 AAAA 记录不符合预期`, `A records as expected, but AAAA records unexpected`,
+	` → 失败: `, ` → failed: `,
+	` 区域 (\S+)，(\d+) 个权威服务器`, ` zone $1, $2 authoritative servers`,
+	`，权威应答 `, `, authoritative answer `,
+	`，不在权威应答中: `, `, not in the authoritative answer: `,
+	`  疑似运营商劫持: `, `  Suspected ISP hijacking: `,
+	`  待确认: 正式判定仍为 (\S+)，本轮判定已连续 (\d+)/(\d+) 轮为 (\S+)`, `  Pending: confirmed verdict is still $1; this run's verdict has been $4 for $2/$3 runs`,
+	`  抽样: 共解析到 (\d+) 个 IP，只查询其中 (\d+) 个`, `  Sampling: resolved $1 IPs, only $2 queried`,
+	`  路由追踪 `, `  Traceroute `,
+	`未到达`, `not reached`,
+	`已到达`, `reached`,
+	`    可达性: `, `    Reachability: `,
+	`，跳转: `, `, redirects: `,
+	`（疑似运营商劫持: `, ` (suspected ISP hijacking: `,
+	`跳转到 IP 地址 `, `redirected to IP address `,
+	`跳转到运营商门户或广告页 `, `redirected to an ISP portal or ad page `,
+	`连接被拒绝（RST）`, `connection refused (RST)`,
+	`（(\S+) 回应 ICMP 不可达）`, ` ($1 replied ICMP unreachable)`,
+	`有回应`, `replied`,
+	`连接成功`, `connected`,
+	`超时无回应`, `timed out`,
+	`探测失败: `, `probe failed: `,
+	`    端口探测`, `    Port probe`,
+	`；端口探测: `, `; port probe: `,
+	`    威胁情报: 被列入 `, `    Threat intel: listed on `,
+	`；威胁情报: `, `; threat intel: `,
+	` 被列入 `, ` listed on `,
+	`正常服务`, `serving`,
+	`疑似拦截页`, `suspected block page`,
+	`拒绝所有连接`, `rejecting all connections`,
+	`黑洞`, `blackhole`,
+	`无法判断`, `undetermined`,
+	`    内容校验: `, `    Content check: `,
+	`请求失败: `, `request failed: `,
+	`不符合（HTTP`, `mismatch (HTTP`,
+	`符合（HTTP`, `match (HTTP`,
+	`，标题 `, `, title `,
+	`，缺少关键字 `, `, missing keywords `,
+	`    响应异常（评分 (\d+)）: `, `    Response anomalies (score $1): `,
+
+	// 单个 IP 的结果
+	`  IP (\S+): 错误 - `, `  IP $1: error - `,
+	` \[命中黑名单: `, ` [blocklisted: `,
+	`私有地址 - 可能是 DNS 重绑定或路由器劫持`, `private address - possible DNS rebinding or router hijacking`,
+	`保留地址 - 可能被污染`, `reserved address - possibly polluted`,
+	`特征库中的伪造 IP - 已被污染`, `known fake IP from the signature bundle - polluted`,
+	`页面标题 (".*?") 符合拦截页特征`, `page title $1 matches a block page signature`,
+	`\(期望: 固定 IP `, `(expected: pinned IPs `,
+	`属于预期网段 `, `in expected CIDRs `,
+	` \[原始: `, ` [raw: `,
+	` 国家=`, ` country=`,
+	` 或网段 `, ` or CIDRs `,
+	`\(期望: `, `(expected: `,
+
+	// 判定汇总与原因
+	`DNS 解析失败: `, `DNS resolution failed: `,
+	`没有找到 IPv4 地址`, `no IPv4 address found`,
+	`检测超时: 超出单个域名时限 -domain-budget `, `check timed out: exceeded per-domain budget -domain-budget `,
+	`检测超时: 超出整轮时限 -run-deadline `, `check timed out: exceeded run deadline -run-deadline `,
+	`检测已取消`, `check cancelled`,
+	`严格模式：部分 IP 不符合预期`, `strict mode: some IPs do not match expectations`,
+	`所有 IP 均符合预期`, `all IPs match expectations`,
+	`宽松模式：无任何 IP 符合预期`, `lenient mode: no IP matches expectations`,
+	`至少有一个 IP 符合预期`, `at least one IP matches expectations`,
+	`权威服务器返回 NXDOMAIN，递归解析却得到了 IP`, `the authoritative servers returned NXDOMAIN but recursive resolution returned IPs`,
+	`递归应答与权威服务器的应答没有交集`, `the recursive answer has no overlap with the authoritative answer`,
+	`(\d+) 个 IP 不在基线中`, `$1 IPs not in the baseline`,
+	`(\d+) 个 IP 返回的页面内容不符合预期`, `$1 IPs returned unexpected page content`,
+	`(\d+) 个 IP 命中黑名单`, `$1 IPs blocklisted`,
+	`命中黑名单: `, `blocklisted: `,
+	`IP 属于 `, `IP belongs to `,
+	`所有 IP 均在固定列表中`, `all IPs are in the pinned list`,
+	`(\d+) 个 IP 不在固定列表中`, `$1 IPs not in the pinned list`,
+	`不在固定列表中`, `not in the pinned list`,
+	`解析到 (\d+) 个 IP，预期 (\d+) 个`, `resolved $1 IPs, expected $2`,
+	`解析到私有地址 (.+?)，可能是 DNS 重绑定或路由器劫持`, `resolved to private addresses $1, possible DNS rebinding or router hijacking`,
+	`（判定脚本出错: `, ` (verdict script error: `,
+	`判定脚本: `, `verdict script: `,
+	`（(\S+) 的 (\d+) 个 IP）`, ` ($2 IPs of $1)`,
+	`可能被污染`, `possibly polluted`,
+
+	// 响应异常
+	`问题部分与查询不一致`, `question section does not match the query`,
+	`递归解析器返回了权威应答（AA 位）`, `recursive resolver returned an authoritative answer (AA bit)`,
+	`查询 (\S+) 却返回了 (\S+) 记录`, `queried $1 but got $2 records`,
+	`A 记录 TTL 为 0`, `A record TTL is 0`,
+	`(\S+) 记录 (\S+) 的 TTL 为 0`, `$1 record $2 has TTL 0`,
+	`应答包含 (\d+) 条 (\S+) 记录，远多于正常水平`, `answer contains $1 $2 records, far more than normal`,
+	`OPT 记录的名称不是根域（`, `OPT record name is not the root (`,
+	`响应包含 (\d+) 条 OPT 记录`, `response contains $1 OPT records`,
+	`查询未使用 EDNS，响应却包含 OPT 记录`, `query did not use EDNS but the response contains an OPT record`,
+	`根服务器直接给出了 A 记录（正常只返回委派）`, `root server answered with an A record (normally only a referral)`,
+	`(\S+) 层服务器的应答未设置 AA 位`, `answer from the $1 level server lacks the AA bit`,
+	`(\d+) 个响应未回显查询名的大小写`, `$1 responses did not echo the query name case`,
+	`(\d+) 个报文 ID 不匹配`, `$1 packets with mismatched IDs`,
+	`(\d+) 个报文来自其他地址或端口`, `$1 packets from another address or port`,
+	`: (\d+)/(\d+) 个查询有响应`, `: $1/$2 queries answered`,
+	`，疑似存在伪造应答的中间设备`, `, a middlebox forging answers is suspected`,
+	`，大小写、ID 与来源端口均一致`, `, case, IDs and source ports all consistent`,
+
+	// 查询错误
+	`API 返回非 200 状态码: `, `API returned non-200 status: `,
+	`所有 API 尝试均失败: `, `all API attempts failed: `,
+	`HTTP 请求失败: `, `HTTP request failed: `,
+	`读取响应体失败: `, `reading response body failed: `,
+	`无法从响应中提取 LLC 字段`, `could not extract the LLC field from the response`,
+	`原始响应已保存至 `, `raw response saved to `,
+	`响应内容: `, `response: `,
+	`响应中 (\S+) 处没有 LLC`, `no LLC at $1 in the response`,
+	`端点已熔断，跳过请求`, `endpoint circuit open, request skipped`,
+	`JSON 解析失败: `, `JSON parse failed: `,
+	`XML 解析失败: `, `XML parse failed: `,
+	`CSV 解析失败: `, `CSV parse failed: `,
+	`响应为空`, `empty response`,
+
+	// 运行统计与熔断
+	`运行统计:`, `Run statistics:`,
+	`  耗时: `, `  Duration: `,
+	`  DNS 查询: (\d+) 次`, `  DNS queries: $1`,
+	`  API 请求: (\d+) 次（其中重试 (\d+) 次），有效速率 `, `  API requests: $1 ($2 retries), effective rate `,
+	`  缓存命中: (\d+) 次`, `  Cache hits: $1`,
+	`  合并查询: (\d+) 次（与其他域名同时查询同一 IP，共享一次请求的结果）`, `  Coalesced lookups: $1 (the same IP queried concurrently for other domains, sharing one request)`,
+	`  限速等待: (.+?)（各请求累计）`, `  Rate-limit wait: $1 (summed over requests)`,
+	`  检测超时: (\d+) 个域名`, `  Timed out: $1 domains`,
+	`其他端点 `, `other endpoints `,
+	`API 熔断汇总:`, `API circuit breaker summary:`,
+	`: 熔断 (\d+) 次，跳过请求 (\d+) 次，当前状态 `, `: tripped $1 times, skipped $2 requests, current state `,
+	`  状态变化:`, `  State changes:`,
+	`(state |: |-> )熔断`, `${1}open`,
+	`(state |: |-> )半开`, `${1}half-open`,
+	`(state |: |-> )正常`, `${1}closed`,
+
+	// 告警与可用性
+	`(?m)^告警:`, `Alerts:`,
+	`\[触发\] 告警 `, `[firing] alert `,
+	`\[恢复\] 告警 `, `[resolved] alert `,
+	` 触发: (.+?)（当前值 (\S+)，连续 (\d+) 轮）`, ` firing: $1 (current value $2, $3 consecutive runs)`,
+	` 恢复: (.+?) 不再满足（当前值 (\S+)，持续 (\S+)）`, ` resolved: $1 no longer holds (current value $2, lasted $3)`,
+	`干净解析可用性（污染视为不可用）:`, `Clean-resolution availability (pollution counts as unavailable):`,
+
+	// 通用词语与全角标点
+	` - 正常`, ` - OK`,
+	`部分不同`, `partially different`,
+	`不一致`, `inconsistent`,
+	`一致`, `consistent`,
+	`无法比较`, `not comparable`,
+	`不可达`, `unreachable`,
+	`(\S)（`, `$1 (`,
+	`（`, `(`,
+	`）`, `)`,
+	`，`, `, `,
+	`；`, `; `,
+	`、`, `, `,
+	`：`, `: `,
+)
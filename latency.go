@@ -0,0 +1,141 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ---------- 解析器延迟统计 ----------
+
+// systemResolverName 是系统解析器在统计中的名称
+const systemResolverName = "system"
+
+// resolverLatencyTopN 是报告中列出的解析器数量上限；迭代解析会涉及大量权威服务器
+const resolverLatencyTopN = 10
+
+// resolverLatency 汇总一轮检测中单个解析器的查询延迟
+type resolverLatency struct {
+	Resolver string
+	Queries  int // 有响应的查询数
+	Failures int // 超时或网络错误的查询数
+	Sum      time.Duration
+	P50      time.Duration
+	P95      time.Duration
+	Max      time.Duration
+}
+
+// collectResolverLatency 按解析器统计本轮的 DNS 延迟：原始解析模式下取每一次查询的耗时，
+// 系统解析器只能取每个域名的整体解析耗时。结果按查询数从多到少排序。
+func collectResolverLatency(results []DomainResult) []resolverLatency {
+	samples := make(map[string][]time.Duration)
+	failures := make(map[string]int)
+	for _, r := range results {
+		if len(r.Trace) == 0 {
+			if r.DNSLatency > 0 {
+				samples[systemResolverName] = append(samples[systemResolverName], r.DNSLatency)
+			}
+			continue
+		}
+		for _, step := range r.Trace {
+			if step.Err != nil {
+				failures[step.Server]++
+				continue
+			}
+			samples[step.Server] = append(samples[step.Server], step.Latency)
+		}
+	}
+
+	var out []resolverLatency
+	for name, n := range failures {
+		if _, ok := samples[name]; !ok {
+			out = append(out, resolverLatency{Resolver: name, Failures: n})
+		}
+	}
+	for name, s := range samples {
+		sort.Slice(s, func(i, j int) bool { return s[i] < s[j] })
+		l := resolverLatency{
+			Resolver: name,
+			Queries:  len(s),
+			Failures: failures[name],
+			P50:      percentile(s, 0.50),
+			P95:      percentile(s, 0.95),
+			Max:      s[len(s)-1],
+		}
+		for _, d := range s {
+			l.Sum += d
+		}
+		out = append(out, l)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Queries+out[i].Failures != out[j].Queries+out[j].Failures {
+			return out[i].Queries+out[i].Failures > out[j].Queries+out[j].Failures
+		}
+		return out[i].Resolver < out[j].Resolver
+	})
+	return out
+}
+
+// percentile 按最近秩法取已排序样本的 p 分位数
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	idx := int(p*float64(len(sorted))+0.999999) - 1
+	if idx < 0 {
+		idx = 0
+	}
+	if idx >= len(sorted) {
+		idx = len(sorted) - 1
+	}
+	return sorted[idx]
+}
+
+// writeResolverLatency 写入报告中的解析器延迟部分
+func writeResolverLatency(b *strings.Builder, stats []resolverLatency) {
+	if len(stats) == 0 {
+		return
+	}
+	b.WriteString("解析器延迟:\n")
+	for i, l := range stats {
+		if i == resolverLatencyTopN {
+			b.WriteString(fmt.Sprintf("  （另有 %d 个解析器未列出）\n", len(stats)-resolverLatencyTopN))
+			break
+		}
+		line := fmt.Sprintf("  %-30s 查询 %d 次  p50 %s  p95 %s  最大 %s", l.Resolver, l.Queries,
+			roundLatency(l.P50), roundLatency(l.P95), roundLatency(l.Max))
+		if l.Failures > 0 {
+			line += fmt.Sprintf("  失败 %d 次", l.Failures)
+		}
+		b.WriteString(line + "\n")
+	}
+	b.WriteString("\n")
+}
+
+// roundLatency 将延迟保留到 0.1 毫秒，便于阅读
+func roundLatency(d time.Duration) time.Duration {
+	return d.Round(100 * time.Microsecond)
+}
+
+// writeResolverLatencyMetrics 以 Prometheus summary 格式输出最近一轮各解析器的延迟（quantile="1" 为最大值）
+func writeResolverLatencyMetrics(w io.Writer, stats []resolverLatency) {
+	fmt.Fprintln(w, "# HELP dnscheck_resolver_latency_seconds 最近一轮各解析器的查询延迟")
+	fmt.Fprintln(w, "# TYPE dnscheck_resolver_latency_seconds summary")
+	for _, l := range stats {
+		if l.Queries == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "dnscheck_resolver_latency_seconds{resolver=%q,quantile=\"0.5\"} %g\n", l.Resolver, l.P50.Seconds())
+		fmt.Fprintf(w, "dnscheck_resolver_latency_seconds{resolver=%q,quantile=\"0.95\"} %g\n", l.Resolver, l.P95.Seconds())
+		fmt.Fprintf(w, "dnscheck_resolver_latency_seconds{resolver=%q,quantile=\"1\"} %g\n", l.Resolver, l.Max.Seconds())
+		fmt.Fprintf(w, "dnscheck_resolver_latency_seconds_sum{resolver=%q} %g\n", l.Resolver, l.Sum.Seconds())
+		fmt.Fprintf(w, "dnscheck_resolver_latency_seconds_count{resolver=%q} %d\n", l.Resolver, l.Queries)
+	}
+	fmt.Fprintln(w, "# HELP dnscheck_resolver_failures 最近一轮各解析器超时或出错的查询数")
+	fmt.Fprintln(w, "# TYPE dnscheck_resolver_failures gauge")
+	for _, l := range stats {
+		fmt.Fprintf(w, "dnscheck_resolver_failures{resolver=%q} %d\n", l.Resolver, l.Failures)
+	}
+}
@@ -1,68 +1,283 @@
 package main
 
 import (
-	_ "embed" // 用于嵌入配置文件，使用匿名导入避免 "imported and not used" 错误
 	"context"
-	"encoding/json"
+	_ "embed" // 用于嵌入配置文件，使用匿名导入避免 "imported and not used" 错误
 	"flag"
 	"fmt"
 	"io"
 	"net"
 	"net/http"
 	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
-	"golang.org/x/time/rate"
 	"gopkg.in/yaml.v3"
 )
 
 //go:embed sites.yaml
 var defaultConfigYAML []byte // 嵌入默认配置文件
 
-
 // ---------- 配置结构 ----------
 type Config struct {
-	Domains []DomainConfig `yaml:"domains"`
+	Domains   []DomainConfig   `yaml:"domains"`
+	Script    string           `yaml:"script"`    // Starlark 判定脚本，定义 verdict(domain, ips, infos)
+	Providers []ProviderConfig `yaml:"providers"` // 各 API 的响应格式与字段映射
+	Profiles  profileMap       `yaml:"profiles"`  // 命名配置方案，用 -profile 选择
+	Alerts    []AlertRule      `yaml:"alerts"`    // 告警规则，配置后由规则驱动 syslog 与运行后钩子
+
+	Maintenance []MaintenanceWindow `yaml:"maintenance"` // 维护窗口，窗口内照常检测但不告警
+	Schedules   []ScheduleConfig    `yaml:"schedules"`   // 守护模式下按分组设置检测间隔与并发数
+
+	Portals []string `yaml:"portal_domains"` // 已知的运营商门户、广告页域名，-probe http/https 跳转到这些域名时标记为劫持
+
+	Sinkholes []string `yaml:"blockpage_cnames"` // 追加到内置列表的拦截页 CNAME 目标，CNAME 链经过时判定为污染
+
+	Retry map[string]RetryPolicy `yaml:"retry"` // 按错误类别的重试策略，见 retry.go
+
+	script    *verdictScript             // 由 normalizeConfig 编译
+	providers map[string]*ProviderConfig // 由 normalizeConfig 根据 providers 构建，键为 URL
+	retry     retryPolicies              // 由 normalizeConfig 根据 retry 构建
+	hash      string                     // 配置文件内容的 SHA-256，由 loadConfigWithFallback 计算
 }
 
 type DomainConfig struct {
-	Name         string   `yaml:"name"`
-	ExpectedLlcs []string `yaml:"expected_llcs"`
+	Name            string        `yaml:"name"`
+	ExpectedLlcs    []Expectation `yaml:"expected_llcs"`
+	ForbiddenLlcs   []Expectation `yaml:"forbidden_llcs"`    // 命中即判定为污染的 LLC
+	ForbiddenASNs   []string      `yaml:"forbidden_asns"`    // 命中即判定为污染的 ASN，如 AS4134 或 4134
+	ForbiddenIPs    []string      `yaml:"forbidden_ips"`     // 命中即判定为污染的 IP 或 CIDR
+	ExpectedIPs     []string      `yaml:"expected_ips"`      // 固定 IP 列表，设置后只做本地比较、不查询 API
+	ExpectedCIDRs   []string      `yaml:"expected_cidrs"`    // 属于这些网段的 IP 直接视为符合预期，不查询 API
+	ExpectedIPCount int           `yaml:"expected_ip_count"` // 预期解析到的 IP 数量（0 表示不检查）
+	Rules           *Rule         `yaml:"rules"`             // 组合规则，设置后代替 expected_llcs 判断每个 IP
+	Match           string        `yaml:"match"`             // 预期未单独指定时使用的匹配方式，默认 prefix
+	CaseInsensitive bool          `yaml:"case_insensitive"`  // 预期未单独指定时是否忽略大小写
+	AllowPrivate    bool          `yaml:"allow_private"`     // 允许解析到私有地址（内网域名）
+	Hosting         string        `yaml:"hosting"`           // 预期的提供方所在：foreign（境外）或 domestic（本国），用于国家不符提示
+	DNSTimeout      time.Duration `yaml:"dns_timeout"`       // 覆盖 -dns-timeout
+	APITimeout      time.Duration `yaml:"api_timeout"`       // 覆盖 -api-timeout
+	Groups          []string      `yaml:"groups"`            // 所属分组，供 profiles 筛选
+	Line            int           `yaml:"-"`                 // 在配置文件中的行号，用于报错定位
+
+	ExpectedBody  []string `yaml:"expected_body_contains"` // 通过解析到的 IP 请求页面，页面必须包含的关键字
+	ExpectedTitle string   `yaml:"expected_title"`         // 页面标题必须包含的文字（忽略大小写）
+	ContentURL    string   `yaml:"content_url"`            // 内容校验请求的地址，默认 https://域名/
+
+	Subdomains []string `yaml:"subdomains"`         // 同时检测的子域名标签，继承本条目的预期，"*" 表示读取词表
+	Wordlist   string   `yaml:"subdomain_wordlist"` // subdomains 含 "*" 时读取的词表文件，每行一个标签
+	Hosts      []string `yaml:"hosts"`              // *.example.com 通配条目匹配的主机名
+	HostsFile  string   `yaml:"hosts_file"`         // 通配条目的主机列表文件，每行一个，只取匹配的主机名
+
+	Redact bool `yaml:"redact"` // -redact 时在报告中隐藏该域名及其子域名（allow_private 的条目总是隐藏）
+
+	forbidden *blocklist   // 由 normalizeConfig 根据 forbidden_* 构建
+	pinned    []*net.IPNet // 由 normalizeConfig 根据 expected_ips 构建
+	accepted  []*net.IPNet // 由 normalizeConfig 根据 expected_cidrs 构建
+	sinkholes []sinkhole   // 由 normalizeConfig 根据内置列表与 blockpage_cnames 构建
 }
 
 // ---------- API 响应 ----------
-type IPInfoRaw map[string]interface{}
+
+// ipInfo 是从 API 响应中提取的 IP 归属信息
+type ipInfo struct {
+	LLC     string
+	ASN     uint32 // 0 表示 API 未返回 ASN
+	Country string // ISO 国家代码，API 未返回时为空
+}
 
 // ---------- 检测结果 ----------
 type IPCheckResult struct {
 	IP        string
-	ActualLLC string
+	ActualLLC string // 经别名表统一后的 LLC
+	RawLLC    string // API 返回的原始 LLC
+	ASN       uint32 // 0 表示未知
+	Country   string // 空表示未知
+	Forbidden string // 命中黑名单的原因，未命中时为空
+	NotPinned bool   // 启用 expected_ips 时，该 IP 不在固定列表中
+	Private   bool   // 公网域名解析到私有地址，未查询 API
+	Source    string // 归属信息的来源，见 classify.go；固定 IP 模式下为空
 	Error     error
+	Latency   time.Duration // LLC 查询耗时（含重试）
+	Probe     *probeResult  // -probe 的可达性探测结果，nil 表示未探测
+	Ports     *portScan     // -probe-ports 的端口探测结果，nil 表示未探测
+	Page      *pageCheck    // expected_body_contains / expected_title 的内容校验结果，nil 表示未校验
+	Listed    []string      // 被列入的 DNSBL 与威胁情报，见 threatintel.go
+
+	Anomalies    []string // 原始解析模式下，返回该 IP 的响应中发现的异常
+	AnomalyScore int      // 各项异常的权重之和
 }
 
 type DomainResult struct {
-	Domain     string
-	Expected   []string
-	IPResults  []IPCheckResult
-	IsPolluted bool
-	Summary    string
+	Domain      string
+	ASCIIDomain string // punycode 形式，国际化域名与 Domain 不同
+	Expected    []Expectation
+	PinnedIPs   []string // 固定 IP 模式下的 expected_ips，非空时不使用 Expected
+	Rules       *Rule    // 组合规则，非空时代替 Expected
+	CIDRs       []string // expected_cidrs，属于这些网段的 IP 直接视为符合预期
+	IPResults   []IPCheckResult
+	IsPolluted  bool
+	Rebinding   bool // 应答中含私有地址（DNS 重绑定或路由器劫持），同时判定为污染
+	Failed      bool // 解析失败、IP 归属查询失败或超时，无法判定；IsPolluted 为 false，见 verdictOf
+	Summary     string
+	DNSLatency  time.Duration // DNS 解析耗时
+	Trace       []resolveStep // 原始解析模式下的每一次查询
+	CNAMEs      []string      // 解析经过的 CNAME 目标，见 blockpage.go
+	Blockpage   string        // CNAME 链经过的已知拦截页及其说明，同时判定为污染
+	Patterns    []string      // 命中的运营商干扰特征，见 interference.go
+	GeoHint     string        // 解析到的 IP 所在国家与 hosting 相反的提示，见 geoip.go
+	SampledFrom int           // 抽样前解析到的 IP 数，未抽样时为 0
+	Muted       string        // 处于维护窗口或被静音时为原因，此时不触发告警
+	Routes      []routeTrace  // -traceroute 对可疑 IP 的路由追踪，只针对被污染的域名
+	Debounced   bool          // 去抖后的正式判定，见 flap.go；未启用 -confirm 时与 IsPolluted 相同
+	Streak      int           // 瞬时判定连续相同的轮数，未启用 -confirm 时为 0
+	Suspicious  []string      // 与历史应答相比的可疑变化，见 novelty.go
+	OffBaseline []string      // 不在 -against 基线中的 IP
+	Hijacks     []string      // -probe http/https 发现跳转到运营商门户、广告页或 IP 地址的 IP 及原因
+	Transport   *crossCheck   // -transports 各传输方式应答的比较，nil 表示未启用
+	Authority   *authCheck    // -verify-authoritative 权威服务器应答与递归应答的比较，nil 表示未启用
+	Resolution  *fanoutResult // -resolver 指定多个解析器时各解析器的应答，nil 表示只有一个解析器
+	DualStack   *dualCheck    // -dual-stack 时 A 与 AAAA 记录的比较，nil 表示未启用
+	Failover    *failover     // 主解析器失败后查询备用解析器的过程，nil 表示未发生切换
 }
 
 // ---------- 命令行参数 ----------
 var (
 	apiURL      = flag.String("api", "https://uapis.cn/api/v1/network/ipinfo?ip=", "IP 信息查询 API 地址（支持多个，用逗号分隔）")
-	concurrency = flag.Int("c", 2, "并发查询数")
+	concurrency = concurrencyFlag("c", 2, "并发查询数（auto 表示根据错误率和 429 自动调整）")
 	strict      = flag.Bool("strict", false, "严格模式：所有解析 IP 的 llc 都必须在预期内才算正常")
 	configFile  = flag.String("f", "sites.yaml", "配置文件路径（默认使用内嵌配置）")
-	timeout     = flag.Duration("timeout", 10*time.Second, "HTTP 请求超时")
-	outputFile  = flag.String("output", "", "输出报告文件路径（默认自动生成带时间戳的文件）")
+	timeout     = flag.Duration("timeout", 10*time.Second, "默认超时：未单独指定时用于 DNS 解析与 API 请求，也用于各类上报")
+	dnsTimeout  = flag.Duration("dns-timeout", 0, "DNS 解析超时（0 表示使用 -timeout）")
+	apiTimeout  = flag.Duration("api-timeout", 0, "IP 信息 API 单次请求超时（0 表示使用 -timeout）")
+	runDeadline = flag.Duration("run-deadline", 0, "整轮检测的时限：到时仍未完成的域名记为检测超时，其余结果照常报告（0 表示不限制）")
+	domBudget   = flag.Duration("domain-budget", 0, "单个域名（解析、API 查询与各项附加检查）的时限，超出时记为检测超时（0 表示不限制）")
+	outputFile  = flag.String("output", "", "输出报告文件路径，支持 {{.Date}} {{.Host}} {{.PollutionLevel}} 等模板变量（默认自动生成带时间戳的文件；指定目录时在该目录下生成）")
+	summaryOnly = flag.Bool("summary", false, "终端只输出统计信息和被污染域名列表（报告文件仍包含完整结果）")
+	quiet       = flag.Bool("quiet", false, "没有域名被污染时不在终端输出任何内容")
+	onlyFilter  = flag.String("only", "", "报告只列出指定类别的域名：polluted、errors 或 clean")
+	minSeverity = flag.String("min-severity", "", "报告只列出不低于该严重程度的域名：ok、warning、suspicious 或 critical")
+	keepReports = flag.Int("keep", 0, "报告目录中最多保留的报告数（0 表示不限制）")
+	keepDays    = flag.Int("keep-days", 0, "删除早于该天数的报告（0 表示不限制）")
+	compressOld = flag.Bool("compress-old", false, "将除最新一份以外的报告压缩为 .gz")
 	rps         = flag.Float64("rps", 2, "每秒请求数限制 (0 表示不限速)")
-	maxRetries  = flag.Int("retry", 2, "API 请求失败时的最大重试次数")
+	maxRetries  = flag.Int("retry", 2, "API 超时与连接错误的默认重试次数（配置文件的 retry 可按错误类别设置）")
+	adaptiveRPS = flag.Bool("adaptive-rps", false, "根据 429 响应自动调整请求速率（以 -rps 为初始值）")
+	maxRPS      = flag.Float64("max-rps", 10, "自适应限速时允许达到的最大速率")
+	apiBurst    = flag.Int("burst", 1, "API 限速令牌桶的容量，即允许的瞬时突发请求数")
+	rpsScope    = flag.String("rps-scope", scopeGlobal, "限速范围：global（所有 API 共用 -rps）或 host（每个 API 主机各自 -rps）")
+	brkFailures = flag.Int("breaker-threshold", 5, "API 端点连续失败多少次后熔断（0 表示禁用熔断）")
+	brkCooldown = flag.Duration("breaker-cooldown", 30*time.Second, "API 端点熔断后的冷却时间")
+	syslogAddr  = flag.String("syslog", "", "将每个域名的判定事件发送到 syslog：local、udp://host:port 或 tcp://host:port")
+	zbxServer   = flag.String("zabbix", "", "Zabbix 服务器地址（host:port），通过 sender 协议推送检测结果")
+	zbxHost     = flag.String("zabbix-host", "", "Zabbix 中的主机名（默认使用本机主机名）")
+	zbxBatch    = flag.String("zabbix-batch", "", "将检测结果写成 zabbix_sender -T -i 可读取的批量文件")
+	format      = flag.String("format", "text", "报告格式：text、influx（InfluxDB 行协议）或 json（与运行后钩子的输入相同，可用 dnscheck diff 比较）")
+	influxURL   = flag.String("influx-url", "", "InfluxDB 写入地址，如 http://localhost:8086/api/v2/write?org=o&bucket=b")
+	influxToken = flag.String("influx-token", "", "InfluxDB API Token")
+	historyDSN  = flag.String("history", "", "历史记录数据库：SQLite 文件路径、postgres://... 或 mysql://...（为空则不保存）")
+	s3URL       = flag.String("s3-url", "", "上传报告的 S3 兼容地址（path-style，含桶名），如 http://minio:9000/reports")
+	s3Region    = flag.String("s3-region", "us-east-1", "S3 区域")
+	s3Key       = flag.String("s3-key", "reports/{date}/{run_id}.{ext}", "S3 对象键模板，支持 {date} {time} {run_id} {host} {format} {ext}")
+	aliasFile   = flag.String("llc-aliases", "", "LLC 别名文件：将不同 API 对同一运营商的不同写法统一后再匹配")
+	carrierMap  = flag.Bool("carrier-names", false, "用内置的对照表将国内外 API 对运营商、云服务商的中英文写法与 ASN 统一为英文标识（如 电信、CHINANET → ChinaTelecom）")
+	ipMapFile   = flag.String("ip-map", "", "静态 IP 归属映射文件（CSV 或 YAML，CIDR → LLC/ASN/国家），命中的 IP 不查询 API")
+	enrichCmd   = flag.String("enrich-cmd", "", "IP 富化钩子命令：每个 IP 的查询结果以 JSON 写入其标准输入，输出的 JSON 覆盖 llc/asn/country")
+	postRunCmd  = flag.String("post-run-cmd", "", "运行后钩子命令：每轮检测结束后执行，标准输入为完整结果的 JSON")
+	hookTimeout = flag.Duration("hook-timeout", 0, "运行后钩子的执行时限，超时后终止钩子（0 表示使用 -timeout）")
+	resolverArg = flag.String("resolver", "", "DNS 解析方式：为空使用系统解析器，iterative 从根服务器迭代解析，或指定递归解析器地址 host[:port]、udp://、tcp:// 或 DoH 的 https:// 地址；逗号分隔多个时按 -strategy 查询")
+	fallbackArg = flag.String("fallback-resolver", "", "备用解析器（逗号分隔，写法同 -resolver）：主解析器解析失败时依次查询，避免单个解析器的 SERVFAIL 或超时被判定为污染")
+	strategy    = flag.String("strategy", strategyRace, "-resolver 指定多个解析器（逗号分隔）时的查询策略：race（采用最快的应答）、all（等待全部应答，采用 IP 的并集）或 sequential（逐个查询，采用第一个成功的应答）")
+	qnameMin    = flag.Bool("qname-min", false, "迭代解析时启用 QNAME 最小化（需配合 -resolver iterative）")
+	maxIPs      = flag.Int("max-ips-per-domain", 0, "每个域名最多查询多少个 IP 的归属（0 表示不限制），超出时按 -ip-sample 抽样")
+	ipCacheTTL  = flag.Duration("ip-cache-ttl", time.Hour, "API 查询结果在进程内的缓存有效期，同一 IP 在有效期内不再查询（0 表示禁用）")
+	ipSample    = flag.String("ip-sample", "first", "IP 数超过 -max-ips-per-domain 时的抽样方式：first、random 或 all（不抽样）")
+	keepRawDays = flag.Int("history-raw-days", 30, "历史记录中原始结果的保留天数，过期后按天汇总（0 表示永久保留）")
+	keepDaily   = flag.Int("history-daily-days", 365, "历史记录中每日汇总的保留天数（0 表示永久保留）")
+	alertFile   = flag.String("alert-state", "", "告警状态文件，单次运行时用于跨运行判断“连续 N 轮”（守护模式下状态保存在内存中）")
+	confirmRuns = flag.Int("confirm", 1, "瞬时判定连续相同多少轮后才改变域名的正式判定，抑制间歇性注入造成的反复跳变（1 表示不去抖）")
+	flapFile    = flag.String("flap-state", "", "去抖状态文件，单次运行时用于跨运行累计 -confirm 的轮数（守护模式下状态保存在内存中）")
+	againstFile = flag.String("against", "", "与 dnscheck baseline save 保存的基线严格比较：解析到基线中没有的 IP 即判定为污染")
+	traceMode   = flag.String("traceroute", "", "对被污染域名中的可疑 IP 执行路由追踪：icmp 或 udp（需要 root 或 CAP_NET_RAW，没有权限时改用系统的 traceroute 命令）")
+	traceHops   = flag.Int("trace-hops", 30, "路由追踪的最大跳数")
+	probeMode   = flag.String("probe", "", "探测每个解析到的 IP 是否可达：ping、tcp:端口（如 tcp:443）、http 或 https（记录跳转链），结果作为旁证写入报告，不影响判定")
+	maxRedirect = flag.Int("max-redirects", 5, "-probe http/https 最多跟随的跳转次数")
+	dualStack   = flag.Bool("dual-stack", false, "同时解析 AAAA 记录并按相同的预期判断，比较 IPv4 与 IPv6 应答的归属：A 记录正常而 AAAA 记录不符合预期时判定为污染")
+	transports  = flag.String("transports", "", "通过多种传输方式查询每个域名并比较应答，逗号分隔，如 udp://8.8.8.8,tcp://8.8.8.8,https://8.8.8.8/dns-query（至少两种）")
+	verifyAuth  = flag.Bool("verify-authoritative", false, "从根服务器跟随委派找到每个域名的权威服务器并直接查询，与递归应答比较（没有交集时判定为污染）")
+	hijackAddr  = flag.String("hijack-check", "192.0.2.1", "检测期间向该不存在的解析器地址发送一次查询，收到应答说明网络在透明劫持 DNS（为空则不检查）")
+	proxyCheck  = flag.String("proxy-check", "", "检测期间向这些不同运营方的解析器（如 8.8.8.8,1.1.1.1,9.9.9.9）查询 whoami.akamai.net，出口地址全部相同说明 53 端口被透明代理（为空则不检查）")
+	egressURL   = flag.String("egress-check", "", "查询本机出口 IP 的地址（应返回纯文本 IP，如 https://api.ipify.org），结果写入报告的运行元数据（为空则不查询）")
+	captiveURL  = flag.String("captive-check", "", "检测前请求该地址（应返回 HTTP 204，如 http://connectivitycheck.gstatic.com/generate_204）确认没有强制门户拦截流量，发现时报告标记为不可信（为空则不检查）")
+	dnsblZones  = flag.String("dnsbl", "", "查询解析到的 IP 是否被列入这些 DNSBL 区域（逗号分隔，如 zen.spamhaus.org），结果写入报告")
+	threatFeeds = flag.String("threat-feed", "", "本地威胁情报文件（每行一个 IP 或网段，逗号分隔多个），解析到的 IP 命中时写入报告")
+	probePorts  = flag.String("probe-ports", "", "探测每个解析到的 IP 的少量 TCP 端口（逗号分隔，如 80,443），按结果区分黑洞、拦截页与正常服务的主机")
+	anycastMode = flag.Bool("anycast", false, "将属于同一 ASN（未知时为同一 LLC）的不同 IP 视为同一个逻辑应答：报告中合并显示，基线比较与应答变化检测按逻辑应答进行")
+	learnDays   = flag.Int("learn-days", 0, "从最近多少天的历史记录中学习各域名的典型应答，标注从未出现的 ASN 与 IP 数突变（需要 -history，0 表示不启用）")
+	rawDir      = flag.String("save-raw", "", "将每个 API 的原始响应保存到该目录（按 API 主机与 IP 命名），便于排查字段提取失败")
+	captureFile = flag.String("capture", "", "将本轮收发的每一条 DNS 报文记录到文件：扩展名为 .pcap 时写成 PCAP（可用 Wireshark 分析），否则为 JSON Lines")
+	redactOut   = flag.Bool("redact", false, "在报告与运行后钩子、syslog 等外发数据中隐藏内网域名、私有地址、本机主机名与出口 IP，便于对外分享")
+	redactKey   = flag.String("redact-key", "", "-redact 生成替代名称使用的密钥；不指定时每次运行随机生成，不同运行之间的替代名称不可关联")
+	reportLang  = flag.String("lang", "zh", "报告语言：zh 或 en；逗号分隔多种语言（如 zh,en）时每种语言各写一个报告文件，终端输出第一种语言")
+	cronFile    = flag.String("cron-state", "", "定时任务模式的状态文件：只有存在污染或判定与上一轮不同时才输出并写入报告，否则不输出任何内容")
+	shardSpec   = flag.String("shard", "", "只检测分到指定分片的域名，格式为 序号/总数（如 3/10）；各机器使用同一份配置，按域名哈希分配，JSON 报告可用 dnscheck merge 合并")
+	profileName = flag.String("profile", "", "使用配置文件 profiles 中的命名方案（命令行显式指定的参数优先）")
+	geoipFile   = flag.String("geoip", "", "本地 IP 国家数据库（CSV：cidr,国家 或 起始IP,结束IP,国家），API 未返回国家时用于 -home-country 的国家不符提示")
+	homeCountry = flag.String("home-country", "", "运行所在国家的两位代码（如 CN）；配置了 hosting 的域名解析到国家相反的 IP 时给出提示")
+	ispFile     = flag.String("isp-profiles", "", "运营商干扰特征文件（YAML），与特征库中的 interference_profiles 一起用于说明判定原因")
+	sigFile     = flag.String("signatures", defaultSigFile, "特征库文件（伪造 IP、拦截页特征，dnscheck update-signatures 下载）；默认路径的文件不存在时使用内嵌特征库")
+	otlpURL     = flag.String("otlp-endpoint", "", "OTLP/HTTP 追踪导出地址，如 http://localhost:4318（为空则不启用追踪）")
 )
 
 func main() {
+	// 子命令
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "serve":
+			runServe(os.Args[2:])
+			return
+		case "healthcheck":
+			runHealthcheck(os.Args[2:])
+			return
+		case "doctor":
+			runDoctor(os.Args[2:])
+			return
+		case "mute":
+			runMute(os.Args[2:])
+			return
+		case "unmute":
+			runUnmute(os.Args[2:])
+			return
+		case "diff":
+			runDiff(os.Args[2:])
+			return
+		case "compare":
+			runCompare(os.Args[2:])
+			return
+		case "merge":
+			runMerge(os.Args[2:])
+			return
+		case "version":
+			fmt.Println(version)
+			return
+		case "baseline":
+			runBaseline(os.Args[2:])
+			return
+		case "soak":
+			runSoak(os.Args[2:])
+			return
+		case "update-signatures":
+			runUpdateSignatures(os.Args[2:])
+			return
+		case "tui":
+			runTUI(os.Args[2:])
+			return
+		}
+	}
+
 	flag.Parse()
 
 	// 1. 加载配置（优先外部，否则使用内嵌）
@@ -71,100 +286,572 @@ func main() {
 		fmt.Fprintf(os.Stderr, "加载配置文件失败: %v\n", err)
 		os.Exit(1)
 	}
+	// profile 会改写参数，因此在套用之后再校验
+	if err := applyProfile(config, *profileName, flag.CommandLine); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(2)
+	}
+	if err := validateFlags(); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(2)
+	}
+	sh, _ := parseShard(*shardSpec) // 已由 validateFlags 检查
+	sh.Apply(config)
+	if *redactOut {
+		redaction = newRedactor(config, *redactKey)
+	}
 
-	// 2. 创建速率限制器
-	var limiter *rate.Limiter
-	if *rps > 0 {
-		limiter = rate.NewLimiter(rate.Limit(*rps), 1)
+	aliases, err := loadLLCAliases(*aliasFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	ipMap, err := loadIPMapping(*ipMapFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	baseline, err := loadBaseline(*againstFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	alerts, err := newAlertEngine(config.Alerts, *alertFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	flaps, err := newFlapTracker(*confirmRuns, *flapFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	if dnsCapture, err = openCapture(*captureFile); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	var cronPrev *cronState
+	if *cronFile != "" {
+		if cronPrev, err = loadCronState(*cronFile); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
 	}
 
-	// 3. 解析 API 列表
+	// 2. 创建检测器并执行检测
+	c := newChecker()
+	c.aliases = aliases
+	c.ipMap = ipMap
+	c.baseline = baseline
+	if c.sigs, err = loadDetectionSignatures(); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	if c.geo, err = loadGeoDB(*geoipFile); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	if c.routes, err = newRouteTracer(*traceMode, *traceHops); err != nil {
+		fmt.Fprintf(os.Stderr, "警告: %v\n", err)
+	}
+	if c.prober, err = newReachProber(*probeMode); err != nil {
+		fmt.Fprintf(os.Stderr, "警告: %v\n", err)
+	}
+	c.ports, _ = newPortScanner(*probePorts) // 已由 validateFlags 检查
+	if c.threats, err = newThreatChecker(*dnsblZones, *threatFeeds); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	c.cross, _ = newCrossChecker(*transports)
+	c.useProviders(config)
+	registerOutputs(c.bus, nil, *syslogAddr, *postRunCmd)
+	if *historyDSN != "" {
+		c.bus.Subscribe(onEvent(busRunResults, func(ev busEvent) error {
+			return saveHistory(*historyDSN, ev.Run.ID, ev.Run.Started, ev.Outcome.Results)
+		}))
+	}
+	startedAt := time.Now()
+	run := runInfo{ID: newRunID(startedAt), Meta: newRunMeta(config), Shard: sh.String(), Started: startedAt, Domains: len(config.Domains)}
+	run.Captive = detectCaptivePortal(context.Background(), *captiveURL)
+	c.bus.Publish(busEvent{Kind: busRunStart, Run: run})
+	finishChecks, finishEgress := startInterceptionChecks(), startEgressCheck()
+	domainResults := c.Run(withRunInfo(context.Background(), run), config)
+	finishChecks(&run)
+	finishEgress(&run)
+	applyMaintenance(domainResults, config.Maintenance, nil, time.Now())
+	if err := flaps.Apply(domainResults); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+	}
+	if err := detectSuspiciousDSN(*historyDSN, domainResults, startedAt); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+	}
+	outcome := &runOutcome{Results: domainResults, Latest: domainResults, Alerts: alerts}
+	c.bus.Publish(busEvent{Kind: busRunResults, Run: run, Outcome: outcome})
+	var events []alertEvent
+	if run.Captive == "" {
+		if events, err = alerts.Evaluate(domainResults, domainResults); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+		}
+	}
+
+	// 3. 生成报告（-quiet 时干净的运行不输出任何内容；-cron-state 时没有污染、判定也没有变化的运行
+	// 既不输出也不写报告文件）
+	// -lang 指定多种语言时，终端输出与上传使用第一种语言，报告文件每种语言各写一个
+	report := c.renderReport(run, domainResults, events)
+	lang := reportLangs()[0]
+	outcome.Events, outcome.Report = events, translateReport(report, lang)
+	changes := cronChanges(cronPrev, domainResults)
+	unchanged := *cronFile != "" && cronPrev != nil && len(changes) == 0 && countDebounced(domainResults) == 0
+	showConsole := !unchanged && !(*quiet && countPolluted(domainResults) == 0)
+	if showConsole && len(changes) > 0 {
+		out := os.Stdout
+		if *format != "text" {
+			out = os.Stderr
+		}
+		fmt.Fprintln(out, "与上一轮相比的变化:")
+		for _, ch := range changes {
+			fmt.Fprintf(out, "  %s\n", redaction.Text(ch))
+		}
+		fmt.Fprintln(out)
+	}
+	if showConsole {
+		if *summaryOnly && *format == "text" {
+			fmt.Print(translateReport(redaction.Text(reportBanner(redaction.Run(run))+buildSummaryReport(domainResults, c.filter)), lang))
+		} else {
+			fmt.Print(outcome.Report)
+		}
+	}
+
+	if !unchanged {
+		writeRunReport(report, run, domainResults, showConsole)
+	}
+	// 报告写入成功后才更新状态文件，写入失败时下一轮仍会与原状态比较
+	if *cronFile != "" {
+		if err := saveCronState(*cronFile, run, domainResults); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+		}
+	}
+	// 4. 通知与导出：syslog、Zabbix、InfluxDB、对象存储与运行后钩子都是总线的订阅者
+	c.bus.Publish(busEvent{Kind: busRunFinish, Run: run, Outcome: outcome})
+	if n, err := dnsCapture.Close(); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+	} else if showConsole && *captureFile != "" {
+		fmt.Fprintf(os.Stderr, "已记录 %d 条 DNS 报文至: %s\n", n, *captureFile)
+	}
+	if rates := c.fetcher.limiter.Rates(); *adaptiveRPS && rates != "" {
+		fmt.Fprintf(os.Stderr, "自适应限速: 最终速率 %s，可作为下次运行的 -rps 参考值\n", rates)
+	}
+}
+
+// writeRunReport 将单次运行的报告写入 -output 并按需轮转；showConsole 为 false 时不输出提示
+func writeRunReport(report string, run runInfo, results []DomainResult, showConsole bool) {
+	outPath, rotatePattern, err := resolveOutputPath(*outputFile, newOutputVars(run.ID, run.Started, results))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	paths, err := writeLocalizedReports(report, outPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "写入报告文件失败: %v\n", err)
+		os.Exit(1)
+	}
+	if !showConsole {
+		// 静默模式下不输出提示
+	} else if *format == "text" {
+		fmt.Printf("\n报告已保存至: %s\n", strings.Join(paths, "、"))
+	} else {
+		// 机器可读格式的标准输出可能被管道消费，提示信息写到标准错误
+		fmt.Fprintf(os.Stderr, "报告已保存至: %s\n", strings.Join(paths, "、"))
+	}
+	if rotatePattern != "" {
+		if err := rotateReports(rotatePattern, *keepReports, *keepDays, *compressOld, time.Now()); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+		}
+	}
+}
+
+// ---------- 检测器 ----------
+
+// checker 持有跨域名（以及守护模式下跨轮次）共享的限速器、熔断器和并发闸门
+type checker struct {
+	fetcher  *llcFetcher
+	cache    *ipCache
+	flight   *lookupGroup
+	metrics  *checkMetrics
+	tracer   *tracer
+	gate     *concurrencyGate
+	filter   resultFilter
+	resolver dnsResolver
+	aliases  llcAliases
+	ipMap    *ipMapping
+	stats    *runStats // 最近一轮的运行统计，由 Run 重新创建
+	enrich   string    // -enrich-cmd
+	strict   bool
+	maxIPs   int    // -max-ips-per-domain
+	sampling string // -ip-sample
+
+	dnsTimeout time.Duration
+	apiTimeout time.Duration
+	splay      time.Duration // 守护模式下各域名开始检测前随机推迟的上限
+
+	deadline time.Duration // -run-deadline，0 表示不限制
+	budget   time.Duration // -domain-budget，0 表示不限制
+
+	bus *eventBus // 发布解析、查询与判定事件，见 bus.go
+
+	baseline *baselineSnapshot // -against，nil 表示不与基线比较
+	routes   *routeTracer      // -traceroute，nil 表示不追踪
+	prober   *reachProber      // -probe，nil 表示不探测
+	ports    *portScanner      // -probe-ports，nil 表示不探测
+	threats  *threatChecker    // -dnsbl / -threat-feed，nil 表示不查询
+	cross    *crossChecker     // -transports，nil 表示不比较
+	dual     bool              // -dual-stack
+	sigs     *signatureBundle  // -signatures，默认为内嵌特征库
+	geo      *geoDB            // -geoip，nil 表示未配置
+	home     string            // -home-country，大写
+	backups  []namedResolver   // -fallback-resolver，主解析器失败时依次查询
+	retry    retryPolicies     // 配置文件的 retry，见 retry.go
+}
+
+// newChecker 根据命令行参数创建检测器
+func newChecker() *checker {
+	// 解析 API 列表
 	apiList := strings.Split(*apiURL, ",")
 	for i := range apiList {
 		apiList[i] = strings.TrimSpace(apiList[i])
 	}
+	metrics := newCheckMetrics()
+	tr := newTracer(*otlpURL, "dnscheck")
+	filter, _ := newResultFilter(*onlyFilter, *minSeverity) // 已在 validateFlags 中校验
+	resolver, _ := newDNSResolver(*resolverArg, *qnameMin, *strategy)
+	backups, _ := newFallbackResolvers(*fallbackArg) // 同样已在 validateFlags 中校验
+	bus := newEventBus()
+	bus.Subscribe(metricsSubscriber(metrics))
+	return &checker{
+		fetcher: &llcFetcher{
+			apis:     apiList,
+			limiter:  newAPILimiters(*rps, *maxRPS, *apiBurst, *adaptiveRPS, *rpsScope),
+			breakers: newBreakerSet(*brkFailures, *brkCooldown),
+			metrics:  metrics,
+			tracer:   tr,
+		},
+		cache:    newIPCache(*ipCacheTTL),
+		flight:   newLookupGroup(),
+		metrics:  metrics,
+		tracer:   tr,
+		gate:     newConcurrencyGate(concurrency),
+		filter:   filter,
+		resolver: resolver,
+		backups:  backups,
+		enrich:   *enrichCmd,
+		strict:   *strict,
+		maxIPs:   *maxIPs,
+		sampling: *ipSample,
+
+		dnsTimeout: orDefault(*dnsTimeout, *timeout),
+		apiTimeout: orDefault(*apiTimeout, *timeout),
+
+		dual: *dualStack,
+		sigs: embeddedSignatures(),
+		home: strings.ToUpper(*homeCountry),
+
+		deadline: *runDeadline,
+		budget:   *domBudget,
+
+		bus: bus,
+	}
+}
+
+// orDefault 在 d 为 0 时返回 def
+func orDefault(d, def time.Duration) time.Duration {
+	if d == 0 {
+		return def
+	}
+	return d
+}
+
+// Run 并发检测配置中的所有域名并返回结果；启用追踪时整轮检测对应一条 trace
+func (c *checker) Run(ctx context.Context, config *Config) []DomainResult {
+	ctx, runSpan := c.tracer.startSpan(ctx, "dnscheck.run")
+	runSpan.SetAttr("domains", len(config.Domains))
+	c.stats = newRunStats()
+	c.fetcher.stats = c.stats
+	defer func() {
+		runSpan.End(nil)
+		if err := c.tracer.Flush(); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+		}
+	}()
+
+	if c.deadline > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.deadline)
+		defer cancel()
+	}
+	ctx = withRunContext(ctx)
+
+	// 配置文件与特征库中的门户域名一起用于 -probe 的跳转检查
+	portals := append(append([]string(nil), config.Portals...), c.sigs.Portals...)
 
-	// 4. 并发工作池
-	sem := make(chan struct{}, *concurrency)
 	var wg sync.WaitGroup
 	results := make(chan DomainResult, len(config.Domains))
 
-	// 5. 处理每个域名
 	for _, dc := range config.Domains {
 		wg.Add(1)
 		go func(dc DomainConfig) {
 			defer wg.Done()
-			sem <- struct{}{}
-			defer func() { <-sem }()
-
-			// DNS 解析
-			ctx, cancel := context.WithTimeout(context.Background(), *timeout)
-			defer cancel()
-			var r net.Resolver
-			ips, err := r.LookupIP(ctx, "ip4", dc.Name)
-			if err != nil {
-				results <- DomainResult{
-					Domain:     dc.Name,
-					Expected:   dc.ExpectedLlcs,
-					Summary:    fmt.Sprintf("DNS 解析失败: %v", err),
-					IsPolluted: true,
+			// 在占用并发槽位之前随机推迟，把本轮的 API 请求分散到 -splay 时间内
+			if c.splay > 0 {
+				select {
+				case <-time.After(randomDelay(c.splay)):
+				case <-ctx.Done():
 				}
-				return
 			}
-			if len(ips) == 0 {
-				results <- DomainResult{
-					Domain:     dc.Name,
-					Expected:   dc.ExpectedLlcs,
-					Summary:    "没有找到 IPv4 地址",
-					IsPolluted: true,
-				}
-				return
-			}
-
-			// 查询每个 IP 的 LLC
-			ipResults := make([]IPCheckResult, 0, len(ips))
-			for _, ip := range ips {
-				if limiter != nil {
-					_ = limiter.Wait(context.Background())
-				}
-				llc, err := fetchLLCWithRetry(ip.String(), apiList, *timeout, *maxRetries)
-				ipResults = append(ipResults, IPCheckResult{
-					IP:        ip.String(),
-					ActualLLC: llc,
-					Error:     err,
-				})
-			}
-
-			// 汇总域名结果
-			domainRes := aggregateDomainResult(dc.Name, dc.ExpectedLlcs, ipResults, *strict)
-			results <- domainRes
+			res := c.withinBudget(ctx, dc, func(ctx context.Context) DomainResult {
+				res := c.checkDomain(ctx, dc)
+				c.checkDualStack(ctx, dc, &res)
+				applyInterference(&res, c.sigs.Profiles)
+				c.checkCountry(dc, &res)
+				verifyContent(ctx, dc, &res)
+				verifyAuthoritative(ctx, &res)
+				c.baseline.Apply(&res)
+				config.script.Apply(&res)
+				c.prober.Probe(ctx, &res, portals, c.sigs.Titles)
+				c.ports.Scan(ctx, &res)
+				c.threats.Lookup(ctx, &res)
+				c.cross.Compare(ctx, &res)
+				res.Routes = c.routes.Trace(ctx, res)
+				return res
+			})
+			c.bus.Publish(busEvent{Kind: busVerdict, Run: runInfoFrom(ctx), Domain: res.Domain, Result: &res})
+			results <- res
 		}(dc)
 	}
 
-	// 6. 等待所有任务完成
+	// 等待所有任务完成
 	go func() {
 		wg.Wait()
 		close(results)
 	}()
 
-	// 7. 收集结果
+	// 收集结果
 	var domainResults []DomainResult
 	for res := range results {
 		domainResults = append(domainResults, res)
 	}
+	c.stats.Finish()
+	return domainResults
+}
+
+// checkDomain 解析单个域名并查询每个 IP 的 LLC
+func (c *checker) checkDomain(ctx context.Context, dc DomainConfig) (res DomainResult) {
+	ctx, domainSpan := c.tracer.startSpan(ctx, "dnscheck.domain")
+	domainSpan.SetAttr("domain", dc.Name)
+	defer domainSpan.End(nil)
+
+	c.gate.Acquire()
+	// 统计本域名的操作结果，供自适应并发使用
+	ops, failed, throttled := 1, 0, 0
+	defer func() { c.gate.Release(ops, failed, throttled) }()
+
+	// 国际化域名先转换为 punycode
+	host, err := toASCIIDomain(dc.Name)
+	if err != nil {
+		return DomainResult{
+			Domain:   dc.Name,
+			Expected: dc.ExpectedLlcs,
+			Summary:  err.Error(),
+			Failed:   true,
+		}
+	}
 
-	// 8. 生成报告
-	report := buildReport(domainResults)
-	fmt.Print(report)
+	// DNS 解析与 API 查询的超时可以按域名单独覆盖
+	dnsTimeout := orDefault(dc.DNSTimeout, c.dnsTimeout)
+	apiTimeout := orDefault(dc.APITimeout, c.apiTimeout)
 
-	if *outputFile == "" {
-		*outputFile = fmt.Sprintf("dnscheck_report_%s.txt", time.Now().Format("20060102_150405"))
+	// DNS 解析
+	dnsCtx, cancel := context.WithTimeout(ctx, dnsTimeout)
+	defer cancel()
+	_, dnsSpan := c.tracer.startSpan(dnsCtx, "dns.lookup")
+	dnsSpan.SetAttr("dns.question.name", host)
+	dnsStart := time.Now()
+	var ips []net.IP
+	var trace []resolveStep
+	var fanout *fanoutResult
+	lookup := func(dnsCtx context.Context) {
+		if f, ok := c.resolver.(*fanoutResolver); ok {
+			ips, trace, fanout, err = f.Lookup(dnsCtx, host)
+		} else {
+			ips, trace, err = c.resolver.LookupIPv4(dnsCtx, host)
+		}
 	}
-	if err := writeReportToFile(report, *outputFile); err != nil {
-		fmt.Fprintf(os.Stderr, "写入报告文件失败: %v\n", err)
-		os.Exit(1)
+	lookup(dnsCtx)
+	if _, ok := c.resolver.(*fanoutResolver); ok {
+		// 各返回路径都带上各解析器的应答
+		defer func() { res.Resolution = fanout }()
+	}
+	// 超时与 SERVFAIL 按配置文件的 retry 重试，每次重试重新计算超时
+	for attempt := 0; err != nil; attempt++ {
+		rule := c.retry.rule(classifyDNSError(err))
+		if attempt >= rule.retries || !sleepRetry(ctx, rule.delay(attempt)) {
+			break
+		}
+		c.stats.AddDNS(1) // 失败的那次查询，本次重试由下方统计
+		retryCtx, retryCancel := context.WithTimeout(ctx, dnsTimeout)
+		lookup(retryCtx)
+		retryCancel()
+	}
+	if err != nil && len(c.backups) > 0 {
+		var fo *failover
+		ips, trace, fo, err = c.resolveFallback(ctx, host, trace, err, dnsTimeout)
+		defer func() { res.Failover = fo }()
+	}
+	dnsLatency := time.Since(dnsStart)
+	c.bus.Publish(busEvent{Kind: busResolution, Run: runInfoFrom(ctx), Domain: dc.Name, IPs: ips, Latency: dnsLatency, Err: err})
+	if len(trace) > 0 {
+		c.stats.AddDNS(len(trace))
+	} else {
+		c.stats.AddDNS(1)
+	}
+	dnsSpan.SetAttr("dns.answer.count", len(ips))
+	dnsSpan.End(err)
+	if err != nil {
+		failed++
+		return DomainResult{
+			Domain:      dc.Name,
+			ASCIIDomain: host,
+			Expected:    dc.ExpectedLlcs,
+			Summary:     fmt.Sprintf("DNS 解析失败: %v", err),
+			Failed:      true,
+			DNSLatency:  dnsLatency,
+			Trace:       trace,
+		}
+	}
+
+	// 无论最终 IP 归属如何，CNAME 链经过已知的拦截页即判定为污染
+	cnames := c.cnameChain(dnsCtx, host, trace)
+	defer func() { applySinkholes(&res, cnames, c.sigs.Sinkholes, dc.sinkholes) }()
+
+	if len(ips) == 0 {
+		return DomainResult{
+			Domain:      dc.Name,
+			ASCIIDomain: host,
+			Expected:    dc.ExpectedLlcs,
+			Summary:     "没有找到 IPv4 地址",
+			IsPolluted:  true,
+			DNSLatency:  dnsLatency,
+			Trace:       trace,
+		}
 	}
-	fmt.Printf("\n报告已保存至: %s\n", *outputFile)
+
+	// 固定 IP 模式：只与本地列表比较，不查询 API
+	if len(dc.pinned) > 0 {
+		ipResults := pinnedIPResults(dc.pinned, ips)
+		markPinnedPrivate(ipResults, dc.AllowPrivate)
+		applyAnomalies(ipResults, trace)
+		domainRes := aggregatePinnedResult(dc.Name, dc.ExpectedIPs, ipResults)
+		applyBlocklist(&domainRes, dc.forbidden)
+		applyIPCount(&domainRes, dc.ExpectedIPCount)
+		applyPrivateIPs(&domainRes)
+		domainRes.ASCIIDomain = host
+		domainRes.DNSLatency = dnsLatency
+		domainRes.Trace = trace
+		return domainRes
+	}
+
+	// 大型 CDN 可能返回大量 IP，抽样以免单个域名耗尽整轮的 API 配额
+	sampledFrom := 0
+	if sampled := sampleIPs(ips, c.maxIPs, c.sampling); len(sampled) < len(ips) {
+		sampledFrom = len(ips)
+		ips = sampled
+	}
+
+	// 查询每个 IP 的 LLC
+	ipResults, apiOps, apiFailed, apiThrottled := c.lookupIPs(ctx, dc, ips, apiTimeout)
+	ops, failed, throttled = ops+apiOps, failed+apiFailed, throttled+apiThrottled
+
+	applyAnomalies(ipResults, trace)
+
+	// 汇总域名结果
+	_, aggSpan := c.tracer.startSpan(ctx, "aggregate")
+	domainRes := aggregateDomainResult(dc.Name, dc.ExpectedLlcs, dc.ExpectedCIDRs, dc.Rules, ipResults, c.strict)
+	domainRes.SampledFrom = sampledFrom
+	applyBlocklist(&domainRes, dc.forbidden)
+	applyIPCount(&domainRes, dc.ExpectedIPCount)
+	applyPrivateIPs(&domainRes)
+	domainRes.ASCIIDomain = host
+	domainRes.DNSLatency = dnsLatency
+	domainRes.Trace = trace
+	aggSpan.SetAttr("polluted", domainRes.IsPolluted)
+	aggSpan.End(nil)
+	return domainRes
+}
+
+// lookupIPs 查询每个 IP 的归属：能在本地得出结论的 IP 不查询 API。返回各 IP 的结果以及
+// API 请求数、失败数与被限流数，供自适应并发使用
+func (c *checker) lookupIPs(ctx context.Context, dc DomainConfig, ips []net.IP, apiTimeout time.Duration) (ipResults []IPCheckResult, ops, failed, throttled int) {
+	ipResults = make([]IPCheckResult, 0, len(ips))
+	for _, ip := range ips {
+		lookupStart := time.Now()
+		// 本地优先：能在本地得出结论的 IP 不查询 API
+		info, source := c.classifyLocal(dc, ip)
+		var err error
+		switch source {
+		case sourcePrivate, sourceBogon, sourceFakeIP, sourceExpected:
+			ipRes := IPCheckResult{IP: ip.String(), Private: source == sourcePrivate, Source: source}
+			c.bus.Publish(busEvent{Kind: busLookup, Run: runInfoFrom(ctx), Domain: dc.Name, IP: &ipRes})
+			ipResults = append(ipResults, ipRes)
+			continue
+		case sourceMapping, sourceCache:
+			if source == sourceCache {
+				c.stats.IncCacheHit()
+				c.metrics.ObserveCache(true)
+			}
+		default:
+			if c.cache != nil {
+				c.metrics.ObserveCache(false)
+			}
+			source = sourceAPI
+			var shared bool
+			info, err, shared = c.flight.Do(ctx, runContextFrom(ctx), ip.String(), func(ctx context.Context) (ipInfo, error) {
+				info, err := c.fetcher.Fetch(ctx, ip.String(), apiTimeout)
+				if err == nil {
+					c.cache.Put(ip.String(), info)
+				}
+				return info, err
+			})
+			switch {
+			case shared:
+				// 与其他域名同时查询同一 IP，共享那次请求的结果，不计入自适应并发的统计
+				c.stats.IncCoalesced()
+			case err != nil:
+				ops++
+				failed++
+				if isThrottled(err) {
+					throttled++
+				}
+			default:
+				ops++
+			}
+		}
+		// 富化钩子在统计之后执行，自适应并发只反映 API 本身的状况
+		if c.enrich != "" {
+			info, err = enrichIPInfo(ctx, c.enrich, dc.Name, ip.String(), info, err, apiTimeout)
+		}
+		ipRes := IPCheckResult{
+			IP:        ip.String(),
+			ActualLLC: c.aliases.Resolve(info.LLC, info.ASN),
+			RawLLC:    info.LLC,
+			ASN:       info.ASN,
+			Country:   info.Country,
+			Source:    source,
+			Error:     err,
+			Latency:   time.Since(lookupStart),
+		}
+		c.bus.Publish(busEvent{Kind: busLookup, Run: runInfoFrom(ctx), Domain: dc.Name, IP: &ipRes})
+		ipResults = append(ipResults, ipRes)
+	}
+	return ipResults, ops, failed, throttled
 }
 
 // loadConfigWithFallback 尝试读取外部配置文件，失败时回退到内嵌配置
@@ -177,6 +864,10 @@ func loadConfigWithFallback(path string) (*Config, error) {
 		if err := yaml.Unmarshal(data, &cfg); err != nil {
 			return nil, fmt.Errorf("解析外部配置文件 %s 失败: %w", path, err)
 		}
+		if err := normalizeConfig(&cfg, path); err != nil {
+			return nil, err
+		}
+		cfg.hash = configHash(data)
 		return &cfg, nil
 	}
 
@@ -187,6 +878,9 @@ func loadConfigWithFallback(path string) (*Config, error) {
 		if err := yaml.Unmarshal(defaultConfigYAML, &cfg); err != nil {
 			return nil, fmt.Errorf("解析内嵌默认配置失败: %w", err)
 		}
+		if err := normalizeConfig(&cfg, "内嵌默认配置"); err != nil {
+			return nil, err
+		}
 		return &cfg, nil
 	}
 
@@ -194,81 +888,131 @@ func loadConfigWithFallback(path string) (*Config, error) {
 	return nil, fmt.Errorf("读取配置文件 %s 失败: %w", path, err)
 }
 
-
 // ---------- 带重试的 LLC 查询 ----------
-func fetchLLCWithRetry(ip string, apiList []string, timeout time.Duration, maxRetries int) (string, error) {
+
+// llcFetcher 汇集 LLC 查询所需的端点列表、限速器、熔断器和重试参数
+type llcFetcher struct {
+	apis      []string
+	providers map[string]*ProviderConfig // 各端点的响应格式与字段映射，见 providers.go
+	limiter   *apiLimiters
+	inflight  inflightLimits // providers 中的 max_in_flight
+	breakers  *breakerSet
+	metrics   *checkMetrics
+	stats     *runStats
+	tracer    *tracer
+	retry     retryPolicies // 配置文件的 retry，见 retry.go
+}
+
+// Fetch 依次尝试各 API 端点查询 IP 的 LLC 与 ASN，跳过处于熔断状态的端点；timeout 是单次请求的超时
+func (f *llcFetcher) Fetch(ctx context.Context, ip string, timeout time.Duration) (ipInfo, error) {
 	var lastErr error
 	// 对每个 API 端点依次尝试
-	for _, baseURL := range apiList {
-		for attempt := 0; attempt <= maxRetries; attempt++ {
-			llc, err := queryLLCFromAPI(ip, baseURL, timeout)
+	for _, baseURL := range f.apis {
+		for attempt := 0; ; attempt++ {
+			// 超出 -domain-budget 或 -run-deadline 后不再发出新的请求
+			if ctx.Err() != nil {
+				return ipInfo{}, fmt.Errorf("所有 API 尝试均失败: %w", ctx.Err())
+			}
+			if !f.breakers.Allow(baseURL) {
+				lastErr = fmt.Errorf("%s: %w", baseURL, errCircuitOpen)
+				break
+			}
+			// 每次请求（包括重试）都先等待提供方的并发槽位，再受速率限制，并将结果反馈给自适应限速
+			waitStart := time.Now()
+			release, err := f.inflight.acquire(ctx, baseURL)
+			if err != nil {
+				lastErr = err
+				break
+			}
+			// 等待令牌时超出期限（或剩余时间不够等到下一个令牌）说明本次查询已无法完成，直接判为失败
+			if err := f.limiter.Wait(ctx, baseURL); err != nil {
+				release()
+				f.stats.AddWait(time.Since(waitStart))
+				return ipInfo{}, fmt.Errorf("所有 API 尝试均失败: %s: 等待限速失败: %w", baseURL, err)
+			}
+			f.stats.AddWait(time.Since(waitStart))
+			f.stats.IncAPI()
+			_, attemptSpan := f.tracer.startSpan(ctx, "api.attempt")
+			attemptSpan.SetAttr("ip", ip)
+			attemptSpan.SetAttr("endpoint", baseURL)
+			attemptSpan.SetAttr("attempt", attempt)
+			start := time.Now()
+			info, err := queryIPInfoFromAPI(ctx, ip, baseURL, f.providers[baseURL], timeout)
+			release()
+			f.metrics.ObserveAPI(baseURL, time.Since(start), err)
+			attemptSpan.End(err)
+			f.limiter.Feedback(baseURL, err)
+			f.breakers.Record(baseURL, err)
 			if err == nil {
-				return llc, nil
+				return info, nil
 			}
 			lastErr = err
-			// 如果是可重试的错误（如网络超时、5xx），则等待后重试
-			if isRetryable(err) && attempt < maxRetries {
-				time.Sleep(backoffDuration(attempt))
+			// 按错误类别的重试策略决定是否等待后重试（默认只重试超时与连接错误）
+			if rule := f.retry.rule(classifyAPIError(err)); attempt < rule.retries {
+				f.metrics.IncRetry(baseURL)
+				f.stats.IncRetry()
+				sleepRetry(ctx, rule.delay(attempt))
 				continue
 			}
 			// 否则跳出当前 API 的重试循环，尝试下一个 API
 			break
 		}
 	}
-	return "", fmt.Errorf("所有 API 尝试均失败: %w", lastErr)
-}
-
-// 判断错误是否可重试（可根据需要扩展）
-func isRetryable(err error) bool {
-	if err == nil {
-		return false
-	}
-	// 简单示例：网络超时、临时性错误可重试
-	if strings.Contains(err.Error(), "timeout") || strings.Contains(err.Error(), "EOF") {
-		return true
-	}
-	// 可增加对具体 HTTP 状态码的判断（由上层传入）
-	return false
-}
-
-// 退避时间：指数退避
-func backoffDuration(attempt int) time.Duration {
-	return time.Duration(1<<uint(attempt)) * time.Second
+	return ipInfo{}, fmt.Errorf("所有 API 尝试均失败: %w", lastErr)
 }
 
 // ---------- 调用单个 API 获取 LLC ----------
-func queryLLCFromAPI(ip, baseURL string, timeout time.Duration) (string, error) {
+// ctx 结束（超出 -domain-budget 或 -run-deadline）时立即中止进行中的请求，timeout 是单次请求的超时
+func queryIPInfoFromAPI(ctx context.Context, ip, baseURL string, provider *ProviderConfig, timeout time.Duration) (ipInfo, error) {
 	url := baseURL + ip
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return ipInfo{}, fmt.Errorf("HTTP 请求失败: %w", err)
+	}
 	client := http.Client{Timeout: timeout}
-	resp, err := client.Get(url)
+	resp, err := client.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("HTTP 请求失败: %w", err)
+		return ipInfo{}, fmt.Errorf("HTTP 请求失败: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
 		// 将 4xx 视为不可重试，5xx 视为可重试（由上层决定）
-		return "", fmt.Errorf("API 返回非 200 状态码: %d", resp.StatusCode)
+		statusErr := &APIStatusError{StatusCode: resp.StatusCode}
+		if *rawDir != "" {
+			body, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+			if path := saveRawResponse(ip, baseURL, provider, body); path != "" {
+				return ipInfo{}, fmt.Errorf("%w（原始响应已保存至 %s）", statusErr, path)
+			}
+		}
+		return ipInfo{}, statusErr
 	}
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return "", fmt.Errorf("读取响应体失败: %w", err)
+		return ipInfo{}, fmt.Errorf("读取响应体失败: %w", err)
 	}
+	saved := saveRawResponse(ip, baseURL, provider, body)
 
-	// 使用 map 解析，避免字段变更导致崩溃
-	var raw IPInfoRaw
-	err = json.Unmarshal(body, &raw)
+	// 按提供方的格式解析为通用结构，避免字段变更导致崩溃
+	raw, err := provider.decode(body)
 	if err != nil {
-		return "", fmt.Errorf("JSON 解析失败: %w", err)
+		if saved != "" {
+			return ipInfo{}, parseError{fmt.Errorf("%w（原始响应已保存至 %s）", err, saved)}
+		}
+		return ipInfo{}, parseError{err}
 	}
 
-	// 提取 llc 字段，支持多种可能的键名（可配置）
-	llc, err := extractLLC(raw)
+	// 按 providers 中配置的路径提取字段，未配置时猜测常见的键名
+	info, err := provider.mapping().extract(raw)
+	if err != nil && saved != "" {
+		// 原始响应已经保存，错误信息中不再附带整个响应的内容
+		return ipInfo{}, parseError{fmt.Errorf("无法从响应中提取 LLC 字段，原始响应已保存至 %s", saved)}
+	}
 	if err != nil {
-		return "", err
+		return info, parseError{err}
 	}
-	return llc, nil
+	return info, nil
 }
 
 // 从解析后的 map 中提取 LLC 字段（容错处理）
@@ -286,33 +1030,75 @@ func extractLLC(data map[string]interface{}) (string, error) {
 	return "", fmt.Errorf("无法从响应中提取 LLC 字段，响应内容: %v", data)
 }
 
+// extractASN 提取 ASN，兼容数字（13335）和字符串（"AS13335"、"AS13335 Cloudflare, Inc."）两种写法，
+// 无法识别时返回 0
+func extractASN(data map[string]interface{}) uint32 {
+	for _, key := range []string{"asn", "as"} {
+		if asn := asnValue(data[key]); asn != 0 {
+			return asn
+		}
+	}
+	return 0
+}
+
+// extractCountry 提取两位国家代码，无法识别时返回空字符串
+func extractCountry(data map[string]interface{}) string {
+	for _, key := range []string{"country_code", "countryCode", "country"} {
+		if s, ok := data[key].(string); ok && len(s) == 2 {
+			return strings.ToUpper(s)
+		}
+	}
+	return ""
+}
+
+// parseASN 解析 "AS13335" 或 "13335" 形式的 ASN
+func parseASN(s string) (uint32, error) {
+	digits := strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(s)), "AS")
+	n, err := strconv.ParseUint(digits, 10, 32)
+	if err != nil || n == 0 {
+		return 0, fmt.Errorf("无效的 ASN %q", s)
+	}
+	return uint32(n), nil
+}
+
+// matchesExpected 判断 LLC 是否符合任一预期；未配置预期时任何 LLC 都符合
+func matchesExpected(llc string, expected []Expectation) bool {
+	if len(expected) == 0 {
+		return true
+	}
+	for _, exp := range expected {
+		if exp.Matches(llc) {
+			return true
+		}
+	}
+	return false
+}
+
 // ---------- 汇总域名结果 ----------
-func aggregateDomainResult(domain string, expected []string, ipResults []IPCheckResult, strict bool) DomainResult {
+func aggregateDomainResult(domain string, expected []Expectation, cidrs []string, rules *Rule, ipResults []IPCheckResult, strict bool) DomainResult {
+	res := DomainResult{Domain: domain, Expected: expected, CIDRs: cidrs, Rules: rules}
+
 	// 先统计每个 IP 是否匹配预期
 	ipMatches := make([]bool, len(ipResults))
 	anySuccess := false
 	allMatch := true
+	anyMismatch := false // 有查询成功但不符合预期的 IP
 
-	for i, res := range ipResults {
-		if res.Error != nil {
+	for i, ipRes := range ipResults {
+		if ipRes.Error != nil {
 			// 查询失败的 IP 视为不匹配
 			ipMatches[i] = false
 			allMatch = false
 			continue
 		}
-		// 检查 LLC 是否匹配预期（前缀匹配）
-		matched := false
-		for _, exp := range expected {
-			if strings.HasPrefix(res.ActualLLC, exp) {
-				matched = true
-				break
-			}
-		}
+		// 检查 LLC 是否匹配预期
+		matched := res.ipMatched(ipRes)
 		ipMatches[i] = matched
 		if matched {
 			anySuccess = true
 		} else {
 			allMatch = false
+			anyMismatch = true
 		}
 	}
 
@@ -335,31 +1121,340 @@ func aggregateDomainResult(domain string, expected []string, ipResults []IPCheck
 		}
 	}
 
+	// 不符合预期完全是由查询失败造成的，无法判定是否污染
+	if polluted && !anyMismatch {
+		polluted = false
+		res.Failed = true
+		summary = "IP 归属查询失败，无法判定"
+	}
+
 	// 构建详细 IP 结果列表（保持原样）
 	detailed := make([]IPCheckResult, len(ipResults))
 	copy(detailed, ipResults)
 
-	return DomainResult{
-		Domain:     domain,
-		Expected:   expected,
-		IPResults:  detailed,
-		IsPolluted: polluted,
-		Summary:    summary,
+	res.IPResults = detailed
+	res.IsPolluted = polluted
+	res.Summary = summary
+	return res
+}
+
+// ipMatched 判断单个 IP 是否正常：未命中黑名单，且在固定 IP 列表中（固定 IP 模式）或查询成功并符合预期
+func (r DomainResult) ipMatched(ip IPCheckResult) bool {
+	if ip.Private {
+		return false
+	}
+	if len(r.PinnedIPs) > 0 {
+		return ip.Forbidden == "" && !ip.NotPinned
+	}
+	switch {
+	case ip.Source == sourceBogon, ip.Source == sourceFakeIP:
+		return false
+	case ip.Source == sourceExpected:
+		return ip.Forbidden == ""
+	case len(r.CIDRs) > 0 && len(r.Expected) == 0 && r.Rules == nil:
+		// 只配置了 expected_cidrs，网段之外的 IP 不符合预期
+		return false
 	}
+	if r.Rules != nil {
+		return ip.Error == nil && ip.Forbidden == "" && r.Rules.Eval(ip)
+	}
+	return ip.Error == nil && ip.Forbidden == "" && matchesExpected(ip.ActualLLC, r.Expected)
 }
 
 // ---------- 构建报告 ----------
-func buildReport(results []DomainResult) string {
+
+// validateFlags 在开始检测前检查 -format、-output 模板、过滤、解析器与超时参数，避免检测完成后才发现参数错误
+func validateFlags() error {
+	if _, err := newDNSResolver(*resolverArg, *qnameMin, *strategy); err != nil {
+		return err
+	}
+	if _, err := newFallbackResolvers(*fallbackArg); err != nil {
+		return err
+	}
+	if err := validateSampling(*maxIPs, *ipSample); err != nil {
+		return err
+	}
+	if err := validateRateFlags(*apiBurst, *rpsScope); err != nil {
+		return err
+	}
+	if err := validateHomeCountry(*homeCountry); err != nil {
+		return err
+	}
+	if *dnsTimeout < 0 || *apiTimeout < 0 {
+		return fmt.Errorf("-dns-timeout 与 -api-timeout 不能为负数")
+	}
+	if err := validateRetention(historyRetention()); err != nil {
+		return err
+	}
+	if *confirmRuns < 1 {
+		return fmt.Errorf("-confirm 必须为正整数")
+	}
+	if err := validateTraceroute(*traceMode, *traceHops); err != nil {
+		return err
+	}
+	if err := validateProbe(*probeMode); err != nil {
+		return err
+	}
+	if _, err := parseReportLangs(*reportLang); err != nil {
+		return err
+	}
+	if *maxRedirect < 0 || *maxRedirect > 20 {
+		return fmt.Errorf("-max-redirects 必须为 0~20 的整数")
+	}
+	if _, err := parseProbePorts(*probePorts); err != nil {
+		return err
+	}
+	if err := validateStrategy(*strategy); err != nil {
+		return err
+	}
+	if _, err := parseTransports(*transports); err != nil {
+		return err
+	}
+	if _, err := parseShard(*shardSpec); err != nil {
+		return err
+	}
+	if err := validateHijackCheck(*hijackAddr); err != nil {
+		return err
+	}
+	if _, err := parseProxyCheck(*proxyCheck); err != nil {
+		return err
+	}
+	if *learnDays < 0 {
+		return fmt.Errorf("-learn-days 不能为负数")
+	}
+	if *learnDays > 0 && *historyDSN == "" {
+		return fmt.Errorf("-learn-days 需要配合 -history 使用")
+	}
+	switch *format {
+	case "text", "influx", "json":
+	default:
+		return fmt.Errorf("不支持的报告格式 %q（可选 text、influx、json）", *format)
+	}
+	if _, err := newResultFilter(*onlyFilter, *minSeverity); err != nil {
+		return err
+	}
+	if strings.Contains(*outputFile, "{{") {
+		if _, err := expandOutputTemplate(*outputFile, outputVars{}); err != nil {
+			return err
+		}
+	}
+	return validateOutputRotation(*outputFile)
+}
+
+// formatExt 返回报告格式对应的默认文件扩展名
+func formatExt(f string) string {
+	switch f {
+	case "influx":
+		return "lp"
+	case "json":
+		return "json"
+	}
+	return "txt"
+}
+
+// renderReport 按 -format 生成报告内容，文本报告末尾附上本轮的告警状态变化
+func (c *checker) renderReport(run runInfo, results []DomainResult, events []alertEvent) string {
+	// -redact 时所有格式的报告（终端、文件、对象存储）都经过脱敏
+	run = redaction.Run(run)
+	switch *format {
+	case "influx":
+		return redaction.Text(buildInfluxLines(results, run.Meta, time.Now(), c.filter))
+	case "json":
+		return redaction.Text(buildJSONReport(run, results, events))
+	}
+	report := reportBanner(run) + buildReport(results, c.filter) + c.stats.Summary()
+	if summary := c.fetcher.breakers.Summary(); summary != "" {
+		report += summary
+	}
+	return redaction.Text(report + alertSection(events))
+}
+
+// reportBanner 返回文本报告开头的网络环境警告：强制门户、透明 DNS 劫持与透明代理；都未发现时为空
+func reportBanner(run runInfo) string {
 	var b strings.Builder
+	if run.Meta.UUID != "" {
+		b.WriteString(run.Meta.String() + "\n")
+	}
+	if run.Captive != "" {
+		b.WriteString(fmt.Sprintf("!!! 检测到强制门户（captive portal）: %s\n!!! 流量被拦截，本报告的结果不可信，也不评估告警；请先完成网络认证后重新检测\n", run.Captive))
+	}
+	if run.Hijack != "" {
+		b.WriteString(fmt.Sprintf("!!! 检测到透明 DNS 劫持: %s\n!!! 网络中的设备在拦截并代答 DNS 查询，指定的解析器可能根本没有收到查询\n", run.Hijack))
+	}
+	if run.Proxy != "" {
+		b.WriteString(fmt.Sprintf("!!! 检测到透明 DNS 代理: %s\n!!! 发往不同解析器的查询实际由同一个解析器处理，-resolver 与 -transports 中的 UDP/TCP 比较不能说明该解析器本身的行为\n", run.Proxy))
+	}
+	if run.Shard != "" {
+		b.WriteString(fmt.Sprintf("分片: %s（本报告只包含分到该分片的 %d 个域名）\n", run.Shard, run.Domains))
+	}
+	if b.Len() > 0 {
+		b.WriteString("\n")
+	}
+	return b.String()
+}
 
-	// 统计
-	total := len(results)
-	polluted := 0
-	for _, r := range results {
-		if r.IsPolluted {
-			polluted++
+// buildReport 生成文本报告；头部统计和异常汇总覆盖全部结果，详细结果只列出通过过滤的域名
+func buildReport(results []DomainResult, filter resultFilter) string {
+	var b strings.Builder
+	writeReportHeader(&b, results)
+	writeResolverLatency(&b, collectResolverLatency(results))
+	writeOffenders(&b, collectOffenders(results))
+	writeHeatmap(&b, resultHeatmap(results))
+	shown := filter.Apply(results)
+	if filter.Active() {
+		b.WriteString(fmt.Sprintf("详细结果（已过滤，显示 %d/%d 个域名）:\n", len(shown), len(results)))
+	} else {
+		b.WriteString("详细结果:\n")
+	}
+
+	for _, res := range shown {
+		b.WriteString(fmt.Sprintf("域名: %s\n", displayDomain(res.Domain, res.ASCIIDomain)))
+		b.WriteString(fmt.Sprintf("  汇总: %s (污染: %s)\n", res.Summary, pollutedLabel(res)))
+		if res.Rules != nil {
+			b.WriteString(fmt.Sprintf("  规则: %s\n", res.Rules))
+		}
+		if len(res.Trace) > 0 {
+			b.WriteString(fmt.Sprintf("  解析路径: %s\n", resolvePath(res.Trace)))
+		}
+		if res.GeoHint != "" {
+			b.WriteString(fmt.Sprintf("  国家不符: %s\n", res.GeoHint))
+		}
+		if len(res.Patterns) > 0 {
+			b.WriteString(fmt.Sprintf("  干扰特征: %s\n", strings.Join(res.Patterns, "；")))
+		}
+		if len(res.CNAMEs) > 0 {
+			b.WriteString(fmt.Sprintf("  CNAME: %s\n", strings.Join(res.CNAMEs, " → ")))
+		}
+		if res.Resolution != nil {
+			b.WriteString(fmt.Sprintf("  多解析器%s\n", res.Resolution))
+		}
+		if res.Failover != nil {
+			b.WriteString(fmt.Sprintf("  解析器切换: %s\n", res.Failover))
+		}
+		if res.Muted != "" {
+			b.WriteString(fmt.Sprintf("  维护中: %s\n", res.Muted))
+		}
+		if len(res.OffBaseline) > 0 {
+			b.WriteString(fmt.Sprintf("  基线: %s 不在基线中\n", strings.Join(res.OffBaseline, ", ")))
+		}
+		if len(res.Suspicious) > 0 {
+			b.WriteString(fmt.Sprintf("  可疑变化: %s\n", strings.Join(res.Suspicious, "；")))
+		}
+		if res.Transport != nil {
+			b.WriteString(fmt.Sprintf("  传输方式比较%s\n", res.Transport))
+		}
+		if res.Authority != nil {
+			b.WriteString(fmt.Sprintf("  权威比较%s\n", res.Authority))
+		}
+		if res.DualStack != nil {
+			b.WriteString(fmt.Sprintf("  双栈比较%s\n", res.DualStack))
+		}
+		if len(res.Hijacks) > 0 {
+			b.WriteString(fmt.Sprintf("  疑似运营商劫持: %s\n", strings.Join(res.Hijacks, "；")))
+		}
+		if res.flapping() {
+			b.WriteString(fmt.Sprintf("  待确认: 正式判定仍为 %s，本轮判定已连续 %d/%d 轮为 %s\n", debouncedVerdict(res), res.Streak, *confirmRuns, verdictOf(res)))
+		}
+		if res.SampledFrom > 0 {
+			b.WriteString(fmt.Sprintf("  抽样: 共解析到 %d 个 IP，只查询其中 %d 个\n", res.SampledFrom, len(res.IPResults)))
+		}
+		for _, rt := range res.Routes {
+			b.WriteString(fmt.Sprintf("  路由追踪 %s\n", rt))
+		}
+		shownIPs := res.IPResults
+		if *anycastMode {
+			shownIPs = collapseAnswers(res)
+		}
+		for _, ipRes := range shownIPs {
+			writeIPLine(&b, res, ipRes)
+			if ipRes.Probe != nil {
+				b.WriteString(fmt.Sprintf("    可达性: %s\n", ipRes.Probe))
+			}
+			if ipRes.Ports != nil {
+				b.WriteString(fmt.Sprintf("    端口探测%s\n", ipRes.Ports))
+			}
+			if len(ipRes.Listed) > 0 {
+				b.WriteString(fmt.Sprintf("    威胁情报: 被列入 %s\n", strings.Join(ipRes.Listed, "、")))
+			}
+			if ipRes.Page != nil {
+				b.WriteString(fmt.Sprintf("    内容校验: %s\n", ipRes.Page))
+			}
+			if len(ipRes.Anomalies) > 0 {
+				b.WriteString(fmt.Sprintf("    响应异常（评分 %d）: %s\n", ipRes.AnomalyScore, strings.Join(ipRes.Anomalies, "；")))
+			}
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// writeIPLine 写入单个 IP 的检查结果
+func writeIPLine(b *strings.Builder, res DomainResult, ipRes IPCheckResult) {
+	if ipRes.Error != nil {
+		b.WriteString(fmt.Sprintf("  IP %s: 错误 - %v", ipRes.IP, ipRes.Error))
+		if ipRes.Forbidden != "" {
+			b.WriteString(fmt.Sprintf(" [命中黑名单: %s]", ipRes.Forbidden))
+		}
+		b.WriteString("\n")
+		return
+	}
+	if ipRes.Private {
+		b.WriteString(fmt.Sprintf("  IP %s: 私有地址 - 可能是 DNS 重绑定或路由器劫持\n", ipRes.IP))
+		return
+	}
+	if ipRes.Source == sourceBogon {
+		b.WriteString(fmt.Sprintf("  IP %s: 保留地址 - 可能被污染\n", ipRes.IP))
+		return
+	}
+	if ipRes.Source == sourceFakeIP {
+		b.WriteString(fmt.Sprintf("  IP %s: 特征库中的伪造 IP - 已被污染\n", ipRes.IP))
+		return
+	}
+
+	// 检查是否匹配预期（用于报告显示）
+	status := "正常"
+	switch {
+	case ipRes.Forbidden != "":
+		status = "命中黑名单: " + ipRes.Forbidden
+	case ipRes.NotPinned:
+		status = "不在固定列表中"
+	case !res.ipMatched(ipRes):
+		status = "可能被污染"
+	}
+	if len(res.PinnedIPs) > 0 {
+		b.WriteString(fmt.Sprintf("  IP %s: (期望: 固定 IP %v) - %s\n", ipRes.IP, res.PinnedIPs, status))
+		return
+	}
+	if ipRes.Source == sourceExpected {
+		b.WriteString(fmt.Sprintf("  IP %s: 属于预期网段 %v - %s\n", ipRes.IP, res.CIDRs, status))
+		return
+	}
+	llc := ipRes.ActualLLC
+	if ipRes.RawLLC != "" && ipRes.RawLLC != ipRes.ActualLLC {
+		llc += fmt.Sprintf(" [原始: %s]", ipRes.RawLLC)
+	}
+	if res.Rules != nil {
+		if ipRes.ASN != 0 {
+			llc += fmt.Sprintf(" ASN=AS%d", ipRes.ASN)
 		}
+		if ipRes.Country != "" {
+			llc += " 国家=" + ipRes.Country
+		}
+		b.WriteString(fmt.Sprintf("  IP %s: LLC=%s - %s\n", ipRes.IP, llc, status))
+		return
+	}
+	expected := fmt.Sprint(res.Expected)
+	if len(res.CIDRs) > 0 {
+		expected += fmt.Sprintf(" 或网段 %v", res.CIDRs)
 	}
+	b.WriteString(fmt.Sprintf("  IP %s: LLC=%s (期望: %s) - %s\n", ipRes.IP, llc, expected, status))
+}
+
+// writeReportHeader 写入报告头部的统计信息
+func writeReportHeader(b *strings.Builder, results []DomainResult) {
+	// 统计
+	total := len(results)
+	polluted := countPolluted(results)
 	rate := 0.0
 	if total > 0 {
 		rate = float64(polluted) / float64(total) * 100
@@ -371,38 +1466,113 @@ func buildReport(results []DomainResult) string {
 	b.WriteString("=================\n")
 	b.WriteString(fmt.Sprintf("检测域名总数: %d\n", total))
 	b.WriteString(fmt.Sprintf("被污染域名数: %d\n", polluted))
+	if failed := countFailed(results); failed > 0 {
+		b.WriteString(fmt.Sprintf("无法判定的域名数: %d（解析或 IP 归属查询失败，不计入污染）\n", failed))
+	}
+	b.WriteString(fmt.Sprintf("私有地址应答域名数: %d\n", countRebinding(results)))
 	b.WriteString(fmt.Sprintf("污染率: %.2f%%\n", rate))
 	b.WriteString(fmt.Sprintf("污染程度: %s\n", level))
+	if muted := countMuted(results); muted > 0 {
+		b.WriteString(fmt.Sprintf("维护中（不告警）的域名数: %d\n", muted))
+	}
+	if blockpage := countBlockpage(results); blockpage > 0 {
+		b.WriteString(fmt.Sprintf("CNAME 指向已知拦截页的域名数: %d\n", blockpage))
+	}
+	if hints := countGeoHints(results); hints > 0 {
+		b.WriteString(fmt.Sprintf("解析到的 IP 所在国家与预期相反的域名数: %d\n", hints))
+	}
+	if matched := countInterference(results); matched > 0 {
+		b.WriteString(fmt.Sprintf("符合已知运营商干扰特征的域名数: %d\n", matched))
+	}
+	if suspicious := countSuspicious(results); suspicious > 0 {
+		b.WriteString(fmt.Sprintf("应答可疑变化的域名数: %d\n", suspicious))
+	}
+	if mismatch := countTransportMismatch(results); mismatch > 0 {
+		b.WriteString(fmt.Sprintf("传输方式应答不一致的域名数: %d\n", mismatch))
+	}
+	if mismatch := countFanoutDisagreement(results); mismatch > 0 {
+		b.WriteString(fmt.Sprintf("各解析器应答不一致的域名数: %d\n", mismatch))
+	}
+	if switched := countFailover(results); switched > 0 {
+		b.WriteString(fmt.Sprintf("主解析器失败、由备用解析器应答的域名数: %d\n", switched))
+	}
+	if mismatch := countDualStackMismatch(results); mismatch > 0 {
+		b.WriteString(fmt.Sprintf("IPv4 与 IPv6 判定不一致的域名数: %d\n", mismatch))
+	}
+	if mismatch := countAuthMismatch(results); mismatch > 0 {
+		b.WriteString(fmt.Sprintf("递归应答与权威应答不一致的域名数: %d\n", mismatch))
+	}
+	if hijacked := countHijacked(results); hijacked > 0 {
+		b.WriteString(fmt.Sprintf("疑似运营商劫持的域名数: %d\n", hijacked))
+	}
+	if unreachable := countUnreachable(results); unreachable > 0 {
+		b.WriteString(fmt.Sprintf("有 IP 探测不可达的域名数: %d\n", unreachable))
+	}
+	if listed := countListed(results); listed > 0 {
+		b.WriteString(fmt.Sprintf("有 IP 被列入 DNSBL 或威胁情报的域名数: %d\n", listed))
+	}
+	if flapping := countFlapping(results); flapping > 0 {
+		b.WriteString(fmt.Sprintf("判定待确认的域名数: %d（正式判定中被污染的域名数: %d）\n", flapping, countDebounced(results)))
+	}
+	writeLocalSources(b, results)
 	b.WriteString("=================\n\n")
-	b.WriteString("详细结果:\n")
-
-	for _, res := range results {
-		b.WriteString(fmt.Sprintf("域名: %s\n", res.Domain))
-		b.WriteString(fmt.Sprintf("  汇总: %s (污染: %v)\n", res.Summary, res.IsPolluted))
-		for _, ipRes := range res.IPResults {
-			if ipRes.Error != nil {
-				b.WriteString(fmt.Sprintf("  IP %s: 错误 - %v\n", ipRes.IP, ipRes.Error))
-			} else {
-				// 检查是否匹配预期（用于报告显示）
-				matched := false
-				for _, exp := range res.Expected {
-					if strings.HasPrefix(ipRes.ActualLLC, exp) {
-						matched = true
-						break
-					}
-				}
-				status := "正常"
-				if !matched {
-					status = "可能被污染"
-				}
-				b.WriteString(fmt.Sprintf("  IP %s: LLC=%s (期望: %v) - %s\n", ipRes.IP, ipRes.ActualLLC, res.Expected, status))
-			}
+}
+
+// buildSummaryReport 只包含头部统计和被污染域名列表，用于 -summary
+func buildSummaryReport(results []DomainResult, filter resultFilter) string {
+	var b strings.Builder
+	writeReportHeader(&b, results)
+	var lines, failed []string
+	for _, res := range filter.Apply(results) {
+		line := fmt.Sprintf("  %s: %s\n", displayDomain(res.Domain, res.ASCIIDomain), res.Summary)
+		switch verdictOf(res) {
+		case verdictPolluted, verdictRebinding:
+			lines = append(lines, line)
+		case verdictError:
+			failed = append(failed, line)
 		}
-		b.WriteString("\n")
+	}
+	if len(lines) > 0 {
+		b.WriteString("被污染的域名:\n")
+		b.WriteString(strings.Join(lines, ""))
+	}
+	if len(failed) > 0 {
+		b.WriteString("无法判定的域名:\n")
+		b.WriteString(strings.Join(failed, ""))
 	}
 	return b.String()
 }
 
+// countFailed 统计因解析或查询失败而无法判定的域名数
+func countFailed(results []DomainResult) int {
+	n := 0
+	for _, r := range results {
+		if verdictOf(r) == verdictError {
+			n++
+		}
+	}
+	return n
+}
+
+// pollutedLabel 返回报告汇总行中的污染结论：true、false 或无法判定
+func pollutedLabel(r DomainResult) string {
+	if verdictOf(r) == verdictError {
+		return "无法判定"
+	}
+	return fmt.Sprint(r.IsPolluted)
+}
+
+// countPolluted 统计被判定为污染的域名数
+func countPolluted(results []DomainResult) int {
+	n := 0
+	for _, r := range results {
+		if r.IsPolluted {
+			n++
+		}
+	}
+	return n
+}
+
 func pollutionLevel(rate float64) string {
 	switch {
 	case rate < 20:
@@ -418,5 +1588,11 @@ func pollutionLevel(rate float64) string {
 
 // ---------- 写入文件 ----------
 func writeReportToFile(report, filename string) error {
+	// 模板生成的路径可能包含尚不存在的目录
+	if dir := filepath.Dir(filename); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
 	return os.WriteFile(filename, []byte(report), 0644)
 }
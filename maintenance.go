@@ -0,0 +1,267 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ---------- 维护窗口与静音 ----------
+
+// MaintenanceWindow 是配置文件顶层 maintenance 中的一个维护窗口。窗口内照常检测，
+// 但结果标记为维护中，告警规则与 syslog 判定事件不再因这些域名通知。
+// 可以写成一次性的时间段（start/end），也可以写成周期性的 cron 表达式加持续时间（schedule/duration）。
+type MaintenanceWindow struct {
+	Name     string        `yaml:"name"`
+	Domains  []string      `yaml:"domains"`  // 为空时对所有域名生效
+	Start    string        `yaml:"start"`    // 如 "2026-10-20 01:00"，使用本地时区，也可写 RFC 3339
+	End      string        `yaml:"end"`      //
+	Schedule string        `yaml:"schedule"` // 五段式 cron：分 时 日 月 周，如 "0 2 * * 0"
+	Duration time.Duration `yaml:"duration"` // 每次从 schedule 命中的时刻起持续多久
+
+	start, end time.Time
+	cron       *cronSpec
+	domains    map[string]bool
+}
+
+// 一次性维护窗口接受的时间格式
+var maintenanceTimeLayouts = []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02T15:04"}
+
+func parseMaintenanceTime(s string) (time.Time, error) {
+	for _, layout := range maintenanceTimeLayouts {
+		if t, err := time.ParseInLocation(layout, strings.TrimSpace(s), time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("无法解析时间 %q（如 2026-10-20 01:00）", s)
+}
+
+// compile 校验并编译维护窗口
+func (w *MaintenanceWindow) compile() error {
+	ranged := w.Start != "" || w.End != ""
+	periodic := w.Schedule != "" || w.Duration != 0
+	switch {
+	case ranged && periodic:
+		return fmt.Errorf("start/end 与 schedule/duration 只能二选一")
+	case ranged:
+		var err error
+		if w.start, err = parseMaintenanceTime(w.Start); err != nil {
+			return err
+		}
+		if w.end, err = parseMaintenanceTime(w.End); err != nil {
+			return err
+		}
+		if !w.end.After(w.start) {
+			return fmt.Errorf("end 必须晚于 start")
+		}
+	case periodic:
+		if w.Duration <= 0 {
+			return fmt.Errorf("schedule 需要配合正的 duration")
+		}
+		spec, err := parseCron(w.Schedule)
+		if err != nil {
+			return err
+		}
+		w.cron = spec
+	default:
+		return fmt.Errorf("需要 start/end 或 schedule/duration")
+	}
+	w.domains = nil
+	if len(w.Domains) > 0 {
+		w.domains = make(map[string]bool, len(w.Domains))
+		for _, d := range w.Domains {
+			name, err := normalizeDomainName(d)
+			if err != nil {
+				return err
+			}
+			w.domains[name] = true
+		}
+	}
+	return nil
+}
+
+// validateMaintenance 编译配置中的维护窗口
+func validateMaintenance(cfg *Config) []string {
+	var problems []string
+	for i := range cfg.Maintenance {
+		w := &cfg.Maintenance[i]
+		label := w.Name
+		if label == "" {
+			label = fmt.Sprintf("第 %d 项", i+1)
+		}
+		if err := w.compile(); err != nil {
+			problems = append(problems, fmt.Sprintf("maintenance %s: %v", label, err))
+		}
+	}
+	return problems
+}
+
+// Active 判断 t 是否处于窗口内
+func (w *MaintenanceWindow) Active(t time.Time) bool {
+	if w.cron == nil {
+		return !t.Before(w.start) && t.Before(w.end)
+	}
+	// 从 t 所在的分钟向前回溯 duration，任一分钟命中 schedule 即处于窗口内
+	for m := t.Truncate(time.Minute); t.Sub(m) < w.Duration; m = m.Add(-time.Minute) {
+		if w.cron.Match(m) {
+			return true
+		}
+	}
+	return false
+}
+
+// Covers 判断窗口是否作用于 domain
+func (w *MaintenanceWindow) Covers(domain string) bool {
+	return w.domains == nil || w.domains[domain]
+}
+
+// muteAll 是静音所有域名时使用的键
+const muteAll = "*"
+
+// applyMaintenance 为处于维护窗口或被静音的域名填写 Muted；mutes 为域名（或 "*"）到静音截止时间的映射
+func applyMaintenance(results []DomainResult, windows []MaintenanceWindow, mutes map[string]time.Time, now time.Time) {
+	var active []*MaintenanceWindow
+	for i := range windows {
+		if windows[i].Active(now) {
+			active = append(active, &windows[i])
+		}
+	}
+	if len(active) == 0 && len(mutes) == 0 {
+		return
+	}
+	for i := range results {
+		r := &results[i]
+		for _, w := range active {
+			if w.Covers(r.Domain) {
+				r.Muted = "维护窗口"
+				if w.Name != "" {
+					r.Muted += " " + w.Name
+				}
+				break
+			}
+		}
+		if r.Muted != "" {
+			continue
+		}
+		for _, key := range []string{r.Domain, muteAll} {
+			if until, ok := mutes[key]; ok && now.Before(until) {
+				r.Muted = fmt.Sprintf("已静音至 %s", until.Format("2006-01-02 15:04"))
+				break
+			}
+		}
+	}
+}
+
+// countMuted 统计处于维护中或被静音的域名数
+func countMuted(results []DomainResult) int {
+	n := 0
+	for _, r := range results {
+		if r.Muted != "" {
+			n++
+		}
+	}
+	return n
+}
+
+// unmuted 返回未处于维护中、也未被静音的结果
+func unmuted(results []DomainResult) []DomainResult {
+	if countMuted(results) == 0 {
+		return results
+	}
+	out := make([]DomainResult, 0, len(results))
+	for _, r := range results {
+		if r.Muted == "" {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+// ---------- cron 表达式 ----------
+
+// cronSpec 是五段式 cron 表达式，各段为允许的取值集合
+type cronSpec struct {
+	minute, hour, dom, month, dow []bool
+	domAny, dowAny                bool // 日、周是否为 *，用于实现 cron 中日与周“或”的语义
+}
+
+// parseCron 解析“分 时 日 月 周”，每段支持 *、数字、a-b、逗号分隔的列表以及 /n 步长；周日可写作 0 或 7
+func parseCron(s string) (*cronSpec, error) {
+	fields := strings.Fields(s)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron 表达式 %q 应有 5 段（分 时 日 月 周）", s)
+	}
+	var (
+		spec cronSpec
+		err  error
+	)
+	if spec.minute, err = parseCronField(fields[0], 0, 59); err != nil {
+		return nil, fmt.Errorf("cron 表达式 %q 的分钟: %w", s, err)
+	}
+	if spec.hour, err = parseCronField(fields[1], 0, 23); err != nil {
+		return nil, fmt.Errorf("cron 表达式 %q 的小时: %w", s, err)
+	}
+	if spec.dom, err = parseCronField(fields[2], 1, 31); err != nil {
+		return nil, fmt.Errorf("cron 表达式 %q 的日期: %w", s, err)
+	}
+	if spec.month, err = parseCronField(fields[3], 1, 12); err != nil {
+		return nil, fmt.Errorf("cron 表达式 %q 的月份: %w", s, err)
+	}
+	if spec.dow, err = parseCronField(fields[4], 0, 7); err != nil {
+		return nil, fmt.Errorf("cron 表达式 %q 的星期: %w", s, err)
+	}
+	if spec.dow[7] {
+		spec.dow[0] = true
+	}
+	spec.domAny = fields[2] == "*"
+	spec.dowAny = fields[4] == "*"
+	return &spec, nil
+}
+
+func parseCronField(field string, min, max int) ([]bool, error) {
+	set := make([]bool, max+1)
+	for _, part := range strings.Split(field, ",") {
+		rng, step := part, 1
+		if i := strings.IndexByte(part, '/'); i >= 0 {
+			n, err := strconv.Atoi(part[i+1:])
+			if err != nil || n <= 0 {
+				return nil, fmt.Errorf("无效的步长 %q", part)
+			}
+			rng, step = part[:i], n
+		}
+		lo, hi := min, max
+		if rng != "*" {
+			bounds := strings.SplitN(rng, "-", 2)
+			var err error
+			if lo, err = strconv.Atoi(bounds[0]); err != nil {
+				return nil, fmt.Errorf("无效的取值 %q", part)
+			}
+			hi = lo
+			if len(bounds) == 2 {
+				if hi, err = strconv.Atoi(bounds[1]); err != nil {
+					return nil, fmt.Errorf("无效的取值 %q", part)
+				}
+			}
+			if lo < min || hi > max || lo > hi {
+				return nil, fmt.Errorf("取值 %q 超出范围 %d-%d", part, min, max)
+			}
+		}
+		for v := lo; v <= hi; v += step {
+			set[v] = true
+		}
+	}
+	return set, nil
+}
+
+// Match 判断 t（精确到分钟）是否命中表达式
+func (c *cronSpec) Match(t time.Time) bool {
+	if !c.minute[t.Minute()] || !c.hour[t.Hour()] || !c.month[int(t.Month())] {
+		return false
+	}
+	domOK, dowOK := c.dom[t.Day()], c.dow[int(t.Weekday())]
+	if c.domAny || c.dowAny {
+		return domOK && dowOK
+	}
+	return domOK || dowOK
+}
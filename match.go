@@ -0,0 +1,145 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ---------- LLC 匹配规则 ----------
+
+// 支持的匹配方式
+const (
+	matchPrefix   = "prefix"
+	matchContains = "contains"
+	matchSuffix   = "suffix"
+	matchExact    = "exact"
+	matchRegex    = "regex"
+)
+
+// Expectation 是一条预期 LLC。配置中可以直接写字符串（前缀匹配），
+// 也可以写成对象单独指定匹配方式：
+//
+//	expected_llcs:
+//	  - GOOGLE
+//	  - { value: "cloudflare", match: contains, case_insensitive: true }
+//
+// 未指定 match / case_insensitive 时继承域名级别的设置。
+type Expectation struct {
+	Value           string `yaml:"value"`
+	Match           string `yaml:"match"`
+	CaseInsensitive *bool  `yaml:"case_insensitive"`
+
+	re      *regexp.Regexp // match 为 regex 时预编译的表达式
+	carrier string         // -carrier-names 时，值对应的内置运营商标识，见 carriers.go
+}
+
+// UnmarshalYAML 同时支持字符串和对象两种写法
+func (e *Expectation) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		return value.Decode(&e.Value)
+	}
+	type plain Expectation
+	return value.Decode((*plain)(e))
+}
+
+// newExpectation 创建并校验一条预期，regex 方式会在此预编译
+func newExpectation(value, match string, caseInsensitive bool) (Expectation, error) {
+	e := Expectation{Value: value, Match: match, CaseInsensitive: &caseInsensitive}
+	if e.Match == "" {
+		e.Match = matchPrefix
+	}
+	switch e.Match {
+	case matchPrefix, matchContains, matchSuffix, matchExact:
+		if *carrierMap {
+			e.carrier = carrierExact(value)
+		}
+	case matchRegex:
+		expr := value
+		if caseInsensitive {
+			expr = "(?i)" + expr
+		}
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return e, fmt.Errorf("预期 %q 的正则表达式无效: %w", value, err)
+		}
+		e.re = re
+	default:
+		return e, fmt.Errorf("预期 %q 的匹配方式 %q 无效（可选 prefix、contains、suffix、exact、regex）", value, match)
+	}
+	return e, nil
+}
+
+// prefixExpectations 将字符串列表转换为默认的区分大小写前缀匹配
+func prefixExpectations(values []string) []Expectation {
+	out := make([]Expectation, 0, len(values))
+	for _, v := range values {
+		e, _ := newExpectation(v, matchPrefix, false)
+		out = append(out, e)
+	}
+	return out
+}
+
+// resolveExpectations 用域名级别的默认设置补全每条预期并校验
+func resolveExpectations(expected []Expectation, match string, caseInsensitive bool) ([]Expectation, error) {
+	out := make([]Expectation, 0, len(expected))
+	for _, e := range expected {
+		m := e.Match
+		if m == "" {
+			m = match
+		}
+		ci := caseInsensitive
+		if e.CaseInsensitive != nil {
+			ci = *e.CaseInsensitive
+		}
+		resolved, err := newExpectation(e.Value, m, ci)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, resolved)
+	}
+	return out, nil
+}
+
+// Matches 判断 LLC 是否符合这条预期
+func (e Expectation) Matches(llc string) bool {
+	if e.Match == matchRegex {
+		return e.re != nil && e.re.MatchString(llc)
+	}
+	if e.carrier != "" && llc == e.carrier {
+		return true
+	}
+	value := e.Value
+	if e.CaseInsensitive != nil && *e.CaseInsensitive {
+		llc, value = strings.ToLower(llc), strings.ToLower(value)
+	}
+	switch e.Match {
+	case matchContains:
+		return strings.Contains(llc, value)
+	case matchSuffix:
+		return strings.HasSuffix(llc, value)
+	case matchExact:
+		return llc == value
+	default:
+		return strings.HasPrefix(llc, value)
+	}
+}
+
+// String 用于报告显示：默认的前缀匹配只显示值，其余方式附带匹配说明
+func (e Expectation) String() string {
+	m := e.Match
+	if m == "" {
+		m = matchPrefix
+	}
+	ci := e.CaseInsensitive != nil && *e.CaseInsensitive
+	if m == matchPrefix && !ci {
+		return e.Value
+	}
+	s := e.Value + "(" + m
+	if ci {
+		s += ",忽略大小写"
+	}
+	return s + ")"
+}
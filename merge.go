@@ -0,0 +1,238 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"html/template"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ---------- 报告合并（dnscheck merge） ----------
+
+// runSource 是合并报告中的一个来源，即一份输入的 JSON 报告
+type runSource struct {
+	File    string    `json:"file"`
+	Host    string    `json:"host,omitempty"`
+	RunID   string    `json:"run_id"`
+	Shard   string    `json:"shard,omitempty"`
+	Started time.Time `json:"started_at"`
+	Domains int       `json:"domains"`
+}
+
+// label 返回来源的标识：主机名，旧版报告没有主机名时为文件名
+func (s runSource) label() string {
+	if s.Host != "" {
+		return s.Host
+	}
+	return filepath.Base(s.File)
+}
+
+// runMerge 实现 `dnscheck merge a.json b.json -o combined.html`：合并多个分片或多个观测点的 -format json 报告。
+// 输出文件扩展名为 .html 时生成 HTML 报告，否则输出 JSON；未指定 -o 时将 JSON 写到标准输出
+func runMerge(args []string) {
+	fs := flag.NewFlagSet("merge", flag.ExitOnError)
+	output := fs.String("o", "", "输出文件，扩展名为 .html 时生成 HTML 报告，否则为 JSON（为空则将 JSON 写到标准输出）")
+	history := fs.String("history", "", "历史记录数据库，指定时在 HTML 报告中加入各域名的污染时间线与整体污染率图")
+	days := fs.Int("days", 30, "-history 图表覆盖的天数")
+	// 允许选项出现在文件名之后，如 dnscheck merge a.json b.json -o combined.html
+	var files []string
+	for rest := args; ; {
+		_ = fs.Parse(rest)
+		if fs.NArg() == 0 {
+			break
+		}
+		files = append(files, fs.Arg(0))
+		rest = fs.Args()[1:]
+	}
+	if len(files) == 0 {
+		fmt.Fprintln(os.Stderr, "用法: dnscheck merge <结果.json>... [-o 输出文件.html|.json] [-history 数据库 -days 天数]")
+		os.Exit(2)
+	}
+
+	runs := make([]runJSON, 0, len(files))
+	for _, f := range files {
+		run, err := loadRunJSON(f)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(2)
+		}
+		runs = append(runs, run)
+	}
+	merged, dropped := mergeRuns(files, runs)
+	if dropped > 0 {
+		fmt.Fprintf(os.Stderr, "已丢弃 %d 条重复结果（同一来源的同一域名只保留最新一轮）\n", dropped)
+	}
+
+	var data []byte
+	var err error
+	switch ext := strings.ToLower(filepath.Ext(*output)); ext {
+	case ".html", ".htm":
+		var charts *historyCharts
+		if *history != "" {
+			if *days < 1 {
+				fmt.Fprintln(os.Stderr, "-days 必须为正整数")
+				os.Exit(2)
+			}
+			if charts, err = loadHistoryCharts(*history, *days, time.Now()); err != nil {
+				fmt.Fprintf(os.Stderr, "读取历史记录失败: %v\n", err)
+				os.Exit(1)
+			}
+		}
+		data, err = renderMergedHTML(merged, charts)
+	default:
+		data, err = json.MarshalIndent(merged, "", "  ")
+		data = append(data, '\n')
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "生成合并报告失败: %v\n", err)
+		os.Exit(1)
+	}
+	if *output == "" {
+		os.Stdout.Write(data)
+		return
+	}
+	if err := os.WriteFile(*output, data, 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "写入合并报告失败: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Fprintf(os.Stderr, "合并了 %d 份报告、%d 条结果，已保存至: %s\n", len(runs), len(merged.Results), *output)
+}
+
+// mergeRuns 合并多份报告：每条结果的 source 标注其来源主机。同一来源的同一域名出现多次时（如分片重叠或重复运行）
+// 只保留开始时间最新的一条，返回丢弃的条数；不同来源的同一域名都会保留，便于比较各观测点的结果
+func mergeRuns(files []string, runs []runJSON) (runJSON, int) {
+	merged := runJSON{RunID: newRunID(time.Now()), Results: []domainJSON{}}
+	type entry struct {
+		result  domainJSON
+		started time.Time
+	}
+	latest := make(map[string]entry)
+	var captive, hijack, proxy []string
+	dropped := 0
+	for i, run := range runs {
+		src := runSource{File: files[i], Host: run.Host, RunID: run.RunID, Shard: run.Shard, Started: run.StartedAt, Domains: len(run.Results)}
+		merged.Sources = append(merged.Sources, src)
+		if merged.StartedAt.IsZero() || run.StartedAt.Before(merged.StartedAt) {
+			merged.StartedAt = run.StartedAt
+		}
+		label := src.label()
+		if run.Captive != "" {
+			captive = append(captive, label+": "+run.Captive)
+		}
+		if run.Hijack != "" {
+			hijack = append(hijack, label+": "+run.Hijack)
+		}
+		if run.Proxy != "" {
+			proxy = append(proxy, label+": "+run.Proxy)
+		}
+		for _, r := range run.Results {
+			r.Source = label
+			key := r.Domain + "\x00" + label
+			if prev, ok := latest[key]; ok {
+				dropped++
+				if !run.StartedAt.After(prev.started) {
+					continue
+				}
+			}
+			latest[key] = entry{result: r, started: run.StartedAt}
+		}
+	}
+	for _, e := range latest {
+		merged.Results = append(merged.Results, e.result)
+	}
+	sort.Slice(merged.Results, func(i, j int) bool {
+		a, b := merged.Results[i], merged.Results[j]
+		if a.Domain != b.Domain {
+			return a.Domain < b.Domain
+		}
+		return a.Source < b.Source
+	})
+	merged.Domains = len(merged.Results)
+	for _, r := range merged.Results {
+		if r.Polluted {
+			merged.Polluted++
+		}
+		if r.Rebinding {
+			merged.Rebinding++
+		}
+	}
+	merged.Captive = strings.Join(captive, "；")
+	merged.Hijack = strings.Join(hijack, "；")
+	merged.Proxy = strings.Join(proxy, "；")
+	return merged, dropped
+}
+
+var mergedHTML = template.Must(template.New("merge").Funcs(template.FuncMap{
+	"verdict": func(r domainJSON) string { return r.verdict() },
+	"label":   func(s runSource) string { return s.label() },
+	"time":    func(t time.Time) string { return t.Local().Format("2006-01-02 15:04:05") },
+	"heatmap": collectHeatmap,
+}).Parse(`<!DOCTYPE html>
+<html lang="zh-CN">
+<head>
+<meta charset="utf-8">
+<title>dnscheck 合并报告</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: left; vertical-align: top; }
+th { background: #f0f0f0; }
+.polluted { background: #fde2e2; }
+.rebinding { background: #fff1cc; }
+.warn { color: #b00; font-weight: bold; }
+svg.spark { vertical-align: middle; }
+.cell-clean { background: #d7f0d7; }
+.cell-polluted { background: #e05050; color: #fff; }
+.cell-differs { background: #ffd27f; }
+.cell-error { background: #ddd; }
+</style>
+</head>
+<body>
+<h1>dnscheck 合并报告</h1>
+<p>合并 ID {{.RunID}}，最早开始于 {{time .StartedAt}}；共 {{.Domains}} 条结果，其中被污染 {{.Polluted}} 条、私有地址应答 {{.Rebinding}} 条。</p>
+{{if .Captive}}<p class="warn">检测到强制门户（相应来源的结果不可信）: {{.Captive}}</p>{{end}}
+{{if .Hijack}}<p class="warn">检测到透明 DNS 劫持: {{.Hijack}}</p>{{end}}
+{{if .Proxy}}<p class="warn">检测到透明 DNS 代理: {{.Proxy}}</p>{{end}}
+<h2>来源</h2>
+<table>
+<tr><th>来源</th><th>文件</th><th>轮次</th><th>分片</th><th>开始时间</th><th>结果数</th></tr>
+{{range .Sources}}<tr><td>{{label .}}</td><td>{{.File}}</td><td>{{.RunID}}</td><td>{{.Shard}}</td><td>{{time .Started}}</td><td>{{.Domains}}</td></tr>
+{{end}}</table>
+{{with $h := heatmap .Results}}<h2>解析器热力图</h2>
+<p>第一列是各来源主解析器的判定，其余各列是 -transports 中的解析器；某一列整列被污染说明问题出在该解析器。鼠标悬停可查看应答。</p>
+<table>
+<tr><th>域名</th>{{range .Columns}}<th>{{.}}</th>{{end}}</tr>
+{{range .Rows}}<tr><td>{{.Label}}</td>{{range .Cells}}<td class="{{.Class}}" title="{{.Detail}}">{{.Label}}</td>{{end}}</tr>
+{{end}}<tr><th>被污染</th>{{range $i, $c := .Columns}}<th>{{index $h.Polluted $i}}/{{index $h.Answered $i}}</th>{{end}}</tr>
+</table>
+{{end}}{{with .History}}<h2>最近 {{.Days}} 天的污染率</h2>
+<p>{{time .Since}} 至 {{time .Until}}，共 {{.Runs}} 条检测记录{{if .Peak}}，污染率最高的一天为 {{.Peak}}{{else}}，没有发现污染{{end}}。每根柱为一天（UTC）所有域名检测中被判定为污染的比例。</p>
+{{.Rate}}
+{{end}}<h2>结果</h2>
+<table>
+<tr><th>域名</th><th>来源</th><th>判定</th><th>严重程度</th><th>汇总</th><th>IP</th>{{if .History}}<th>最近 {{.History.Days}} 天</th>{{end}}</tr>
+{{range .Results}}<tr class="{{verdict .}}"><td>{{.Domain}}</td><td>{{.Source}}</td><td>{{verdict .}}</td><td>{{.Severity}}</td><td>{{.Summary}}</td><td>{{range .IPs}}{{.IP}}{{if .LLC}}（{{.LLC}}）{{end}}<br>{{end}}</td>{{if $.History}}<td>{{$.History.Sparkline .Domain}}</td>{{end}}</tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+// mergedPage 是 HTML 合并报告模板的数据
+type mergedPage struct {
+	runJSON
+	History *historyCharts // 未指定 -history 时为 nil
+}
+
+// renderMergedHTML 将合并结果渲染为独立的 HTML 页面；charts 非 nil 时加入历史图表
+func renderMergedHTML(run runJSON, charts *historyCharts) ([]byte, error) {
+	var b strings.Builder
+	if err := mergedHTML.Execute(&b, mergedPage{runJSON: run, History: charts}); err != nil {
+		return nil, err
+	}
+	return []byte(b.String()), nil
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// ---------- 运行元数据 ----------
+
+// version 是工具版本，发布构建时通过 -ldflags "-X main.version=v1.2.3" 写入
+var version = "dev"
+
+// egressTimeout 是查询出口 IP 的超时时间
+const egressTimeout = 3 * time.Second
+
+// runMeta 记录一轮检测的运行环境，写入各种格式的报告，使归档的报告在几个月后仍然可以解读和比较
+type runMeta struct {
+	UUID       string   `json:"uuid"`
+	Version    string   `json:"version"`
+	Host       string   `json:"-"` // JSON 报告中为顶层的 host 字段
+	EgressIP   string   `json:"egress_ip,omitempty"`
+	Resolvers  []string `json:"resolvers"`
+	ConfigHash string   `json:"config_hash,omitempty"` // 配置文件内容的 SHA-256，内嵌默认配置时为空
+}
+
+func (m runMeta) String() string {
+	s := fmt.Sprintf("运行 %s（dnscheck %s，主机 %s", m.UUID, m.Version, m.Host)
+	if m.EgressIP != "" {
+		s += "，出口 IP " + m.EgressIP
+	}
+	s += "，解析器 " + strings.Join(m.Resolvers, "、")
+	if m.ConfigHash != "" {
+		s += "，配置 " + m.ConfigHash[:12]
+	}
+	return s + "）"
+}
+
+// newRunMeta 生成一轮检测的元数据；出口 IP 由 startEgressCheck 在检测期间查询
+func newRunMeta(cfg *Config) runMeta {
+	host, _ := os.Hostname()
+	return runMeta{
+		UUID:       newUUID(),
+		Version:    version,
+		Host:       host,
+		Resolvers:  resolverSet(),
+		ConfigHash: cfg.hash,
+	}
+}
+
+// newUUID 生成随机的 UUID（RFC 4122 第 4 版）
+func newUUID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	h := hex.EncodeToString(b[:])
+	return h[:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:]
+}
+
+// configHash 返回配置文件内容的 SHA-256
+func configHash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// resolverSet 描述本轮使用的解析器：-resolver（系统解析器时列出 /etc/resolv.conf 中的地址）与 -transports
+func resolverSet() []string {
+	var set []string
+	switch *resolverArg {
+	case "", "system":
+		if servers := systemNameservers(); len(servers) > 0 {
+			for _, s := range servers {
+				set = append(set, "system:"+s)
+			}
+		} else {
+			set = append(set, "system")
+		}
+	default:
+		set = append(set, *resolverArg)
+	}
+	specs, _ := parseTransports(*transports) // 已由 validateFlags 检查
+	for _, spec := range specs {
+		set = append(set, spec.String())
+	}
+	return set
+}
+
+// startEgressCheck 与本轮检测并行查询 -egress-check，返回的函数等待查询完成并写入 run.Meta
+func startEgressCheck() func(run *runInfo) {
+	egress := make(chan string, 1)
+	go func() { egress <- detectEgressIP(context.Background(), *egressURL) }()
+	return func(run *runInfo) {
+		run.Meta.EgressIP = <-egress
+	}
+}
+
+// detectEgressIP 请求返回纯文本 IP 的地址（如 https://api.ipify.org），得到本机的出口 IP；
+// 未启用、请求失败或响应不是 IP 时返回空字符串
+func detectEgressIP(ctx context.Context, target string) string {
+	if target == "" {
+		return ""
+	}
+	ctx, cancel := context.WithTimeout(ctx, egressTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return ""
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return ""
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 64))
+	if err != nil || resp.StatusCode != http.StatusOK {
+		return ""
+	}
+	ip := net.ParseIP(strings.TrimSpace(string(body)))
+	if ip == nil {
+		return ""
+	}
+	return ip.String()
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ---------- 运行指标 ----------
+
+// latencyBuckets 是延迟直方图的桶上界（秒）
+var latencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// histogram 是按 Prometheus 语义累积的简单直方图
+type histogram struct {
+	counts []uint64 // 与 latencyBuckets 一一对应（非累积）
+	count  uint64
+	sum    float64
+}
+
+func (h *histogram) observe(v float64) {
+	if h.counts == nil {
+		h.counts = make([]uint64, len(latencyBuckets))
+	}
+	for i, le := range latencyBuckets {
+		if v <= le {
+			h.counts[i]++
+			break
+		}
+	}
+	h.count++
+	h.sum += v
+}
+
+// checkMetrics 记录 DNS 解析与 API 查询的延迟和计数，供 /metrics 使用
+type checkMetrics struct {
+	mu           sync.Mutex
+	dnsLatency   histogram
+	apiLatency   map[string]*histogram // 按端点
+	apiRequests  map[string]uint64     // 键为 "端点\x00结果"
+	apiRetries   map[string]uint64     // 按端点
+	avoided      map[string]uint64     // 本地分类省下的 API 查询，按来源
+	cacheHits    uint64                // IP 查询缓存的累计命中次数
+	cacheLookups uint64                // IP 查询缓存的累计查找次数（命中与未命中）
+}
+
+func newCheckMetrics() *checkMetrics {
+	return &checkMetrics{
+		apiLatency:  make(map[string]*histogram),
+		apiRequests: make(map[string]uint64),
+		apiRetries:  make(map[string]uint64),
+		avoided:     make(map[string]uint64),
+	}
+}
+
+// ObserveDNS 记录一次 DNS 解析耗时
+func (m *checkMetrics) ObserveDNS(d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.dnsLatency.observe(d.Seconds())
+}
+
+// ObserveAPI 记录一次 API 请求的耗时和结果
+func (m *checkMetrics) ObserveAPI(endpoint string, d time.Duration, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	h, ok := m.apiLatency[endpoint]
+	if !ok {
+		h = &histogram{}
+		m.apiLatency[endpoint] = h
+	}
+	h.observe(d.Seconds())
+	result := "success"
+	if err != nil {
+		result = "error"
+		if isThrottled(err) {
+			result = "throttled"
+		}
+	}
+	m.apiRequests[endpoint+"\x00"+result]++
+}
+
+// IncRetry 记录一次 API 重试
+func (m *checkMetrics) IncRetry(endpoint string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.apiRetries[endpoint]++
+}
+
+// IncAvoided 记录一次由本地分类代替的 API 查询
+func (m *checkMetrics) IncAvoided(source string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.avoided[source]++
+}
+
+// ObserveCache 记录一次 IP 查询缓存的查找及是否命中
+func (m *checkMetrics) ObserveCache(hit bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.cacheLookups++
+	if hit {
+		m.cacheHits++
+	}
+}
+
+// WritePrometheus 以 Prometheus 文本格式输出累积指标
+func (m *checkMetrics) WritePrometheus(w io.Writer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	fmt.Fprintln(w, "# HELP dnscheck_dns_resolution_seconds DNS 解析耗时")
+	fmt.Fprintln(w, "# TYPE dnscheck_dns_resolution_seconds histogram")
+	writeHistogram(w, "dnscheck_dns_resolution_seconds", "", &m.dnsLatency)
+
+	fmt.Fprintln(w, "# HELP dnscheck_api_request_seconds IP 信息 API 请求耗时")
+	fmt.Fprintln(w, "# TYPE dnscheck_api_request_seconds histogram")
+	endpoints := make([]string, 0, len(m.apiLatency))
+	for endpoint := range m.apiLatency {
+		endpoints = append(endpoints, endpoint)
+	}
+	sort.Strings(endpoints)
+	for _, endpoint := range endpoints {
+		writeHistogram(w, "dnscheck_api_request_seconds", fmt.Sprintf("endpoint=%q", endpoint), m.apiLatency[endpoint])
+	}
+
+	fmt.Fprintln(w, "# HELP dnscheck_api_requests_total IP 信息 API 请求次数（按结果）")
+	fmt.Fprintln(w, "# TYPE dnscheck_api_requests_total counter")
+	for _, key := range sortedKeys(m.apiRequests) {
+		parts := strings.SplitN(key, "\x00", 2)
+		fmt.Fprintf(w, "dnscheck_api_requests_total{endpoint=%q,result=%q} %d\n", parts[0], parts[1], m.apiRequests[key])
+	}
+
+	fmt.Fprintln(w, "# HELP dnscheck_api_retries_total IP 信息 API 重试次数")
+	fmt.Fprintln(w, "# TYPE dnscheck_api_retries_total counter")
+	for _, endpoint := range sortedKeys(m.apiRetries) {
+		fmt.Fprintf(w, "dnscheck_api_retries_total{endpoint=%q} %d\n", endpoint, m.apiRetries[endpoint])
+	}
+
+	fmt.Fprintln(w, "# HELP dnscheck_api_lookups_avoided_total 在本地完成分类、未查询 API 的 IP 数（按来源）")
+	fmt.Fprintln(w, "# TYPE dnscheck_api_lookups_avoided_total counter")
+	for _, source := range sortedKeys(m.avoided) {
+		fmt.Fprintf(w, "dnscheck_api_lookups_avoided_total{source=%q} %d\n", source, m.avoided[source])
+	}
+
+	fmt.Fprintln(w, "# HELP dnscheck_ip_cache_hits_total IP 查询缓存（-ip-cache-ttl）的命中次数")
+	fmt.Fprintln(w, "# TYPE dnscheck_ip_cache_hits_total counter")
+	fmt.Fprintf(w, "dnscheck_ip_cache_hits_total %d\n", m.cacheHits)
+	fmt.Fprintln(w, "# HELP dnscheck_ip_cache_lookups_total IP 查询缓存的查找次数（命中与未命中），与命中次数相除即为命中率")
+	fmt.Fprintln(w, "# TYPE dnscheck_ip_cache_lookups_total counter")
+	fmt.Fprintf(w, "dnscheck_ip_cache_lookups_total %d\n", m.cacheLookups)
+}
+
+// writeHistogram 输出单个直方图的 bucket/sum/count 行
+func writeHistogram(w io.Writer, name, labels string, h *histogram) {
+	sep := ""
+	if labels != "" {
+		sep = ","
+	}
+	var cum uint64
+	for i, le := range latencyBuckets {
+		if h.counts != nil {
+			cum += h.counts[i]
+		}
+		fmt.Fprintf(w, "%s_bucket{%s%sle=\"%g\"} %d\n", name, labels, sep, le, cum)
+	}
+	fmt.Fprintf(w, "%s_bucket{%s%sle=\"+Inf\"} %d\n", name, labels, sep, h.count)
+	if labels != "" {
+		labels = "{" + labels + "}"
+	}
+	fmt.Fprintf(w, "%s_sum%s %g\n", name, labels, h.sum)
+	fmt.Fprintf(w, "%s_count%s %d\n", name, labels, h.count)
+}
+
+func sortedKeys(m map[string]uint64) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ---------- 临时静音（mute / unmute 子命令与守护模式 /mute） ----------
+
+// muteJSON 是 /mute 返回的单条静音记录
+type muteJSON struct {
+	Domain string    `json:"domain"` // "*" 表示全部域名
+	Until  time.Time `json:"until"`
+}
+
+// activeMutes 返回未过期静音的副本，并清理已过期的记录
+func (d *daemon) activeMutes() map[string]time.Time {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	now := time.Now()
+	out := make(map[string]time.Time, len(d.mutes))
+	for domain, until := range d.mutes {
+		if !now.Before(until) {
+			delete(d.mutes, domain)
+			continue
+		}
+		out[domain] = until
+	}
+	return out
+}
+
+// muteList 按域名排序返回当前的静音
+func (d *daemon) muteList() []muteJSON {
+	mutes := d.activeMutes()
+	list := make([]muteJSON, 0, len(mutes))
+	for domain, until := range mutes {
+		list = append(list, muteJSON{Domain: domain, Until: until})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Domain < list[j].Domain })
+	return list
+}
+
+// muteTarget 校验要静音的域名：必须是 "*" 或配置中的域名
+func (d *daemon) muteTarget(domain string) (string, error) {
+	if domain == muteAll {
+		return domain, nil
+	}
+	name, err := normalizeDomainName(domain)
+	if err != nil {
+		return "", err
+	}
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	for _, dc := range d.config.Domains {
+		if dc.Name == name {
+			return name, nil
+		}
+	}
+	return "", fmt.Errorf("域名 %s 不在检测列表中", domain)
+}
+
+// handleMute 管理临时静音：GET 列出，POST domain=&duration= 添加，DELETE domain= 解除。
+// 静音只保存在内存中，守护进程重启后失效。
+func (d *daemon) handleMute(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, d.muteList())
+	case http.MethodPost:
+		domain, err := d.muteTarget(r.FormValue("domain"))
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		dur, err := time.ParseDuration(r.FormValue("duration"))
+		if err != nil || dur <= 0 {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("无效的静音时长 %q（如 2h、30m）", r.FormValue("duration"))})
+			return
+		}
+		until := time.Now().Add(dur)
+		d.mu.Lock()
+		d.mutes[domain] = until
+		d.mu.Unlock()
+		writeJSON(w, http.StatusOK, muteJSON{Domain: domain, Until: until})
+	case http.MethodDelete:
+		domain, err := d.muteTarget(r.FormValue("domain"))
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		d.mu.Lock()
+		_, ok := d.mutes[domain]
+		delete(d.mutes, domain)
+		d.mu.Unlock()
+		if !ok {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": fmt.Sprintf("%s 未被静音", domain)})
+			return
+		}
+		writeJSON(w, http.StatusOK, muteJSON{Domain: domain})
+	default:
+		w.Header().Set("Allow", "GET, POST, DELETE")
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "只支持 GET、POST 与 DELETE"})
+	}
+}
+
+// runMute 实现 `dnscheck mute [-daemon URL] <域名|*> <时长>` 与 `dnscheck mute -list`
+func runMute(args []string) {
+	fs := flag.NewFlagSet("mute", flag.ExitOnError)
+	daemonURL := fs.String("daemon", "http://127.0.0.1:8080", "守护进程的 HTTP 地址")
+	list := fs.Bool("list", false, "列出当前的静音")
+	token := fs.String("token", os.Getenv("DNSCHECK_TOKEN"), "守护进程启用 -api-tokens 时使用的令牌（默认取环境变量 DNSCHECK_TOKEN）")
+	_ = fs.Parse(args)
+
+	var (
+		mutes []muteJSON
+		err   error
+	)
+	switch {
+	case *list && fs.NArg() == 0:
+		err = muteRequest(http.MethodGet, *daemonURL, *token, nil, &mutes)
+	case !*list && fs.NArg() == 2:
+		var m muteJSON
+		err = muteRequest(http.MethodPost, *daemonURL, *token, url.Values{"domain": {fs.Arg(0)}, "duration": {fs.Arg(1)}}, &m)
+		mutes = []muteJSON{m}
+	default:
+		fmt.Fprintln(os.Stderr, "用法: dnscheck mute [-daemon URL] <域名|*> <时长>  或  dnscheck mute [-daemon URL] -list")
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	if len(mutes) == 0 {
+		fmt.Println("当前没有静音")
+	}
+	for _, m := range mutes {
+		fmt.Printf("%s 已静音至 %s\n", m.Domain, m.Until.Local().Format("2006-01-02 15:04:05"))
+	}
+}
+
+// runUnmute 实现 `dnscheck unmute [-daemon URL] <域名|*>`
+func runUnmute(args []string) {
+	fs := flag.NewFlagSet("unmute", flag.ExitOnError)
+	daemonURL := fs.String("daemon", "http://127.0.0.1:8080", "守护进程的 HTTP 地址")
+	token := fs.String("token", os.Getenv("DNSCHECK_TOKEN"), "守护进程启用 -api-tokens 时使用的令牌（默认取环境变量 DNSCHECK_TOKEN）")
+	_ = fs.Parse(args)
+	if fs.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "用法: dnscheck unmute [-daemon URL] <域名|*>")
+		os.Exit(2)
+	}
+	if err := muteRequest(http.MethodDelete, *daemonURL, *token, url.Values{"domain": {fs.Arg(0)}}, nil); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("%s 已解除静音\n", fs.Arg(0))
+}
+
+// muteRequest 调用守护进程的 /mute 接口，成功时将响应解码到 out（可为 nil）。token 非空时以 Bearer 令牌认证
+func muteRequest(method, daemonURL, token string, values url.Values, out interface{}) error {
+	endpoint := strings.TrimSuffix(daemonURL, "/") + "/mute"
+	var body io.Reader
+	if method == http.MethodGet || method == http.MethodDelete {
+		if len(values) > 0 {
+			endpoint += "?" + values.Encode()
+		}
+	} else {
+		body = strings.NewReader(values.Encode())
+	}
+	req, err := http.NewRequest(method, endpoint, body)
+	if err != nil {
+		return fmt.Errorf("无效的守护进程地址: %w", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("连接守护进程失败: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		var e struct {
+			Error string `json:"error"`
+		}
+		if json.NewDecoder(resp.Body).Decode(&e) == nil && e.Error != "" {
+			return fmt.Errorf("守护进程返回错误: %s", e.Error)
+		}
+		return fmt.Errorf("守护进程返回 HTTP %d", resp.StatusCode)
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("解析守护进程响应失败: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// ---------- 应答变化检测（基于历史记录） ----------
+
+// 学习历史应答的参数
+const (
+	minLearnSamples = 5   // 历史检测少于该次数时不做判断
+	noveltyZ        = 3.0 // IP 数偏离历史均值超过多少个标准差视为突变
+	minCountStddev  = 0.5 // 标准差的下限，避免历史 IP 数恒定时任何变化都被放大
+)
+
+// answerProfile 是从历史记录中学到的单个域名的典型应答
+type answerProfile struct {
+	Samples    int            // 有应答的历史检测次数
+	ASNSamples int            // 其中带有 ASN 信息的次数（早期版本的历史记录不含 ASN）
+	ASNs       map[uint32]int // 各 ASN 出现的次数
+	Counts     []int          // 每次检测的 IP 数（-anycast 时为逻辑应答数）
+}
+
+// learnProfiles 从 since 之后的历史记录中按域名统计应答。
+// DNS 解析失败（没有 IP）的记录不计入，以免把故障期间的空应答当作常态
+func learnProfiles(store historyStore, since time.Time) (map[string]*answerProfile, error) {
+	records, err := store.Query(historyFilter{From: since})
+	if err != nil {
+		return nil, err
+	}
+	profiles := make(map[string]*answerProfile)
+	for _, rec := range records {
+		if len(rec.IPs) == 0 {
+			continue
+		}
+		p := profiles[rec.Domain]
+		if p == nil {
+			p = &answerProfile{ASNs: make(map[uint32]int)}
+			profiles[rec.Domain] = p
+		}
+		p.Samples++
+		p.Counts = append(p.Counts, historyAnswerCount(rec.IPs))
+		withASN := false
+		for _, ip := range rec.IPs {
+			if ip.ASN != 0 {
+				p.ASNs[ip.ASN]++
+				withASN = true
+			}
+		}
+		if withASN {
+			p.ASNSamples++
+		}
+	}
+	return profiles, nil
+}
+
+// countStats 返回历史 IP 数的均值与标准差
+func (p *answerProfile) countStats() (mean, stddev float64) {
+	for _, n := range p.Counts {
+		mean += float64(n)
+	}
+	mean /= float64(len(p.Counts))
+	for _, n := range p.Counts {
+		stddev += (float64(n) - mean) * (float64(n) - mean)
+	}
+	return mean, math.Sqrt(stddev / float64(len(p.Counts)))
+}
+
+// Novelties 将本轮结果与历史应答比较，返回可疑变化的描述：历史上从未出现过的 ASN，
+// 以及偏离历史均值超过 noveltyZ 个标准差的 IP 数（-anycast 时为逻辑应答数）。即使这些 IP 符合预期（如预期范围较宽），也会报告
+func (p *answerProfile) Novelties(r DomainResult) []string {
+	if p == nil || p.Samples < minLearnSamples || len(r.IPResults) == 0 {
+		return nil
+	}
+	var found []string
+	if p.ASNSamples >= minLearnSamples {
+		seen := make(map[uint32]bool)
+		for _, ip := range r.IPResults {
+			if ip.ASN == 0 || p.ASNs[ip.ASN] > 0 || seen[ip.ASN] {
+				continue
+			}
+			seen[ip.ASN] = true
+			found = append(found, fmt.Sprintf("AS%d（%s）在过去 %d 次检测中从未出现", ip.ASN, ip.IP, p.ASNSamples))
+		}
+	}
+	mean, stddev := p.countStats()
+	n := answerCount(r.IPResults)
+	if math.Abs(float64(n)-mean)/math.Max(stddev, minCountStddev) >= noveltyZ {
+		unit := "IP"
+		if *anycastMode {
+			unit = "逻辑应答"
+		}
+		found = append(found, fmt.Sprintf("解析到 %d 个%s，历史均值为 %.1f", n, unit, mean))
+	}
+	return found
+}
+
+// applySuspicious 为每个结果写入与历史应答相比的可疑变化
+func applySuspicious(results []DomainResult, profiles map[string]*answerProfile) {
+	for i := range results {
+		results[i].Suspicious = profiles[results[i].Domain].Novelties(results[i])
+	}
+}
+
+// detectSuspicious 在配置了 -learn-days 时，从历史记录中学习各域名的典型应答并标注本轮的可疑变化。
+// 必须在保存本轮结果之前调用，否则本轮应答会被当作历史
+func detectSuspicious(store historyStore, results []DomainResult, now time.Time) error {
+	if store == nil || *learnDays <= 0 {
+		return nil
+	}
+	profiles, err := learnProfiles(store, now.AddDate(0, 0, -*learnDays))
+	if err != nil {
+		return fmt.Errorf("学习历史应答失败: %w", err)
+	}
+	applySuspicious(results, profiles)
+	return nil
+}
+
+// detectSuspiciousDSN 是单次运行使用的便捷函数：打开历史存储、标注可疑变化后关闭
+func detectSuspiciousDSN(dsn string, results []DomainResult, now time.Time) error {
+	if dsn == "" || *learnDays <= 0 {
+		return nil
+	}
+	store, err := openHistoryStore(dsn)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+	return detectSuspicious(store, results, now)
+}
+
+// countSuspicious 统计存在可疑变化的域名数
+func countSuspicious(results []DomainResult) int {
+	n := 0
+	for _, r := range results {
+		if len(r.Suspicious) > 0 {
+			n++
+		}
+	}
+	return n
+}
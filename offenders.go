@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ---------- 异常汇总（Top offenders） ----------
+
+// offendersTopN 是每个排行榜列出的条目数
+const offendersTopN = 5
+
+// offenderCount 是排行榜中的一项
+type offenderCount struct {
+	Key     string
+	Count   int
+	Domains []string // 出现该项的域名（已排序、去重）
+}
+
+// offenders 汇总所有不符合预期的 IP，将逐个域名的结果归纳为可操作的规律
+type offenders struct {
+	LLCs      []offenderCount // 最常见的非预期 LLC
+	ASNs      []offenderCount // 最常见的非预期 ASN
+	IPs       []offenderCount // 最常出现的非预期 IP（疑似注入地址）
+	Resolvers []offenderCount // -resolver 指定多个解析器时，应答中非预期 IP 最多的解析器
+}
+
+// collectOffenders 统计查询成功但不符合预期的 IP；查询失败的 IP 不计入。
+// 解析器排行按各解析器应答中出现的非预期 IP 计数，未查询归属的 IP（如被抽样略过）不计入
+func collectOffenders(results []DomainResult) offenders {
+	llcs := make(map[string]map[string]int)
+	asns := make(map[string]map[string]int)
+	ips := make(map[string]map[string]int)
+	resolvers := make(map[string]map[string]int)
+	add := func(m map[string]map[string]int, key, domain string) {
+		if m[key] == nil {
+			m[key] = make(map[string]int)
+		}
+		m[key][domain]++
+	}
+	for _, r := range results {
+		unexpected := make(map[string]bool)
+		for _, ip := range r.IPResults {
+			if ip.Error != nil || r.ipMatched(ip) {
+				continue
+			}
+			unexpected[ip.IP] = true
+			if len(r.PinnedIPs) == 0 && !ip.Private && ip.Source != sourceBogon {
+				add(llcs, ip.ActualLLC, r.Domain)
+				if ip.ASN != 0 {
+					add(asns, fmt.Sprintf("AS%d", ip.ASN), r.Domain)
+				}
+			}
+			add(ips, ip.IP, r.Domain)
+		}
+		if r.Resolution == nil || len(unexpected) == 0 {
+			continue
+		}
+		for _, a := range r.Resolution.Answers {
+			for _, ip := range a.IPs {
+				if unexpected[ip] {
+					add(resolvers, a.Resolver, r.Domain)
+				}
+			}
+		}
+	}
+	return offenders{
+		LLCs:      rankOffenders(llcs),
+		ASNs:      rankOffenders(asns),
+		IPs:       rankOffenders(ips),
+		Resolvers: rankOffenders(resolvers),
+	}
+}
+
+// rankOffenders 按出现次数从多到少排序，次数相同时按名称排序，只保留前 offendersTopN 项
+func rankOffenders(m map[string]map[string]int) []offenderCount {
+	out := make([]offenderCount, 0, len(m))
+	for key, domains := range m {
+		oc := offenderCount{Key: key}
+		for d, n := range domains {
+			oc.Count += n
+			oc.Domains = append(oc.Domains, d)
+		}
+		sort.Strings(oc.Domains)
+		out = append(out, oc)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Count != out[j].Count {
+			return out[i].Count > out[j].Count
+		}
+		return out[i].Key < out[j].Key
+	})
+	if len(out) > offendersTopN {
+		out = out[:offendersTopN]
+	}
+	return out
+}
+
+// writeOffenders 写入报告中的异常汇总部分，没有非预期 IP 时不输出
+func writeOffenders(b *strings.Builder, o offenders) {
+	if len(o.IPs) == 0 {
+		return
+	}
+	b.WriteString("异常汇总:\n")
+	if len(o.LLCs) > 0 {
+		b.WriteString("  最常见的非预期 LLC:\n")
+	}
+	for _, oc := range o.LLCs {
+		llc := oc.Key
+		if llc == "" {
+			llc = "(空)"
+		}
+		b.WriteString(fmt.Sprintf("    %-30s %d 次  涉及域名: %s\n", llc, oc.Count, joinDomains(oc.Domains)))
+	}
+	if len(o.ASNs) > 0 {
+		b.WriteString("  最常见的非预期 ASN:\n")
+	}
+	for _, oc := range o.ASNs {
+		b.WriteString(fmt.Sprintf("    %-30s %d 次  涉及域名: %s\n", oc.Key, oc.Count, joinDomains(oc.Domains)))
+	}
+	b.WriteString("  最常出现的非预期 IP:\n")
+	for _, oc := range o.IPs {
+		b.WriteString(fmt.Sprintf("    %-30s %d 次  涉及域名: %s\n", oc.Key, oc.Count, joinDomains(oc.Domains)))
+	}
+	if len(o.Resolvers) > 0 {
+		b.WriteString("  返回非预期 IP 最多的解析器:\n")
+	}
+	for _, oc := range o.Resolvers {
+		b.WriteString(fmt.Sprintf("    %-30s %d 次  涉及域名: %s\n", oc.Key, oc.Count, joinDomains(oc.Domains)))
+	}
+	b.WriteString("\n")
+}
+
+// joinDomains 拼接域名列表，过长时只显示前几个
+func joinDomains(domains []string) string {
+	const max = 3
+	if len(domains) <= max {
+		return strings.Join(domains, ", ")
+	}
+	return fmt.Sprintf("%s 等 %d 个", strings.Join(domains[:max], ", "), len(domains))
+}
@@ -0,0 +1,20 @@
+package main
+
+import (
+	_ "embed"
+	"net/http"
+)
+
+// ---------- 接口文档（守护模式 /api/openapi.json） ----------
+
+// 守护模式各接口的 OpenAPI 3 文档与程序一起发布，集成方可以据此生成客户端；Go 程序可直接使用 client 包。
+// 修改接口或响应结构时须同时更新 openapi.json 与 client 包
+
+//go:embed openapi.json
+var openAPISpec []byte
+
+// handleOpenAPI 返回内嵌的 OpenAPI 文档
+func (d *daemon) handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	_, _ = w.Write(openAPISpec)
+}
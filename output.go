@@ -0,0 +1,165 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+	"unicode"
+)
+
+// ---------- 报告文件命名 ----------
+
+const (
+	reportPrefix = "dnscheck_report_"
+	// defaultOutputTemplate 是未指定 -output 或 -output 为目录时使用的文件名模板
+	defaultOutputTemplate = reportPrefix + "{{.Timestamp}}.{{.Ext}}"
+)
+
+// outputVars 是 -output 模板可用的变量
+type outputVars struct {
+	Date           string  // 2006-01-02
+	Time           string  // 150405
+	Timestamp      string  // 20060102_150405
+	Host           string  // 主机名
+	Config         string  // 配置文件名（不含扩展名）
+	RunID          string  // 本轮检测的唯一 ID
+	Format         string  // 报告格式
+	Ext            string  // 报告格式对应的扩展名
+	Total          int     // 检测域名总数
+	Polluted       int     // 被污染域名数
+	PollutionRate  float64 // 污染率（百分比）
+	PollutionLevel string  // 污染程度
+	Group          string  // 守护模式下本轮所属的 schedules 名称，未按分组调度时为空
+	Project        string  // 守护模式按项目运行时的项目 ID
+}
+
+// newOutputVars 根据本轮检测的元数据和结果构造模板变量
+func newOutputVars(runID string, at time.Time, results []DomainResult) outputVars {
+	host, _ := os.Hostname()
+	polluted := 0
+	for _, r := range results {
+		if r.IsPolluted {
+			polluted++
+		}
+	}
+	rate := 0.0
+	if len(results) > 0 {
+		rate = float64(polluted) / float64(len(results)) * 100
+	}
+	return outputVars{
+		Date:           at.Format("2006-01-02"),
+		Time:           at.Format("150405"),
+		Timestamp:      at.Format("20060102_150405"),
+		Host:           host,
+		Config:         configName(*configFile),
+		RunID:          runID,
+		Format:         *format,
+		Ext:            formatExt(*format),
+		Total:          len(results),
+		Polluted:       polluted,
+		PollutionRate:  rate,
+		PollutionLevel: pollutionLevel(rate),
+	}
+}
+
+// configName 返回配置文件名（不含扩展名），即模板变量 Config
+func configName(path string) string {
+	return strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+}
+
+// resolveOutputPath 根据 -output 决定报告路径：
+// 为空时在当前目录按默认模板命名；指向目录（已存在或以路径分隔符结尾）时在该目录下按默认模板命名；
+// 包含 {{ 时作为 text/template 模板展开；否则视为固定文件名。
+// 第二个返回值是轮转范围的文件名通配模式（见 rotateReports），固定文件名时为空。
+func resolveOutputPath(output string, vars outputVars) (string, string, error) {
+	isDir := strings.HasSuffix(output, "/") || strings.HasSuffix(output, string(os.PathSeparator))
+	if fi, err := os.Stat(output); err == nil && fi.IsDir() {
+		isDir = true
+	}
+	switch {
+	case output == "":
+		name, err := expandOutputTemplate(defaultOutputTemplate, vars)
+		return name, reportPrefix + "*", err
+	case isDir:
+		name, err := expandOutputTemplate(defaultOutputTemplate, vars)
+		return filepath.Join(output, name), filepath.Join(output, reportPrefix+"*"), err
+	case strings.Contains(output, "{{"):
+		path, err := expandOutputTemplate(output, vars)
+		return path, outputRotatePattern(output), err
+	default:
+		return output, "", nil
+	}
+}
+
+// outputRotatePattern 将 -output 模板转换为轮转使用的通配模式：文件名中的每个模板动作替换为 *，
+// 如 /var/log/dnscheck/report-{{.Host}}-{{.Date}}.txt → /var/log/dnscheck/report-*-*.txt。
+// 轮转会删除匹配的文件，因此文件名必须以含字母或数字的固定前缀开头，否则 {{.Date}}.txt 之类的模板
+// 会匹配目录中所有 .txt 文件；目录部分含模板动作（报告分散在多个目录中）或没有这样的前缀时返回空，见 validateOutputRotation
+func outputRotatePattern(tmpl string) string {
+	dir, file := filepath.Split(tmpl)
+	if strings.Contains(dir, "{{") {
+		return ""
+	}
+	prefix := file
+	if i := strings.Index(file, "{{"); i >= 0 {
+		prefix = file[:i]
+	}
+	if strings.IndexFunc(prefix, func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) }) < 0 {
+		return ""
+	}
+	var b strings.Builder
+	for file != "" {
+		i := strings.Index(file, "{{")
+		if i < 0 {
+			i = len(file)
+		}
+		for _, r := range file[:i] {
+			if strings.ContainsRune(`*?[\`, r) {
+				b.WriteRune('\\')
+			}
+			b.WriteRune(r)
+		}
+		file = file[i:]
+		if file == "" {
+			break
+		}
+		end := strings.Index(file, "}}")
+		if end < 0 {
+			return ""
+		}
+		if !strings.HasSuffix(b.String(), "*") {
+			b.WriteByte('*')
+		}
+		file = file[end+2:]
+	}
+	return filepath.Join(dir, b.String())
+}
+
+// validateOutputRotation 检查 -keep、-keep-days、-compress-old 能否作用于 output：
+// 模板展开后的报告无法限定在一个目录内的一组文件时报错，而不是静默地不轮转
+func validateOutputRotation(output string) error {
+	if *keepReports <= 0 && *keepDays <= 0 && !*compressOld {
+		return nil
+	}
+	if strings.Contains(output, "{{") && outputRotatePattern(output) == "" {
+		return fmt.Errorf("-output 模板 %q 不能与 -keep、-keep-days、-compress-old 同时使用：目录部分不能包含模板变量，文件名需以固定前缀开头（如 report-{{.Date}}.txt），以免删除目录中的其他文件", output)
+	}
+	return nil
+}
+
+// expandOutputTemplate 展开文件名模板
+func expandOutputTemplate(tmpl string, vars outputVars) (string, error) {
+	t, err := template.New("output").Option("missingkey=error").Parse(tmpl)
+	if err != nil {
+		return "", fmt.Errorf("无效的输出文件模板: %w", err)
+	}
+	var b bytes.Buffer
+	if err := t.Execute(&b, vars); err != nil {
+		return "", fmt.Errorf("展开输出文件模板失败: %w", err)
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"net"
+)
+
+// ---------- 固定 IP（expected_ips / expected_ip_count） ----------
+
+// validatePinning 校验并编译域名的固定 IP 配置。
+// expected_ips 只做本地比较、不查询 API，因此不能与依赖 LLC/ASN 的规则同时使用。
+func validatePinning(dc *DomainConfig) error {
+	if dc.ExpectedIPCount < 0 {
+		return fmt.Errorf("expected_ip_count 不能为负数")
+	}
+	if len(dc.ExpectedIPs) == 0 {
+		return nil
+	}
+	if len(dc.ExpectedLlcs) > 0 || len(dc.ExpectedCIDRs) > 0 || len(dc.ForbiddenLlcs) > 0 || len(dc.ForbiddenASNs) > 0 || dc.Rules != nil {
+		return fmt.Errorf("expected_ips 不查询 API，不能与 expected_llcs、expected_cidrs、forbidden_llcs、forbidden_asns、rules 同时使用")
+	}
+	dc.pinned = nil
+	for _, s := range dc.ExpectedIPs {
+		n, err := parseIPOrCIDR(s)
+		if err != nil {
+			return err
+		}
+		dc.pinned = append(dc.pinned, n)
+	}
+	return nil
+}
+
+// pinnedIPResults 将解析结果与固定 IP 列表比较，不在列表中的 IP 标记为 NotPinned
+func pinnedIPResults(pinned []*net.IPNet, ips []net.IP) []IPCheckResult {
+	results := make([]IPCheckResult, 0, len(ips))
+	for _, ip := range ips {
+		r := IPCheckResult{IP: ip.String(), NotPinned: true}
+		for _, n := range pinned {
+			if n.Contains(ip) {
+				r.NotPinned = false
+				break
+			}
+		}
+		results = append(results, r)
+	}
+	return results
+}
+
+// aggregatePinnedResult 汇总固定 IP 模式的结果：任何一个 IP 不在列表中即判定为污染，与 -strict 无关
+func aggregatePinnedResult(domain string, pinnedIPs []string, ipResults []IPCheckResult) DomainResult {
+	res := DomainResult{
+		Domain:    domain,
+		PinnedIPs: pinnedIPs,
+		IPResults: ipResults,
+		Summary:   "所有 IP 均在固定列表中",
+	}
+	unexpected := 0
+	for _, r := range ipResults {
+		if r.NotPinned {
+			unexpected++
+		}
+	}
+	if unexpected > 0 {
+		res.IsPolluted = true
+		res.Summary = fmt.Sprintf("%d 个 IP 不在固定列表中", unexpected)
+	}
+	return res
+}
+
+// applyIPCount 检查解析到的 IP 数量，与 expected_ip_count 不符时判定为污染
+func applyIPCount(res *DomainResult, want int) {
+	got := len(res.IPResults)
+	if res.SampledFrom > 0 {
+		got = res.SampledFrom
+	}
+	if want <= 0 || got == want {
+		return
+	}
+	res.IsPolluted = true
+	res.Summary = fmt.Sprintf("解析到 %d 个 IP，预期 %d 个", got, want)
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ---------- 端口探测（-probe-ports） ----------
+
+// maxProbePorts 是 -probe-ports 最多允许的端口数，只做小范围探测，不是端口扫描器
+const maxProbePorts = 8
+
+// 根据端口探测结果对 IP 的分类
+const (
+	portsServing   = "serving"   // 有端口可以连接，像是正常提供服务的主机
+	portsBlockPage = "blockpage" // 只有 80 端口可以连接、443 不通，常见于拦截页或运营商劫持页
+	portsRejecting = "rejecting" // 没有端口可以连接，且有端口回应 RST
+	portsBlackhole = "blackhole" // 所有端口都没有回应或不可达
+)
+
+// portScan 是对单个 IP 的端口探测结果
+type portScan struct {
+	Ports []probeResult `json:"ports"`
+	Class string        `json:"class,omitempty"` // 见上方的分类；全部探测失败时为空
+}
+
+// className 返回分类在报告中的名称
+func (s portScan) className() string {
+	switch s.Class {
+	case portsServing:
+		return "正常服务"
+	case portsBlockPage:
+		return "疑似拦截页"
+	case portsRejecting:
+		return "拒绝所有连接"
+	case portsBlackhole:
+		return "黑洞"
+	}
+	return "无法判断"
+}
+
+func (s portScan) String() string {
+	parts := make([]string, 0, len(s.Ports))
+	for _, p := range s.Ports {
+		parts = append(parts, p.String())
+	}
+	return fmt.Sprintf("（%s）: %s", s.className(), strings.Join(parts, "，"))
+}
+
+// suspicious 判断端口探测是否表明该 IP 不是正常提供服务的主机；nil 表示未探测
+func (s *portScan) suspicious() bool {
+	return s != nil && s.Class != "" && s.Class != portsServing
+}
+
+// classify 根据各端口的探测结果为 IP 分类
+func (s *portScan) classify() {
+	open := make(map[string]bool, len(s.Ports))
+	refused, silent, scanned443 := false, 0, false
+	for _, p := range s.Ports {
+		if p.Method == "tcp:443" {
+			scanned443 = true
+		}
+		switch p.State {
+		case reachOK:
+			open[p.Method] = true
+		case reachRefused:
+			refused = true
+		case reachTimeout, reachUnreachable:
+			silent++
+		}
+	}
+	switch {
+	case open["tcp:80"] && scanned443 && !open["tcp:443"]:
+		s.Class = portsBlockPage
+	case len(open) > 0:
+		s.Class = portsServing
+	case refused:
+		s.Class = portsRejecting
+	case silent == len(s.Ports):
+		s.Class = portsBlackhole
+	}
+}
+
+// portScanner 对每个解析到的 IP 探测少量 TCP 端口。nil 表示不探测
+type portScanner struct {
+	ports []string
+}
+
+// parseProbePorts 解析 -probe-ports，如 "80,443"
+func parseProbePorts(spec string) ([]string, error) {
+	var ports []string
+	seen := make(map[string]bool)
+	for _, f := range strings.Split(spec, ",") {
+		f = strings.TrimSpace(f)
+		if f == "" {
+			continue
+		}
+		n, err := strconv.Atoi(f)
+		if err != nil || n < 1 || n > 65535 {
+			return nil, fmt.Errorf("无效的 -probe-ports 端口 %q", f)
+		}
+		if port := strconv.Itoa(n); !seen[port] {
+			seen[port] = true
+			ports = append(ports, port)
+		}
+	}
+	if len(ports) > maxProbePorts {
+		return nil, fmt.Errorf("-probe-ports 最多 %d 个端口", maxProbePorts)
+	}
+	return ports, nil
+}
+
+// newPortScanner 根据 -probe-ports 创建端口探测器；spec 为空时返回 nil
+func newPortScanner(spec string) (*portScanner, error) {
+	ports, err := parseProbePorts(spec)
+	if err != nil || len(ports) == 0 {
+		return nil, err
+	}
+	return &portScanner{ports: ports}, nil
+}
+
+// Scan 并发探测域名结果中每个 IP 的各个端口，结果写入 IPResults[i].Ports。
+// 域名被判定为污染时，不是正常服务的可疑 IP 及其分类会追加到汇总中，作为判定依据
+func (s *portScanner) Scan(ctx context.Context, res *DomainResult) {
+	if s == nil {
+		return
+	}
+	var wg sync.WaitGroup
+	for i := range res.IPResults {
+		ip := net.ParseIP(res.IPResults[i].IP)
+		if ip == nil {
+			continue
+		}
+		scan := &portScan{Ports: make([]probeResult, len(s.ports))}
+		res.IPResults[i].Ports = scan
+		for j, port := range s.ports {
+			wg.Add(1)
+			go func(j int, port string) {
+				defer wg.Done()
+				scan.Ports[j] = dialTCP(ctx, ip, port)
+			}(j, port)
+		}
+	}
+	wg.Wait()
+
+	var notes []string
+	for i := range res.IPResults {
+		ip := &res.IPResults[i]
+		if ip.Ports == nil {
+			continue
+		}
+		ip.Ports.classify()
+		if res.IsPolluted && ip.Ports.suspicious() && !res.ipMatched(*ip) {
+			notes = append(notes, fmt.Sprintf("%s %s", ip.IP, ip.Ports.className()))
+		}
+	}
+	if len(notes) > 0 {
+		res.Summary += "；端口探测: " + strings.Join(notes, "，")
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// ---------- 私有地址应答（DNS 重绑定 / 路由器劫持） ----------
+
+// privateNets 是不应出现在公网域名解析结果中的地址段
+var privateNets = mustParseCIDRs(
+	"10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", // RFC 1918
+	"127.0.0.0/8",    // 回环
+	"169.254.0.0/16", // 链路本地
+	"fc00::/7",       // ULA
+	"fe80::/10",      // IPv6 链路本地
+	"::1/128",
+)
+
+func mustParseCIDRs(cidrs ...string) []*net.IPNet {
+	nets := make([]*net.IPNet, 0, len(cidrs))
+	for _, c := range cidrs {
+		_, n, err := net.ParseCIDR(c)
+		if err != nil {
+			panic(err)
+		}
+		nets = append(nets, n)
+	}
+	return nets
+}
+
+// isPrivateIP 判断 ip 是否属于 privateNets
+func isPrivateIP(ip net.IP) bool {
+	for _, n := range privateNets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// markPinnedPrivate 在固定 IP 模式下标记不在固定列表中的私有地址；列表中的私有地址视为预期结果
+func markPinnedPrivate(ipResults []IPCheckResult, allow bool) {
+	if allow {
+		return
+	}
+	for i, r := range ipResults {
+		if r.NotPinned && isPrivateIP(net.ParseIP(r.IP)) {
+			ipResults[i].Private = true
+		}
+	}
+}
+
+// applyPrivateIPs 在有私有地址应答时将域名判定为污染，并标记为单独的 Rebinding 类别
+func applyPrivateIPs(res *DomainResult) {
+	var private []string
+	for _, r := range res.IPResults {
+		if r.Private {
+			private = append(private, r.IP)
+		}
+	}
+	if len(private) == 0 {
+		return
+	}
+	res.IsPolluted = true
+	res.Rebinding = true
+	res.Summary = fmt.Sprintf("解析到私有地址 %s，可能是 DNS 重绑定或路由器劫持", strings.Join(private, ", "))
+}
+
+// countRebinding 统计应答中含私有地址的域名数
+func countRebinding(results []DomainResult) int {
+	n := 0
+	for _, r := range results {
+		if r.Rebinding {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,278 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+)
+
+// ---------- 应答可达性探测（-probe） ----------
+
+// probeTimeout 是单个 IP 等待 ping 回应或 TCP 握手完成的时间
+const probeTimeout = 2 * time.Second
+
+// 可达性探测的结果状态
+const (
+	reachOK          = "reachable"   // ping 有回应，或 TCP 握手成功
+	reachRefused     = "refused"     // TCP 连接被拒绝（收到 RST）
+	reachUnreachable = "unreachable" // 收到 ICMP 不可达，或本机没有到该地址的路由
+	reachTimeout     = "timeout"     // 超时无回应
+	reachFailed      = "error"       // 探测本身失败
+)
+
+// probeResult 是对单个 IP 的可达性探测结果。注入的应答常指向不可路由、
+// 或对任何连接都回应 RST 的地址，这些结果作为判定的旁证写入报告
+type probeResult struct {
+	Method string  `json:"method"` // ping 或 tcp:端口
+	State  string  `json:"state"`
+	RTTMs  float64 `json:"rtt_ms,omitempty"`
+	From   string  `json:"from,omitempty"` // 回应 ICMP 不可达的路由器
+	Error  string  `json:"error,omitempty"`
+
+	Status    int      `json:"status,omitempty"`    // -probe http/https：最终响应的状态码
+	Redirects []string `json:"redirects,omitempty"` // 依次跳转到的 URL
+	Portal    string   `json:"portal,omitempty"`    // 跳转到运营商门户、广告页或 IP 地址的原因
+}
+
+func (r probeResult) String() string {
+	var s string
+	switch r.State {
+	case reachOK:
+		switch {
+		case r.Status != 0:
+			s = fmt.Sprintf("HTTP %d", r.Status)
+			if len(r.Redirects) > 0 {
+				s += "，跳转: " + strings.Join(r.Redirects, " → ")
+			}
+			if r.Portal != "" {
+				s += "（疑似运营商劫持: " + r.Portal + "）"
+			}
+		case r.Method == "ping":
+			s = "有回应"
+		default:
+			s = "连接成功"
+		}
+	case reachRefused:
+		s = "连接被拒绝（RST）"
+	case reachUnreachable:
+		s = "不可达"
+		if r.From != "" {
+			s += fmt.Sprintf("（%s 回应 ICMP 不可达）", r.From)
+		}
+	case reachTimeout:
+		s = "超时无回应"
+	default:
+		s = "探测失败: " + r.Error
+	}
+	if r.RTTMs > 0 {
+		s += fmt.Sprintf(" (%.1fms)", r.RTTMs)
+	}
+	return r.Method + " " + s
+}
+
+// unreachable 判断探测结果是否表明该 IP 无法正常访问；nil 表示未探测
+func (r *probeResult) unreachable() bool {
+	return r != nil && r.State != reachOK
+}
+
+// reachProber 探测每个解析到的 IP 是否可达。nil 表示不探测
+type reachProber struct {
+	method  string // ping、tcp:端口、http 或 https
+	port    string
+	scheme  string // http 或 https
+	network string // ping 使用的 ICMP 套接字：ip4:icmp（需要权限）或 udp4（非特权 ICMP）
+}
+
+// newReachProber 创建探测器；mode 为空时返回 nil。ping 优先使用原始套接字，没有权限时改用
+// 非特权 ICMP 套接字（Linux 需要 net.ipv4.ping_group_range 包含当前用户组），两者都不可用时返回错误，
+// 调用方只输出警告、不探测
+func newReachProber(mode string) (*reachProber, error) {
+	switch {
+	case mode == "":
+		return nil, nil
+	case strings.HasPrefix(mode, "tcp:"):
+		return &reachProber{method: mode, port: strings.TrimPrefix(mode, "tcp:")}, nil
+	case mode == "http" || mode == "https":
+		return &reachProber{method: mode, scheme: mode}, nil
+	}
+	for _, network := range []string{"ip4:icmp", "udp4"} {
+		if c, err := icmp.ListenPacket(network, "0.0.0.0"); err == nil {
+			c.Close()
+			return &reachProber{method: mode, network: network}, nil
+		}
+	}
+	return nil, fmt.Errorf("ping 探测需要 root、CAP_NET_RAW 或允许非特权 ICMP（net.ipv4.ping_group_range），已禁用 -probe")
+}
+
+// validateProbe 检查 -probe
+func validateProbe(mode string) error {
+	switch mode {
+	case "", "ping", "http", "https":
+		return nil
+	}
+	if strings.HasPrefix(mode, "tcp:") {
+		if port, err := strconv.Atoi(strings.TrimPrefix(mode, "tcp:")); err == nil && port >= 1 && port <= 65535 {
+			return nil
+		}
+	}
+	return fmt.Errorf("无效的 -probe %q（可选 ping、tcp:端口（如 tcp:443）、http 或 https）", mode)
+}
+
+// Probe 并发探测域名结果中的每个 IP，结果写入 IPResults[i].Probe。ping 目前只支持 IPv4，
+// IPv6 地址不做 ping 探测。http/https 探测中跳转到 portals 中的域名或 IP 地址、或页面标题命中 titles 的 IP 记入 res.Hijacks
+func (p *reachProber) Probe(ctx context.Context, res *DomainResult, portals []string, titles []titleSig) {
+	if p == nil {
+		return
+	}
+	var wg sync.WaitGroup
+	for i := range res.IPResults {
+		ip := net.ParseIP(res.IPResults[i].IP)
+		if ip == nil || (p.method == "ping" && ip.To4() == nil) {
+			continue
+		}
+		wg.Add(1)
+		go func(i int, ip net.IP) {
+			defer wg.Done()
+			var r probeResult
+			switch {
+			case p.port != "":
+				r = dialTCP(ctx, ip, p.port)
+			case p.scheme != "":
+				r = p.fetch(ctx, res.ASCIIDomain, ip, portals, titles)
+			default:
+				r = p.ping(ctx, ip.To4())
+			}
+			res.IPResults[i].Probe = &r
+		}(i, ip)
+	}
+	wg.Wait()
+	for _, ip := range res.IPResults {
+		if ip.Probe != nil && ip.Probe.Portal != "" {
+			res.Hijacks = append(res.Hijacks, fmt.Sprintf("%s %s", ip.IP, ip.Probe.Portal))
+		}
+	}
+}
+
+// dialTCP 尝试与目标的端口建立 TCP 连接，握手成功后立即关闭
+func dialTCP(ctx context.Context, ip net.IP, port string) probeResult {
+	r := probeResult{Method: "tcp:" + port}
+	d := net.Dialer{Timeout: probeTimeout}
+	start := time.Now()
+	conn, err := d.DialContext(ctx, "tcp", net.JoinHostPort(ip.String(), port))
+	elapsed := float64(time.Since(start)) / float64(time.Millisecond)
+	if err == nil {
+		conn.Close()
+		r.State, r.RTTMs = reachOK, elapsed
+		return r
+	}
+	if r.State, r.Error = classifyDialError(err); r.State == reachRefused {
+		r.RTTMs = elapsed
+	}
+	return r
+}
+
+// classifyDialError 将连接错误转换为探测状态；无法归类时返回 reachFailed 与错误信息
+func classifyDialError(err error) (string, string) {
+	var netErr net.Error
+	switch {
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return reachRefused, ""
+	case errors.Is(err, syscall.EHOSTUNREACH), errors.Is(err, syscall.ENETUNREACH):
+		return reachUnreachable, ""
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return reachTimeout, ""
+	}
+	return reachFailed, err.Error()
+}
+
+// ping 发送一个 ICMP Echo 并等待回应。原始套接字下还能收到路由器返回的目的不可达
+func (p *reachProber) ping(ctx context.Context, dst net.IP) probeResult {
+	r := probeResult{Method: p.method}
+	c, err := icmp.ListenPacket(p.network, "0.0.0.0")
+	if err != nil {
+		r.State, r.Error = reachFailed, fmt.Sprintf("打开 ICMP 套接字失败: %v", err)
+		return r
+	}
+	defer c.Close()
+
+	var to net.Addr = &net.IPAddr{IP: dst}
+	if p.network == "udp4" {
+		to = &net.UDPAddr{IP: dst}
+	}
+	id := traceID()
+	msg := icmp.Message{Type: ipv4.ICMPTypeEcho, Body: &icmp.Echo{ID: id, Seq: 1, Data: []byte(tracePayload)}}
+	b, err := msg.Marshal(nil)
+	if err != nil {
+		r.State, r.Error = reachFailed, err.Error()
+		return r
+	}
+	start := time.Now()
+	if _, err := c.WriteTo(b, to); err != nil {
+		if errors.Is(err, syscall.EHOSTUNREACH) || errors.Is(err, syscall.ENETUNREACH) {
+			r.State = reachUnreachable
+		} else {
+			r.State, r.Error = reachFailed, fmt.Sprintf("发送探测失败: %v", err)
+		}
+		return r
+	}
+	deadline := start.Add(probeTimeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	_ = c.SetReadDeadline(deadline)
+
+	buf := make([]byte, 1500)
+	for {
+		n, peer, err := c.ReadFrom(buf)
+		if err != nil {
+			r.State = reachTimeout
+			return r
+		}
+		m, err := icmp.ParseMessage(protocolICMP, buf[:n])
+		if err != nil {
+			continue
+		}
+		switch body := m.Body.(type) {
+		case *icmp.Echo:
+			// 非特权套接字的 Echo 标识由内核改写，只能按序号与来源核对
+			if m.Type != ipv4.ICMPTypeEchoReply || body.Seq != 1 || hostOf(peer) != dst.String() || (p.network != "udp4" && body.ID != id) {
+				continue
+			}
+			r.State = reachOK
+		case *icmp.DstUnreach:
+			inner, ok := innerPayload(body.Data, dst, protocolICMP)
+			if !ok || len(inner) < 8 || int(binary.BigEndian.Uint16(inner[4:6])) != id {
+				continue
+			}
+			r.State, r.From = reachUnreachable, hostOf(peer)
+		default:
+			continue
+		}
+		r.RTTMs = float64(time.Since(start)) / float64(time.Millisecond)
+		return r
+	}
+}
+
+// countUnreachable 统计至少有一个 IP 探测不可达的域名数
+func countUnreachable(results []DomainResult) int {
+	n := 0
+	for _, r := range results {
+		for _, ip := range r.IPResults {
+			if ip.Probe.unreachable() {
+				n++
+				break
+			}
+		}
+	}
+	return n
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ---------- 命名配置方案（-profile） ----------
+
+// Profile 是配置文件顶层 profiles 中的一个方案，把常在一起切换的参数（解析器、API、判定阈值）
+// 与要检测的域名分组打包，如家庭网络与办公网络各用一套。未填写的项沿用命令行参数或其默认值。
+type Profile struct {
+	Resolver   string        `yaml:"resolver"`           // 同 -resolver
+	Strategy   string        `yaml:"strategy"`           // 同 -strategy
+	APIs       []string      `yaml:"api"`                // 同 -api，可以选用 providers 中的地址
+	Strict     *bool         `yaml:"strict"`             // 同 -strict
+	RPS        *float64      `yaml:"rps"`                // 同 -rps
+	MaxIPs     int           `yaml:"max_ips_per_domain"` // 同 -max-ips-per-domain
+	DNSTimeout time.Duration `yaml:"dns_timeout"`        // 同 -dns-timeout
+	APITimeout time.Duration `yaml:"api_timeout"`        // 同 -api-timeout
+	Groups     []string      `yaml:"groups"`             // 只检测属于这些分组的域名，为空时检测全部
+}
+
+// profileMap 以方案名为键
+type profileMap map[string]*Profile
+
+// validateProfiles 检查 profiles 中的取值以及引用的分组是否存在
+func validateProfiles(cfg *Config) []string {
+	groups := make(map[string]bool)
+	for _, dc := range cfg.Domains {
+		for _, g := range dc.Groups {
+			groups[g] = true
+		}
+	}
+	var problems []string
+	for _, name := range profileNames(cfg.Profiles) {
+		p := cfg.Profiles[name]
+		if p == nil {
+			problems = append(problems, fmt.Sprintf("profiles.%s 为空", name))
+			continue
+		}
+		if p.Strategy != "" {
+			if err := validateStrategy(p.Strategy); err != nil {
+				problems = append(problems, fmt.Sprintf("profiles.%s: %v", name, err))
+			}
+		}
+		if p.Resolver != "" {
+			if _, err := newDNSResolver(p.Resolver, false, strategyRace); err != nil {
+				problems = append(problems, fmt.Sprintf("profiles.%s: %v", name, err))
+			}
+		}
+		if (p.RPS != nil && *p.RPS < 0) || p.MaxIPs < 0 || p.DNSTimeout < 0 || p.APITimeout < 0 {
+			problems = append(problems, fmt.Sprintf("profiles.%s: rps、max_ips_per_domain、dns_timeout 与 api_timeout 不能为负数", name))
+		}
+		for _, g := range p.Groups {
+			if !groups[g] {
+				problems = append(problems, fmt.Sprintf("profiles.%s: 没有域名属于分组 %q", name, g))
+			}
+		}
+	}
+	return problems
+}
+
+// applyProfile 套用名为 name 的方案：方案中的参数只在命令行未显式指定时生效，
+// 并按方案的分组筛选 cfg.Domains。name 为空时不做任何事。
+func applyProfile(cfg *Config, name string, fs *flag.FlagSet) error {
+	if name == "" {
+		return nil
+	}
+	p, ok := cfg.Profiles[name]
+	if !ok {
+		if len(cfg.Profiles) == 0 {
+			return fmt.Errorf("配置文件中没有定义 profiles，无法使用 -profile %s", name)
+		}
+		return fmt.Errorf("未知的 profile %q（可选 %s）", name, strings.Join(profileNames(cfg.Profiles), "、"))
+	}
+
+	var values [][2]string
+	if p.Resolver != "" {
+		values = append(values, [2]string{"resolver", p.Resolver})
+	}
+	if p.Strategy != "" {
+		values = append(values, [2]string{"strategy", p.Strategy})
+	}
+	if len(p.APIs) > 0 {
+		values = append(values, [2]string{"api", strings.Join(p.APIs, ",")})
+	}
+	if p.Strict != nil {
+		values = append(values, [2]string{"strict", strconv.FormatBool(*p.Strict)})
+	}
+	if p.RPS != nil {
+		values = append(values, [2]string{"rps", strconv.FormatFloat(*p.RPS, 'f', -1, 64)})
+	}
+	if p.MaxIPs > 0 {
+		values = append(values, [2]string{"max-ips-per-domain", strconv.Itoa(p.MaxIPs)})
+	}
+	if p.DNSTimeout > 0 {
+		values = append(values, [2]string{"dns-timeout", p.DNSTimeout.String()})
+	}
+	if p.APITimeout > 0 {
+		values = append(values, [2]string{"api-timeout", p.APITimeout.String()})
+	}
+	// 先记下命令行显式指定的参数，之后 fs.Set 设置的参数也会被视为已指定
+	explicit := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
+	for _, v := range values {
+		if explicit[v[0]] {
+			continue
+		}
+		if err := fs.Set(v[0], v[1]); err != nil {
+			return fmt.Errorf("profile %s: %w", name, err)
+		}
+	}
+
+	if len(p.Groups) > 0 {
+		cfg.Domains = filterDomainsByGroup(cfg.Domains, p.Groups)
+	}
+	return nil
+}
+
+// filterDomainsByGroup 返回至少属于 groups 中一个分组的域名
+func filterDomainsByGroup(domains []DomainConfig, groups []string) []DomainConfig {
+	want := make(map[string]bool, len(groups))
+	for _, g := range groups {
+		want[g] = true
+	}
+	var out []DomainConfig
+	for _, dc := range domains {
+		for _, g := range dc.Groups {
+			if want[g] {
+				out = append(out, dc)
+				break
+			}
+		}
+	}
+	return out
+}
+
+// profileNames 按名称排序返回所有方案名
+func profileNames(profiles profileMap) []string {
+	names := make([]string, 0, len(profiles))
+	for name := range profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ---------- 按项目运行（serve -projects） ----------
+
+// 一个中心实例为多个团队检测时，-projects 指定的文件列出各项目。每个项目是一个独立的守护实例（daemon）：
+// 使用各自的配置文件、检测间隔、历史记录、告警状态与通知，接口位于 /api/projects/{id}/ 下。
+// 各项目共用命令行中的其他参数、接口认证与监听地址；项目文件本身只在启动时读取
+
+// ProjectSpec 是项目文件中的一项；未按项目运行时由全局参数构造，ID 为空
+type ProjectSpec struct {
+	ID         string        `yaml:"id"`           // 接口路径中的项目 ID
+	Config     string        `yaml:"config"`       // 配置文件，同 -f
+	Interval   time.Duration `yaml:"interval"`     // 检测间隔，为空时使用 -interval
+	Output     string        `yaml:"output"`       // 同 -output，支持 {{.Project}}
+	History    string        `yaml:"history"`      // 同 -history
+	AlertState string        `yaml:"alert_state"`  // 同 -alert-state
+	FlapState  string        `yaml:"flap_state"`   // 同 -flap-state
+	Syslog     string        `yaml:"syslog"`       // 同 -syslog
+	PostRunCmd string        `yaml:"post_run_cmd"` // 同 -post-run-cmd
+}
+
+var projectIDRe = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// projectOnlyFlags 是按项目运行时必须在项目文件中分别指定的参数：各项目共用会使结果、状态与通知混在一起
+var projectOnlyFlags = []string{"f", "output", "history", "alert-state", "flap-state", "syslog", "post-run-cmd"}
+
+// loadProjects 读取并校验项目文件；interval 为未指定 interval 的项目使用的检测间隔
+func loadProjects(path string, interval time.Duration) ([]ProjectSpec, error) {
+	for _, name := range projectOnlyFlags {
+		if flagSet(flag.CommandLine, name) {
+			return nil, fmt.Errorf("-%s 不能与 -projects 同时使用，请在项目文件中为各项目分别指定", name)
+		}
+	}
+	// profile 会修改全局参数，脱敏的替代名称由配置中的域名生成，都只能有一份
+	if *profileName != "" {
+		return nil, fmt.Errorf("-profile 不能与 -projects 同时使用")
+	}
+	if *redactOut {
+		return nil, fmt.Errorf("-redact 不能与 -projects 同时使用")
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取项目文件失败: %w", err)
+	}
+	var specs []ProjectSpec
+	if err := yaml.Unmarshal(data, &specs); err != nil {
+		return nil, fmt.Errorf("解析项目文件 %s 失败: %w", path, err)
+	}
+	if len(specs) == 0 {
+		return nil, fmt.Errorf("项目文件 %s 中没有项目", path)
+	}
+	seen := make(map[string]bool, len(specs))
+	histories := make(map[string]string)
+	for i := range specs {
+		p := &specs[i]
+		switch {
+		case !projectIDRe.MatchString(p.ID):
+			return nil, fmt.Errorf("项目文件 %s 第 %d 项: id %q 只能包含小写字母、数字、- 与 _", path, i+1, p.ID)
+		case seen[p.ID]:
+			return nil, fmt.Errorf("项目文件 %s: 项目 %s 重复", path, p.ID)
+		case p.Config == "":
+			return nil, fmt.Errorf("项目文件 %s: 项目 %s 缺少 config", path, p.ID)
+		case p.Interval != 0 && p.Interval < time.Second:
+			return nil, fmt.Errorf("项目文件 %s: 项目 %s 的 interval 不能小于 1s", path, p.ID)
+		}
+		if err := validateOutputRotation(p.Output); err != nil {
+			return nil, fmt.Errorf("项目文件 %s: 项目 %s: %w", path, p.ID, err)
+		}
+		// 历史记录按域名保存，共用一个数据库时各项目的可用性与 /history 会混在一起
+		if other, ok := histories[p.History]; ok && p.History != "" {
+			return nil, fmt.Errorf("项目文件 %s: 项目 %s 与 %s 使用了同一个 history", path, p.ID, other)
+		}
+		seen[p.ID] = true
+		histories[p.History] = p.ID
+		if p.Interval == 0 {
+			p.Interval = interval
+		}
+	}
+	return specs, nil
+}
+
+// projectSet 是按项目运行时的全部实例，顺序与项目文件相同；未按项目运行时只有一个 ID 为空的实例
+type projectSet []*daemon
+
+// routes 注册各项目的接口，以及汇总全部项目的探针、/api/projects 与 /api/reload
+func (ps projectSet) routes(mux *http.ServeMux, api *apiGuard) {
+	mux.HandleFunc("/healthz", ps.handleHealthz)
+	mux.HandleFunc("/readyz", ps.handleReadyz)
+	mux.HandleFunc("/api/projects", api.wrap(anyProject, ps.handleProjects, scopeRead, scopeRead))
+	mux.HandleFunc("/api/projects/", ps.handleUnknown)
+	mux.HandleFunc("/api/reload", api.wrap("", ps.handleReload, scopeManage, scopeManage))
+	for _, d := range ps {
+		prefix := "/api/projects/" + d.project
+		mux.HandleFunc(prefix+"/healthz", d.handleHealthz)
+		mux.HandleFunc(prefix+"/readyz", d.handleReadyz)
+		d.routes(mux, prefix)
+	}
+}
+
+// requestReload 让所有项目重新加载配置（SIGHUP）
+func (ps projectSet) requestReload() {
+	for _, d := range ps {
+		d.requestReload()
+	}
+}
+
+// stalled 判断是否有项目的调度器卡死，供 systemd 看门狗使用
+func (ps projectSet) stalled() bool {
+	for _, d := range ps {
+		if d.stalled() {
+			return true
+		}
+	}
+	return false
+}
+
+// projectsStatus 是按项目运行时 /healthz 与 /readyz 的响应；running 与 runs 为各项目的汇总
+type projectsStatus struct {
+	Status   string                `json:"status"`
+	Running  bool                  `json:"running"`
+	Runs     int                   `json:"runs"`
+	Projects map[string]statusBody `json:"projects"`
+}
+
+// aggregate 汇总各项目的探针结果：任一项目不正常时返回 503，status 列出这些项目
+func (ps projectSet) aggregate(ok string, probe func(*daemon) (statusBody, int)) (projectsStatus, int) {
+	out := projectsStatus{Status: ok, Projects: make(map[string]statusBody, len(ps))}
+	var failed []string
+	for _, d := range ps {
+		body, code := probe(d)
+		out.Projects[d.project] = body
+		out.Running = out.Running || body.Running
+		out.Runs += body.Runs
+		if code != http.StatusOK {
+			failed = append(failed, d.project)
+		}
+	}
+	if len(failed) > 0 {
+		out.Status = "projects unavailable: " + strings.Join(failed, ", ")
+		return out, http.StatusServiceUnavailable
+	}
+	return out, http.StatusOK
+}
+
+// handleHealthz 存活探针：任一项目的调度器卡死时返回 503
+func (ps projectSet) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	body, code := ps.aggregate("ok", (*daemon).health)
+	writeJSON(w, code, body)
+}
+
+// handleReadyz 就绪探针：所有项目都就绪时才返回 200，各项目单独的状态见 /api/projects/{id}/readyz
+func (ps projectSet) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	body, code := ps.aggregate("ready", (*daemon).readiness)
+	writeJSON(w, code, body)
+}
+
+// projectJSON 是 /api/projects 中的一项
+type projectJSON struct {
+	ID          string     `json:"id"`
+	Config      string     `json:"config"`
+	Domains     int        `json:"domains"`
+	Schedule    string     `json:"schedule"`
+	Runs        int        `json:"runs"`
+	LastSuccess *time.Time `json:"last_success,omitempty"`
+	LastError   string     `json:"last_error,omitempty"`
+}
+
+// handleProjects 列出调用方可以访问的项目
+func (ps projectSet) handleProjects(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "只支持 GET"})
+		return
+	}
+	p := principalFrom(r)
+	list := []projectJSON{}
+	for _, d := range ps {
+		if p != nil && !p.allows(d.project) {
+			continue
+		}
+		st := d.status()
+		d.mu.RLock()
+		item := projectJSON{
+			ID:          d.project,
+			Config:      d.configPath,
+			Domains:     len(d.config.Domains),
+			Schedule:    describeGroups(d.groups),
+			Runs:        st.Runs,
+			LastSuccess: st.LastSuccess,
+			LastError:   st.LastError,
+		}
+		d.mu.RUnlock()
+		list = append(list, item)
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+// handleUnknown 处理不属于任何项目的 /api/projects/ 路径
+func (ps projectSet) handleUnknown(w http.ResponseWriter, r *http.Request) {
+	rest := strings.TrimPrefix(r.URL.Path, "/api/projects/")
+	id := strings.SplitN(rest, "/", 2)[0]
+	for _, d := range ps {
+		if d.project == id {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": fmt.Sprintf("项目 %s 没有接口 %s", id, r.URL.Path)})
+			return
+		}
+	}
+	writeJSON(w, http.StatusNotFound, map[string]string{"error": fmt.Sprintf("没有项目 %q", id)})
+}
+
+// handleReload 实现按项目运行时的 POST /api/reload：所有项目重新加载配置，同 SIGHUP
+func (ps projectSet) handleReload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "只支持 POST"})
+		return
+	}
+	ps.requestReload()
+	writeJSON(w, http.StatusAccepted, map[string]string{"status": "reload requested"})
+}
@@ -0,0 +1,309 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ---------- API 提供方的字段映射 ----------
+
+// ProviderConfig 描述一个 IP 信息 API 的响应格式，配置在配置文件顶层的 providers 中
+type ProviderConfig struct {
+	URL       string       `yaml:"url"`       // 与 -api 中的地址完全一致
+	Format    string       `yaml:"format"`    // 响应格式：json（默认）、xml、csv、text，见 decode.go
+	Delimiter string       `yaml:"delimiter"` // csv 的分隔符，默认为逗号
+	Header    bool         `yaml:"header"`    // csv 的第一行是否为列名
+	RPS       float64      `yaml:"rps"`       // 该主机单独的每秒请求数，0 表示沿用 -rps
+	Burst     int          `yaml:"burst"`     // 该主机单独的令牌桶容量，0 表示沿用 -burst
+	Fields    FieldMapping `yaml:"fields"`    // 未指定的字段沿用默认的键名猜测
+
+	MaxInFlight int `yaml:"max_in_flight"` // 同时进行的请求数上限，与 -c 无关，0 表示不限制
+}
+
+// decode 按提供方的格式解码响应体；p 为 nil 时按 JSON 解码
+func (p *ProviderConfig) decode(body []byte) (interface{}, error) {
+	if p == nil {
+		return decodeResponse(formatJSON, body, "", false)
+	}
+	return decodeResponse(p.Format, body, p.Delimiter, p.Header)
+}
+
+// mapping 返回字段映射，p 为 nil 时返回 nil（使用默认的键名猜测）
+func (p *ProviderConfig) mapping() *FieldMapping {
+	if p == nil {
+		return nil
+	}
+	return &p.Fields
+}
+
+// FieldMapping 是各字段在解码后响应中的路径，如 "data.org"、"$.data.asn.number"、"results[0].isp"；
+// XML 与 CSV 响应的路径写法见 decode.go
+type FieldMapping struct {
+	LLC     string `yaml:"llc"`
+	ASN     string `yaml:"asn"`
+	Country string `yaml:"country"`
+
+	llc, asn, country jsonPath // 由 compile 编译，nil 表示使用默认的键名猜测
+}
+
+// compile 编译各字段的路径
+func (m *FieldMapping) compile() error {
+	var err error
+	if m.llc, err = parseJSONPath(m.LLC); err != nil {
+		return fmt.Errorf("fields.llc: %w", err)
+	}
+	if m.asn, err = parseJSONPath(m.ASN); err != nil {
+		return fmt.Errorf("fields.asn: %w", err)
+	}
+	if m.country, err = parseJSONPath(m.Country); err != nil {
+		return fmt.Errorf("fields.country: %w", err)
+	}
+	return nil
+}
+
+// compileProviders 校验并编译配置中的 providers，返回以 URL 为键的提供方配置
+func compileProviders(providers []ProviderConfig) (map[string]*ProviderConfig, error) {
+	if len(providers) == 0 {
+		return nil, nil
+	}
+	out := make(map[string]*ProviderConfig, len(providers))
+	rates := make(map[string]*ProviderConfig) // 主机 -> 首个配置了 rps 的提供方
+	for i := range providers {
+		p := &providers[i]
+		if strings.TrimSpace(p.URL) == "" {
+			return nil, fmt.Errorf("providers 第 %d 项缺少 url", i+1)
+		}
+		if _, dup := out[p.URL]; dup {
+			return nil, fmt.Errorf("providers 中的 %s 重复", p.URL)
+		}
+		p.Format = strings.ToLower(strings.TrimSpace(p.Format))
+		if err := validateFormat(p.Format, p.Delimiter); err != nil {
+			return nil, fmt.Errorf("providers %s: %w", p.URL, err)
+		}
+		if err := p.Fields.compile(); err != nil {
+			return nil, fmt.Errorf("providers %s: %w", p.URL, err)
+		}
+		if p.RPS < 0 || p.Burst < 0 || p.MaxInFlight < 0 {
+			return nil, fmt.Errorf("providers %s: rps、burst 与 max_in_flight 不能为负数", p.URL)
+		}
+		if p.RPS > 0 {
+			host := apiHost(p.URL)
+			if prev, ok := rates[host]; ok && (prev.RPS != p.RPS || prev.Burst != p.Burst) {
+				return nil, fmt.Errorf("providers %s 与 %s 属于同一主机 %s，rps 与 burst 必须一致", prev.URL, p.URL, host)
+			}
+			rates[host] = p
+		}
+		out[p.URL] = p
+	}
+	return out, nil
+}
+
+// useProviders 为检测器设置配置文件中的提供方、限速与重试策略；未显式指定 -api 时改用 providers 中的地址
+func (c *checker) useProviders(cfg *Config) {
+	c.fetcher.providers = cfg.providers
+	c.fetcher.limiter.useProviders(cfg.providers)
+	c.fetcher.inflight = newInflightLimits(cfg.providers)
+	c.fetcher.retry = cfg.retry
+	c.retry = cfg.retry
+	if len(cfg.Providers) > 0 && !flagSet(flag.CommandLine, "api") {
+		c.fetcher.apis = providerURLs(cfg.Providers)
+	}
+}
+
+// inflightLimits 限制各提供方同时进行的请求数，键为 URL；没有配置 max_in_flight 的提供方不受限制。
+// 有的 API 能承受较高的请求速率却不能承受并发连接，-c 同时决定 DNS 查询的并发，无法兼顾两者
+type inflightLimits map[string]chan struct{}
+
+func newInflightLimits(providers map[string]*ProviderConfig) inflightLimits {
+	limits := make(inflightLimits)
+	for url, p := range providers {
+		if p.MaxInFlight > 0 {
+			limits[url] = make(chan struct{}, p.MaxInFlight)
+		}
+	}
+	return limits
+}
+
+// acquire 等待 baseURL 的空闲槽位，返回释放槽位的函数
+func (l inflightLimits) acquire(ctx context.Context, baseURL string) (func(), error) {
+	sem, ok := l[baseURL]
+	if !ok {
+		return func() {}, nil
+	}
+	select {
+	case sem <- struct{}{}:
+		return func() { <-sem }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// providerURLs 按配置顺序返回 providers 的地址
+func providerURLs(providers []ProviderConfig) []string {
+	urls := make([]string, 0, len(providers))
+	for _, p := range providers {
+		urls = append(urls, p.URL)
+	}
+	return urls
+}
+
+// flagSet 判断参数是否在命令行中显式指定
+func flagSet(fs *flag.FlagSet, name string) bool {
+	set := false
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
+// extract 按映射从响应中提取归属信息；m 为 nil 或字段未配置路径时使用默认的键名猜测
+func (m *FieldMapping) extract(data interface{}) (ipInfo, error) {
+	obj, _ := data.(map[string]interface{})
+	var info ipInfo
+
+	if m != nil && m.llc != nil {
+		v, ok := m.llc.eval(data)
+		s := scalarString(v)
+		if !ok || s == "" {
+			return ipInfo{}, fmt.Errorf("响应中 %s 处没有 LLC，响应内容: %v", m.LLC, data)
+		}
+		info.LLC = s
+	} else {
+		llc, err := extractLLC(obj)
+		if err != nil {
+			return ipInfo{}, err
+		}
+		info.LLC = llc
+	}
+
+	if m != nil && m.asn != nil {
+		if v, ok := m.asn.eval(data); ok {
+			info.ASN = asnValue(v)
+		}
+	} else {
+		info.ASN = extractASN(obj)
+	}
+
+	if m != nil && m.country != nil {
+		if v, ok := m.country.eval(data); ok {
+			info.Country = strings.ToUpper(scalarString(v))
+		}
+	} else {
+		info.Country = extractCountry(obj)
+	}
+	return info, nil
+}
+
+// asnValue 将数字（13335）或字符串（"AS13335"、"AS13335 Cloudflare, Inc."）形式的 ASN 转为整数，无法识别时返回 0
+func asnValue(v interface{}) uint32 {
+	switch v := v.(type) {
+	case float64:
+		if v > 0 && v <= math.MaxUint32 {
+			return uint32(v)
+		}
+	case string:
+		if fields := strings.Fields(v); len(fields) > 0 {
+			if asn, err := parseASN(fields[0]); err == nil {
+				return asn
+			}
+		}
+	}
+	return 0
+}
+
+// scalarString 将字符串或数字转换为字符串，其他类型返回空字符串
+func scalarString(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return strings.TrimSpace(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return ""
+}
+
+// ---------- 简单的 JSON 路径 ----------
+
+// jsonPath 是点分路径的各级，字符串为对象键，整数为数组下标
+type jsonPath []interface{}
+
+// parseJSONPath 解析 "data.asn.number"、"$.data.org"、"results[0].isp" 形式的路径；
+// 键中含点等特殊字符时可写成 ['a.b']。空字符串返回 nil。
+func parseJSONPath(s string) (jsonPath, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, nil
+	}
+	src := s
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "$"), ".")
+	var path jsonPath
+	for s != "" {
+		switch {
+		case strings.HasPrefix(s, "['"):
+			end := strings.Index(s, "']")
+			if end < 0 {
+				return nil, fmt.Errorf("路径 %q 中的 [' 没有闭合", src)
+			}
+			path = append(path, s[2:end])
+			s = s[end+2:]
+		case s[0] == '[':
+			end := strings.IndexByte(s, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("路径 %q 中的 [ 没有闭合", src)
+			}
+			idx, err := strconv.Atoi(s[1:end])
+			if err != nil || idx < 0 {
+				return nil, fmt.Errorf("路径 %q 中的数组下标 %q 无效", src, s[1:end])
+			}
+			path = append(path, idx)
+			s = s[end+1:]
+		default:
+			end := strings.IndexAny(s, ".[")
+			if end < 0 {
+				end = len(s)
+			}
+			if end == 0 {
+				return nil, fmt.Errorf("路径 %q 中有空的键", src)
+			}
+			path = append(path, s[:end])
+			s = s[end:]
+		}
+		if strings.HasPrefix(s, ".") {
+			s = s[1:]
+			if s == "" {
+				return nil, fmt.Errorf("路径 %q 以 . 结尾", src)
+			}
+		}
+	}
+	return path, nil
+}
+
+// eval 沿路径取值，任一级不存在时返回 false
+func (p jsonPath) eval(data interface{}) (interface{}, bool) {
+	cur := data
+	for _, step := range p {
+		switch step := step.(type) {
+		case string:
+			obj, ok := cur.(map[string]interface{})
+			if !ok {
+				return nil, false
+			}
+			if cur, ok = obj[step]; !ok {
+				return nil, false
+			}
+		case int:
+			arr, ok := cur.([]interface{})
+			if !ok || step >= len(arr) {
+				return nil, false
+			}
+			cur = arr[step]
+		}
+	}
+	return cur, true
+}
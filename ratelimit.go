@@ -0,0 +1,241 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"golang.org/x/time/rate"
+)
+
+// ---------- API 速率限制（支持 429 反馈的自适应调整） ----------
+
+const (
+	aimdIncrease = 0.2 // 每秒成功请求带来的速率增量（加性增）
+	aimdDecrease = 0.5 // 遇到 429 时的速率乘数（乘性减）
+	aimdMinRPS   = 0.1 // 自适应模式的速率下限
+)
+
+// apiRateLimiter 包装 rate.Limiter。自适应模式下按 AIMD 规则调整速率：
+// 成功请求缓慢提升速率，收到 429 时立即减半，使速率收敛到服务端实际允许的值。
+// nil 值表示不限速。
+type apiRateLimiter struct {
+	mu       sync.Mutex
+	lim      *rate.Limiter
+	adaptive bool
+	max      float64
+	name     string // 按主机限速时为主机名，用于日志
+}
+
+// newAPIRateLimiter 创建限速器，burst 为令牌桶容量（允许的瞬时突发请求数）；rps <= 0 时返回 nil（不限速）
+func newAPIRateLimiter(rps, maxRPS float64, burst int, adaptive bool) *apiRateLimiter {
+	if rps <= 0 {
+		return nil
+	}
+	if maxRPS < rps {
+		maxRPS = rps
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	return &apiRateLimiter{
+		lim:      rate.NewLimiter(rate.Limit(rps), burst),
+		adaptive: adaptive,
+		max:      maxRPS,
+	}
+}
+
+// Wait 阻塞直到允许发出下一个 API 请求
+func (l *apiRateLimiter) Wait(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+	return l.lim.Wait(ctx)
+}
+
+// Feedback 根据一次 API 请求的结果调整速率（仅自适应模式生效）
+func (l *apiRateLimiter) Feedback(err error) {
+	if l == nil || !l.adaptive {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	cur := float64(l.lim.Limit())
+	switch {
+	case isThrottled(err):
+		next := cur * aimdDecrease
+		if next < aimdMinRPS {
+			next = aimdMinRPS
+		}
+		l.lim.SetLimit(rate.Limit(next))
+		if l.name != "" {
+			fmt.Fprintf(os.Stderr, "自适应限速（%s）: 收到 429，速率 %.2f -> %.2f rps\n", l.name, cur, next)
+		} else {
+			fmt.Fprintf(os.Stderr, "自适应限速: 收到 429，速率 %.2f -> %.2f rps\n", cur, next)
+		}
+	case err == nil && cur < l.max:
+		// 每个成功请求增加 aimdIncrease/cur，约合每秒增加 aimdIncrease
+		next := cur + aimdIncrease/cur
+		if next > l.max {
+			next = l.max
+		}
+		l.lim.SetLimit(rate.Limit(next))
+	}
+}
+
+// RPS 返回当前生效的速率
+func (l *apiRateLimiter) RPS() float64 {
+	if l == nil {
+		return 0
+	}
+	return float64(l.lim.Limit())
+}
+
+// ---------- 按 API 主机分别限速 ----------
+
+// 限速范围（-rps-scope）
+const (
+	scopeGlobal = "global" // 所有 API 端点共用一个令牌桶
+	scopeHost   = "host"   // 每个 API 主机各用一个令牌桶
+)
+
+// hostRate 是 providers 中为某个主机单独配置的速率
+type hostRate struct {
+	rps   float64
+	burst int
+}
+
+// apiLimiters 为每个 API 端点选择令牌桶：providers 中单独配置了 rps 的主机、以及 host 范围下的每个主机各有一个，
+// 其余端点共用全局令牌桶。令牌桶在首次使用时创建，自适应调整也按桶各自进行，
+// 因此一个主机返回 429 不会拖慢其他主机。
+type apiLimiters struct {
+	mu        sync.Mutex
+	perHost   bool
+	rps       float64
+	maxRPS    float64
+	burst     int
+	adaptive  bool
+	global    *apiRateLimiter
+	overrides map[string]hostRate // 键为主机
+	hosts     map[string]*apiRateLimiter
+}
+
+// validateRateFlags 检查 -burst 与 -rps-scope 参数
+func validateRateFlags(burst int, scope string) error {
+	if burst < 1 {
+		return fmt.Errorf("-burst 不能小于 1")
+	}
+	switch scope {
+	case scopeGlobal, scopeHost:
+		return nil
+	}
+	return fmt.Errorf("无效的 -rps-scope 参数 %q（可选 global、host）", scope)
+}
+
+func newAPILimiters(rps, maxRPS float64, burst int, adaptive bool, scope string) *apiLimiters {
+	return &apiLimiters{
+		perHost:  scope == scopeHost,
+		rps:      rps,
+		maxRPS:   maxRPS,
+		burst:    burst,
+		adaptive: adaptive,
+		global:   newAPIRateLimiter(rps, maxRPS, burst, adaptive),
+		hosts:    make(map[string]*apiRateLimiter),
+	}
+}
+
+// useProviders 采用 providers 中按主机配置的 rps 与 burst（已由 compileProviders 校验一致性）
+func (s *apiLimiters) useProviders(providers map[string]*ProviderConfig) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.overrides = make(map[string]hostRate)
+	for _, p := range providers {
+		if p.RPS > 0 {
+			s.overrides[apiHost(p.URL)] = hostRate{rps: p.RPS, burst: p.Burst}
+		}
+	}
+	s.hosts = make(map[string]*apiRateLimiter)
+}
+
+// get 返回 baseURL 对应的令牌桶，nil 表示不限速
+func (s *apiLimiters) get(baseURL string) *apiRateLimiter {
+	if s == nil {
+		return nil
+	}
+	host := apiHost(baseURL)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	o, override := s.overrides[host]
+	if !override && !s.perHost {
+		return s.global
+	}
+	if l, ok := s.hosts[host]; ok {
+		return l
+	}
+	rps, burst := s.rps, s.burst
+	if override {
+		rps = o.rps
+		if o.burst > 0 {
+			burst = o.burst
+		}
+	}
+	l := newAPIRateLimiter(rps, s.maxRPS, burst, s.adaptive)
+	if l != nil {
+		l.name = host
+	}
+	s.hosts[host] = l
+	return l
+}
+
+// Wait 阻塞直到允许向 baseURL 发出下一个请求
+func (s *apiLimiters) Wait(ctx context.Context, baseURL string) error {
+	return s.get(baseURL).Wait(ctx)
+}
+
+// Feedback 将一次请求的结果反馈给 baseURL 所用的令牌桶
+func (s *apiLimiters) Feedback(baseURL string, err error) {
+	s.get(baseURL).Feedback(err)
+}
+
+// Rates 描述各令牌桶当前的速率，如 "2.00 rps" 或 "a.example.com 1.00 rps，b.example.com 4.00 rps"
+func (s *apiLimiters) Rates() string {
+	if s == nil {
+		return ""
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	hosts := make([]string, 0, len(s.hosts))
+	for h, l := range s.hosts {
+		if l != nil {
+			hosts = append(hosts, h)
+		}
+	}
+	sort.Strings(hosts)
+	var parts []string
+	if !s.perHost && s.global != nil {
+		if len(hosts) == 0 {
+			return fmt.Sprintf("%.2f rps", s.global.RPS())
+		}
+		parts = append(parts, fmt.Sprintf("其他端点 %.2f rps", s.global.RPS()))
+	}
+	for _, h := range hosts {
+		parts = append(parts, fmt.Sprintf("%s %.2f rps", h, s.hosts[h].RPS()))
+	}
+	return strings.Join(parts, "，")
+}
+
+// apiHost 返回 API 地址的主机部分（含端口），无法解析时返回原地址
+func apiHost(baseURL string) string {
+	u, err := url.Parse(baseURL)
+	if err != nil || u.Host == "" {
+		return baseURL
+	}
+	return u.Host
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ---------- 保存 API 原始响应（-save-raw） ----------
+
+// saveRawResponse 将 API 的原始响应体保存为 <-save-raw>/<API 主机>/<IP>.<格式>，同一 IP 再次查询时覆盖。
+// 返回保存的路径；未启用或保存失败时返回空字符串（失败只输出警告，不影响查询）
+func saveRawResponse(ip, baseURL string, provider *ProviderConfig, body []byte) string {
+	if *rawDir == "" {
+		return ""
+	}
+	ext := formatJSON
+	if provider != nil && provider.Format != "" {
+		ext = provider.Format
+	}
+	if ext == "text" {
+		ext = "txt"
+	}
+	// IPv6 地址与主机端口中的冒号在 Windows 上不能用于文件名
+	dir := filepath.Join(*rawDir, strings.ReplaceAll(apiHost(baseURL), ":", "_"))
+	path := filepath.Join(dir, strings.ReplaceAll(ip, ":", "_")+"."+ext)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		fmt.Fprintf(os.Stderr, "警告: 保存原始响应失败: %v\n", err)
+		return ""
+	}
+	if err := os.WriteFile(path, body, 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "警告: 保存原始响应失败: %v\n", err)
+		return ""
+	}
+	return path
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net"
+	"regexp"
+	"strings"
+)
+
+// ---------- 报告脱敏（-redact） ----------
+
+// redaction 是本进程使用的脱敏规则，由 main 与 serve 在 -redact 时根据配置生成（重新加载配置时重建）；
+// nil 表示不脱敏
+var redaction *redactor
+
+// redactor 将报告中的内网域名（allow_private 或 redact 的条目及其子域名）、私有地址、本机主机名与出口 IP
+// 替换为由密钥派生的替代名称。同一名称在同一密钥下总是得到同一个替代名称，报告中的对应关系仍然可读
+type redactor struct {
+	key      []byte
+	internal map[string]bool // 内网域名（小写，ASCII 与 Unicode 形式都有）
+}
+
+// redactToken 匹配报告中的 IPv6 地址、主机名与 IPv4 地址（冒号分隔的部分在前，以免 IPv6 地址被拆开）
+var redactToken = regexp.MustCompile(`[0-9A-Fa-f:]*:[0-9A-Fa-f:.]+|[A-Za-z0-9_-]+(?:\.[A-Za-z0-9_-]+)*\.?`)
+
+// newRedactor 根据配置生成脱敏规则；key 为空时使用随机密钥
+func newRedactor(cfg *Config, key string) *redactor {
+	r := &redactor{key: []byte(key), internal: make(map[string]bool)}
+	if key == "" {
+		r.key = make([]byte, 32)
+		fillRandom(r.key)
+	}
+	for _, dc := range cfg.Domains {
+		if !dc.AllowPrivate && !dc.Redact {
+			continue
+		}
+		name := strings.TrimPrefix(strings.ToLower(strings.TrimSuffix(dc.Name, ".")), "*.")
+		r.internal[name] = true
+		if ascii, err := toASCIIDomain(name); err == nil {
+			r.internal[strings.ToLower(ascii)] = true
+		}
+	}
+	return r
+}
+
+// token 返回 s 的替代名称
+func (r *redactor) token(prefix, s string) string {
+	mac := hmac.New(sha256.New, r.key)
+	mac.Write([]byte(strings.ToLower(s)))
+	return prefix + hex.EncodeToString(mac.Sum(nil))[:8]
+}
+
+// isInternal 判断 name 是否为内网域名或其子域名
+func (r *redactor) isInternal(name string) bool {
+	name = strings.ToLower(name)
+	for {
+		if r.internal[name] {
+			return true
+		}
+		i := strings.IndexByte(name, '.')
+		if i < 0 {
+			return false
+		}
+		name = name[i+1:]
+	}
+}
+
+// Text 替换报告文本中的内网域名与私有地址；r 为 nil 时原样返回
+func (r *redactor) Text(s string) string {
+	if r == nil {
+		return s
+	}
+	return redactToken.ReplaceAllStringFunc(s, r.replace)
+}
+
+// replace 替换一个匹配到的片段
+func (r *redactor) replace(t string) string {
+	if ip := net.ParseIP(t); ip != nil {
+		if isPrivateIP(ip) {
+			return r.token("redacted-ip-", ip.String())
+		}
+		return t
+	}
+	// 不是 IPv6 地址的冒号分隔片段，如 "system:10.0.0.1"、时间 "10:30:00"，逐段处理
+	if strings.Contains(t, ":") {
+		parts := strings.Split(t, ":")
+		for i, p := range parts {
+			if p != "" {
+				parts[i] = r.replace(p)
+			}
+		}
+		return strings.Join(parts, ":")
+	}
+	name := strings.TrimSuffix(t, ".")
+	if name == "" || !r.isInternal(name) {
+		return t
+	}
+	return r.token("redacted-", name) + t[len(name):]
+}
+
+// Host 返回本机主机名的替代名称；r 为 nil 或 host 为空时原样返回
+func (r *redactor) Host(host string) string {
+	if r == nil || host == "" {
+		return host
+	}
+	return r.token("host-", host)
+}
+
+// Run 返回替换了本机主机名与出口 IP 的运行信息；r 为 nil 时原样返回
+func (r *redactor) Run(run runInfo) runInfo {
+	if r == nil {
+		return run
+	}
+	run.Meta.Host = r.Host(run.Meta.Host)
+	if run.Meta.EgressIP != "" {
+		run.Meta.EgressIP = r.token("redacted-ip-", run.Meta.EgressIP)
+	}
+	return run
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"flag"
+	"net/http"
+	"syscall"
+	"time"
+)
+
+// ---------- 重新加载配置（守护模式，SIGHUP） ----------
+
+// reload 重新读取配置文件（-f 或项目的 config）、-signatures 特征库与 -api-tokens 令牌文件，替换域名、预期、告警规则、维护窗口、schedules、providers 与特征库。
+// 调用时各调度循环已经停止、没有进行中的检测。缓存、熔断器、历史记录、静音与告警状态都保留；
+// 命令行参数（包括 profile 中的参数）不会重新读取，需要重启才能生效。配置有误时返回错误，原配置保持不变
+func (d *daemon) reload() error {
+	config, err := loadConfigWithFallback(d.configPath)
+	if err != nil {
+		return err
+	}
+	if err := applyProfile(config, *profileName, flag.CommandLine); err != nil {
+		return err
+	}
+	sh, _ := parseShard(*shardSpec) // 已由 validateFlags 检查
+	sh.Apply(config)
+	sigs, err := loadDetectionSignatures()
+	if err != nil {
+		return err
+	}
+	auth, err := d.api.load()
+	if err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	// 调度组复制的是 d.checker，提供方与特征库需要在建组之前替换，配置有误时再恢复
+	oldSigs := d.checker.sigs
+	d.checker.useProviders(config)
+	d.checker.sigs = sigs
+	groups := newScheduleGroups(config, d.checker, d.interval)
+	if err := validateSplay(d.jitter, d.checker.splay, groups); err != nil {
+		d.checker.useProviders(d.config)
+		d.checker.sigs = oldSigs
+		return err
+	}
+	alerts, err := reloadAlertEngine(d.alerts, config.Alerts, d.alertPath)
+	if err != nil {
+		d.checker.useProviders(d.config)
+		d.checker.sigs = oldSigs
+		return err
+	}
+
+	// 同名的调度组沿用上一轮的完成时间，重新加载后按原来的节奏继续，而不是所有组立即检测一轮
+	finished := make(map[string]time.Time, len(d.groups))
+	for _, g := range d.groups {
+		finished[g.name] = g.lastFinish
+	}
+	for _, g := range groups {
+		g.lastFinish = finished[g.name]
+	}
+	// 从配置中移除的域名不再出现在 /metrics 与整轮告警中
+	kept := make(map[string]bool, len(config.Domains))
+	for _, dc := range config.Domains {
+		kept[dc.Name] = true
+	}
+	latest := d.lastResults[:0:0]
+	for _, r := range d.lastResults {
+		if kept[r.Domain] {
+			latest = append(latest, r)
+		}
+	}
+	d.config, d.groups, d.alerts, d.lastResults = config, groups, alerts, latest
+	d.api.set(auth)
+	// 沿用原来的密钥，重新加载前后同一名称的替代名称不变
+	if redaction != nil {
+		redaction = newRedactor(config, string(redaction.key))
+	}
+	return nil
+}
+
+// handleReload 实现 POST /api/reload：与 SIGHUP 相同，正在进行的检测完成后重新加载配置。
+// 重新加载在后台进行，结果输出到日志
+func (d *daemon) handleReload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "只支持 POST"})
+		return
+	}
+	d.requestReload()
+	writeJSON(w, http.StatusAccepted, map[string]string{"status": "reload requested"})
+}
+
+// requestReload 让调度循环在进行中的检测完成后重新加载配置，同 SIGHUP
+func (d *daemon) requestReload() {
+	select {
+	case d.reloads <- syscall.SIGHUP:
+	default: // 已有一次重新加载在等待
+	}
+}
@@ -0,0 +1,380 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// ---------- 域名解析器 ----------
+
+// resolveStep 记录原始解析过程中的一次查询，用于定位干扰发生在哪一级委派
+type resolveStep struct {
+	Zone    string // 被查询服务器负责的区域，如 "." "com."
+	Server  string
+	QName   string
+	QType   dnsmessage.Type
+	RCode   dnsmessage.RCode
+	Latency time.Duration
+	CNAMEs  []string // 应答中 CNAME 记录的目标
+	Err     error
+
+	Anomalies []responseAnomaly // 响应中发现的异常，见 scoreResponse
+}
+
+func (s resolveStep) String() string {
+	status := s.RCode.String()
+	if s.Err != nil {
+		status = s.Err.Error()
+	}
+	return fmt.Sprintf("%s [%s] %s %s: %s", s.Zone, s.Server, s.QName, s.QType, status)
+}
+
+// dnsResolver 解析域名的 IPv4 地址；原始解析模式下同时返回每一步的查询记录
+type dnsResolver interface {
+	LookupIPv4(ctx context.Context, host string) ([]net.IP, []resolveStep, error)
+}
+
+// newDNSResolver 根据 -resolver 参数创建解析器：
+// 空表示系统解析器，iterative 表示从根服务器开始迭代解析，udp://、tcp:// 与 https:// 通过对应的传输方式查询，
+// 其余视为 host[:port] 形式的递归解析器地址；逗号分隔的多个解析器按 strategy 查询（见 fanout.go）
+func newDNSResolver(spec string, qnameMin bool, strategy string) (dnsResolver, error) {
+	if strings.Contains(spec, ",") {
+		return newFanoutResolver(spec, qnameMin, strategy)
+	}
+	if strings.Contains(spec, "://") {
+		if qnameMin {
+			return nil, fmt.Errorf("-qname-min 需要配合 -resolver iterative 使用")
+		}
+		t, err := parseTransportSpec(spec)
+		if err != nil {
+			return nil, err
+		}
+		return transportResolver{spec: t}, nil
+	}
+	switch spec {
+	case "", "system":
+		if qnameMin {
+			return nil, fmt.Errorf("-qname-min 需要配合 -resolver iterative 使用")
+		}
+		return systemResolver{}, nil
+	case "iterative":
+		return &iterativeResolver{qnameMin: qnameMin}, nil
+	}
+	if qnameMin {
+		return nil, fmt.Errorf("-qname-min 需要配合 -resolver iterative 使用")
+	}
+	server := spec
+	if _, _, err := net.SplitHostPort(spec); err != nil {
+		server = net.JoinHostPort(spec, "53")
+	}
+	if _, err := net.ResolveUDPAddr("udp", server); err != nil {
+		return nil, fmt.Errorf("无效的解析器地址 %q: %w", spec, err)
+	}
+	return directResolver{server: server}, nil
+}
+
+// systemResolver 使用操作系统配置的解析器
+type systemResolver struct{}
+
+func (systemResolver) LookupIPv4(ctx context.Context, host string) ([]net.IP, []resolveStep, error) {
+	var r net.Resolver
+	if dnsCapture != nil {
+		r.PreferGo, r.Dial = true, captureDial
+	}
+	ips, err := r.LookupIP(ctx, "ip4", host)
+	return ips, nil, err
+}
+
+// directResolver 直接向指定的递归解析器发送原始查询
+type directResolver struct {
+	server string
+}
+
+func (d directResolver) LookupIPv4(ctx context.Context, host string) ([]net.IP, []resolveStep, error) {
+	step, resp := rawQuery(ctx, "", d.server, dnsFQDN(host), dnsmessage.TypeA)
+	steps := []resolveStep{step}
+	if step.Err != nil {
+		return nil, steps, step.Err
+	}
+	if resp.Header.RCode != dnsmessage.RCodeSuccess {
+		return nil, steps, fmt.Errorf("%s 返回 %s", d.server, resp.Header.RCode)
+	}
+	return answerIPv4(resp), steps, nil
+}
+
+// rawQuery 发送一次查询并记录为 resolveStep；单次查询超时不超过 2 秒和 ctx 剩余时间中较小者。
+// zone 为空表示 server 是递归解析器，异常评分时据此判断 AA 位是否正常。
+func rawQuery(ctx context.Context, zone, server, qname string, qtype dnsmessage.Type) (resolveStep, *dnsmessage.Message) {
+	step := resolveStep{Zone: zone, Server: server, QName: qname, QType: qtype}
+	timeout := 2 * time.Second
+	if deadline, ok := ctx.Deadline(); ok {
+		if remaining := time.Until(deadline); remaining < timeout {
+			timeout = remaining
+		}
+	}
+	if timeout <= 0 {
+		step.Err = context.DeadlineExceeded
+		return step, nil
+	}
+	query, err := newDNSQuery(qname, qtype)
+	if err != nil {
+		step.Err = err
+		return step, nil
+	}
+	start := time.Now()
+	resp, err := exchangeDNS(server, query, timeout)
+	step.Latency = time.Since(start)
+	if err != nil {
+		step.Err = err
+		return step, nil
+	}
+	step.RCode = resp.Header.RCode
+	step.CNAMEs = answerCNAMEs(resp)
+	step.Anomalies = scoreResponse(qname, qtype, resp, zone == "")
+	return step, resp
+}
+
+// answerIPv4 提取应答部分中的全部 A 记录
+func answerIPv4(resp *dnsmessage.Message) []net.IP {
+	var ips []net.IP
+	for _, rr := range resp.Answers {
+		if a, ok := rr.Body.(*dnsmessage.AResource); ok {
+			ips = append(ips, net.IPv4(a.A[0], a.A[1], a.A[2], a.A[3]))
+		}
+	}
+	return ips
+}
+
+// resolvePath 将查询记录压缩为逐级委派的路径，如 ". [198.41.0.4] → com. [192.5.6.30] → example.com. [199.43.135.53]"，
+// 每一级显示最后一个被查询的服务器，失败的查询附带原因，响应有异常的一级标注“响应异常”
+func resolvePath(steps []resolveStep) string {
+	var parts []string
+	for i, s := range steps {
+		if i+1 < len(steps) && steps[i+1].Zone == s.Zone && s.Err == nil && len(s.Anomalies) == 0 {
+			continue
+		}
+		part := s.Zone
+		if part == "" {
+			part = s.Server
+		} else {
+			part += " [" + strings.TrimSuffix(s.Server, ":53") + "]"
+		}
+		switch {
+		case s.Err != nil:
+			part += "（" + s.Err.Error() + "）"
+		case len(s.Anomalies) > 0:
+			// 标出应答开始出现异常的那一级，便于判断干扰发生在通往哪一级服务器的链路上
+			part += "（响应异常）"
+		}
+		parts = append(parts, part)
+	}
+	return fmt.Sprintf("%s（共 %d 次查询）", strings.Join(parts, " → "), len(steps))
+}
+
+// ---------- 迭代解析（可选 QNAME 最小化） ----------
+
+// rootServers 是根服务器的 IPv4 地址（root hints）
+var rootServers = []string{
+	"198.41.0.4", "170.247.170.2", "192.33.4.12", "199.7.91.13", "192.203.230.10", "192.5.5.241", "192.112.36.4",
+	"198.97.190.53", "192.36.148.17", "192.58.128.30", "193.0.14.129", "199.7.83.42", "202.12.27.33",
+}
+
+// 迭代解析的上限，防止委派或 CNAME 形成环路
+const (
+	maxResolveSteps = 40
+	maxCNAMEChain   = 8
+	maxServerTries  = 3
+)
+
+// iterativeResolver 不依赖任何递归解析器，从根服务器开始逐级跟随委派。
+// 启用 QNAME 最小化（RFC 9156）时，向每一级服务器只暴露比其区域多一个标签的名称并查询 NS，
+// 只有最后一步才向权威服务器发送完整名称，因此可以观察到干扰发生在哪一级委派。
+type iterativeResolver struct {
+	qnameMin bool
+}
+
+func (r *iterativeResolver) LookupIPv4(ctx context.Context, host string) ([]net.IP, []resolveStep, error) {
+	var steps []resolveStep
+	ips, err := r.resolve(ctx, dnsFQDN(strings.ToLower(host)), 0, &steps)
+	return ips, steps, err
+}
+
+// resolve 迭代解析 target 的 A 记录；depth 为 CNAME 与 NS 地址解析的嵌套层数
+func (r *iterativeResolver) resolve(ctx context.Context, target string, depth int, steps *[]resolveStep) ([]net.IP, error) {
+	if depth > maxCNAMEChain {
+		return nil, fmt.Errorf("解析 %s 时 CNAME 或委派嵌套过深", target)
+	}
+	labels := dnsLabels(target)
+	zone, servers := ".", rootServers
+	revealed := 1 // QNAME 最小化时已暴露的标签数
+
+	for len(*steps) < maxResolveSteps {
+		qname, qtype := target, dnsmessage.TypeA
+		if r.qnameMin && revealed < len(labels) {
+			qname, qtype = joinLabels(labels[len(labels)-revealed:]), dnsmessage.TypeNS
+		}
+		final := qname == target
+
+		var resp *dnsmessage.Message
+		var step resolveStep
+		for i, server := range servers {
+			if i >= maxServerTries {
+				break
+			}
+			step, resp = rawQuery(ctx, zone, net.JoinHostPort(server, "53"), qname, qtype)
+			*steps = append(*steps, step)
+			if step.Err == nil {
+				break
+			}
+		}
+		if resp == nil {
+			return nil, fmt.Errorf("在 %s 层查询 %s（%s）失败: %v", zone, qname, qtype, step.Err)
+		}
+		if resp.Header.RCode == dnsmessage.RCodeNameError {
+			return nil, fmt.Errorf("在 %s 层查询 %s 返回 NXDOMAIN（服务器 %s）", zone, qname, step.Server)
+		}
+		if resp.Header.RCode != dnsmessage.RCodeSuccess {
+			return nil, fmt.Errorf("在 %s 层查询 %s 返回 %s（服务器 %s）", zone, qname, resp.Header.RCode, step.Server)
+		}
+
+		if final {
+			if ips := answerIPv4(resp); len(ips) > 0 {
+				last := &(*steps)[len(*steps)-1]
+				last.Anomalies = append(last.Anomalies, scoreDelegation(zone, resp)...)
+				return ips, nil
+			}
+			if cname := answerCNAME(resp, target); cname != "" {
+				return r.resolve(ctx, strings.ToLower(cname), depth+1, steps)
+			}
+		}
+
+		// 委派：权威部分的 NS 指向比当前区域更深、且包含目标名称的子区域
+		if child, nsNames := referral(resp, zone, target); child != "" {
+			addrs, err := r.nsAddresses(ctx, resp, nsNames, depth, steps)
+			if err != nil {
+				return nil, fmt.Errorf("在 %s 层获取 %s 的权威服务器地址失败: %v", zone, child, err)
+			}
+			zone, servers = child, addrs
+			revealed = len(dnsLabels(child)) + 1
+			continue
+		}
+
+		if final {
+			return nil, fmt.Errorf("%s 没有 A 记录（权威区域 %s）", target, zone)
+		}
+		// QNAME 最小化下 NS 查询在应答部分给出 NS：同一服务器同时负责父子区域
+		if nsNames := answerNS(resp, qname); len(nsNames) > 0 {
+			addrs, err := r.nsAddresses(ctx, resp, nsNames, depth, steps)
+			if err != nil {
+				return nil, fmt.Errorf("获取 %s 的权威服务器地址失败: %v", qname, err)
+			}
+			zone, servers = qname, addrs
+		}
+		// 没有区域切分（NODATA），继续暴露下一个标签
+		revealed++
+	}
+	return nil, fmt.Errorf("解析 %s 的查询次数超过上限 %d", target, maxResolveSteps)
+}
+
+// nsAddresses 取得 NS 的地址：优先使用附加部分的胶水记录，没有时再迭代解析 NS 名称
+func (r *iterativeResolver) nsAddresses(ctx context.Context, resp *dnsmessage.Message, nsNames []string, depth int, steps *[]resolveStep) ([]string, error) {
+	var addrs []string
+	for _, rr := range resp.Additionals {
+		a, ok := rr.Body.(*dnsmessage.AResource)
+		if !ok {
+			continue
+		}
+		for _, ns := range nsNames {
+			if strings.EqualFold(rr.Header.Name.String(), ns) {
+				addrs = append(addrs, net.IPv4(a.A[0], a.A[1], a.A[2], a.A[3]).String())
+			}
+		}
+	}
+	if len(addrs) > 0 {
+		return addrs, nil
+	}
+	var lastErr error
+	for _, ns := range nsNames {
+		ips, err := r.resolve(ctx, strings.ToLower(ns), depth+1, steps)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		for _, ip := range ips {
+			addrs = append(addrs, ip.String())
+		}
+		if len(addrs) > 0 {
+			return addrs, nil
+		}
+	}
+	if lastErr == nil {
+		lastErr = fmt.Errorf("没有可用的 NS")
+	}
+	return nil, lastErr
+}
+
+// referral 从权威部分提取委派：返回子区域名称和 NS 名称列表，不是委派时返回空
+func referral(resp *dnsmessage.Message, zone, target string) (string, []string) {
+	var child string
+	var names []string
+	for _, rr := range resp.Authorities {
+		ns, ok := rr.Body.(*dnsmessage.NSResource)
+		if !ok {
+			continue
+		}
+		owner := strings.ToLower(rr.Header.Name.String())
+		if !isSubdomain(owner, zone) || owner == zone || !isSubdomain(target, owner) {
+			continue
+		}
+		if child != "" && owner != child {
+			continue
+		}
+		child = owner
+		names = append(names, ns.NS.String())
+	}
+	return child, names
+}
+
+// answerNS 返回应答部分中 name 的 NS 记录
+func answerNS(resp *dnsmessage.Message, name string) []string {
+	var names []string
+	for _, rr := range resp.Answers {
+		if ns, ok := rr.Body.(*dnsmessage.NSResource); ok && strings.EqualFold(rr.Header.Name.String(), name) {
+			names = append(names, ns.NS.String())
+		}
+	}
+	return names
+}
+
+// answerCNAME 返回应答部分中 name 的 CNAME 目标
+func answerCNAME(resp *dnsmessage.Message, name string) string {
+	for _, rr := range resp.Answers {
+		if c, ok := rr.Body.(*dnsmessage.CNAMEResource); ok && strings.EqualFold(rr.Header.Name.String(), name) {
+			return c.CNAME.String()
+		}
+	}
+	return ""
+}
+
+// dnsLabels 将 FQDN 拆分为标签，根区域返回空
+func dnsLabels(name string) []string {
+	name = strings.TrimSuffix(name, ".")
+	if name == "" {
+		return nil
+	}
+	return strings.Split(name, ".")
+}
+
+func joinLabels(labels []string) string {
+	return strings.Join(labels, ".") + "."
+}
+
+// isSubdomain 判断 name 是否等于 zone 或位于 zone 之下（均为小写 FQDN）
+func isSubdomain(name, zone string) bool {
+	return zone == "." || name == zone || strings.HasSuffix(name, "."+zone)
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// ---------- 历史记录保留与压缩 ----------
+
+// retentionPolicy 是历史记录的保留策略：超过 RawDays 天的原始结果按天、按域名汇总后删除，
+// 超过 DailyDays 天的每日汇总直接删除。天数为 0 表示永久保留。
+type retentionPolicy struct {
+	RawDays   int
+	DailyDays int
+}
+
+// compactStats 是一次压缩的结果
+type compactStats struct {
+	Rolled  int64 // 汇总并删除的原始结果条数
+	Expired int64 // 删除的每日汇总条数
+}
+
+// compactInterval 是守护模式下两次自动压缩的最小间隔
+const compactInterval = time.Hour
+
+// historyRetention 根据命令行参数返回保留策略
+func historyRetention() retentionPolicy {
+	return retentionPolicy{RawDays: *keepRawDays, DailyDays: *keepDaily}
+}
+
+// validateRetention 检查保留天数
+func validateRetention(p retentionPolicy) error {
+	if p.RawDays < 0 || p.DailyDays < 0 {
+		return fmt.Errorf("-history-raw-days 与 -history-daily-days 不能为负数")
+	}
+	if p.DailyDays > 0 && p.RawDays > p.DailyDays {
+		return fmt.Errorf("-history-raw-days 不能大于 -history-daily-days")
+	}
+	return nil
+}
+
+// dayStart 返回 t 所在 UTC 日的零点；每日汇总以此为键，与时区无关
+func dayStart(t time.Time) time.Time {
+	return t.UTC().Truncate(24 * time.Hour)
+}
+
+// dailyCount 是汇总前从原始结果中统计出的一天一个域名的检测次数
+type dailyCount struct {
+	day          int64
+	domain       string
+	runs         int64
+	polluted     int64
+	inconclusive int64
+}
+
+// Compact 按 p 汇总并删除过期的原始结果和每日汇总。只汇总完整的 UTC 日，
+// 每天的原始结果要么全部保留、要么全部汇总，因此 Counts 不会重复计数。
+func (s *sqlHistoryStore) Compact(now time.Time, p retentionPolicy) (compactStats, error) {
+	var st compactStats
+	tx, err := s.db.Begin()
+	if err != nil {
+		return st, fmt.Errorf("压缩历史记录失败: %w", err)
+	}
+	defer tx.Rollback()
+
+	if p.RawDays > 0 {
+		cutoff := dayStart(now).AddDate(0, 0, -p.RawDays).Unix()
+		// 先读出全部汇总再写入：部分驱动不允许在同一事务中边读边写
+		rows, err := tx.Query(s.bind(`SELECT checked_at - checked_at % 86400, domain, COUNT(*), SUM(polluted), SUM(CASE WHEN verdict = ? THEN 1 ELSE 0 END) FROM dnscheck_results WHERE checked_at < ? GROUP BY checked_at - checked_at % 86400, domain`), verdictError, cutoff)
+		if err != nil {
+			return st, fmt.Errorf("压缩历史记录失败: %w", err)
+		}
+		var counts []dailyCount
+		for rows.Next() {
+			var c dailyCount
+			if err := rows.Scan(&c.day, &c.domain, &c.runs, &c.polluted, &c.inconclusive); err != nil {
+				rows.Close()
+				return st, fmt.Errorf("压缩历史记录失败: %w", err)
+			}
+			counts = append(counts, c)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return st, fmt.Errorf("压缩历史记录失败: %w", err)
+		}
+
+		// 多个 agent 共用数据库时同一天可能已有汇总，因此累加而不是覆盖
+		for _, c := range counts {
+			res, err := tx.Exec(s.bind(`UPDATE dnscheck_daily SET runs = runs + ?, polluted = polluted + ?, inconclusive = inconclusive + ? WHERE day = ? AND domain = ?`),
+				c.runs, c.polluted, c.inconclusive, c.day, c.domain)
+			if err != nil {
+				return st, fmt.Errorf("压缩历史记录失败: %w", err)
+			}
+			if n, _ := res.RowsAffected(); n > 0 {
+				continue
+			}
+			if _, err := tx.Exec(s.bind(`INSERT INTO dnscheck_daily (day, domain, runs, polluted, inconclusive) VALUES (?, ?, ?, ?, ?)`),
+				c.day, c.domain, c.runs, c.polluted, c.inconclusive); err != nil {
+				return st, fmt.Errorf("压缩历史记录失败: %w", err)
+			}
+		}
+
+		res, err := tx.Exec(s.bind(`DELETE FROM dnscheck_results WHERE checked_at < ?`), cutoff)
+		if err != nil {
+			return st, fmt.Errorf("压缩历史记录失败: %w", err)
+		}
+		st.Rolled, _ = res.RowsAffected()
+		if _, err := tx.Exec(s.bind(`DELETE FROM dnscheck_runs WHERE started_at < ?`), cutoff); err != nil {
+			return st, fmt.Errorf("压缩历史记录失败: %w", err)
+		}
+	}
+
+	if p.DailyDays > 0 {
+		cutoff := dayStart(now).AddDate(0, 0, -p.DailyDays).Unix()
+		res, err := tx.Exec(s.bind(`DELETE FROM dnscheck_daily WHERE day < ?`), cutoff)
+		if err != nil {
+			return st, fmt.Errorf("压缩历史记录失败: %w", err)
+		}
+		st.Expired, _ = res.RowsAffected()
+	}
+	if err := tx.Commit(); err != nil {
+		return st, fmt.Errorf("压缩历史记录失败: %w", err)
+	}
+	return st, nil
+}
+
+// String 返回用于日志的压缩结果
+func (st compactStats) String() string {
+	return fmt.Sprintf("汇总了 %d 条过期的原始结果，删除了 %d 条过期的每日汇总", st.Rolled, st.Expired)
+}
+
+// compactHistory 在距上次压缩超过 compactInterval 时压缩历史记录
+func (d *daemon) compactHistory(now time.Time) {
+	d.mu.Lock()
+	due := now.Sub(d.compacted) >= compactInterval
+	if due {
+		d.compacted = now
+	}
+	d.mu.Unlock()
+	if !due {
+		return
+	}
+	st, err := d.history.Compact(now, historyRetention())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return
+	}
+	if st.Rolled > 0 || st.Expired > 0 {
+		fmt.Printf("[%s] 历史记录压缩: %s\n", now.Format("2006-01-02 15:04:05"), st)
+	}
+}
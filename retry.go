@@ -0,0 +1,212 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// ---------- 按错误类别的重试策略（配置文件的 retry） ----------
+
+// 不同的错误值得不同的处理：API 超时多半是偶发的，值得立即重试；429 需要等得更久；
+// 响应格式错误重试也没有用。配置文件的 retry 可以为每类错误单独设置重试次数与退避时间：
+//
+//	retry:
+//	  dns_timeout:  { retries: 1 }
+//	  dns_servfail: { retries: 2, backoff: 500ms }
+//	  api_429:      { retries: 3, backoff: 5s, max_backoff: 30s }
+//	  api_parse:    { retryable: false }
+//
+// 未配置的类别沿用原来的行为：API 超时与连接错误按 -retry 重试，其余错误不重试
+
+// 错误类别
+const (
+	retryDNSTimeout  = "dns_timeout"  // DNS 查询超时
+	retryDNSServfail = "dns_servfail" // 解析器返回 SERVFAIL（系统解析器为 "server misbehaving"）
+	retryAPITimeout  = "api_timeout"  // API 请求超时或连接中断
+	retryAPI5xx      = "api_5xx"      // API 返回 5xx
+	retryAPI429      = "api_429"      // API 返回 429
+	retryAPIParse    = "api_parse"    // API 响应无法解析或缺少 LLC 字段
+)
+
+var retryClasses = []string{retryDNSTimeout, retryDNSServfail, retryAPITimeout, retryAPI5xx, retryAPI429, retryAPIParse}
+
+// RetryPolicy 是配置文件中一类错误的重试策略
+type RetryPolicy struct {
+	Retries    *int          `yaml:"retries"`     // 最大重试次数，未设置时 retryable: true 使用 -retry
+	Backoff    time.Duration `yaml:"backoff"`     // 第一次重试前的等待，之后每次翻倍，默认 1s
+	MaxBackoff time.Duration `yaml:"max_backoff"` // 单次等待的上限，0 表示不限制
+	Retryable  *bool         `yaml:"retryable"`   // false 表示该类错误从不重试
+}
+
+// retryRule 是一类错误生效的重试策略
+type retryRule struct {
+	retries    int
+	backoff    time.Duration
+	maxBackoff time.Duration
+}
+
+// delay 返回第 attempt 次（从 0 开始）重试前的等待时间：指数退避，不超过 maxBackoff
+func (r retryRule) delay(attempt int) time.Duration {
+	d := r.backoff
+	for i := 0; i < attempt && (r.maxBackoff <= 0 || d < r.maxBackoff); i++ {
+		d *= 2
+	}
+	if r.maxBackoff > 0 && d > r.maxBackoff {
+		d = r.maxBackoff
+	}
+	return d
+}
+
+// retryPolicies 是各类错误生效的重试策略，nil 表示全部使用默认策略
+type retryPolicies map[string]retryRule
+
+// defaultRetryRule 返回未配置时的策略：API 超时与连接错误按 -retry 重试，其余不重试
+func defaultRetryRule(class string) retryRule {
+	r := retryRule{backoff: time.Second}
+	if class == retryAPITimeout {
+		r.retries = *maxRetries
+	}
+	return r
+}
+
+// rule 返回错误类别生效的策略；class 为空（不属于任何类别的错误，如 4xx）时不重试
+func (p retryPolicies) rule(class string) retryRule {
+	if class == "" {
+		return retryRule{}
+	}
+	if r, ok := p[class]; ok {
+		return r
+	}
+	return defaultRetryRule(class)
+}
+
+func (p retryPolicies) String() string {
+	var parts []string
+	for _, class := range retryClasses {
+		r := p.rule(class)
+		if r.retries == 0 {
+			parts = append(parts, class+" 不重试")
+			continue
+		}
+		s := fmt.Sprintf("%s 重试 %d 次（退避 %s", class, r.retries, r.backoff)
+		if r.maxBackoff > 0 {
+			s += fmt.Sprintf("，上限 %s", r.maxBackoff)
+		}
+		parts = append(parts, s+"）")
+	}
+	return strings.Join(parts, "，")
+}
+
+// validateRetry 校验配置文件的 retry 并构建生效的策略
+func validateRetry(cfg *Config) []string {
+	var problems []string
+	classes := make([]string, 0, len(cfg.Retry))
+	for class := range cfg.Retry {
+		classes = append(classes, class)
+	}
+	sort.Strings(classes)
+	policies := make(retryPolicies, len(cfg.Retry))
+	for _, class := range classes {
+		p := cfg.Retry[class]
+		known := false
+		for _, c := range retryClasses {
+			known = known || c == class
+		}
+		if !known {
+			problems = append(problems, fmt.Sprintf("retry: 未知的错误类别 %q（可选 %s）", class, strings.Join(retryClasses, "、")))
+			continue
+		}
+		if (p.Retries != nil && *p.Retries < 0) || p.Backoff < 0 || p.MaxBackoff < 0 {
+			problems = append(problems, fmt.Sprintf("retry %s: retries、backoff 与 max_backoff 不能为负数", class))
+			continue
+		}
+		r := defaultRetryRule(class)
+		switch {
+		case p.Retryable != nil && !*p.Retryable:
+			r.retries = 0
+		case p.Retries != nil:
+			r.retries = *p.Retries
+		case p.Retryable != nil:
+			r.retries = *maxRetries
+		}
+		if p.Backoff > 0 {
+			r.backoff = p.Backoff
+		}
+		r.maxBackoff = p.MaxBackoff
+		policies[class] = r
+	}
+	cfg.retry = policies
+	return problems
+}
+
+// parseError 标记 API 响应无法解析的错误，用于重试分类；错误信息保持不变
+type parseError struct{ err error }
+
+func (e parseError) Error() string { return e.err.Error() }
+func (e parseError) Unwrap() error { return e.err }
+
+// classifyAPIError 返回 API 请求错误的类别，不属于任何类别（如 4xx、熔断）时返回空字符串
+func classifyAPIError(err error) string {
+	var statusErr *APIStatusError
+	var pe parseError
+	switch {
+	case err == nil || errors.Is(err, errCircuitOpen):
+		return ""
+	case errors.As(err, &statusErr):
+		switch {
+		case statusErr.StatusCode == 429:
+			return retryAPI429
+		case statusErr.StatusCode >= 500:
+			return retryAPI5xx
+		}
+		return ""
+	case errors.As(err, &pe):
+		return retryAPIParse
+	case isTimeoutError(err), strings.Contains(err.Error(), "EOF"), strings.Contains(err.Error(), "connection reset"):
+		return retryAPITimeout
+	}
+	return ""
+}
+
+// classifyDNSError 返回解析错误的类别，NXDOMAIN 等确定的应答返回空字符串。
+// 原始解析模式的错误信息中包含应答的 RCODE，如 "返回 ServerFailure"
+func classifyDNSError(err error) string {
+	var dnsErr *net.DNSError
+	switch {
+	case err == nil:
+		return ""
+	case isTimeoutError(err):
+		return retryDNSTimeout
+	case strings.Contains(err.Error(), dnsmessage.RCodeServerFailure.String()):
+		return retryDNSServfail
+	case errors.As(err, &dnsErr) && dnsErr.IsTemporary && !dnsErr.IsNotFound:
+		return retryDNSServfail
+	}
+	return ""
+}
+
+// isTimeoutError 判断错误是否为超时
+func isTimeoutError(err error) bool {
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	return errors.Is(err, context.DeadlineExceeded) || strings.Contains(err.Error(), "timeout")
+}
+
+// sleepRetry 等待 d 或 ctx 结束，ctx 已结束时返回 false
+func sleepRetry(ctx context.Context, d time.Duration) bool {
+	select {
+	case <-time.After(d):
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ---------- 报告轮转 ----------
+
+// rotateReports 清理文件名匹配通配模式 pattern 的报告（自动命名时为 dnscheck_report_*，
+// 模板命名时见 outputRotatePattern；压缩后的 .gz 同样计入）：
+// keep > 0 时只保留最新的 keep 份，keepDays > 0 时删除早于该天数的报告，
+// compress 为 true 时将除最新一份以外的报告压缩为 .gz
+func rotateReports(pattern string, keep, keepDays int, compress bool, now time.Time) error {
+	if keep <= 0 && keepDays <= 0 && !compress {
+		return nil
+	}
+	plain, err := filepath.Glob(pattern)
+	if err != nil {
+		return fmt.Errorf("无效的报告轮转范围 %q: %w", pattern, err)
+	}
+	gzipped, _ := filepath.Glob(pattern + ".gz")
+	type report struct {
+		path string
+		at   time.Time
+	}
+	var reports []report
+	seen := make(map[string]bool)
+	for _, path := range append(plain, gzipped...) {
+		if fi, err := os.Stat(path); err != nil || fi.IsDir() || seen[path] {
+			continue
+		}
+		seen[path] = true
+		reports = append(reports, report{path: path, at: reportTime(path, filepath.Base(path))})
+	}
+	// 从新到旧；自动命名的报告按文件名中的时间戳，模板命名的报告按修改时间
+	sort.SliceStable(reports, func(i, j int) bool {
+		if !reports[i].at.Equal(reports[j].at) {
+			return reports[i].at.After(reports[j].at)
+		}
+		return reports[i].path > reports[j].path
+	})
+
+	var errs []string
+	for i, r := range reports {
+		path, name := r.path, filepath.Base(r.path)
+		expired := keepDays > 0 && now.Sub(r.at) > time.Duration(keepDays)*24*time.Hour
+		if (keep > 0 && i >= keep) || expired {
+			if err := os.Remove(path); err != nil {
+				errs = append(errs, err.Error())
+			}
+			continue
+		}
+		if compress && i > 0 && !strings.HasSuffix(name, ".gz") {
+			if err := gzipFile(path); err != nil {
+				errs = append(errs, err.Error())
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("报告轮转失败: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// reportTime 从文件名解析报告时间，解析失败时使用修改时间
+func reportTime(path, name string) time.Time {
+	stamp := strings.TrimPrefix(name, reportPrefix)
+	if len(stamp) >= len("20060102_150405") {
+		if t, err := time.ParseInLocation("20060102_150405", stamp[:len("20060102_150405")], time.Local); err == nil {
+			return t
+		}
+	}
+	if fi, err := os.Stat(path); err == nil {
+		return fi.ModTime()
+	}
+	return time.Now()
+}
+
+// gzipFile 将文件压缩为同名 .gz 并删除原文件
+func gzipFile(path string) error {
+	in, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(path + ".gz")
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(out)
+	zw.Name = filepath.Base(path)
+	if _, err := io.Copy(zw, in); err != nil {
+		out.Close()
+		os.Remove(path + ".gz")
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		out.Close()
+		os.Remove(path + ".gz")
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	// 保留原文件的修改时间，模板命名的报告按修改时间排序
+	if fi, err := in.Stat(); err == nil {
+		_ = os.Chtimes(path+".gz", fi.ModTime(), fi.ModTime())
+	}
+	in.Close()
+	return os.Remove(path)
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+)
+
+// ---------- 组合规则（rules） ----------
+
+// Rule 是组合判定规则的一个节点，每个节点只能设置一种条件：
+//
+//	all / any / not         组合子规则（与 / 或 / 非）
+//	llc                     LLC 符合任一预期（写法同 expected_llcs）
+//	asn / country / cidr    ASN、国家代码或 IP 属于列表中任一项
+//
+// 例如 "ASN 为 13335 且国家为 US 或 NL"：
+//
+//	rules:
+//	  all:
+//	    - asn: [13335]
+//	    - country: [US, NL]
+type Rule struct {
+	All     []*Rule       `yaml:"all"`
+	Any     []*Rule       `yaml:"any"`
+	Not     *Rule         `yaml:"not"`
+	LLC     []Expectation `yaml:"llc"`
+	ASN     []string      `yaml:"asn"`
+	Country []string      `yaml:"country"`
+	CIDR    []string      `yaml:"cidr"`
+
+	asns      map[uint32]bool
+	countries map[string]bool
+	nets      []*net.IPNet
+}
+
+// compile 校验规则树并预处理各条件；llc 条件继承域名级别的 match / case_insensitive
+func (r *Rule) compile(match string, caseInsensitive bool) error {
+	set := 0
+	for _, ok := range []bool{len(r.All) > 0, len(r.Any) > 0, r.Not != nil, len(r.LLC) > 0, len(r.ASN) > 0, len(r.Country) > 0, len(r.CIDR) > 0} {
+		if ok {
+			set++
+		}
+	}
+	if set != 1 {
+		return fmt.Errorf("规则节点必须且只能包含 all、any、not、llc、asn、country、cidr 中的一项")
+	}
+
+	for _, sub := range append(append([]*Rule{}, r.All...), r.Any...) {
+		if sub == nil {
+			return fmt.Errorf("规则节点不能为空")
+		}
+		if err := sub.compile(match, caseInsensitive); err != nil {
+			return err
+		}
+	}
+	if r.Not != nil {
+		if err := r.Not.compile(match, caseInsensitive); err != nil {
+			return err
+		}
+	}
+	if len(r.LLC) > 0 {
+		llc, err := resolveExpectations(r.LLC, match, caseInsensitive)
+		if err != nil {
+			return err
+		}
+		r.LLC = llc
+	}
+	if len(r.ASN) > 0 {
+		r.asns = make(map[uint32]bool)
+		for _, s := range r.ASN {
+			asn, err := parseASN(s)
+			if err != nil {
+				return err
+			}
+			r.asns[asn] = true
+		}
+	}
+	if len(r.Country) > 0 {
+		r.countries = make(map[string]bool)
+		for _, c := range r.Country {
+			r.countries[strings.ToUpper(strings.TrimSpace(c))] = true
+		}
+	}
+	for _, s := range r.CIDR {
+		n, err := parseIPOrCIDR(s)
+		if err != nil {
+			return err
+		}
+		r.nets = append(r.nets, n)
+	}
+	return nil
+}
+
+// Eval 判断单个 IP 的查询结果是否满足规则
+func (r *Rule) Eval(ip IPCheckResult) bool {
+	switch {
+	case len(r.All) > 0:
+		for _, sub := range r.All {
+			if !sub.Eval(ip) {
+				return false
+			}
+		}
+		return true
+	case len(r.Any) > 0:
+		for _, sub := range r.Any {
+			if sub.Eval(ip) {
+				return true
+			}
+		}
+		return false
+	case r.Not != nil:
+		return !r.Not.Eval(ip)
+	case len(r.LLC) > 0:
+		return matchesExpected(ip.ActualLLC, r.LLC)
+	case r.asns != nil:
+		return ip.ASN != 0 && r.asns[ip.ASN]
+	case r.countries != nil:
+		return ip.Country != "" && r.countries[strings.ToUpper(ip.Country)]
+	case len(r.nets) > 0:
+		if parsed := net.ParseIP(ip.IP); parsed != nil {
+			for _, n := range r.nets {
+				if n.Contains(parsed) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// String 以紧凑的表达式形式显示规则，用于报告
+func (r *Rule) String() string {
+	join := func(subs []*Rule, op string) string {
+		parts := make([]string, 0, len(subs))
+		for _, sub := range subs {
+			parts = append(parts, sub.String())
+		}
+		return "(" + strings.Join(parts, " "+op+" ") + ")"
+	}
+	switch {
+	case len(r.All) > 0:
+		return join(r.All, "AND")
+	case len(r.Any) > 0:
+		return join(r.Any, "OR")
+	case r.Not != nil:
+		return "NOT " + r.Not.String()
+	case len(r.LLC) > 0:
+		return fmt.Sprintf("llc%v", r.LLC)
+	case r.asns != nil:
+		asns := make([]string, 0, len(r.asns))
+		for asn := range r.asns {
+			asns = append(asns, fmt.Sprintf("AS%d", asn))
+		}
+		sort.Strings(asns)
+		return "asn[" + strings.Join(asns, " ") + "]"
+	case r.countries != nil:
+		return "country[" + strings.Join(sortedBoolKeys(r.countries), " ") + "]"
+	default:
+		return "cidr[" + strings.Join(r.CIDR, " ") + "]"
+	}
+}
+
+// sortedBoolKeys 返回集合中排好序的键
+func sortedBoolKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
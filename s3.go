@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ---------- 上传报告到 S3 兼容对象存储 ----------
+
+// s3Target 描述上传目标。endpoint 采用 path-style 写法并包含桶名，
+// 如 https://s3.us-east-1.amazonaws.com/my-bucket 或 http://minio:9000/reports
+type s3Target struct {
+	endpoint     string
+	region       string
+	accessKey    string
+	secretKey    string
+	sessionToken string
+}
+
+// newS3TargetFromEnv 使用标准的 AWS_* 环境变量作为凭据
+func newS3TargetFromEnv(endpoint, region string) (*s3Target, error) {
+	t := &s3Target{
+		endpoint:     strings.TrimSuffix(endpoint, "/"),
+		region:       region,
+		accessKey:    os.Getenv("AWS_ACCESS_KEY_ID"),
+		secretKey:    os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		sessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+	}
+	if t.accessKey == "" || t.secretKey == "" {
+		return nil, fmt.Errorf("上传 S3 需要设置环境变量 AWS_ACCESS_KEY_ID 与 AWS_SECRET_ACCESS_KEY")
+	}
+	return t, nil
+}
+
+// expandKeyTemplate 展开对象键模板，支持 {date}、{time}、{run_id}、{host}、{format}、{ext}
+func expandKeyTemplate(tmpl, runID string, at time.Time) string {
+	host, _ := os.Hostname()
+	return strings.NewReplacer(
+		"{date}", at.Format("2006-01-02"),
+		"{time}", at.Format("150405"),
+		"{run_id}", runID,
+		"{host}", host,
+		"{format}", *format,
+		"{ext}", formatExt(*format),
+	).Replace(tmpl)
+}
+
+// Put 使用 AWS Signature Version 4 上传对象
+func (t *s3Target) Put(key string, body []byte, contentType string, timeout time.Duration) error {
+	u, err := url.Parse(t.endpoint + "/" + strings.TrimPrefix(key, "/"))
+	if err != nil {
+		return fmt.Errorf("无效的 S3 地址: %w", err)
+	}
+	u.RawPath = s3EscapePath(u.Path)
+
+	req, err := http.NewRequest(http.MethodPut, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("无效的 S3 地址: %w", err)
+	}
+	req.Header.Set("Content-Type", contentType)
+	t.sign(req, body, time.Now().UTC())
+
+	client := http.Client{Timeout: timeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("上传报告到 S3 失败: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("上传报告到 S3 失败: 状态码 %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+// sign 为请求添加 SigV4 签名相关的头
+func (t *s3Target) sign(req *http.Request, body []byte, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	day := now.Format("20060102")
+	payloadHash := sha256Hex(body)
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if t.sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", t.sessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for k, v := range req.Header {
+		headers[strings.ToLower(k)] = strings.TrimSpace(strings.Join(v, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, k := range names {
+		canonicalHeaders.WriteString(k + ":" + headers[k] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := day + "/" + t.region + "/s3/aws4_request"
+	stringToSign := strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, sha256Hex([]byte(canonicalRequest))}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+t.secretKey), day)
+	key = hmacSHA256(key, t.region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		t.accessKey, scope, signedHeaders, signature))
+}
+
+// s3EscapePath 按 SigV4 要求对路径逐段编码（仅保留 RFC 3986 非保留字符）
+func s3EscapePath(p string) string {
+	var b strings.Builder
+	for i := 0; i < len(p); i++ {
+		c := p[i]
+		if c == '/' || c == '-' || c == '_' || c == '.' || c == '~' ||
+			('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || ('0' <= c && c <= '9') {
+			b.WriteByte(c)
+			continue
+		}
+		b.WriteString(fmt.Sprintf("%%%02X", c))
+	}
+	return b.String()
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// uploadReport 在指定了 -s3-url 时上传报告，未启用时不做任何事
+func uploadReport(report, runID string, at time.Time) error {
+	if *s3URL == "" {
+		return nil
+	}
+	target, err := newS3TargetFromEnv(*s3URL, *s3Region)
+	if err != nil {
+		return err
+	}
+	key := expandKeyTemplate(*s3Key, runID, at)
+	if err := target.Put(key, []byte(report), "text/plain; charset=utf-8", *timeout); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "报告已上传至: %s/%s\n", target.endpoint, key)
+	return nil
+}
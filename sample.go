@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"net"
+	"sort"
+	"time"
+)
+
+// ---------- 每个域名的 IP 抽样（-max-ips-per-domain） ----------
+
+// 抽样方式
+const (
+	sampleFirst  = "first"  // 按 DNS 应答顺序取前 N 个
+	sampleRandom = "random" // 随机取 N 个
+	sampleAll    = "all"    // 不抽样
+)
+
+// validateSampling 检查 -max-ips-per-domain 与 -ip-sample 参数
+func validateSampling(max int, strategy string) error {
+	if max < 0 {
+		return fmt.Errorf("-max-ips-per-domain 不能为负数")
+	}
+	switch strategy {
+	case sampleFirst, sampleRandom, sampleAll:
+		return nil
+	}
+	return fmt.Errorf("无效的 -ip-sample 参数 %q（可选 first、random、all）", strategy)
+}
+
+// sampleIPs 在 IP 数超过 max 时按 strategy 取其中 max 个，随机抽样时保留原有顺序；
+// max 为 0 或 strategy 为 all 时原样返回
+func sampleIPs(ips []net.IP, max int, strategy string) []net.IP {
+	if max <= 0 || strategy == sampleAll || len(ips) <= max {
+		return ips
+	}
+	if strategy != sampleRandom {
+		return ips[:max]
+	}
+	rng := rand.New(rand.NewSource(time.Now().UnixNano()))
+	picked := rng.Perm(len(ips))[:max]
+	sort.Ints(picked)
+	out := make([]net.IP, 0, max)
+	for _, i := range picked {
+		out = append(out, ips[i])
+	}
+	return out
+}
@@ -0,0 +1,315 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ---------- 按分组调度（守护模式） ----------
+
+// ScheduleConfig 是配置文件顶层 schedules 中的一项：守护模式下属于 groups 的域名按各自的间隔检测，
+// 并使用独立的并发预算，使大批量的低优先级域名不会挤占关键域名的检测。
+type ScheduleConfig struct {
+	Name        string        `yaml:"name"`
+	Groups      []string      `yaml:"groups"`      // 属于这些分组之一的域名归入本项
+	Interval    time.Duration `yaml:"interval"`    // 检测间隔
+	Concurrency int           `yaml:"concurrency"` // 并发查询数，0 表示沿用 -c
+}
+
+// validateSchedules 检查 schedules 的取值，并确保每个域名至多归入一项
+func validateSchedules(cfg *Config) []string {
+	groups := make(map[string]bool)
+	for _, dc := range cfg.Domains {
+		for _, g := range dc.Groups {
+			groups[g] = true
+		}
+	}
+	seen := make(map[string]bool)
+	var problems []string
+	for i, s := range cfg.Schedules {
+		if s.Name == "" {
+			problems = append(problems, fmt.Sprintf("schedules 第 %d 项缺少 name", i+1))
+			continue
+		}
+		if seen[s.Name] {
+			problems = append(problems, fmt.Sprintf("schedules 中的 %s 重复", s.Name))
+			continue
+		}
+		seen[s.Name] = true
+		if s.Interval < time.Second {
+			problems = append(problems, fmt.Sprintf("schedules %s: interval 不能小于 1s", s.Name))
+		}
+		if s.Concurrency < 0 {
+			problems = append(problems, fmt.Sprintf("schedules %s: concurrency 不能为负数", s.Name))
+		}
+		if len(s.Groups) == 0 {
+			problems = append(problems, fmt.Sprintf("schedules %s: 缺少 groups", s.Name))
+		}
+		for _, g := range s.Groups {
+			if !groups[g] {
+				problems = append(problems, fmt.Sprintf("schedules %s: 没有域名属于分组 %q", s.Name, g))
+			}
+		}
+	}
+	if len(problems) > 0 {
+		return problems
+	}
+	for _, dc := range cfg.Domains {
+		var names []string
+		for _, s := range cfg.Schedules {
+			if len(filterDomainsByGroup([]DomainConfig{dc}, s.Groups)) > 0 {
+				names = append(names, s.Name)
+			}
+		}
+		if len(names) > 1 {
+			problems = append(problems, fmt.Sprintf("域名 %s 同时属于 schedules 中的 %s", dc.Name, strings.Join(names, "、")))
+		}
+	}
+	return problems
+}
+
+// scheduleGroup 是守护模式下按同一间隔检测的一组域名
+type scheduleGroup struct {
+	name     string // schedules 中的名称，未归入任何一项的域名所在的组为空
+	interval time.Duration
+	checker  *checker // 与其他组共享缓存、限速器与熔断器，但并发预算与运行统计独立
+	config   *Config  // 只包含本组域名
+
+	trigger chan struct{} // 通过 /api/check 立即检测一轮，缓冲为 1
+
+	// 以下字段由 daemon.mu 保护
+	running    bool
+	lastStart  time.Time
+	lastFinish time.Time
+}
+
+// label 返回日志中标识实例与组的后缀：按项目运行时加上项目 ID，如“（team-a）”“（team-a/cdn）”。g 可为 nil
+func (d *daemon) label(g *scheduleGroup) string {
+	name := ""
+	if g != nil {
+		name = g.name
+	}
+	switch {
+	case d.project == "" && name == "":
+		return ""
+	case d.project == "":
+		return fmt.Sprintf("（%s）", name)
+	case name == "":
+		return fmt.Sprintf("（%s）", d.project)
+	}
+	return fmt.Sprintf("（%s/%s）", d.project, name)
+}
+
+// newScheduleGroups 按 schedules 划分域名；未归入任何一项的域名使用 c、interval 与 -c。
+// 没有配置 schedules 时只有一组，即全部域名。
+func newScheduleGroups(cfg *Config, c *checker, interval time.Duration) []*scheduleGroup {
+	var groups []*scheduleGroup
+	assigned := make(map[string]bool)
+	for _, s := range cfg.Schedules {
+		domains := filterDomainsByGroup(cfg.Domains, s.Groups)
+		if len(domains) == 0 {
+			continue // 分组中的域名都被 profile 筛掉了
+		}
+		for _, dc := range domains {
+			assigned[dc.Name] = true
+		}
+		budget := concurrency
+		if s.Concurrency > 0 {
+			budget = &concurrencyValue{n: s.Concurrency}
+		}
+		sub := *cfg
+		sub.Domains = domains
+		groups = append(groups, &scheduleGroup{
+			name:     s.Name,
+			interval: s.Interval,
+			checker:  c.withGate(newConcurrencyGate(budget)),
+			config:   &sub,
+			trigger:  make(chan struct{}, 1),
+		})
+	}
+	if len(groups) == 0 {
+		return []*scheduleGroup{{interval: interval, checker: c, config: cfg, trigger: make(chan struct{}, 1)}}
+	}
+	var rest []DomainConfig
+	for _, dc := range cfg.Domains {
+		if !assigned[dc.Name] {
+			rest = append(rest, dc)
+		}
+	}
+	if len(rest) > 0 {
+		sub := *cfg
+		sub.Domains = rest
+		groups = append(groups, &scheduleGroup{interval: interval, checker: c, config: &sub, trigger: make(chan struct{}, 1)})
+	}
+	return groups
+}
+
+// withGate 返回与 c 共享缓存、限速器、熔断器与指标的检测器副本，使用 gate 作为并发预算，
+// 运行统计也与 c 分开，因此各组可以同时运行
+func (c *checker) withGate(gate *concurrencyGate) *checker {
+	cc := *c
+	fetcher := *c.fetcher
+	cc.fetcher = &fetcher
+	cc.gate = gate
+	return &cc
+}
+
+// mergeResults 用本轮结果替换 prev 中相同域名的结果，返回新的切片
+func mergeResults(prev, results []DomainResult) []DomainResult {
+	fresh := make(map[string]DomainResult, len(results))
+	for _, r := range results {
+		fresh[r.Domain] = r
+	}
+	merged := make([]DomainResult, 0, len(prev)+len(results))
+	for _, r := range prev {
+		if nr, ok := fresh[r.Domain]; ok {
+			r = nr
+			delete(fresh, r.Domain)
+		}
+		merged = append(merged, r)
+	}
+	for _, r := range results {
+		if _, ok := fresh[r.Domain]; ok {
+			merged = append(merged, r)
+		}
+	}
+	return merged
+}
+
+// schedule 为每个调度组启动独立的检测循环，直到 ctx 结束。收到 reload 信号时停止各循环，
+// 等待进行中的检测完成后重新加载配置（见 reload.go），再按新的调度组重新启动
+func (d *daemon) schedule(ctx context.Context, reload <-chan os.Signal) {
+	for {
+		loopCtx, cancel := context.WithCancel(ctx)
+		var wg sync.WaitGroup
+		for _, g := range d.groups {
+			wg.Add(1)
+			go func(g *scheduleGroup) {
+				defer wg.Done()
+				d.loop(ctx, loopCtx, g)
+			}(g)
+		}
+		select {
+		case <-ctx.Done():
+		case <-reload:
+		}
+		cancel()
+		wg.Wait()
+		if ctx.Err() != nil {
+			return
+		}
+		d.notify.Notify("RELOADING=1")
+		err := d.reload()
+		d.notify.Notify("READY=1")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "重新加载配置失败%s，继续使用原配置: %v\n", d.label(nil), err)
+			continue
+		}
+		fmt.Printf("已重新加载配置%s，检测间隔 %s\n", d.label(nil), describeGroups(d.groups))
+	}
+}
+
+// loop 执行组的检测，之后按组的间隔重复：组从未运行过时立即开始，否则在上一轮完成一个间隔后开始。
+// 每轮都在计划时间的基础上随机推迟至多 -jitter，避免由同一模板部署、同时启动的大量实例在同一时刻请求 API。
+// stop 结束时不再开始新一轮，进行中的检测使用 ctx，不受影响
+func (d *daemon) loop(ctx, stop context.Context, g *scheduleGroup) {
+	d.mu.RLock()
+	next := time.Now()
+	if !g.lastFinish.IsZero() {
+		next = g.lastFinish.Add(g.interval)
+	}
+	d.mu.RUnlock()
+	for {
+		timer := time.NewTimer(time.Until(next.Add(randomDelay(d.jitter))))
+		select {
+		case <-stop.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		case <-g.trigger:
+			// 立即检测一轮，之后从本轮开始重新计算间隔
+			timer.Stop()
+			next = time.Now()
+		}
+		d.runOnce(ctx, g)
+		// 本轮耗时超过间隔时立即开始下一轮，不补回错过的轮次
+		if next = next.Add(g.interval); next.Before(time.Now()) {
+			next = time.Now()
+		}
+	}
+}
+
+// randomDelay 返回 [0, max) 内的随机时长，max 不大于 0 时返回 0。
+// 使用密码学随机数，各实例即使同时启动也不会得到相同的序列
+func randomDelay(max time.Duration) time.Duration {
+	if max <= 0 {
+		return 0
+	}
+	var b [8]byte
+	fillRandom(b[:])
+	return time.Duration(binary.BigEndian.Uint64(b[:]) % uint64(max))
+}
+
+// validateSplay 检查 -jitter 与 -splay：不能为负数，也不能超过最短的检测间隔
+func validateSplay(jitter, splay time.Duration, groups []*scheduleGroup) error {
+	if jitter < 0 || splay < 0 {
+		return fmt.Errorf("-jitter 与 -splay 不能为负数")
+	}
+	for _, g := range groups {
+		if jitter >= g.interval || splay >= g.interval {
+			return fmt.Errorf("-jitter 与 -splay 必须小于检测间隔 %s", g.interval)
+		}
+	}
+	return nil
+}
+
+// describeGroups 返回启动日志中的检测间隔说明
+func describeGroups(groups []*scheduleGroup) string {
+	if len(groups) == 1 && groups[0].name == "" {
+		return groups[0].interval.String()
+	}
+	parts := make([]string, 0, len(groups))
+	for _, g := range groups {
+		name := g.name
+		if name == "" {
+			name = "其余域名"
+		}
+		parts = append(parts, fmt.Sprintf("%s %s（%d 个域名）", name, g.interval, len(g.config.Domains)))
+	}
+	return strings.Join(parts, "，")
+}
+
+// handleCheck 实现 POST /api/check：所有调度组（指定 group 时只有该组）立即检测一轮。
+// 正在检测的组在本轮结束后立即再检测一轮；已在等待的请求不会重复排队
+func (d *daemon) handleCheck(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "只支持 POST"})
+		return
+	}
+	group := r.FormValue("group")
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	triggered := []string{}
+	for _, g := range d.groups {
+		if group != "" && g.name != group {
+			continue
+		}
+		select {
+		case g.trigger <- struct{}{}:
+		default:
+		}
+		triggered = append(triggered, g.name)
+	}
+	if len(triggered) == 0 {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": fmt.Sprintf("没有名为 %q 的调度组", group)})
+		return
+	}
+	writeJSON(w, http.StatusAccepted, map[string][]string{"groups": triggered})
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"go.starlark.net/starlark"
+)
+
+// ---------- 自定义判定脚本（Starlark） ----------
+
+// scriptMaxSteps 限制单次 verdict 调用的执行步数，防止脚本死循环拖住整轮检测
+const scriptMaxSteps = 1000000
+
+// verdictScript 是配置中 script 字段编译后的结果。脚本需定义函数
+//
+//	def verdict(domain, ips, infos): ...
+//
+// 其中 ips 为解析到的 IP 列表，infos 为每个 IP 的详细信息（dict），返回值：
+//
+//	None                      沿用内置判定
+//	"ok" / "polluted"         覆盖内置判定
+//	("polluted", "原因")      覆盖内置判定并给出汇总说明
+//
+// 脚本给出的判定是确定的：两种判定都清除“无法判定”，"ok" 还清除私有地址应答（重绑定）的判定，
+// 使 JSON、syslog、告警等输出中的判定与汇总一致。
+//
+// nil 表示未配置脚本。
+type verdictScript struct {
+	fn starlark.Callable
+}
+
+// newVerdictScript 执行脚本顶层代码并取出 verdict 函数；全局变量随后被冻结，可在多个 goroutine 中并发调用
+func newVerdictScript(src string) (*verdictScript, error) {
+	thread := &starlark.Thread{Name: "load", Print: scriptPrint}
+	globals, err := starlark.ExecFile(thread, "script", src, nil)
+	if err != nil {
+		return nil, fmt.Errorf("加载判定脚本失败: %w", err)
+	}
+	globals.Freeze()
+	fn, ok := globals["verdict"].(starlark.Callable)
+	if !ok {
+		return nil, fmt.Errorf("判定脚本中没有定义 verdict(domain, ips, infos) 函数")
+	}
+	return &verdictScript{fn: fn}, nil
+}
+
+// scriptPrint 将脚本中 print() 的输出写到标准错误，便于调试
+func scriptPrint(thread *starlark.Thread, msg string) {
+	fmt.Fprintf(os.Stderr, "[脚本] %s\n", msg)
+}
+
+// Apply 调用 verdict 并按返回值覆盖域名结果；脚本出错时保留内置判定并在汇总中注明
+func (s *verdictScript) Apply(res *DomainResult) {
+	if s == nil || len(res.IPResults) == 0 {
+		return
+	}
+	ips := make([]starlark.Value, 0, len(res.IPResults))
+	infos := make([]starlark.Value, 0, len(res.IPResults))
+	for _, ip := range res.IPResults {
+		ips = append(ips, starlark.String(ip.IP))
+		infos = append(infos, scriptIPInfo(*res, ip))
+	}
+
+	thread := &starlark.Thread{Name: "verdict:" + res.Domain, Print: scriptPrint}
+	thread.SetMaxExecutionSteps(scriptMaxSteps)
+	v, err := starlark.Call(thread, s.fn, starlark.Tuple{
+		starlark.String(res.Domain),
+		starlark.NewList(ips),
+		starlark.NewList(infos),
+	}, nil)
+	if err != nil {
+		res.Summary += fmt.Sprintf("（判定脚本出错: %v）", err)
+		return
+	}
+
+	verdict, reason, err := parseScriptVerdict(v)
+	if err != nil {
+		res.Summary += fmt.Sprintf("（判定脚本出错: %v）", err)
+		return
+	}
+	switch verdict {
+	case "":
+		return
+	case "ok":
+		res.IsPolluted, res.Rebinding, res.Failed = false, false, false
+	case "polluted":
+		res.IsPolluted, res.Failed = true, false
+	}
+	if reason == "" {
+		reason = "判定脚本: " + verdict
+	}
+	res.Summary = reason
+}
+
+// scriptIPInfo 将单个 IP 的结果转换为脚本可读的 dict
+func scriptIPInfo(res DomainResult, ip IPCheckResult) *starlark.Dict {
+	d := starlark.NewDict(12)
+	set := func(k string, v starlark.Value) { _ = d.SetKey(starlark.String(k), v) }
+	set("ip", starlark.String(ip.IP))
+	set("llc", starlark.String(ip.ActualLLC))
+	set("raw_llc", starlark.String(ip.RawLLC))
+	set("asn", starlark.MakeUint(uint(ip.ASN)))
+	set("country", starlark.String(ip.Country))
+	set("matched", starlark.Bool(res.ipMatched(ip)))
+	set("forbidden", starlark.String(ip.Forbidden))
+	set("private", starlark.Bool(ip.Private))
+	set("source", starlark.String(ip.Source))
+	anomalies := make([]starlark.Value, 0, len(ip.Anomalies))
+	for _, a := range ip.Anomalies {
+		anomalies = append(anomalies, starlark.String(a))
+	}
+	set("anomalies", starlark.NewList(anomalies))
+	set("anomaly_score", starlark.MakeInt(ip.AnomalyScore))
+	if ip.Error != nil {
+		set("error", starlark.String(ip.Error.Error()))
+	} else {
+		set("error", starlark.None)
+	}
+	return d
+}
+
+// parseScriptVerdict 解析 verdict 的返回值，None 返回空判定
+func parseScriptVerdict(v starlark.Value) (verdict, reason string, err error) {
+	if v == starlark.None {
+		return "", "", nil
+	}
+	if t, ok := v.(starlark.Tuple); ok {
+		if len(t) != 2 {
+			return "", "", fmt.Errorf("verdict 返回的元组必须是 (判定, 原因)")
+		}
+		if reason, ok = starlark.AsString(t[1]); !ok {
+			return "", "", fmt.Errorf("verdict 返回的原因必须是字符串")
+		}
+		v = t[0]
+	}
+	verdict, ok := starlark.AsString(v)
+	if !ok || (verdict != "ok" && verdict != "polluted") {
+		return "", "", fmt.Errorf("verdict 必须返回 None、\"ok\"、\"polluted\" 或 (判定, 原因)，实际为 %s", v.String())
+	}
+	return verdict, reason, nil
+}
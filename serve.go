@@ -0,0 +1,634 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// ---------- 守护模式（serve） ----------
+
+// daemon 定时执行检测，并通过 HTTP 暴露运行状态
+type daemon struct {
+	checker    *checker
+	config     *Config
+	groups     []*scheduleGroup // 按 schedules 划分的调度组，未配置时只有一组
+	jitter     time.Duration    // -jitter
+	interval   time.Duration    // -interval，未归入 schedules 的域名使用
+	outputPath string
+	syslog     *syslogWriter
+	history    historyStore
+	alerts     *alertEngine
+	flaps      *flapTracker
+	stream     *eventHub
+	notify     *sdNotifier
+	reloads    chan os.Signal // SIGHUP 与 /api/reload 都发送到这里
+
+	api        *apiGuard // 接口认证，按项目运行时各项目共用
+	project    string    // 按项目运行时的项目 ID，否则为空
+	configPath string    // -f 或项目的 config，重新加载时重新读取
+	alertPath  string    // -alert-state 或项目的 alert_state
+
+	// mu 也保护重新加载配置时替换的 config、groups 与 alerts
+	mu          sync.RWMutex
+	running     int // 正在运行的调度组数
+	runs        int
+	lastStart   time.Time
+	lastFinish  time.Time
+	lastSuccess time.Time
+	lastError   string
+	lastResults []DomainResult
+	available   []domainAvailability // 由历史记录计算的各域名可用性，未配置 -history 时为空
+	mutes       map[string]time.Time // 通过 /mute 设置的临时静音，键为域名或 "*"
+	compacted   time.Time            // 最近一次压缩历史记录的时间
+}
+
+// runServe 实现 `dnscheck serve` 子命令：按固定间隔检测，并提供 /healthz、/readyz、/metrics 与 /mute；
+// 收到 SIGHUP 或 POST /api/reload 时重新加载配置文件。指定 -projects 时按项目运行，见 projects.go
+func runServe(args []string) {
+	listen := flag.String("listen", ":8080", "守护模式 HTTP 监听地址")
+	interval := flag.Duration("interval", 10*time.Minute, "守护模式检测间隔（schedules 中的分组使用各自的间隔）")
+	jitter := flag.Duration("jitter", 0, "每轮检测在计划时间后随机推迟的上限，避免大量实例同时请求 API")
+	splay := flag.Duration("splay", 0, "每轮中各域名随机推迟开始检测的上限，将 API 请求分散到这段时间内")
+	tokenFile := flag.String("api-tokens", "", "接口令牌文件（YAML），列出 Bearer 令牌及其权限（read、check、manage）；为空且未指定 -tls-client-ca 时接口不认证")
+	tlsCert := flag.String("tls-cert", "", "HTTPS 证书文件（PEM），与 -tls-key 一起指定后以 HTTPS 提供接口")
+	tlsKey := flag.String("tls-key", "", "HTTPS 私钥文件（PEM）")
+	clientCA := flag.String("tls-client-ca", "", "客户端证书的 CA（PEM），指定后按客户端证书认证（双向 TLS）")
+	projectFile := flag.String("projects", "", "项目文件（YAML）：每个项目使用各自的配置文件、检测间隔、历史记录与通知，接口位于 /api/projects/{id}/")
+	if err := flag.CommandLine.Parse(args); err != nil {
+		os.Exit(2)
+	}
+
+	var (
+		specs   []ProjectSpec
+		configs []*Config
+	)
+	if *projectFile == "" {
+		config, err := loadConfigWithFallback(*configFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "加载配置文件失败: %v\n", err)
+			os.Exit(1)
+		}
+		if err := applyProfile(config, *profileName, flag.CommandLine); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(2)
+		}
+		if err := validateFlags(); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(2)
+		}
+		// 唯一的实例使用 -f 与各全局参数（可能已被 profile 修改）
+		specs = []ProjectSpec{{
+			Config:     *configFile,
+			Interval:   *interval,
+			Output:     *outputFile,
+			History:    *historyDSN,
+			AlertState: *alertFile,
+			FlapState:  *flapFile,
+			Syslog:     *syslogAddr,
+			PostRunCmd: *postRunCmd,
+		}}
+		configs = []*Config{config}
+	} else {
+		if err := validateFlags(); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(2)
+		}
+		var err error
+		if specs, err = loadProjects(*projectFile, *interval); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(2)
+		}
+		for _, spec := range specs {
+			config, err := loadConfigWithFallback(spec.Config)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "项目 %s: 加载配置文件失败: %v\n", spec.ID, err)
+				os.Exit(1)
+			}
+			configs = append(configs, config)
+		}
+	}
+
+	tlsConfig, err := serverTLSConfig(*tlsCert, *tlsKey, *clientCA)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(2)
+	}
+	api, err := newAPIGuard(*tokenFile, *clientCA != "")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	if !api.enabled() && !isLoopbackListen(*listen) {
+		fmt.Fprintln(os.Stderr, "警告: 未启用接口认证（-api-tokens / -tls-client-ca），任何能访问监听地址的人都可以静音告警、触发检测与重新加载配置")
+	}
+	// 守护模式下记录文件在退出时才关闭，会随运行时间持续增长
+	if dnsCapture, err = openCapture(*captureFile); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	defer dnsCapture.Close()
+
+	notify := newSDNotifier()
+	var daemons projectSet
+	for i, spec := range specs {
+		d, err := newDaemon(spec, configs[i], api, notify, *jitter, *splay)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+		defer d.Close()
+		if err := validateSplay(*jitter, *splay, d.groups); err != nil {
+			if d.project != "" {
+				err = fmt.Errorf("项目 %s: %w", d.project, err)
+			}
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(2)
+		}
+		daemons = append(daemons, d)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/openapi.json", daemons[0].handleOpenAPI)
+	if *projectFile == "" {
+		d := daemons[0]
+		mux.HandleFunc("/healthz", d.handleHealthz)
+		mux.HandleFunc("/readyz", d.handleReadyz)
+		d.routes(mux, "")
+	} else {
+		daemons.routes(mux, api)
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	// 请求的 context 派生自 ctx，退出时 /api/stream 等长连接随之结束
+	srv := &http.Server{Addr: *listen, Handler: mux, TLSConfig: tlsConfig, BaseContext: func(net.Listener) context.Context { return ctx }}
+
+	// SIGHUP 重新加载所有项目
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+	go func() {
+		for range hup {
+			daemons.requestReload()
+		}
+	}()
+	for _, d := range daemons {
+		go d.schedule(ctx, d.reloads)
+	}
+	go watchdog(ctx, notify, daemons.stalled)
+	go func() {
+		<-ctx.Done()
+		notify.Notify("STOPPING=1")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(shutdownCtx)
+	}()
+
+	ln, err := net.Listen("tcp", *listen)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "HTTP 服务启动失败: %v\n", err)
+		os.Exit(1)
+	}
+	if tlsConfig != nil {
+		ln = tls.NewListener(ln, tlsConfig)
+	}
+	if *projectFile == "" {
+		fmt.Printf("守护模式已启动，监听 %s，检测间隔 %s\n", *listen, describeGroups(daemons[0].groups))
+	} else {
+		fmt.Printf("守护模式已启动，监听 %s，共 %d 个项目\n", *listen, len(daemons))
+		for _, d := range daemons {
+			fmt.Printf("  %s: %s，检测间隔 %s\n", d.project, d.configPath, describeGroups(d.groups))
+		}
+	}
+	// Type=notify 时 systemd 在收到 READY=1 后才认为服务已启动，此时探针接口已可访问
+	notify.Notify("READY=1", "STATUS=等待第一轮检测完成")
+	if err := srv.Serve(ln); err != nil && err != http.ErrServerClosed {
+		fmt.Fprintf(os.Stderr, "HTTP 服务异常退出: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// newDaemon 按 spec 创建一个守护实例：创建 checker 与调度组，打开历史记录、syslog 等输出。
+// config 是已加载的 spec.Config。未按项目运行时只有一个实例，spec 取自全局参数
+func newDaemon(spec ProjectSpec, config *Config, api *apiGuard, notify *sdNotifier, jitter, splay time.Duration) (*daemon, error) {
+	// 按项目运行时错误信息带上项目 ID
+	fail := func(err error) error {
+		if spec.ID != "" {
+			err = fmt.Errorf("项目 %s: %w", spec.ID, err)
+		}
+		return err
+	}
+	sh, _ := parseShard(*shardSpec) // 已由 validateFlags 检查
+	sh.Apply(config)
+	if *redactOut {
+		redaction = newRedactor(config, *redactKey)
+	}
+
+	aliases, err := loadLLCAliases(*aliasFile)
+	if err != nil {
+		return nil, fail(err)
+	}
+	ipMap, err := loadIPMapping(*ipMapFile)
+	if err != nil {
+		return nil, fail(err)
+	}
+
+	d := &daemon{
+		checker:    newChecker(),
+		config:     config,
+		outputPath: spec.Output,
+		jitter:     jitter,
+		interval:   spec.Interval,
+		stream:     newEventHub(),
+		notify:     notify,
+		reloads:    make(chan os.Signal, 1),
+		mutes:      make(map[string]time.Time),
+		api:        api,
+		project:    spec.ID,
+		configPath: spec.Config,
+		alertPath:  spec.AlertState,
+	}
+	d.checker.aliases = aliases
+	d.checker.ipMap = ipMap
+	if d.checker.baseline, err = loadBaseline(*againstFile); err != nil {
+		return nil, fail(err)
+	}
+	if d.checker.sigs, err = loadDetectionSignatures(); err != nil {
+		return nil, fail(err)
+	}
+	if d.checker.geo, err = loadGeoDB(*geoipFile); err != nil {
+		return nil, fail(err)
+	}
+	if d.checker.routes, err = newRouteTracer(*traceMode, *traceHops); err != nil {
+		fmt.Fprintf(os.Stderr, "警告: %v\n", err)
+	}
+	if d.checker.prober, err = newReachProber(*probeMode); err != nil {
+		fmt.Fprintf(os.Stderr, "警告: %v\n", err)
+	}
+	d.checker.ports, _ = newPortScanner(*probePorts) // 已由 validateFlags 检查
+	if d.checker.threats, err = newThreatChecker(*dnsblZones, *threatFeeds); err != nil {
+		return nil, fail(err)
+	}
+	d.checker.cross, _ = newCrossChecker(*transports)
+	d.checker.useProviders(config)
+	d.checker.splay = splay
+	d.groups = newScheduleGroups(config, d.checker, spec.Interval)
+	// 守护模式下告警状态保存在内存中，指定了 -alert-state 时也会写入文件，重启后继续计数
+	if d.alerts, err = newAlertEngine(config.Alerts, spec.AlertState); err != nil {
+		return nil, fail(err)
+	}
+	if d.flaps, err = newFlapTracker(*confirmRuns, spec.FlapState); err != nil {
+		return nil, fail(err)
+	}
+	if spec.Syslog != "" {
+		if d.syslog, err = newSyslogWriter(spec.Syslog); err != nil {
+			return nil, fail(err)
+		}
+	}
+	if spec.History != "" {
+		if d.history, err = openHistoryStore(spec.History); err != nil {
+			d.Close()
+			return nil, fail(err)
+		}
+	}
+	// 各调度组的 checker 共用同一条总线，见 bus.go
+	registerOutputs(d.checker.bus, d.syslog, spec.Syslog, spec.PostRunCmd)
+	if d.history != nil {
+		d.checker.bus.Subscribe(onEvent(busRunResults, func(ev busEvent) error {
+			defer d.compactHistory(time.Now())
+			return d.history.SaveRun(ev.Run.ID, ev.Run.Started, ev.Outcome.Results)
+		}))
+	}
+	d.checker.bus.Subscribe(d.streamSubscriber())
+	return d, nil
+}
+
+// Close 关闭实例打开的 syslog 连接与历史记录
+func (d *daemon) Close() {
+	if d.syslog != nil {
+		d.syslog.Close()
+	}
+	if d.history != nil {
+		d.history.Close()
+	}
+}
+
+// routes 在 mux 中注册探针与接口文档以外的接口。prefix 为空时使用原来的路径；
+// 按项目运行时 prefix 为 /api/projects/{id}，各接口位于其下，如 /api/projects/{id}/history
+func (d *daemon) routes(mux *http.ServeMux, prefix string) {
+	path := func(single, name string) string {
+		if prefix == "" {
+			return single
+		}
+		return prefix + "/" + name
+	}
+	mux.HandleFunc(path("/metrics", "metrics"), d.guard(d.handleMetrics, scopeRead, scopeRead))
+	mux.HandleFunc(path("/availability", "availability"), d.guard(d.handleAvailability, scopeRead, scopeRead))
+	mux.HandleFunc(path("/mute", "mute"), d.guard(d.handleMute, scopeRead, scopeManage))
+	mux.HandleFunc(path("/api/history", "history"), d.guard(d.handleHistory, scopeRead, scopeRead))
+	mux.HandleFunc(path("/api/stream", "stream"), d.guard(d.handleStream, scopeRead, scopeRead))
+	mux.HandleFunc(path("/api/check", "check"), d.guard(d.handleCheck, scopeCheck, scopeCheck))
+	mux.HandleFunc(path("/api/reload", "reload"), d.guard(d.handleReload, scopeManage, scopeManage))
+}
+
+// runOnce 执行调度组 g 的一轮检测并更新状态
+func (d *daemon) runOnce(ctx context.Context, g *scheduleGroup) {
+	started := time.Now()
+	d.mu.Lock()
+	d.running++
+	g.running = true
+	g.lastStart = started
+	d.lastStart = started
+	d.mu.Unlock()
+
+	sh, _ := parseShard(*shardSpec)
+	run := runInfo{ID: newRunID(started), Meta: newRunMeta(d.config), Group: g.name, Shard: sh.String(), Started: started, Domains: len(g.config.Domains)}
+	run.Captive = detectCaptivePortal(ctx, *captiveURL)
+	bus := g.checker.bus
+	bus.Publish(busEvent{Kind: busRunStart, Run: run})
+	finishChecks, finishEgress := startInterceptionChecks(), startEgressCheck()
+	results := g.checker.Run(withRunInfo(ctx, run), g.config)
+	finishChecks(&run)
+	finishEgress(&run)
+	applyMaintenance(results, d.config.Maintenance, d.activeMutes(), time.Now())
+	if err := d.flaps.Apply(results); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+	}
+	if err := detectSuspicious(d.history, results, started); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+	}
+
+	// 各组的结果合并为各域名的最新结果，整轮告警规则、可用性与 /metrics 都基于合并后的结果
+	d.mu.Lock()
+	prev := d.lastResults
+	d.lastResults = mergeResults(prev, results)
+	latest := d.lastResults
+	d.mu.Unlock()
+
+	// run_results 的订阅者先保存历史记录，使本轮报告中的可用性包含本轮结果
+	outcome := &runOutcome{Results: results, Latest: latest, Prev: prev, Alerts: d.alerts}
+	bus.Publish(busEvent{Kind: busRunResults, Run: run, Outcome: outcome})
+	var available []domainAvailability
+	if d.history != nil {
+		var err error
+		if available, err = computeAvailability(d.history, resultDomains(latest), time.Now()); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+		}
+	}
+	// 强制门户之后的结果不可信，不评估告警，以免所有域名同时告警
+	var events []alertEvent
+	if run.Captive == "" {
+		var err error
+		if events, err = d.alerts.Evaluate(results, latest); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+		}
+	}
+	report := g.checker.renderReport(run, results, events)
+	if *format == "text" {
+		var b strings.Builder
+		writeAvailability(&b, available)
+		report += redaction.Text(b.String())
+	}
+
+	var writeErr error
+	if d.outputPath != "" {
+		var outPath, rotatePattern string
+		vars := newOutputVars(run.ID, started, results)
+		vars.Group, vars.Project, vars.Config = g.name, d.project, configName(d.configPath)
+		outPath, rotatePattern, writeErr = resolveOutputPath(d.outputPath, vars)
+		if writeErr == nil {
+			_, writeErr = writeLocalizedReports(report, outPath)
+		}
+		if writeErr == nil && rotatePattern != "" {
+			if err := rotateReports(rotatePattern, *keepReports, *keepDays, *compressOld, time.Now()); err != nil {
+				fmt.Fprintf(os.Stderr, "%v\n", err)
+			}
+		}
+	}
+	outcome.Events, outcome.Report = events, translateReport(report, reportLangs()[0])
+	bus.Publish(busEvent{Kind: busRunFinish, Run: run, Outcome: outcome})
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.running--
+	d.runs++
+	d.lastFinish = time.Now()
+	g.running = false
+	g.lastFinish = d.lastFinish
+	if available != nil {
+		d.available = available
+	}
+	if writeErr != nil {
+		d.lastError = fmt.Sprintf("写入报告文件失败: %v", writeErr)
+		fmt.Fprintf(os.Stderr, "%s\n", d.lastError)
+		return
+	}
+	d.lastError = ""
+	d.lastSuccess = d.lastFinish
+
+	fmt.Printf("[%s] 第 %d 轮检测完成%s: %d/%d 个域名被污染，耗时 %s\n",
+		d.lastFinish.Format("2006-01-02 15:04:05"), d.runs, d.label(g), countPolluted(results), len(results),
+		d.lastFinish.Sub(started).Round(time.Millisecond))
+	d.notify.Notify(fmt.Sprintf("STATUS=第 %d 轮检测完成于 %s%s: %d/%d 个域名被污染",
+		d.runs, d.lastFinish.Format("15:04:05"), d.label(g), countPolluted(results), len(results)))
+}
+
+// providerHealth 返回各 API 端点的熔断状态，以及是否至少有一个端点可用
+func (d *daemon) providerHealth() (map[string]string, bool) {
+	d.mu.RLock()
+	apis := d.checker.fetcher.apis
+	d.mu.RUnlock()
+	states := d.checker.fetcher.breakers.States(apis)
+	health := make(map[string]string, len(states))
+	anyUp := false
+	for endpoint, state := range states {
+		health[endpoint] = state.Code()
+		if state != breakerOpen {
+			anyUp = true
+		}
+	}
+	return health, anyUp
+}
+
+// statusBody 是 /healthz 与 /readyz 的响应内容
+type statusBody struct {
+	Status      string            `json:"status"`
+	Running     bool              `json:"running"`
+	Runs        int               `json:"runs"`
+	LastStart   *time.Time        `json:"last_run_start,omitempty"`
+	LastSuccess *time.Time        `json:"last_success,omitempty"`
+	LastError   string            `json:"last_error,omitempty"`
+	Providers   map[string]string `json:"providers,omitempty"`
+}
+
+func (d *daemon) status() statusBody {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	body := statusBody{Running: d.running > 0, Runs: d.runs, LastError: d.lastError}
+	if !d.lastStart.IsZero() {
+		t := d.lastStart
+		body.LastStart = &t
+	}
+	if !d.lastSuccess.IsZero() {
+		t := d.lastSuccess
+		body.LastSuccess = &t
+	}
+	return body
+}
+
+// stalled 判断调度器是否卡死：某个调度组超过两个检测间隔没有开始新一轮检测，或一轮检测持续了两个间隔以上
+func (d *daemon) stalled() bool {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	for _, g := range d.groups {
+		if g.running && time.Since(g.lastStart) > 2*g.interval {
+			return true
+		}
+		if !g.running && !g.lastFinish.IsZero() && time.Since(g.lastFinish) > 2*g.interval {
+			return true
+		}
+	}
+	return false
+}
+
+// health 返回存活探针的响应：调度器在预期时间内有运行即视为健康
+func (d *daemon) health() (statusBody, int) {
+	body := d.status()
+	body.Status = "ok"
+	if d.stalled() {
+		body.Status = "scheduler stalled"
+		return body, http.StatusServiceUnavailable
+	}
+	return body, http.StatusOK
+}
+
+// readiness 返回就绪探针的响应：至少完成过一轮成功检测，且至少有一个 API 端点未熔断
+func (d *daemon) readiness() (statusBody, int) {
+	body := d.status()
+	providers, anyUp := d.providerHealth()
+	body.Providers = providers
+
+	body.Status = "ready"
+	code := http.StatusOK
+	switch {
+	case body.LastSuccess == nil:
+		body.Status = "no successful run yet"
+		code = http.StatusServiceUnavailable
+	case !anyUp:
+		body.Status = "all providers unavailable"
+		code = http.StatusServiceUnavailable
+	}
+	return body, code
+}
+
+// handleHealthz 存活探针，见 health
+func (d *daemon) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	body, code := d.health()
+	writeJSON(w, code, body)
+}
+
+// handleReadyz 就绪探针，见 readiness
+func (d *daemon) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	body, code := d.readiness()
+	writeJSON(w, code, body)
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(code)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(v)
+}
+
+// handleMetrics 以 Prometheus 文本格式输出最近一轮的域名状态以及累积的延迟和计数指标
+func (d *daemon) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+
+	d.mu.RLock()
+	results := d.lastResults
+	runs := d.runs
+	lastSuccess := d.lastSuccess
+	available := d.available
+	alerts := d.alerts
+	d.mu.RUnlock()
+
+	fmt.Fprintln(w, "# HELP dnscheck_runs_total 已完成的检测轮数")
+	fmt.Fprintln(w, "# TYPE dnscheck_runs_total counter")
+	fmt.Fprintf(w, "dnscheck_runs_total %d\n", runs)
+	fmt.Fprintln(w, "# HELP dnscheck_last_success_timestamp_seconds 最近一次成功检测的完成时间")
+	fmt.Fprintln(w, "# TYPE dnscheck_last_success_timestamp_seconds gauge")
+	if !lastSuccess.IsZero() {
+		fmt.Fprintf(w, "dnscheck_last_success_timestamp_seconds %d\n", lastSuccess.Unix())
+	}
+
+	polluted := 0
+	fmt.Fprintln(w, "# HELP dnscheck_domain_polluted 域名在最近一轮检测中是否被判定为污染（1 为污染）")
+	fmt.Fprintln(w, "# TYPE dnscheck_domain_polluted gauge")
+	for _, res := range results {
+		v := 0
+		if res.IsPolluted {
+			v = 1
+			polluted++
+		}
+		fmt.Fprintf(w, "dnscheck_domain_polluted{domain=%q} %d\n", res.Domain, v)
+	}
+	fmt.Fprintln(w, "# HELP dnscheck_domain_polluted_debounced 域名去抖后的正式判定（1 为污染，见 -confirm）")
+	fmt.Fprintln(w, "# TYPE dnscheck_domain_polluted_debounced gauge")
+	for _, res := range results {
+		v := 0
+		if res.Debounced {
+			v = 1
+		}
+		fmt.Fprintf(w, "dnscheck_domain_polluted_debounced{domain=%q} %d\n", res.Domain, v)
+	}
+	rate := 0.0
+	if len(results) > 0 {
+		rate = float64(polluted) / float64(len(results)) * 100
+	}
+	fmt.Fprintln(w, "# HELP dnscheck_domains_total 最近一轮检测的域名总数")
+	fmt.Fprintln(w, "# TYPE dnscheck_domains_total gauge")
+	fmt.Fprintf(w, "dnscheck_domains_total %d\n", len(results))
+	fmt.Fprintln(w, "# HELP dnscheck_polluted_domains 最近一轮被污染的域名数")
+	fmt.Fprintln(w, "# TYPE dnscheck_polluted_domains gauge")
+	fmt.Fprintf(w, "dnscheck_polluted_domains %d\n", polluted)
+	fmt.Fprintln(w, "# HELP dnscheck_inconclusive_domains 最近一轮因解析或 IP 归属查询失败而无法判定的域名数")
+	fmt.Fprintln(w, "# TYPE dnscheck_inconclusive_domains gauge")
+	fmt.Fprintf(w, "dnscheck_inconclusive_domains %d\n", countFailed(results))
+	fmt.Fprintln(w, "# HELP dnscheck_rebinding_domains 最近一轮应答中含私有地址的域名数")
+	fmt.Fprintln(w, "# TYPE dnscheck_rebinding_domains gauge")
+	fmt.Fprintf(w, "dnscheck_rebinding_domains %d\n", countRebinding(results))
+	fmt.Fprintln(w, "# HELP dnscheck_pollution_rate_percent 最近一轮的污染率（百分比）")
+	fmt.Fprintln(w, "# TYPE dnscheck_pollution_rate_percent gauge")
+	fmt.Fprintf(w, "dnscheck_pollution_rate_percent %g\n", rate)
+
+	writeResolverLatencyMetrics(w, collectResolverLatency(results))
+	writeAvailabilityMetrics(w, available)
+	alerts.WriteMetrics(w)
+	d.checker.metrics.WritePrometheus(w)
+}
+
+// handleAvailability 以 JSON 返回各域名在 24h / 7d / 30d 窗口内的干净解析可用性（需要 -history）
+func (d *daemon) handleAvailability(w http.ResponseWriter, r *http.Request) {
+	if d.history == nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "未配置 -history，无法计算可用性"})
+		return
+	}
+	d.mu.RLock()
+	available := d.available
+	d.mu.RUnlock()
+	if available == nil {
+		available = []domainAvailability{}
+	}
+	writeJSON(w, http.StatusOK, available)
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"strings"
+)
+
+// ---------- 多机分片（-shard） ----------
+
+// shard 是 -shard 指定的分片，Index 从 1 开始；Total 为 0 表示不分片
+type shard struct {
+	Index int
+	Total int
+}
+
+func (s shard) String() string {
+	if s.Total == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%d/%d", s.Index, s.Total)
+}
+
+// parseShard 解析 -shard，格式为 "序号/总数"，如 3/10；为空表示不分片
+func parseShard(spec string) (shard, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		return shard{}, nil
+	}
+	parts := strings.SplitN(spec, "/", 2)
+	if len(parts) == 2 {
+		index, err1 := strconv.Atoi(strings.TrimSpace(parts[0]))
+		total, err2 := strconv.Atoi(strings.TrimSpace(parts[1]))
+		if err1 == nil && err2 == nil && total > 0 && index >= 1 && index <= total {
+			return shard{Index: index, Total: total}, nil
+		}
+	}
+	return shard{}, fmt.Errorf("无效的 -shard 参数 %q（格式为 序号/总数，如 3/10，序号从 1 开始）", spec)
+}
+
+// Apply 只保留分到本分片的域名。按规范化后域名的 FNV-1a 哈希取模，与域名在配置中的顺序无关：
+// 各机器使用同一份配置即可得到互不重叠、合起来覆盖全部域名的分片，增删域名也不会让其他域名换到别的分片
+func (s shard) Apply(cfg *Config) {
+	if s.Total <= 1 {
+		return
+	}
+	kept := cfg.Domains[:0]
+	for _, dc := range cfg.Domains {
+		h := fnv.New32a()
+		h.Write([]byte(dc.Name))
+		if int(h.Sum32()%uint32(s.Total)) == s.Index-1 {
+			kept = append(kept, dc)
+		}
+	}
+	cfg.Domains = kept
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	_ "embed"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// ---------- 特征库（-signatures 与 dnscheck update-signatures） ----------
+
+// 已知的伪造 IP、拦截页特征与拦截页 CNAME 目标随时间变化，放在带版本号的特征库中，
+// 下载新版本即可改进检测而无需重新编译。内嵌一份特征库，下载的文件版本不低于它时才使用
+
+//go:embed signatures.json
+var embeddedSignaturesJSON []byte
+
+// defaultSigFile 是 -signatures 的默认路径，文件不存在时使用内嵌的特征库
+const defaultSigFile = "signatures.json"
+
+// signatureBundle 是一个版本的特征库
+type signatureBundle struct {
+	Version   int        `json:"version"` // 单调递增，update-signatures 默认拒绝降级
+	Published string     `json:"published,omitempty"`
+	FakeIPs   []string   `json:"fake_ips"`         // 污染时注入的伪造 IP 或网段，命中即判定为污染，不查询 API
+	Sinkholes []sinkhole `json:"sinkhole_cnames"`  // 拦截页的 CNAME 目标，见 blockpage.go
+	Portals   []string   `json:"portal_domains"`   // 运营商门户、拦截页域名，与配置文件的 portal_domains 合并
+	Titles    []titleSig `json:"blockpage_titles"` // 拦截页的页面标题特征，-probe http/https 时检查
+
+	Profiles []ispProfile `json:"interference_profiles"` // 运营商干扰特征，见 interference.go
+
+	source   string       // 文件路径，内嵌特征库为空
+	fakeNets []*net.IPNet // 由 parseSignatures 根据 fake_ips 构建
+}
+
+// titleSig 是一条拦截页标题特征，标题包含 Pattern（忽略大小写）即命中
+type titleSig struct {
+	Pattern string `json:"pattern"`
+	Name    string `json:"name"`
+}
+
+// embeddedSignatures 解析内嵌的特征库，内嵌文件有误属于编译错误
+func embeddedSignatures() *signatureBundle {
+	b, err := parseSignatures(embeddedSignaturesJSON)
+	if err != nil {
+		panic("内嵌特征库有误: " + err.Error())
+	}
+	return b
+}
+
+// parseSignatures 解析并校验特征库；未知字段忽略，以便旧版本读取新格式的特征库
+func parseSignatures(data []byte) (*signatureBundle, error) {
+	var b signatureBundle
+	if err := json.Unmarshal(data, &b); err != nil {
+		return nil, fmt.Errorf("解析特征库失败: %w", err)
+	}
+	if b.Version <= 0 {
+		return nil, fmt.Errorf("特征库缺少有效的 version")
+	}
+	for _, s := range b.FakeIPs {
+		n, err := parseIPOrCIDR(s)
+		if err != nil {
+			return nil, fmt.Errorf("特征库 fake_ips: %w", err)
+		}
+		b.fakeNets = append(b.fakeNets, n)
+	}
+	for i, s := range b.Sinkholes {
+		name, err := normalizeDomainName(s.Target)
+		if err != nil {
+			return nil, fmt.Errorf("特征库 sinkhole_cnames: %w", err)
+		}
+		b.Sinkholes[i].Target = name
+	}
+	for i := range b.Profiles {
+		if err := b.Profiles[i].compile(); err != nil {
+			return nil, fmt.Errorf("特征库 interference_profiles: %w", err)
+		}
+	}
+	for _, t := range b.Titles {
+		if strings.TrimSpace(t.Pattern) == "" {
+			return nil, fmt.Errorf("特征库 blockpage_titles 中有空的 pattern")
+		}
+	}
+	return &b, nil
+}
+
+// loadSignatures 读取 -signatures 指定的特征库。默认路径的文件不存在，或文件的版本低于内嵌特征库时使用内嵌特征库
+func loadSignatures(path string) (*signatureBundle, error) {
+	embedded := embeddedSignatures()
+	if path == "" {
+		return embedded, nil
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) && path == defaultSigFile {
+		return embedded, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取特征库失败: %w", err)
+	}
+	b, err := parseSignatures(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if b.Version < embedded.Version {
+		return embedded, nil
+	}
+	b.source = path
+	return b, nil
+}
+
+func (b *signatureBundle) String() string {
+	src := "内嵌"
+	if b.source != "" {
+		src = b.source
+	}
+	return fmt.Sprintf("版本 %d（%s；伪造 IP %d 条，CNAME %d 条，门户域名 %d 条，标题 %d 条，运营商干扰特征 %d 个）",
+		b.Version, src, len(b.FakeIPs), len(b.Sinkholes), len(b.Portals), len(b.Titles), len(b.Profiles))
+}
+
+// isFakeIP 判断 ip 是否属于特征库中的伪造 IP
+func (b *signatureBundle) isFakeIP(ip net.IP) bool {
+	for _, n := range b.fakeNets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchTitle 返回命中的标题特征说明，未命中时返回空字符串
+func matchTitle(title string, sigs []titleSig) string {
+	title = strings.ToLower(title)
+	for _, t := range sigs {
+		if title != "" && strings.Contains(title, strings.ToLower(t.Pattern)) {
+			return t.Name
+		}
+	}
+	return ""
+}
+
+// runUpdateSignatures 实现 `dnscheck update-signatures -url https://.../signatures.json`：下载特征库，
+// 校验 SHA-256（-sha256 或同目录的 .sha256 文件），指定 -pubkey 时再校验 .sig 中的 Ed25519 签名，
+// 确认版本不低于现有文件后原子地写入 -o
+func runUpdateSignatures(args []string) {
+	fs := flag.NewFlagSet("update-signatures", flag.ExitOnError)
+	url := fs.String("url", "", "特征库地址")
+	output := fs.String("o", defaultSigFile, "保存路径，检测时用 -signatures 指定")
+	sum := fs.String("sha256", "", "特征库的 SHA-256（十六进制）；为空时下载 <url>.sha256")
+	pubkey := fs.String("pubkey", "", "发布者的 Ed25519 公钥（base64）；指定时下载 <url>.sig 并校验签名")
+	force := fs.Bool("force", false, "允许用较低的版本覆盖现有特征库")
+	timeout := fs.Duration("timeout", 30*time.Second, "每次下载的超时")
+	_ = fs.Parse(args)
+	if *url == "" || fs.NArg() > 0 {
+		fmt.Fprintln(os.Stderr, "用法: dnscheck update-signatures -url <地址> [-o signatures.json] [-sha256 校验和] [-pubkey 公钥] [-force]")
+		os.Exit(2)
+	}
+
+	client := &http.Client{Timeout: *timeout}
+	data, err := downloadSignatures(client, *url)
+	if err == nil {
+		err = verifySignatures(client, *url, data, *sum, *pubkey)
+	}
+	var b *signatureBundle
+	if err == nil {
+		b, err = parseSignatures(data)
+	}
+	if err == nil && !*force {
+		if old, oerr := os.ReadFile(*output); oerr == nil {
+			if cur, perr := parseSignatures(old); perr == nil && b.Version < cur.Version {
+				err = fmt.Errorf("下载的特征库版本 %d 低于现有的版本 %d（-force 强制覆盖）", b.Version, cur.Version)
+			}
+		}
+	}
+	if err == nil {
+		err = writeSignatures(*output, data)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "更新特征库失败: %v\n", err)
+		os.Exit(1)
+	}
+	b.source = *output
+	fmt.Printf("特征库已更新: %s\n", b)
+}
+
+// downloadSignatures 下载 url 的内容，大小不超过 16 MiB
+func downloadSignatures(client *http.Client, url string) ([]byte, error) {
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("下载 %s 失败: HTTP %d", url, resp.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 16<<20))
+	if err != nil {
+		return nil, fmt.Errorf("下载 %s 失败: %w", url, err)
+	}
+	return data, nil
+}
+
+// verifySignatures 校验特征库的 SHA-256 与（指定公钥时的）Ed25519 签名。
+// .sha256 文件可以是 sha256sum 的输出格式；.sig 文件为签名的 base64
+func verifySignatures(client *http.Client, url string, data []byte, sum, pubkey string) error {
+	if sum == "" {
+		body, err := downloadSignatures(client, url+".sha256")
+		if err != nil {
+			return fmt.Errorf("获取校验和失败: %w", err)
+		}
+		if fields := strings.Fields(string(body)); len(fields) > 0 {
+			sum = fields[0]
+		}
+	}
+	want, err := hex.DecodeString(strings.TrimSpace(sum))
+	if err != nil || len(want) != sha256.Size {
+		return fmt.Errorf("无效的 SHA-256 校验和 %q", sum)
+	}
+	if got := sha256.Sum256(data); !bytes.Equal(got[:], want) {
+		return fmt.Errorf("SHA-256 不匹配: 期望 %x，实际 %x", want, got)
+	}
+	if pubkey == "" {
+		return nil
+	}
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(pubkey))
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return fmt.Errorf("无效的 Ed25519 公钥")
+	}
+	body, err := downloadSignatures(client, url+".sig")
+	if err != nil {
+		return fmt.Errorf("获取签名失败: %w", err)
+	}
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(body)))
+	if err != nil || !ed25519.Verify(ed25519.PublicKey(key), data, sig) {
+		return fmt.Errorf("签名校验失败")
+	}
+	return nil
+}
+
+// writeSignatures 原子地写入特征库（先写临时文件再重命名），检测中途读取也不会读到不完整的文件
+func writeSignatures(path string, data []byte) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("写入 %s 失败: %w", path, err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("写入 %s 失败: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,296 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// ---------- 持续压测（dnscheck soak） ----------
+
+// 污染往往是概率性的（注入的应答与真实应答赛跑），单轮检测会低估它。soak 在一段时间内以固定速率
+// 反复解析配置中的域名，统计收到被注入或不符合预期应答的查询比例及其随时间的变化
+
+// soakStats 是一段时间内的查询统计
+type soakStats struct {
+	Queries  int
+	Polluted int // 应答被判定为污染（含私有地址）的查询
+	Errors   int // 解析失败或没有 IPv4 地址的查询
+}
+
+// Rate 返回有应答的查询中被污染的比例（百分比）
+func (s soakStats) Rate() float64 {
+	answered := s.Queries - s.Errors
+	if answered == 0 {
+		return 0
+	}
+	return float64(s.Polluted) / float64(answered) * 100
+}
+
+func (s *soakStats) add(res DomainResult) {
+	s.Queries++
+	switch {
+	case res.Failed || len(res.IPResults) == 0:
+		s.Errors++
+	case res.IsPolluted:
+		s.Polluted++
+	}
+}
+
+// soakDomain 是单个域名的统计；Injected 记录被污染的查询中不符合预期的 IP 及出现次数
+type soakDomain struct {
+	soakStats
+	Domain   string
+	Injected map[string]int
+}
+
+// soakBucket 是时间线上的一段
+type soakBucket struct {
+	soakStats
+	Start time.Time
+}
+
+// soakRecorder 汇总压测期间的查询结果
+type soakRecorder struct {
+	mu       sync.Mutex
+	started  time.Time
+	interval time.Duration
+	total    soakStats
+	domains  map[string]*soakDomain
+	buckets  []soakBucket
+}
+
+func newSoakRecorder(started time.Time, interval time.Duration) *soakRecorder {
+	return &soakRecorder{started: started, interval: interval, domains: make(map[string]*soakDomain)}
+}
+
+// Record 记录一次在 at 发出的查询的结果
+func (r *soakRecorder) Record(at time.Time, res DomainResult) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.total.add(res)
+	d, ok := r.domains[res.Domain]
+	if !ok {
+		d = &soakDomain{Domain: res.Domain, Injected: make(map[string]int)}
+		r.domains[res.Domain] = d
+	}
+	d.add(res)
+	if res.IsPolluted {
+		for _, ip := range res.IPResults {
+			if ip.Error == nil && !res.ipMatched(ip) {
+				d.Injected[ip.IP]++
+			}
+		}
+	}
+	i := int(at.Sub(r.started) / r.interval)
+	for len(r.buckets) <= i {
+		r.buckets = append(r.buckets, soakBucket{Start: r.started.Add(time.Duration(len(r.buckets)) * r.interval)})
+	}
+	r.buckets[i].add(res)
+}
+
+// Bucket 返回第 i 段的统计；该段还没有查询时返回零值
+func (r *soakRecorder) Bucket(i int) soakBucket {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if i < len(r.buckets) {
+		return r.buckets[i]
+	}
+	return soakBucket{Start: r.started.Add(time.Duration(i) * r.interval)}
+}
+
+// runSoak 实现 `dnscheck soak -duration 1h -qps 50`。除下列参数外接受与单次运行相同的参数
+// （-f、-api、-resolver、-profile、-against、-c 等）；-output 指定时最终报告同时写入该文件
+func runSoak(args []string) {
+	duration := flag.Duration("duration", time.Hour, "压测持续时间")
+	qps := flag.Float64("qps", 10, "每秒发出的 DNS 查询数（各域名轮流）")
+	interval := flag.Duration("interval", time.Minute, "时间线每一段的长度，每段结束时输出一行进度")
+	if err := flag.CommandLine.Parse(args); err != nil {
+		os.Exit(2)
+	}
+	if *duration <= 0 || *qps <= 0 || *interval <= 0 {
+		fmt.Fprintln(os.Stderr, "-duration、-qps 与 -interval 必须大于 0")
+		os.Exit(2)
+	}
+	config, err := loadConfigWithFallback(*configFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "加载配置文件失败: %v\n", err)
+		os.Exit(1)
+	}
+	if err := applyProfile(config, *profileName, flag.CommandLine); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(2)
+	}
+	if err := validateFlags(); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(2)
+	}
+	if len(config.Domains) == 0 {
+		fmt.Fprintln(os.Stderr, "配置中没有域名")
+		os.Exit(1)
+	}
+	c := newChecker()
+	if c.aliases, err = loadLLCAliases(*aliasFile); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	if c.ipMap, err = loadIPMapping(*ipMapFile); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	if c.baseline, err = loadBaseline(*againstFile); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	if c.sigs, err = loadDetectionSignatures(); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	if c.geo, err = loadGeoDB(*geoipFile); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	c.useProviders(config)
+	c.stats = newRunStats()
+	c.fetcher.stats = c.stats
+
+	// Ctrl-C 提前结束，仍然输出已收集的统计
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	ctx, cancel := context.WithTimeout(ctx, *duration)
+	defer cancel()
+
+	started := time.Now()
+	rec := newSoakRecorder(started, *interval)
+	fmt.Printf("开始压测: %d 个域名，%.1f 次/秒，持续 %s（Ctrl-C 提前结束）\n", len(config.Domains), *qps, *duration)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		soakLoop(ctx, c, config.Domains, *qps, rec)
+	}()
+	for i := 0; ; i++ {
+		select {
+		case <-done:
+			report := rec.Report(time.Now())
+			fmt.Print(report)
+			if *outputFile != "" {
+				if err := writeReportToFile(report, *outputFile); err != nil {
+					fmt.Fprintf(os.Stderr, "%v\n", err)
+					os.Exit(1)
+				}
+				fmt.Printf("报告已保存至: %s\n", *outputFile)
+			}
+			return
+		case <-time.After(time.Until(started.Add(time.Duration(i+1) * *interval))):
+			b := rec.Bucket(i)
+			fmt.Printf("[%s] 查询 %d 次，污染 %d（%.2f%%），失败 %d\n", b.Start.Local().Format("15:04:05"), b.Queries, b.Polluted, b.Rate(), b.Errors)
+		}
+	}
+}
+
+// soakLoop 以 qps 的速率轮流检测各域名，直到 ctx 结束；进行中的查询数超过一秒的配额时暂停发出新查询，
+// 实际速率因此可能低于 qps（报告中给出实际速率）
+func soakLoop(ctx context.Context, c *checker, domains []DomainConfig, qps float64, rec *soakRecorder) {
+	tick := time.NewTicker(time.Duration(float64(time.Second) / qps))
+	defer tick.Stop()
+	slots := make(chan struct{}, int(math.Ceil(qps)))
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	for i := 0; ; i++ {
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick.C:
+		}
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+			return
+		}
+		wg.Add(1)
+		go func(dc DomainConfig) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			// 进行中的查询不受压测结束的影响，以免把超时计为失败；按发出时间计入时间线
+			sent := time.Now()
+			res := c.checkDomain(context.Background(), dc)
+			c.baseline.Apply(&res)
+			rec.Record(sent, res)
+		}(domains[i%len(domains)])
+	}
+}
+
+// Report 生成压测报告：总体与各域名的污染比例、注入的 IP 以及时间线
+func (r *soakRecorder) Report(now time.Time) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var b strings.Builder
+	elapsed := now.Sub(r.started)
+	b.WriteString("\nDNS 污染压测报告\n=================\n")
+	b.WriteString(fmt.Sprintf("开始时间: %s，持续 %s\n", r.started.Local().Format("2006-01-02 15:04:05"), elapsed.Round(time.Second)))
+	qps := 0.0
+	if elapsed > 0 {
+		qps = float64(r.total.Queries) / elapsed.Seconds()
+	}
+	b.WriteString(fmt.Sprintf("查询 %d 次（实际 %.1f 次/秒），污染 %d 次（%.2f%%），失败 %d 次\n", r.total.Queries, qps, r.total.Polluted, r.total.Rate(), r.total.Errors))
+	b.WriteString("=================\n\n")
+
+	domains := make([]*soakDomain, 0, len(r.domains))
+	for _, d := range r.domains {
+		domains = append(domains, d)
+	}
+	sort.Slice(domains, func(i, j int) bool {
+		if ri, rj := domains[i].Rate(), domains[j].Rate(); ri != rj {
+			return ri > rj
+		}
+		return domains[i].Domain < domains[j].Domain
+	})
+	b.WriteString("各域名污染比例:\n")
+	for _, d := range domains {
+		b.WriteString(fmt.Sprintf("  %7.2f%%  %d/%d  %s", d.Rate(), d.Polluted, d.Queries-d.Errors, d.Domain))
+		if d.Errors > 0 {
+			b.WriteString(fmt.Sprintf("（失败 %d 次）", d.Errors))
+		}
+		b.WriteString("\n")
+		if len(d.Injected) > 0 {
+			b.WriteString("           注入的 IP: " + soakTopIPs(d.Injected, 5) + "\n")
+		}
+	}
+
+	b.WriteString("\n时间线:\n")
+	for _, bk := range r.buckets {
+		b.WriteString(fmt.Sprintf("  %s  查询 %5d  污染 %5d（%6.2f%%）  失败 %d\n", bk.Start.Local().Format("15:04:05"), bk.Queries, bk.Polluted, bk.Rate(), bk.Errors))
+	}
+	return b.String()
+}
+
+// soakTopIPs 返回出现次数最多的 n 个 IP，如 "203.0.113.7 ×12, 198.51.100.1 ×3"
+func soakTopIPs(counts map[string]int, n int) string {
+	ips := make([]string, 0, len(counts))
+	for ip := range counts {
+		ips = append(ips, ip)
+	}
+	sort.Slice(ips, func(i, j int) bool {
+		if counts[ips[i]] != counts[ips[j]] {
+			return counts[ips[i]] > counts[ips[j]]
+		}
+		return ips[i] < ips[j]
+	})
+	parts := make([]string, 0, n)
+	for i, ip := range ips {
+		if i == n {
+			parts = append(parts, fmt.Sprintf("等 %d 个", len(ips)))
+			break
+		}
+		parts = append(parts, fmt.Sprintf("%s ×%d", ip, counts[ip]))
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// ---------- 0x20 大小写随机化与报文一致性检查 ----------
+
+// spoofProbeRounds 是每个解析器发送的查询次数，每次使用新的本地端口和随机 ID
+const spoofProbeRounds = 3
+
+// spoofProbeResult 汇总对单个解析器的多次探测
+type spoofProbeResult struct {
+	Server      string
+	Sent        int
+	Answered    int
+	CaseMangled int // 问题部分未原样回显随机化大小写的响应数
+	IDMismatch  int // 来源正确但 ID 不匹配的报文数
+	WrongSource int // 发往本地端口、但来源地址或端口不是解析器的报文数
+}
+
+// Suspicious 报告是否出现了中间设备伪造应答的典型特征
+func (r spoofProbeResult) Suspicious() bool {
+	return r.CaseMangled > 0 || r.IDMismatch > 0 || r.WrongSource > 0
+}
+
+func (r spoofProbeResult) String() string {
+	var issues []string
+	if r.CaseMangled > 0 {
+		issues = append(issues, fmt.Sprintf("%d 个响应未回显查询名的大小写", r.CaseMangled))
+	}
+	if r.IDMismatch > 0 {
+		issues = append(issues, fmt.Sprintf("%d 个报文 ID 不匹配", r.IDMismatch))
+	}
+	if r.WrongSource > 0 {
+		issues = append(issues, fmt.Sprintf("%d 个报文来自其他地址或端口", r.WrongSource))
+	}
+	s := fmt.Sprintf("%s: %d/%d 个查询有响应", r.Server, r.Answered, r.Sent)
+	if len(issues) > 0 {
+		s += "，" + strings.Join(issues, "，")
+	}
+	return s
+}
+
+// randomizeCase 按 0x20 编码随机翻转查询名中字母的大小写
+func randomizeCase(name string) string {
+	b := []byte(name)
+	var rnd [64]byte
+	for i := range b {
+		if i%len(rnd) == 0 {
+			fillRandom(rnd[:])
+		}
+		c := b[i]
+		if ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') {
+			if rnd[i%len(rnd)]&1 == 1 {
+				b[i] = c ^ 0x20
+			} else {
+				b[i] = c | 0x20
+			}
+		}
+	}
+	return string(b)
+}
+
+// probeSpoofing 向解析器发送若干个大小写随机化的查询，逐个检查收到的报文：
+// 来源地址和端口、ID 以及问题部分是否原样回显查询名。
+// 正常的解析器会保持查询名的大小写不变，中间设备伪造的应答往往将其改写为小写或不回显问题部分。
+func probeSpoofing(server, name string, timeout time.Duration) spoofProbeResult {
+	res := spoofProbeResult{Server: server}
+	raddr, err := net.ResolveUDPAddr("udp", server)
+	if err != nil {
+		return res
+	}
+	for i := 0; i < spoofProbeRounds; i++ {
+		qname := randomizeCase(dnsFQDN(name))
+		query, err := newDNSQuery(qname, dnsmessage.TypeA)
+		if err != nil {
+			return res
+		}
+		packed, err := query.Pack()
+		if err != nil {
+			return res
+		}
+		res.Sent++
+		probeOnce(&res, raddr, packed, query.Header.ID, qname, timeout)
+	}
+	return res
+}
+
+// probeOnce 从新的本地端口发送一个查询，并在超时前检查所有收到的报文
+func probeOnce(res *spoofProbeResult, raddr *net.UDPAddr, packed []byte, id uint16, qname string, timeout time.Duration) {
+	conn, err := net.ListenUDP("udp", nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	if err := conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return
+	}
+	if _, err := conn.WriteToUDP(packed, raddr); err != nil {
+		return
+	}
+	dnsCapture.Record("udp", conn.LocalAddr(), raddr, true, packed)
+
+	buf := make([]byte, 65535)
+	for {
+		n, from, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			return
+		}
+		dnsCapture.Record("udp", conn.LocalAddr(), from, false, append([]byte(nil), buf[:n]...))
+		if !from.IP.Equal(raddr.IP) || from.Port != raddr.Port {
+			res.WrongSource++
+			continue
+		}
+		var resp dnsmessage.Message
+		if err := resp.Unpack(buf[:n]); err != nil || !resp.Header.Response {
+			continue
+		}
+		if resp.Header.ID != id {
+			res.IDMismatch++
+			continue
+		}
+		res.Answered++
+		if len(resp.Questions) != 1 || resp.Questions[0].Name.String() != qname {
+			res.CaseMangled++
+		}
+		return
+	}
+}
+
+// checkSpoofing 对系统解析器和公共 DNS 执行 0x20 与报文一致性检查
+func (d *doctor) checkSpoofing(timeout time.Duration) {
+	servers := append([]string{}, doctorPublicResolvers...)
+	for _, ns := range systemNameservers() {
+		servers = append(servers, net.JoinHostPort(ns, "53"))
+	}
+	for _, server := range servers {
+		r := probeSpoofing(server, "www.example.com", timeout)
+		switch {
+		case r.Suspicious():
+			d.report(doctorFail, "%s，疑似存在伪造应答的中间设备", r)
+		case r.Answered == 0:
+			d.report(doctorWarn, "%s", r)
+		default:
+			d.report(doctorOK, "%s，大小写、ID 与来源端口均一致", r)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ---------- 单轮运行统计 ----------
+
+// runStats 统计一轮检测中的请求数与耗时，写在报告末尾；与 checkMetrics 不同，每轮重新计数。nil 表示不统计
+type runStats struct {
+	mu         sync.Mutex
+	started    time.Time
+	finished   time.Time
+	dnsQueries int
+	apiCalls   int
+	cacheHits  int
+	coalesced  int // 与进行中的相同查询合并、未单独请求 API 的次数
+	retries    int
+	rateWait   time.Duration // 各请求在限速器上等待的累计时间
+	timedOut   int           // 超出 -domain-budget 或 -run-deadline 的域名数
+}
+
+func newRunStats() *runStats {
+	return &runStats{started: time.Now()}
+}
+
+// AddDNS 记录 n 次 DNS 查询（原始解析模式下一个域名可能对应多次查询）
+func (s *runStats) AddDNS(n int) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.dnsQueries += n
+}
+
+// IncAPI 记录一次 API 请求（包括重试）
+func (s *runStats) IncAPI() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.apiCalls++
+}
+
+// IncCacheHit 记录一次缓存命中
+func (s *runStats) IncCacheHit() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cacheHits++
+}
+
+// IncCoalesced 记录一次与进行中的相同查询合并的 API 查询
+func (s *runStats) IncCoalesced() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.coalesced++
+}
+
+// IncRetry 记录一次 API 重试
+func (s *runStats) IncRetry() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.retries++
+}
+
+// IncTimeout 记录一个超出检测时限的域名
+func (s *runStats) IncTimeout() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.timedOut++
+}
+
+// AddWait 累计一次限速等待的时间
+func (s *runStats) AddWait(d time.Duration) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rateWait += d
+}
+
+// Finish 记录本轮结束时间
+func (s *runStats) Finish() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.finished = time.Now()
+}
+
+// Summary 生成报告中的运行统计段落；有效速率为 API 请求数除以整轮耗时
+func (s *runStats) Summary() string {
+	if s == nil {
+		return ""
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	end := s.finished
+	if end.IsZero() {
+		end = time.Now()
+	}
+	elapsed := end.Sub(s.started)
+	effective := 0.0
+	if elapsed > 0 {
+		effective = float64(s.apiCalls) / elapsed.Seconds()
+	}
+	var b strings.Builder
+	b.WriteString("运行统计:\n")
+	b.WriteString(fmt.Sprintf("  耗时: %s\n", elapsed.Round(time.Millisecond)))
+	b.WriteString(fmt.Sprintf("  DNS 查询: %d 次\n", s.dnsQueries))
+	b.WriteString(fmt.Sprintf("  API 请求: %d 次（其中重试 %d 次），有效速率 %.2f rps\n", s.apiCalls, s.retries, effective))
+	b.WriteString(fmt.Sprintf("  缓存命中: %d 次\n", s.cacheHits))
+	if s.coalesced > 0 {
+		b.WriteString(fmt.Sprintf("  合并查询: %d 次（与其他域名同时查询同一 IP，共享一次请求的结果）\n", s.coalesced))
+	}
+	b.WriteString(fmt.Sprintf("  限速等待: %s（各请求累计）\n", s.rateWait.Round(time.Millisecond)))
+	if s.timedOut > 0 {
+		b.WriteString(fmt.Sprintf("  检测超时: %d 个域名\n", s.timedOut))
+	}
+	return b.String()
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ---------- 实时结果推送（守护模式 /api/stream，Server-Sent Events） ----------
+
+// streamEvent 是推送给订阅者的一条事件，Type 对应 SSE 的 event 字段
+type streamEvent struct {
+	Type string
+	Data interface{}
+}
+
+// 推送的事件类型
+const (
+	streamRunStart  = "run_start"
+	streamVerdict   = "verdict"
+	streamAlert     = "alert"
+	streamRunFinish = "run_finish"
+)
+
+// streamRunJSON 是 run_start 与 run_finish 事件的数据
+type streamRunJSON struct {
+	RunID      string    `json:"run_id"`
+	Group      string    `json:"group,omitempty"`
+	At         time.Time `json:"at"`
+	Domains    int       `json:"domains"`
+	Polluted   *int      `json:"polluted,omitempty"` // 只在 run_finish 中出现
+	DurationMs float64   `json:"duration_ms,omitempty"`
+}
+
+// streamVerdictJSON 是 verdict 事件的数据，每个域名检测完成时推送一条
+type streamVerdictJSON struct {
+	RunID    string    `json:"run_id"`
+	Group    string    `json:"group,omitempty"`
+	At       time.Time `json:"at"`
+	Domain   string    `json:"domain"`
+	Verdict  string    `json:"verdict"` // clean、polluted 或 rebinding，与 syslog 相同
+	Polluted bool      `json:"polluted"`
+	Summary  string    `json:"summary"`
+	Muted    string    `json:"muted,omitempty"`
+}
+
+// 订阅者的缓冲区大小；订阅者读取过慢、缓冲区已满时丢弃新事件，不阻塞检测
+const streamBuffer = 256
+
+// eventHub 向所有订阅者广播事件。nil 表示不推送
+type eventHub struct {
+	mu   sync.Mutex
+	subs map[chan streamEvent]struct{}
+}
+
+func newEventHub() *eventHub {
+	return &eventHub{subs: make(map[chan streamEvent]struct{})}
+}
+
+// Subscribe 注册一个订阅者，返回事件通道与取消订阅的函数
+func (h *eventHub) Subscribe() (<-chan streamEvent, func()) {
+	ch := make(chan streamEvent, streamBuffer)
+	h.mu.Lock()
+	h.subs[ch] = struct{}{}
+	h.mu.Unlock()
+	return ch, func() {
+		h.mu.Lock()
+		delete(h.subs, ch)
+		h.mu.Unlock()
+	}
+}
+
+// Publish 以非阻塞方式向所有订阅者发送事件
+func (h *eventHub) Publish(typ string, data interface{}) {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch := range h.subs {
+		select {
+		case ch <- streamEvent{Type: typ, Data: data}:
+		default:
+		}
+	}
+}
+
+// streamSubscriber 把总线上的轮次、判定与告警事件转发给 /api/stream 的订阅者
+func (d *daemon) streamSubscriber() subscriber {
+	return subscriberFunc(func(ev busEvent) error {
+		switch ev.Kind {
+		case busRunStart:
+			d.stream.Publish(streamRunStart, streamRunJSON{RunID: ev.Run.ID, Group: ev.Run.Group, At: ev.At, Domains: ev.Run.Domains})
+		case busVerdict:
+			d.publishVerdict(ev)
+		case busRunFinish:
+			for _, a := range ev.Outcome.Events {
+				d.stream.Publish(streamAlert, a)
+			}
+			polluted := countPolluted(ev.Outcome.Results)
+			d.stream.Publish(streamRunFinish, streamRunJSON{
+				RunID:      ev.Run.ID,
+				Group:      ev.Run.Group,
+				At:         ev.At,
+				Domains:    len(ev.Outcome.Results),
+				Polluted:   &polluted,
+				DurationMs: float64(ev.At.Sub(ev.Run.Started)) / float64(time.Millisecond),
+			})
+		}
+		return nil
+	})
+}
+
+// publishVerdict 在每个域名检测完成时推送 verdict 事件
+func (d *daemon) publishVerdict(ev busEvent) {
+	res := *ev.Result
+	// 维护窗口与静音在整轮结束后才会写入结果，这里先单独判断一次
+	one := []DomainResult{res}
+	applyMaintenance(one, d.config.Maintenance, d.activeMutes(), ev.At)
+	d.stream.Publish(streamVerdict, streamVerdictJSON{
+		RunID:    ev.Run.ID,
+		Group:    ev.Run.Group,
+		At:       ev.At,
+		Domain:   res.Domain,
+		Verdict:  verdictOf(res),
+		Polluted: res.IsPolluted,
+		Summary:  res.Summary,
+		Muted:    one[0].Muted,
+	})
+}
+
+// handleStream 以 Server-Sent Events 推送检测过程中的事件，连接保持到客户端断开或守护进程退出
+func (d *daemon) handleStream(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "当前连接不支持流式响应"})
+		return
+	}
+	events, cancel := d.stream.Subscribe()
+	defer cancel()
+
+	w.Header().Set("Content-Type", "text/event-stream; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no") // 关闭 nginx 等反向代理的缓冲
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, ": connected\n\n")
+	flusher.Flush()
+
+	// 定期发送注释行，避免空闲连接被代理关闭
+	heartbeat := time.NewTicker(30 * time.Second)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": ping\n\n")
+		case ev := <-events:
+			data, err := json.Marshal(ev.Data)
+			if err != nil {
+				continue
+			}
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", ev.Type, redaction.Text(string(data)))
+		}
+		flusher.Flush()
+	}
+}
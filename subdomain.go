@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ---------- 子域名展开（subdomains）与通配条目（*.example.com） ----------
+
+// maxSubdomains 是单个域名条目最多展开的主机名数，防止误用超大词表
+const maxSubdomains = 1000
+
+// expandSubdomains 将带 subdomains 的域名条目与 *.example.com 形式的通配条目展开为独立的条目：
+// 每个主机名继承原条目的全部预期与覆盖设置，"*" 表示读取 subdomain_wordlist 词表。
+// 配置中已单独列出的主机名以单独的条目为准，不重复展开。返回发现的问题，行号为原条目的行号
+func expandSubdomains(cfg *Config) []string {
+	var problems []string
+	problem := func(line int, format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf("第 %d 行: %s", line, fmt.Sprintf(format, args...)))
+	}
+	explicit := make(map[string]bool)
+	for _, dc := range cfg.Domains {
+		if name, err := normalizeDomainName(dc.Name); err == nil {
+			explicit[name] = true
+		}
+	}
+
+	var expanded []DomainConfig
+	for _, dc := range cfg.Domains {
+		name := strings.ToLower(strings.TrimSuffix(strings.TrimSpace(dc.Name), "."))
+		wildcard := strings.HasPrefix(name, "*.")
+		if !wildcard {
+			expanded = append(expanded, dc)
+			if len(dc.Hosts) > 0 || dc.HostsFile != "" {
+				problem(dc.Line, "hosts 与 hosts_file 只能用于 *.example.com 形式的通配条目")
+				continue
+			}
+			if len(dc.Subdomains) == 0 {
+				continue
+			}
+		}
+		base := strings.TrimPrefix(name, "*.")
+		if strings.Contains(base, "*") {
+			problem(dc.Line, "通配条目只支持最左侧的 *，如 *.example.com")
+			continue
+		}
+		labels, err := subdomainLabels(dc)
+		if err != nil {
+			problem(dc.Line, "%v", err)
+			continue
+		}
+		var hosts []string
+		for _, label := range labels {
+			hosts = append(hosts, label+"."+base)
+		}
+		if wildcard {
+			matched, err := wildcardHosts(dc, base)
+			if err != nil {
+				problem(dc.Line, "%v", err)
+				continue
+			}
+			hosts = append(hosts, matched...)
+			if len(hosts) == 0 {
+				problem(dc.Line, "通配条目 %s 没有匹配的主机，请用 hosts、hosts_file 或 subdomains 指定", dc.Name)
+				continue
+			}
+		}
+		if len(hosts) > maxSubdomains {
+			problem(dc.Line, "%s 展开后有 %d 个主机名，超过上限 %d", dc.Name, len(hosts), maxSubdomains)
+			continue
+		}
+		for _, host := range hosts {
+			sub := dc
+			sub.Name = host
+			sub.Subdomains, sub.Wordlist, sub.Hosts, sub.HostsFile = nil, "", nil, ""
+			if name, err := normalizeDomainName(host); err == nil {
+				if explicit[name] {
+					continue
+				}
+				explicit[name] = true
+			}
+			expanded = append(expanded, sub)
+		}
+	}
+	cfg.Domains = expanded
+	return problems
+}
+
+// wildcardHosts 返回通配条目匹配的主机名：hosts 中的主机名必须属于 base，
+// hosts_file（如从资产清单或证书透明日志导出的主机列表）中不属于 base 的主机名直接忽略
+func wildcardHosts(dc DomainConfig, base string) ([]string, error) {
+	var hosts []string
+	for _, h := range dc.Hosts {
+		name, err := normalizeDomainName(h)
+		if err != nil {
+			return nil, fmt.Errorf("hosts: %v", err)
+		}
+		if !strings.HasSuffix(name, "."+base) {
+			return nil, fmt.Errorf("hosts 中的 %s 不匹配 %s", name, dc.Name)
+		}
+		hosts = append(hosts, name)
+	}
+	if dc.HostsFile != "" {
+		lines, err := readWordlist(dc.HostsFile)
+		if err != nil {
+			return nil, err
+		}
+		for _, h := range lines {
+			if name, err := normalizeDomainName(h); err == nil && strings.HasSuffix(name, "."+base) {
+				hosts = append(hosts, name)
+			}
+		}
+	}
+	return hosts, nil
+}
+
+// subdomainLabels 返回条目要展开的子域名标签（去重、保持顺序），"*" 替换为词表内容
+func subdomainLabels(dc DomainConfig) ([]string, error) {
+	var labels []string
+	seen := make(map[string]bool)
+	add := func(label string) error {
+		label = strings.ToLower(strings.TrimSpace(label))
+		if label == "" || seen[label] {
+			return nil
+		}
+		if strings.HasPrefix(label, ".") || strings.HasSuffix(label, ".") {
+			return fmt.Errorf("subdomains 中的 %q 不是合法的子域名标签", label)
+		}
+		seen[label] = true
+		labels = append(labels, label)
+		return nil
+	}
+	for _, s := range dc.Subdomains {
+		if strings.TrimSpace(s) != "*" {
+			if err := add(s); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if dc.Wordlist == "" {
+			return nil, fmt.Errorf("subdomains 使用 \"*\" 时必须指定 subdomain_wordlist")
+		}
+		words, err := readWordlist(dc.Wordlist)
+		if err != nil {
+			return nil, err
+		}
+		for _, w := range words {
+			if err := add(w); err != nil {
+				return nil, fmt.Errorf("%s: %v", dc.Wordlist, err)
+			}
+		}
+	}
+	return labels, nil
+}
+
+// readWordlist 读取子域名词表或主机列表：每行一项，忽略空行和 # 开头的注释
+func readWordlist(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取 %s 失败: %w", path, err)
+	}
+	var words []string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		words = append(words, line)
+	}
+	return words, scanner.Err()
+}
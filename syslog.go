@@ -0,0 +1,250 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ---------- Syslog 输出（RFC 5424） ----------
+
+const (
+	syslogFacility = 16 // local0
+	syslogSDID     = "dnscheck@32473"
+	sevWarning     = 4
+	sevNotice      = 5
+	sevInfo        = 6
+)
+
+// syslogWriter 以 RFC 5424 格式发送事件。支持本地 syslog（unix socket）
+// 以及远程 udp:// 和 tcp://（TCP 使用 RFC 6587 的 octet-counting 分帧）。
+// 不依赖 log/syslog，以便在 Windows 上也能向远程服务器发送。
+type syslogWriter struct {
+	network  string
+	addr     string
+	hostname string
+	conn     net.Conn
+}
+
+// newSyslogWriter 解析目标地址：local、udp://host:port 或 tcp://host:port
+func newSyslogWriter(target string) (*syslogWriter, error) {
+	w := &syslogWriter{}
+	switch {
+	case target == "local":
+		w.network, w.addr = "unixgram", "/dev/log"
+		if runtime.GOOS == "darwin" {
+			w.addr = "/var/run/syslog"
+		}
+	case strings.HasPrefix(target, "udp://"):
+		w.network, w.addr = "udp", strings.TrimPrefix(target, "udp://")
+	case strings.HasPrefix(target, "tcp://"):
+		w.network, w.addr = "tcp", strings.TrimPrefix(target, "tcp://")
+	default:
+		return nil, fmt.Errorf("无效的 syslog 地址 %q（应为 local、udp://host:port 或 tcp://host:port）", target)
+	}
+	if w.network != "unixgram" {
+		if _, _, err := net.SplitHostPort(w.addr); err != nil {
+			w.addr = net.JoinHostPort(w.addr, "514")
+		}
+	}
+	w.hostname, _ = os.Hostname()
+	if w.hostname == "" {
+		w.hostname = "-"
+	}
+	return w, nil
+}
+
+// Send 发送一条消息；连接断开时重连一次
+func (w *syslogWriter) Send(severity int, msgID string, sd map[string]string, msg string) error {
+	line := w.format(severity, msgID, sd, msg)
+	if w.network == "tcp" {
+		line = fmt.Sprintf("%d %s", len(line), line)
+	}
+	for attempt := 0; attempt < 2; attempt++ {
+		if w.conn == nil {
+			conn, err := net.DialTimeout(w.network, w.addr, 5*time.Second)
+			if err != nil {
+				return fmt.Errorf("连接 syslog %s 失败: %w", w.addr, err)
+			}
+			w.conn = conn
+		}
+		if _, err := w.conn.Write([]byte(line)); err != nil {
+			w.conn.Close()
+			w.conn = nil
+			if attempt == 1 {
+				return fmt.Errorf("发送 syslog 消息失败: %w", err)
+			}
+			continue
+		}
+		return nil
+	}
+	return nil
+}
+
+// Close 关闭底层连接
+func (w *syslogWriter) Close() error {
+	if w.conn == nil {
+		return nil
+	}
+	err := w.conn.Close()
+	w.conn = nil
+	return err
+}
+
+// format 生成 RFC 5424 消息：<PRI>1 TIMESTAMP HOST APP PROCID MSGID [SD] MSG。-redact 时主机名、
+// 结构化数据与消息正文都经过脱敏
+func (w *syslogWriter) format(severity int, msgID string, sd map[string]string, msg string) string {
+	var sdb strings.Builder
+	sdb.WriteString("[" + syslogSDID)
+	for _, k := range sortedStringKeys(sd) {
+		v := redaction.Text(sd[k])
+		// RFC 5424 要求转义 '"'、'\' 和 ']'
+		v = strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`).Replace(v)
+		sdb.WriteString(fmt.Sprintf(` %s="%s"`, k, v))
+	}
+	sdb.WriteString("]")
+	return fmt.Sprintf("<%d>1 %s %s dnscheck %d %s %s \xef\xbb\xbf%s",
+		syslogFacility*8+severity, time.Now().Format(time.RFC3339Nano), w.sender(),
+		os.Getpid(), msgID, sdb.String(), redaction.Text(msg))
+}
+
+// sender 返回消息头中的主机名
+func (w *syslogWriter) sender() string {
+	if w.hostname == "-" {
+		return w.hostname
+	}
+	return redaction.Host(w.hostname)
+}
+
+// 域名的判定结果
+const (
+	verdictClean     = "clean"
+	verdictPolluted  = "polluted"
+	verdictRebinding = "rebinding" // 污染的一种：应答中含私有地址
+	verdictError     = "error"     // 解析或 IP 归属查询失败，无法判定
+)
+
+// verdictOf 返回事件中使用的判定结果：clean、polluted、rebinding 或 error。
+// 其他检查判定为污染时，即使部分查询失败也按污染处理
+func verdictOf(r DomainResult) string {
+	switch {
+	case r.Rebinding:
+		return verdictRebinding
+	case r.IsPolluted:
+		return verdictPolluted
+	case r.Failed:
+		return verdictError
+	}
+	return verdictClean
+}
+
+// sendVerdictEvents 为每个域名发送一条判定事件；prev 非空时，
+// 对正式判定（见 flap.go）与上一轮不同的域名额外发送一条变化事件
+func sendVerdictEvents(w *syslogWriter, results, prev []DomainResult) error {
+	previous := make(map[string]bool, len(prev))
+	for _, r := range prev {
+		previous[r.Domain] = r.Debounced
+	}
+	for _, r := range results {
+		sev, verdict := sevInfo, verdictOf(r)
+		switch verdict {
+		case verdictPolluted, verdictRebinding:
+			sev = sevWarning
+		case verdictError:
+			sev = sevNotice
+		}
+		sd := map[string]string{"domain": r.Domain, "verdict": verdict}
+		if err := w.Send(sev, "verdict", sd, fmt.Sprintf("%s: %s", r.Domain, r.Summary)); err != nil {
+			return err
+		}
+		if was, ok := previous[r.Domain]; ok && was != r.Debounced {
+			from := "clean"
+			if was {
+				from = "polluted"
+			}
+			sd["previous"] = from
+			msg := fmt.Sprintf("%s: 判定由 %s 变为 %s", r.Domain, from, verdict)
+			if err := w.Send(sevNotice, "change", sd, msg); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// sendAlertEvents 为每个告警状态变化发送一条事件（配置了 alerts 时代替逐域名的判定事件）
+func sendAlertEvents(w *syslogWriter, events []alertEvent) error {
+	for _, ev := range events {
+		sev := sevWarning
+		if ev.Status == alertResolved {
+			sev = sevNotice
+		}
+		sd := map[string]string{"rule": ev.Rule, "status": ev.Status}
+		if ev.Domain != "" {
+			sd["domain"] = ev.Domain
+		}
+		if err := w.Send(sev, "alert", sd, ev.Message); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func sortedStringKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// syslogOutput 是总线上的 syslog 订阅者：配置了 alerts 时发送告警事件，否则发送逐域名的判定事件。
+// w 为空时（单次运行）每轮按 addr 建立连接
+type syslogOutput struct {
+	addr string
+	w    *syslogWriter
+}
+
+func (o syslogOutput) Handle(ev busEvent) error {
+	if ev.Kind != busRunFinish {
+		return nil
+	}
+	out := ev.Outcome
+	switch {
+	case o.w != nil && out.Alerts != nil:
+		return sendAlertEvents(o.w, out.Events)
+	case o.w != nil:
+		return sendVerdictEvents(o.w, unmuted(out.Results), out.Prev)
+	case out.Alerts != nil:
+		return sendAlertsToSyslog(o.addr, out.Events)
+	}
+	return sendVerdictsToSyslog(o.addr, unmuted(out.Results))
+}
+
+// sendVerdictsToSyslog 是单次运行使用的便捷函数：建立连接、发送判定事件后关闭
+func sendVerdictsToSyslog(target string, results []DomainResult) error {
+	w, err := newSyslogWriter(target)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	return sendVerdictEvents(w, results, nil)
+}
+
+// sendAlertsToSyslog 是单次运行使用的便捷函数：有告警状态变化时建立连接并发送
+func sendAlertsToSyslog(target string, events []alertEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+	w, err := newSyslogWriter(target)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	return sendAlertEvents(w, events)
+}
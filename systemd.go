@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ---------- systemd 集成（Type=notify 与看门狗） ----------
+
+// sdNotifier 按 sd_notify(3) 的协议向 NOTIFY_SOCKET 发送状态。不依赖 libsystemd；
+// 不在 systemd 下运行（没有 NOTIFY_SOCKET）时为 nil，各方法什么也不做
+type sdNotifier struct {
+	addr     *net.UnixAddr
+	watchdog time.Duration // WATCHDOG_USEC，未启用看门狗时为 0
+}
+
+// newSDNotifier 读取 systemd 设置的 NOTIFY_SOCKET 与 WATCHDOG_USEC / WATCHDOG_PID
+func newSDNotifier() *sdNotifier {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return nil
+	}
+	if strings.HasPrefix(socket, "@") {
+		socket = "\x00" + socket[1:] // 抽象命名空间
+	}
+	n := &sdNotifier{addr: &net.UnixAddr{Name: socket, Net: "unixgram"}}
+	// WATCHDOG_PID 不是本进程时（如由包装脚本启动），看门狗不属于本进程
+	if pid := os.Getenv("WATCHDOG_PID"); pid == "" || pid == strconv.Itoa(os.Getpid()) {
+		if usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64); err == nil && usec > 0 {
+			n.watchdog = time.Duration(usec) * time.Microsecond
+		}
+	}
+	return n
+}
+
+// Notify 发送一条或多条状态，如 "READY=1"、"STOPPING=1"；失败时只输出警告
+func (n *sdNotifier) Notify(states ...string) {
+	if n == nil {
+		return
+	}
+	conn, err := net.DialUnix("unixgram", nil, n.addr)
+	if err == nil {
+		_, err = conn.Write([]byte(strings.Join(states, "\n")))
+		conn.Close()
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "警告: 向 systemd 发送 %s 失败: %v\n", strings.Join(states, ","), err)
+	}
+}
+
+// watchdog 在启用了 WatchdogSec= 时按看门狗超时的一半发送 WATCHDOG=1，直到 ctx 结束。
+// 调度器卡死（stalled 返回真，见 daemon.stalled）时停止发送，由 systemd 在超时后重启服务
+func watchdog(ctx context.Context, n *sdNotifier, stalled func() bool) {
+	if n == nil || n.watchdog <= 0 {
+		return
+	}
+	ticker := time.NewTicker(n.watchdog / 2)
+	defer ticker.Stop()
+	warned := false
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if stalled() {
+			if !warned {
+				fmt.Fprintln(os.Stderr, "警告: 调度器长时间没有完成检测，停止向 systemd 发送看门狗心跳")
+				warned = true
+			}
+			continue
+		}
+		warned = false
+		n.Notify("WATCHDOG=1")
+	}
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ---------- DNSBL 与威胁情报（-dnsbl / -threat-feed） ----------
+
+// 被劫持的域名经常指向早已因滥用而被列入黑名单的 IP，查询 DNSBL 与本地威胁情报可以作为旁证。
+// 结果只写入报告，不改变污染判定
+
+// threatCacheTTL 是同一 IP 查询结果的缓存时间，守护模式下避免每轮重复查询 DNSBL
+const threatCacheTTL = time.Hour
+
+// threatFeed 是一份本地威胁情报，每行一个 IP 或网段
+type threatFeed struct {
+	name string // 文件名（不含扩展名），用于显示
+	nets []*net.IPNet
+}
+
+// threatEntry 是一个 IP 的缓存查询结果
+type threatEntry struct {
+	lists   []string
+	expires time.Time
+}
+
+// threatChecker 查询解析到的 IP 是否被列入 DNSBL 或威胁情报。nil 表示不查询
+type threatChecker struct {
+	zones []string
+	feeds []threatFeed
+
+	mu    sync.Mutex
+	cache map[string]threatEntry
+}
+
+// newThreatChecker 根据 -dnsbl 与 -threat-feed 创建查询器；两者都为空时返回 nil
+func newThreatChecker(zones, feeds string) (*threatChecker, error) {
+	t := &threatChecker{cache: make(map[string]threatEntry)}
+	for _, z := range splitList(zones) {
+		name, err := normalizeDomainName(z)
+		if err != nil {
+			return nil, fmt.Errorf("无效的 -dnsbl 区域: %w", err)
+		}
+		t.zones = append(t.zones, name)
+	}
+	for _, path := range splitList(feeds) {
+		feed, err := loadThreatFeed(path)
+		if err != nil {
+			return nil, err
+		}
+		t.feeds = append(t.feeds, feed)
+	}
+	if len(t.zones) == 0 && len(t.feeds) == 0 {
+		return nil, nil
+	}
+	return t, nil
+}
+
+// loadThreatFeed 读取一份威胁情报文件。# 之后为注释，每行第一个字段为 IP 或网段，其余字段忽略
+func loadThreatFeed(path string) (threatFeed, error) {
+	feed := threatFeed{name: strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))}
+	f, err := os.Open(path)
+	if err != nil {
+		return feed, fmt.Errorf("读取威胁情报失败: %w", err)
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	for line := 1; sc.Scan(); line++ {
+		text := sc.Text()
+		if i := strings.IndexAny(text, "#;"); i >= 0 {
+			text = text[:i]
+		}
+		fields := strings.Fields(text)
+		if len(fields) == 0 {
+			continue
+		}
+		n, err := parseIPOrCIDR(fields[0])
+		if err != nil {
+			return feed, fmt.Errorf("威胁情报 %s 第 %d 行: %w", path, line, err)
+		}
+		feed.nets = append(feed.nets, n)
+	}
+	if err := sc.Err(); err != nil {
+		return feed, fmt.Errorf("读取威胁情报失败: %w", err)
+	}
+	return feed, nil
+}
+
+// Lookup 并发查询域名结果中每个公网 IP，命中的列表写入 IPResults[i].Listed。
+// 域名被判定为污染时，被列入的 IP 会追加到汇总中，作为判定依据
+func (t *threatChecker) Lookup(ctx context.Context, res *DomainResult) {
+	if t == nil {
+		return
+	}
+	var wg sync.WaitGroup
+	for i := range res.IPResults {
+		ip := net.ParseIP(res.IPResults[i].IP)
+		if ip == nil || res.IPResults[i].Private {
+			continue
+		}
+		wg.Add(1)
+		go func(i int, ip net.IP) {
+			defer wg.Done()
+			res.IPResults[i].Listed = t.lists(ctx, ip)
+		}(i, ip)
+	}
+	wg.Wait()
+
+	var notes []string
+	for _, ip := range res.IPResults {
+		if len(ip.Listed) > 0 && !res.ipMatched(ip) {
+			notes = append(notes, fmt.Sprintf("%s 被列入 %s", ip.IP, strings.Join(ip.Listed, "、")))
+		}
+	}
+	if res.IsPolluted && len(notes) > 0 {
+		res.Summary += "；威胁情报: " + strings.Join(notes, "，")
+	}
+}
+
+// lists 返回 ip 被列入的 DNSBL 与威胁情报，优先使用缓存
+func (t *threatChecker) lists(ctx context.Context, ip net.IP) []string {
+	key := ip.String()
+	t.mu.Lock()
+	e, ok := t.cache[key]
+	t.mu.Unlock()
+	if ok && time.Now().Before(e.expires) {
+		return e.lists
+	}
+
+	var hits []string
+	for _, f := range t.feeds {
+		if containsIP(f.nets, ip) {
+			hits = append(hits, f.name)
+		}
+	}
+	cacheable := true
+	for _, z := range t.zones {
+		hit, err := queryDNSBL(ctx, ip, z)
+		if err != nil {
+			cacheable = false // 查询失败不缓存，下一轮重试
+			continue
+		}
+		if hit != "" {
+			hits = append(hits, hit)
+		}
+	}
+	if cacheable {
+		t.mu.Lock()
+		t.cache[key] = threatEntry{lists: hits, expires: time.Now().Add(threatCacheTTL)}
+		t.mu.Unlock()
+	}
+	return hits
+}
+
+// queryDNSBL 通过系统解析器查询 ip 是否被列入 zone。未列入时返回空字符串；
+// 命中时返回 "区域（返回码）"。127.255.255.0/24 是 Spamhaus 等拒绝服务时的返回码（如经公共解析器查询），视为查询失败
+func queryDNSBL(ctx context.Context, ip net.IP, zone string) (string, error) {
+	addrs, err := net.DefaultResolver.LookupHost(ctx, dnsblName(ip, zone))
+	if err != nil {
+		if dnsErr, ok := err.(*net.DNSError); ok && dnsErr.IsNotFound {
+			return "", nil
+		}
+		return "", err
+	}
+	for _, a := range addrs {
+		if strings.HasPrefix(a, "127.255.255.") {
+			return "", fmt.Errorf("%s 拒绝查询（返回 %s）", zone, a)
+		}
+	}
+	return fmt.Sprintf("%s（%s）", zone, strings.Join(addrs, ", ")), nil
+}
+
+// dnsblName 返回 ip 在 zone 中的查询名：IPv4 为倒序的四段，IPv6 为倒序的 32 个半字节
+func dnsblName(ip net.IP, zone string) string {
+	var parts []string
+	if v4 := ip.To4(); v4 != nil {
+		for i := len(v4) - 1; i >= 0; i-- {
+			parts = append(parts, fmt.Sprint(v4[i]))
+		}
+	} else {
+		const hex = "0123456789abcdef"
+		v6 := ip.To16()
+		for i := len(v6) - 1; i >= 0; i-- {
+			parts = append(parts, string(hex[v6[i]&0xf]), string(hex[v6[i]>>4]))
+		}
+	}
+	return strings.Join(parts, ".") + "." + zone
+}
+
+// countListed 统计有 IP 被列入 DNSBL 或威胁情报的域名数
+func countListed(results []DomainResult) int {
+	n := 0
+	for _, r := range results {
+		for _, ip := range r.IPResults {
+			if len(ip.Listed) > 0 {
+				n++
+				break
+			}
+		}
+	}
+	return n
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"strings"
+	"time"
+)
+
+// ---------- HTML 报告中的历史图表（dnscheck merge -history） ----------
+
+const (
+	sparkWidth  = 240 // 单个域名时间线的宽度（像素）
+	sparkHeight = 16
+	rateWidth   = 720 // 整体污染率图的宽度
+	rateHeight  = 120
+)
+
+// historyCharts 是从历史数据库生成的图表：每个域名一条污染时间线，以及按天统计的整体污染率
+type historyCharts struct {
+	Days  int
+	Since time.Time
+	Until time.Time
+	Rate  template.HTML // 整体污染率柱状图（SVG）
+	Runs  int           // 时间范围内的检测记录数
+	Peak  string        // 污染率最高的一天，如 "2025-03-30（12.50%）"；没有污染时为空
+
+	sparks map[string]template.HTML
+}
+
+// dayRate 是某一 UTC 日（与每日汇总一致，见 dayStart）所有检测记录中被判定为污染的比例
+type dayRate struct {
+	Day      time.Time
+	Runs     int
+	Polluted int
+}
+
+// loadHistoryCharts 读取最近 days 天的原始历史记录并生成图表；已压缩为每日汇总的日期没有逐次记录，不在图中显示
+func loadHistoryCharts(dsn string, days int, now time.Time) (*historyCharts, error) {
+	store, err := openHistoryStore(dsn)
+	if err != nil {
+		return nil, err
+	}
+	defer store.Close()
+	since := dayStart(now).AddDate(0, 0, -days+1)
+	records, err := store.Query(historyFilter{From: since, To: now})
+	if err != nil {
+		return nil, err
+	}
+	return buildHistoryCharts(records, days, since, now), nil
+}
+
+// buildHistoryCharts 根据历史记录生成图表；records 按时间倒序（Query 的顺序）
+func buildHistoryCharts(records []historyRecord, days int, since, until time.Time) *historyCharts {
+	h := &historyCharts{Days: days, Since: since, Until: until, Runs: len(records), sparks: make(map[string]template.HTML)}
+	byDomain := make(map[string][]historyRecord)
+	rates := make([]dayRate, days)
+	for i := range rates {
+		rates[i].Day = since.AddDate(0, 0, i)
+	}
+	for i := len(records) - 1; i >= 0; i-- {
+		rec := records[i]
+		byDomain[rec.Domain] = append(byDomain[rec.Domain], rec)
+		day := int(dayStart(rec.CheckedAt).Sub(since) / (24 * time.Hour))
+		if day < 0 || day >= days {
+			continue
+		}
+		rates[day].Runs++
+		if rec.Polluted {
+			rates[day].Polluted++
+		}
+	}
+	for domain, recs := range byDomain {
+		h.sparks[domain] = sparklineSVG(recs, since, until)
+	}
+	h.Rate = rateChartSVG(rates)
+	peak := -1.0
+	for _, r := range rates {
+		if r.Runs == 0 || r.Polluted == 0 {
+			continue
+		}
+		if rate := float64(r.Polluted) / float64(r.Runs) * 100; rate > peak {
+			peak = rate
+			h.Peak = fmt.Sprintf("%s（%.2f%%）", r.Day.Format("2006-01-02"), rate)
+		}
+	}
+	return h
+}
+
+// Sparkline 返回域名的污染时间线；h 为 nil（未指定 -history）或没有该域名的记录时返回空
+func (h *historyCharts) Sparkline(domain string) template.HTML {
+	if h == nil {
+		return ""
+	}
+	return h.sparks[domain]
+}
+
+// sparklineSVG 将一个域名的检测记录（按时间正序）画成时间线：每次检测一条竖线，污染为红色、干净为绿色，
+// 横坐标为检测时间
+func sparklineSVG(recs []historyRecord, since, until time.Time) template.HTML {
+	span := until.Sub(since).Seconds()
+	polluted := 0
+	var b strings.Builder
+	for _, rec := range recs {
+		color := "#3a3"
+		if rec.Polluted {
+			color = "#c33"
+			polluted++
+		}
+		x := 0.0
+		if span > 0 {
+			x = rec.CheckedAt.Sub(since).Seconds() / span * (sparkWidth - 2)
+		}
+		fmt.Fprintf(&b, `<rect x="%.1f" y="0" width="2" height="%d" fill="%s"/>`, x, sparkHeight, color)
+	}
+	return template.HTML(fmt.Sprintf(`<svg class="spark" width="%d" height="%d" viewBox="0 0 %d %d"><title>%d 次检测，污染 %d 次</title><rect width="%d" height="%d" fill="#f4f4f4"/>%s</svg> %d/%d`,
+		sparkWidth, sparkHeight, sparkWidth, sparkHeight, len(recs), polluted, sparkWidth, sparkHeight, b.String(), polluted, len(recs)))
+}
+
+// rateChartSVG 将每天的整体污染率画成柱状图；没有污染的日期画一条绿色的细线，没有检测记录的日期留空
+func rateChartSVG(rates []dayRate) template.HTML {
+	if len(rates) == 0 {
+		return ""
+	}
+	const top, bottom = 10, 20 // 上方留白与下方日期标注的高度
+	plot := float64(rateHeight - top - bottom)
+	slot := float64(rateWidth) / float64(len(rates))
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg class="rate" width="%d" height="%d" viewBox="0 0 %d %d">`, rateWidth, rateHeight, rateWidth, rateHeight)
+	fmt.Fprintf(&b, `<line x1="0" y1="%d" x2="%d" y2="%d" stroke="#999"/>`, rateHeight-bottom, rateWidth, rateHeight-bottom)
+	for i, r := range rates {
+		if r.Runs == 0 {
+			continue
+		}
+		rate := float64(r.Polluted) / float64(r.Runs)
+		h, color := rate*plot, "#c33"
+		if r.Polluted == 0 {
+			h, color = 2, "#3a3"
+		} else if h < 1 {
+			h = 1
+		}
+		fmt.Fprintf(&b, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" fill="%s"><title>%s: %d/%d（%.2f%%）</title></rect>`,
+			float64(i)*slot+1, float64(rateHeight-bottom)-h, slot-2, h, color, r.Day.Format("2006-01-02"), r.Polluted, r.Runs, rate*100)
+	}
+	// 标注首尾两天的日期
+	fmt.Fprintf(&b, `<text x="0" y="%d" font-size="11">%s</text>`, rateHeight-5, rates[0].Day.Format("01-02"))
+	fmt.Fprintf(&b, `<text x="%d" y="%d" font-size="11" text-anchor="end">%s</text>`, rateWidth, rateHeight-5, rates[len(rates)-1].Day.Format("01-02"))
+	fmt.Fprintf(&b, `<text x="%d" y="%d" font-size="11" text-anchor="end">100%%</text>`, rateWidth, top)
+	b.WriteString(`</svg>`)
+	return template.HTML(b.String())
+}
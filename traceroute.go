@@ -0,0 +1,366 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+)
+
+// ---------- 可疑 IP 的路由追踪（-traceroute） ----------
+
+// 路由追踪的参数
+const (
+	traceMaxTargets = 3                // 每个域名最多追踪的 IP 数
+	traceHopTimeout = time.Second      // 每一跳等待回应的时间
+	traceMaxSilent  = 5                // 连续多少跳无回应后放弃
+	traceBasePort   = 33434            // UDP 探测的起始目的端口，与 traceroute 相同
+	protocolICMP    = 1                // ICMPv4 的协议号
+	tracePayload    = "dnscheck-trace" // ICMP 探测报文的数据
+)
+
+// traceHop 是路由上的一跳；Addr 为空表示该跳没有回应
+type traceHop struct {
+	TTL   int     `json:"ttl"`
+	Addr  string  `json:"addr,omitempty"`
+	RTTMs float64 `json:"rtt_ms,omitempty"`
+}
+
+// routeTrace 是对一个 IP 的路由追踪结果
+type routeTrace struct {
+	Target  string     `json:"target"`
+	Method  string     `json:"method"` // icmp、udp 或 traceroute（系统命令）
+	Hops    []traceHop `json:"hops"`
+	Reached bool       `json:"reached"`
+	Error   string     `json:"error,omitempty"`
+}
+
+func (t routeTrace) String() string {
+	var parts []string
+	for _, h := range t.Hops {
+		if h.Addr == "" {
+			parts = append(parts, fmt.Sprintf("%d *", h.TTL))
+			continue
+		}
+		parts = append(parts, fmt.Sprintf("%d %s (%.1fms)", h.TTL, h.Addr, h.RTTMs))
+	}
+	state := "未到达"
+	if t.Reached {
+		state = "已到达"
+	}
+	s := fmt.Sprintf("%s（%s，%s）: %s", t.Target, t.Method, state, strings.Join(parts, " → "))
+	if t.Error != "" {
+		s += "；" + t.Error
+	}
+	return s
+}
+
+// routeTracer 对被污染域名中的可疑 IP 执行路由追踪。原始套接字需要 root 或 CAP_NET_RAW 权限，
+// 没有权限时改用系统的 traceroute 命令。nil 表示不追踪
+type routeTracer struct {
+	mode    string // icmp 或 udp
+	hops    int
+	native  bool   // 可以打开原始 ICMP 套接字
+	command string // 没有权限时使用的 traceroute 命令路径
+}
+
+// newRouteTracer 检测运行权限并创建追踪器；mode 为空时返回 nil。
+// 既没有权限又找不到 traceroute 命令时返回错误，调用方只输出警告、不追踪
+func newRouteTracer(mode string, hops int) (*routeTracer, error) {
+	if mode == "" {
+		return nil, nil
+	}
+	t := &routeTracer{mode: mode, hops: hops}
+	if c, err := icmp.ListenPacket("ip4:icmp", "0.0.0.0"); err == nil {
+		c.Close()
+		t.native = true
+		return t, nil
+	}
+	path, err := exec.LookPath("traceroute")
+	if err != nil {
+		return nil, fmt.Errorf("路由追踪需要 root 或 CAP_NET_RAW 权限，且未找到 traceroute 命令，已禁用 -traceroute")
+	}
+	t.command = path
+	return t, nil
+}
+
+// validateTraceroute 检查 -traceroute 与 -trace-hops
+func validateTraceroute(mode string, hops int) error {
+	switch mode {
+	case "", "icmp", "udp":
+	default:
+		return fmt.Errorf("无效的 -traceroute %q（可选 icmp、udp）", mode)
+	}
+	if hops < 1 || hops > 64 {
+		return fmt.Errorf("-trace-hops 必须为 1~64 的整数")
+	}
+	return nil
+}
+
+// suspectIPs 返回域名结果中值得追踪的 IP：不符合预期、命中黑名单、不在固定列表或基线中的公网地址
+func suspectIPs(res DomainResult) []string {
+	offBaseline := make(map[string]bool, len(res.OffBaseline))
+	for _, ip := range res.OffBaseline {
+		offBaseline[ip] = true
+	}
+	var out []string
+	for _, ip := range res.IPResults {
+		if ip.Error != nil || ip.Private || ip.Source == sourceBogon {
+			continue
+		}
+		if ip.Forbidden != "" || ip.NotPinned || offBaseline[ip.IP] || !res.ipMatched(ip) {
+			out = append(out, ip.IP)
+		}
+		if len(out) == traceMaxTargets {
+			break
+		}
+	}
+	return out
+}
+
+// Trace 追踪被污染域名中的可疑 IP
+func (t *routeTracer) Trace(ctx context.Context, res DomainResult) []routeTrace {
+	if t == nil || !res.IsPolluted {
+		return nil
+	}
+	var traces []routeTrace
+	for _, target := range suspectIPs(res) {
+		ip := net.ParseIP(target).To4()
+		if ip == nil {
+			continue // 原始套接字只实现了 IPv4
+		}
+		var rt routeTrace
+		var err error
+		switch {
+		case !t.native:
+			rt, err = t.traceCommand(ctx, ip)
+		case t.mode == "udp":
+			rt, err = t.traceUDP(ctx, ip)
+		default:
+			rt, err = t.traceICMP(ctx, ip)
+		}
+		if err != nil {
+			rt.Error = err.Error()
+		}
+		rt.Target = target
+		traces = append(traces, rt)
+	}
+	return traces
+}
+
+// hopProbe 发送第 ttl 跳的探测报文；match 判断收到的 ICMP 报文是否是对该探测的回应，
+// 以及回应是否来自目标本身
+type hopProbe struct {
+	send  func(ttl int) error
+	match func(m *icmp.Message, ttl int) (ok, reached bool)
+}
+
+// walk 逐跳发送探测并等待回应，直到到达目标、超过跳数、连续无回应或 ctx 结束
+func (t *routeTracer) walk(ctx context.Context, c *icmp.PacketConn, p hopProbe, rt *routeTrace) error {
+	buf := make([]byte, 1500)
+	silent := 0
+	for ttl := 1; ttl <= t.hops && silent < traceMaxSilent; ttl++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		start := time.Now()
+		if err := p.send(ttl); err != nil {
+			return fmt.Errorf("发送探测失败: %w", err)
+		}
+		hop := traceHop{TTL: ttl}
+		_ = c.SetReadDeadline(start.Add(traceHopTimeout))
+		for {
+			n, peer, err := c.ReadFrom(buf)
+			if err != nil {
+				break // 超时：该跳无回应
+			}
+			m, err := icmp.ParseMessage(protocolICMP, buf[:n])
+			if err != nil {
+				continue
+			}
+			ok, reached := p.match(m, ttl)
+			if !ok {
+				continue
+			}
+			hop.Addr = hostOf(peer)
+			hop.RTTMs = float64(time.Since(start)) / float64(time.Millisecond)
+			rt.Reached = reached
+			break
+		}
+		rt.Hops = append(rt.Hops, hop)
+		if hop.Addr == "" {
+			silent++
+		} else {
+			silent = 0
+		}
+		if rt.Reached {
+			return nil
+		}
+	}
+	return nil
+}
+
+// traceICMP 发送 TTL 递增的 ICMP Echo，中间路由器回应超时，目标回应 Echo Reply
+func (t *routeTracer) traceICMP(ctx context.Context, dst net.IP) (routeTrace, error) {
+	rt := routeTrace{Method: "icmp"}
+	c, err := icmp.ListenPacket("ip4:icmp", "0.0.0.0")
+	if err != nil {
+		return rt, fmt.Errorf("打开 ICMP 套接字失败: %w", err)
+	}
+	defer c.Close()
+	id := traceID()
+	p := hopProbe{
+		send: func(ttl int) error {
+			if err := c.IPv4PacketConn().SetTTL(ttl); err != nil {
+				return err
+			}
+			msg := icmp.Message{Type: ipv4.ICMPTypeEcho, Body: &icmp.Echo{ID: id, Seq: ttl, Data: []byte(tracePayload)}}
+			b, err := msg.Marshal(nil)
+			if err != nil {
+				return err
+			}
+			_, err = c.WriteTo(b, &net.IPAddr{IP: dst})
+			return err
+		},
+		match: func(m *icmp.Message, ttl int) (bool, bool) {
+			switch body := m.Body.(type) {
+			case *icmp.Echo:
+				return m.Type == ipv4.ICMPTypeEchoReply && body.ID == id && body.Seq == ttl, true
+			case *icmp.TimeExceeded:
+				inner, ok := innerPayload(body.Data, dst, protocolICMP)
+				return ok && len(inner) >= 8 && int(binary.BigEndian.Uint16(inner[4:6])) == id && int(binary.BigEndian.Uint16(inner[6:8])) == ttl, false
+			}
+			return false, false
+		},
+	}
+	return rt, t.walk(ctx, c, p, &rt)
+}
+
+// traceUDP 向递增的高位端口发送 TTL 递增的 UDP 报文，中间路由器回应超时，目标回应端口不可达；
+// 回应仍通过原始 ICMP 套接字接收
+func (t *routeTracer) traceUDP(ctx context.Context, dst net.IP) (routeTrace, error) {
+	rt := routeTrace{Method: "udp"}
+	c, err := icmp.ListenPacket("ip4:icmp", "0.0.0.0")
+	if err != nil {
+		return rt, fmt.Errorf("打开 ICMP 套接字失败: %w", err)
+	}
+	defer c.Close()
+	uc, err := net.ListenPacket("udp4", ":0")
+	if err != nil {
+		return rt, fmt.Errorf("打开 UDP 套接字失败: %w", err)
+	}
+	defer uc.Close()
+	up := ipv4.NewPacketConn(uc)
+	p := hopProbe{
+		send: func(ttl int) error {
+			if err := up.SetTTL(ttl); err != nil {
+				return err
+			}
+			_, err := uc.WriteTo([]byte(tracePayload), &net.UDPAddr{IP: dst, Port: traceBasePort + ttl})
+			return err
+		},
+		match: func(m *icmp.Message, ttl int) (bool, bool) {
+			var data []byte
+			switch body := m.Body.(type) {
+			case *icmp.TimeExceeded:
+				data = body.Data
+			case *icmp.DstUnreach:
+				data = body.Data
+			default:
+				return false, false
+			}
+			inner, ok := innerPayload(data, dst, 17)
+			if !ok || len(inner) < 4 || int(binary.BigEndian.Uint16(inner[2:4])) != traceBasePort+ttl {
+				return false, false
+			}
+			return true, m.Type == ipv4.ICMPTypeDestinationUnreachable
+		},
+	}
+	return rt, t.walk(ctx, c, p, &rt)
+}
+
+// innerPayload 从 ICMP 差错报文携带的原始 IP 报文中取出传输层数据，并核对目的地址与协议
+func innerPayload(data []byte, dst net.IP, proto byte) ([]byte, bool) {
+	if len(data) < 20 || data[0]>>4 != 4 {
+		return nil, false
+	}
+	ihl := int(data[0]&0x0f) * 4
+	if len(data) < ihl || data[9] != proto || !net.IP(data[16:20]).Equal(dst) {
+		return nil, false
+	}
+	return data[ihl:], true
+}
+
+// traceCommand 在没有原始套接字权限时调用系统的 traceroute 命令（通常带有 setuid 或相应的 capability）
+func (t *routeTracer) traceCommand(ctx context.Context, dst net.IP) (routeTrace, error) {
+	rt := routeTrace{Method: "traceroute"}
+	args := []string{"-n", "-q", "1", "-w", "1", "-m", strconv.Itoa(t.hops)}
+	if t.mode == "icmp" {
+		args = append(args, "-I")
+	}
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(t.hops+1)*traceHopTimeout*2)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, t.command, append(args, dst.String())...).Output()
+	rt.Hops = parseTracerouteOutput(out)
+	for _, h := range rt.Hops {
+		if h.Addr == dst.String() {
+			rt.Reached = true
+		}
+	}
+	if err != nil && len(rt.Hops) == 0 {
+		return rt, fmt.Errorf("执行 traceroute 失败: %w", err)
+	}
+	return rt, nil
+}
+
+// parseTracerouteOutput 解析 traceroute -n -q 1 的输出，每行形如 " 3  10.0.0.1  3.215 ms" 或 " 4  *"
+func parseTracerouteOutput(out []byte) []traceHop {
+	var hops []traceHop
+	sc := bufio.NewScanner(bytes.NewReader(out))
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		ttl, err := strconv.Atoi(fields[0])
+		if err != nil {
+			continue // 标题行
+		}
+		hop := traceHop{TTL: ttl}
+		if net.ParseIP(fields[1]) != nil {
+			hop.Addr = fields[1]
+			if len(fields) >= 3 {
+				hop.RTTMs, _ = strconv.ParseFloat(fields[2], 64)
+			}
+		}
+		hops = append(hops, hop)
+	}
+	return hops
+}
+
+// traceID 返回随机的 ICMP Echo 标识，区分同时进行的多个追踪
+func traceID() int {
+	var b [2]byte
+	fillRandom(b[:])
+	return int(binary.BigEndian.Uint16(b[:]))
+}
+
+// hostOf 返回地址中的 IP 部分
+func hostOf(addr net.Addr) string {
+	switch a := addr.(type) {
+	case *net.IPAddr:
+		return a.IP.String()
+	case *net.UDPAddr:
+		return a.IP.String()
+	}
+	return addr.String()
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// ---------- 不同传输方式的应答比较（-transports） ----------
+
+// 比较结论
+const (
+	crossConsistent = "consistent" // 各传输方式的应答相同
+	crossPartial    = "partial"    // 应答有交集但不完全相同（常见于 CDN 轮换）
+	crossDiffers    = "differs"    // 至少两种传输方式的应答没有任何交集，是最直接的污染证据
+	crossIncomplete = "incomplete" // 成功的传输方式不足两种，无法比较
+)
+
+// transportSpec 是一种传输方式与对应的服务器
+type transportSpec struct {
+	Kind string // udp、tcp 或 doh
+	Addr string // udp/tcp 为 host:port，doh 为 URL
+}
+
+func (t transportSpec) String() string {
+	if t.Kind == "doh" {
+		return t.Addr
+	}
+	return t.Kind + "://" + t.Addr
+}
+
+// transportAnswer 是通过一种传输方式得到的应答
+type transportAnswer struct {
+	Transport string   `json:"transport"`
+	IPs       []string `json:"ips"`
+	RCode     string   `json:"rcode,omitempty"`
+	Error     string   `json:"error,omitempty"`
+}
+
+// crossCheck 是单个域名在各传输方式之间的比较结果
+type crossCheck struct {
+	Verdict string            `json:"verdict"`
+	Answers []transportAnswer `json:"answers"`
+}
+
+func (c crossCheck) String() string {
+	names := map[string]string{
+		crossConsistent: "一致",
+		crossPartial:    "部分不同",
+		crossDiffers:    "不一致",
+		crossIncomplete: "无法比较",
+	}
+	parts := make([]string, 0, len(c.Answers))
+	for _, a := range c.Answers {
+		switch {
+		case a.Error != "":
+			parts = append(parts, fmt.Sprintf("%s → 失败: %s", a.Transport, a.Error))
+		case len(a.IPs) == 0:
+			parts = append(parts, fmt.Sprintf("%s → %s", a.Transport, a.RCode))
+		default:
+			parts = append(parts, fmt.Sprintf("%s → %s", a.Transport, strings.Join(a.IPs, ", ")))
+		}
+	}
+	return fmt.Sprintf("（%s）: %s", names[c.Verdict], strings.Join(parts, "；"))
+}
+
+// parseTransports 解析 -transports，如 "udp://8.8.8.8,tcp://8.8.8.8,https://8.8.8.8/dns-query"；
+// udp/tcp 未指定端口时使用 53。启用时至少需要两种传输方式
+func parseTransports(spec string) ([]transportSpec, error) {
+	if spec == "" {
+		return nil, nil
+	}
+	var specs []transportSpec
+	for _, f := range strings.Split(spec, ",") {
+		t, err := parseTransportSpec(strings.TrimSpace(f))
+		if err != nil {
+			return nil, err
+		}
+		specs = append(specs, t)
+	}
+	if len(specs) < 2 {
+		return nil, fmt.Errorf("-transports 至少需要两种传输方式")
+	}
+	return specs, nil
+}
+
+// parseTransportSpec 解析一种传输方式，如 "udp://8.8.8.8"、"tcp://8.8.8.8:5353" 或 DoH 的 https:// 地址
+func parseTransportSpec(f string) (transportSpec, error) {
+	switch {
+	case strings.HasPrefix(f, "udp://"), strings.HasPrefix(f, "tcp://"):
+		kind, addr := f[:3], f[6:]
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			addr = net.JoinHostPort(addr, "53")
+		}
+		if host, _, _ := net.SplitHostPort(addr); host == "" {
+			return transportSpec{}, fmt.Errorf("无效的传输方式 %q", f)
+		}
+		return transportSpec{Kind: kind, Addr: addr}, nil
+	case strings.HasPrefix(f, "https://"):
+		return transportSpec{Kind: "doh", Addr: f}, nil
+	}
+	return transportSpec{}, fmt.Errorf("无效的传输方式 %q（可选 udp://host[:port]、tcp://host[:port] 或 DoH 的 https:// 地址）", f)
+}
+
+// crossChecker 通过多种传输方式查询每个域名并比较应答。nil 表示不比较
+type crossChecker struct {
+	specs []transportSpec
+}
+
+// newCrossChecker 根据 -transports 创建比较器；spec 为空时返回 nil
+func newCrossChecker(spec string) (*crossChecker, error) {
+	specs, err := parseTransports(spec)
+	if err != nil || specs == nil {
+		return nil, err
+	}
+	return &crossChecker{specs: specs}, nil
+}
+
+// Compare 并发通过各传输方式查询域名的 A 记录，结果写入 res.Transport。
+// 比较结论只作为证据，不改变污染判定
+func (x *crossChecker) Compare(ctx context.Context, res *DomainResult) {
+	if x == nil || res.ASCIIDomain == "" {
+		return
+	}
+	check := &crossCheck{Answers: make([]transportAnswer, len(x.specs))}
+	var wg sync.WaitGroup
+	for i, spec := range x.specs {
+		wg.Add(1)
+		go func(i int, spec transportSpec) {
+			defer wg.Done()
+			check.Answers[i] = queryTransport(ctx, spec, res.ASCIIDomain)
+		}(i, spec)
+	}
+	wg.Wait()
+	check.Verdict = compareAnswers(check.Answers)
+	res.Transport = check
+}
+
+// queryTransport 通过一种传输方式查询 host 的 A 记录；单次查询超时与 rawQuery 相同
+func queryTransport(ctx context.Context, spec transportSpec, host string) transportAnswer {
+	a := transportAnswer{Transport: spec.String(), IPs: []string{}}
+	timeout := 2 * time.Second
+	if deadline, ok := ctx.Deadline(); ok {
+		if remaining := time.Until(deadline); remaining < timeout {
+			timeout = remaining
+		}
+	}
+	query, err := newDNSQuery(host, dnsmessage.TypeA)
+	if err != nil {
+		a.Error = err.Error()
+		return a
+	}
+	var resp *dnsmessage.Message
+	switch spec.Kind {
+	case "udp":
+		resp, err = exchangeUDP(spec.Addr, query, timeout)
+	case "tcp":
+		resp, err = exchangeTCP(spec.Addr, query, timeout)
+	default:
+		resp, err = exchangeDoH(ctx, spec.Addr, query, timeout)
+	}
+	if err != nil {
+		a.Error = err.Error()
+		return a
+	}
+	a.RCode = resp.Header.RCode.String()
+	for _, ip := range answerIPv4(resp) {
+		a.IPs = append(a.IPs, ip.String())
+	}
+	sort.Strings(a.IPs)
+	return a
+}
+
+// compareAnswers 比较查询成功的各个应答：完全相同为 consistent，任意两者没有交集为 differs，
+// 其余为 partial。RCode 不同（如一方 NXDOMAIN、一方有应答）也视为没有交集
+func compareAnswers(answers []transportAnswer) string {
+	var ok []transportAnswer
+	for _, a := range answers {
+		if a.Error == "" {
+			ok = append(ok, a)
+		}
+	}
+	if len(ok) < 2 {
+		return crossIncomplete
+	}
+	verdict := crossConsistent
+	for i := 0; i < len(ok); i++ {
+		for j := i + 1; j < len(ok); j++ {
+			switch {
+			case ok[i].RCode == ok[j].RCode && strings.Join(ok[i].IPs, ",") == strings.Join(ok[j].IPs, ","):
+			case ok[i].RCode != ok[j].RCode || len(stringsMissing(ok[i].IPs, ok[j].IPs)) == len(ok[i].IPs):
+				return crossDiffers
+			default:
+				verdict = crossPartial
+			}
+		}
+	}
+	return verdict
+}
+
+// exchangeDoH 按 RFC 8484 以 POST 发送 DNS over HTTPS 查询。
+// DoH 服务器的主机名由系统解析器解析，为避免被污染，建议直接使用 IP 形式的地址（如 https://1.1.1.1/dns-query）
+func exchangeDoH(ctx context.Context, url string, query dnsmessage.Message, timeout time.Duration) (*dnsmessage.Message, error) {
+	query.Header.ID = 0 // RFC 8484 建议 ID 为 0，便于 HTTP 缓存
+	packed, err := query.Pack()
+	if err != nil {
+		return nil, fmt.Errorf("编码 DNS 查询失败: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(packed))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/dns-message")
+	req.Header.Set("Accept", "application/dns-message")
+	// 记录报文时使用实际连接的地址（-capture）
+	var local, remote net.Addr
+	req = req.WithContext(httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{GotConn: func(info httptrace.GotConnInfo) {
+		local, remote = info.Conn.LocalAddr(), info.Conn.RemoteAddr()
+	}}))
+	resp, err := (&http.Client{Timeout: timeout}).Do(req)
+	if err != nil {
+		return nil, err
+	}
+	dnsCapture.Record("https", local, remote, true, packed)
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("DoH 服务器返回 HTTP %d", resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 65535))
+	if err != nil {
+		return nil, err
+	}
+	dnsCapture.Record("https", local, remote, false, body)
+	var msg dnsmessage.Message
+	if err := msg.Unpack(body); err != nil {
+		return nil, fmt.Errorf("解析 DoH 响应失败: %w", err)
+	}
+	return &msg, nil
+}
+
+// countTransportMismatch 统计各传输方式应答没有交集的域名数
+func countTransportMismatch(results []DomainResult) int {
+	n := 0
+	for _, r := range results {
+		if r.Transport != nil && r.Transport.Verdict == crossDiffers {
+			n++
+		}
+	}
+	return n
+}
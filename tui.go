@@ -0,0 +1,646 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// ---------- 交互式结果浏览（dnscheck tui） ----------
+
+// 报告动辄上百个域名，在文本报告里翻找某个域名的 IP 明细很不方便。dnscheck tui 以全屏方式浏览一轮结果：
+// 按判定筛选、展开单个域名的 IP 明细，并可以按当前配置立即重新检测选中的域名。
+// 终端通过 stty 切换为原始模式，因此需要类 Unix 系统
+
+// tuiFilters 是 f 键依次切换的判定筛选，空字符串表示全部
+var tuiFilters = []string{"", "polluted", "rebinding", verdictError, "clean"}
+
+// tuiVerdictNames 是各判定在界面中的名称
+var tuiVerdictNames = map[string]string{
+	"":           "全部",
+	"polluted":   "污染",
+	"rebinding":  "重绑定",
+	verdictError: "无法判定",
+	"clean":      "正常",
+}
+
+// tuiVerdictColors 是各判定的 ANSI 颜色
+var tuiVerdictColors = map[string]string{
+	"polluted":   "31",
+	"rebinding":  "35",
+	verdictError: "33",
+	"clean":      "32",
+}
+
+// runTUI 实现 `dnscheck tui [参数] [结果.json]`：未指定文件时，配置了 -history 则读取历史记录中最近一轮
+// （-run 指定某一轮），否则读取 -output 目录（默认当前目录）中最新的 JSON 报告。
+// 接受与单次运行相同的参数，按 r 重新检测时使用
+func runTUI(args []string) {
+	runID := flag.String("run", "", "与 -history 一起使用，浏览指定的一轮（运行 ID），默认最近一轮")
+	if err := flag.CommandLine.Parse(args); err != nil {
+		os.Exit(2)
+	}
+	if flag.NArg() > 1 {
+		fmt.Fprintln(os.Stderr, "用法: dnscheck tui [参数] [结果.json]")
+		os.Exit(2)
+	}
+
+	var (
+		run    runJSON
+		source string
+		err    error
+	)
+	switch {
+	case flag.NArg() == 1:
+		source = flag.Arg(0)
+		run, err = loadRunJSON(source)
+	case *historyDSN != "":
+		source = "历史记录"
+		run, err = loadHistoryRun(*historyDSN, *runID)
+	default:
+		if source, err = latestJSONReport(*outputFile); err == nil {
+			run, err = loadRunJSON(source)
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+
+	t := newTUIState(run, source)
+	// 配置或参数有误时仍可浏览，只是不能重新检测
+	if config, c, err := newConfiguredChecker(); err != nil {
+		t.status = "无法重新检测: " + err.Error()
+	} else {
+		t.recheck = func(domain string) (domainJSON, error) { return recheckDomain(c, config, domain) }
+	}
+
+	restore, err := rawTerminal()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	// 使用备用屏幕，退出后恢复原来的终端内容
+	fmt.Print("\x1b[?1049h\x1b[?25l")
+	defer func() {
+		fmt.Print("\x1b[?25h\x1b[?1049l")
+		restore()
+	}()
+	t.Loop(os.Stdin, os.Stdout)
+}
+
+// latestJSONReport 返回 dir 中最新的 -format json 报告；dir 为空时为当前目录，为文件路径时取其所在目录
+func latestJSONReport(dir string) (string, error) {
+	if dir == "" {
+		dir = "."
+	} else if fi, err := os.Stat(dir); err != nil || !fi.IsDir() {
+		dir = filepath.Dir(dir)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", fmt.Errorf("读取报告目录失败: %w", err)
+	}
+	var latest string
+	var latestAt time.Time
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasPrefix(e.Name(), reportPrefix) || !strings.HasSuffix(e.Name(), ".json") {
+			continue
+		}
+		path := filepath.Join(dir, e.Name())
+		if at := reportTime(path, e.Name()); latest == "" || at.After(latestAt) {
+			latest, latestAt = path, at
+		}
+	}
+	if latest == "" {
+		return "", fmt.Errorf("%s 中没有 JSON 报告（使用 -format json 生成，或指定结果文件、-history）", dir)
+	}
+	return latest, nil
+}
+
+// loadHistoryRun 从历史记录读取一轮结果，runID 为空时读取最近一轮。
+// 历史记录只保存判定、汇总与 IP 的 LLC，其余明细不可用
+func loadHistoryRun(dsn, runID string) (runJSON, error) {
+	store, err := openHistoryStore(dsn)
+	if err != nil {
+		return runJSON{}, err
+	}
+	defer store.Close()
+
+	// 同一轮的记录时间相同，均为该轮的开始时间，运行 ID 的前缀也是这个时间
+	var at time.Time
+	if runID == "" {
+		latest, err := store.Query(historyFilter{Limit: 1})
+		if err != nil {
+			return runJSON{}, err
+		}
+		if len(latest) == 0 {
+			return runJSON{}, fmt.Errorf("历史记录为空")
+		}
+		runID, at = latest[0].RunID, latest[0].CheckedAt
+	} else {
+		stamp := runID
+		if i := strings.IndexByte(stamp, '-'); i >= 0 {
+			stamp = stamp[:i]
+		}
+		if at, err = time.Parse("20060102T150405Z", stamp); err != nil {
+			return runJSON{}, fmt.Errorf("无效的运行 ID %q", runID)
+		}
+	}
+	records, err := store.Query(historyFilter{From: at, To: at})
+	if err != nil {
+		return runJSON{}, err
+	}
+
+	run := runJSON{RunID: runID, StartedAt: at}
+	for i := len(records) - 1; i >= 0; i-- {
+		rec := records[i]
+		if rec.RunID != runID {
+			continue
+		}
+		d := domainJSON{Domain: rec.Domain, Polluted: rec.Polluted, Rebinding: rec.Verdict == verdictRebinding, Summary: rec.Summary, Verdict: rec.Verdict}
+		for _, ip := range rec.IPs {
+			d.IPs = append(d.IPs, ipJSON{IP: ip.IP, LLC: ip.LLC, ASN: ip.ASN, Error: ip.Error})
+		}
+		run.Results = append(run.Results, d)
+	}
+	if len(run.Results) == 0 {
+		return runJSON{}, fmt.Errorf("历史记录中没有运行 %s", runID)
+	}
+	run.Domains = len(run.Results)
+	return run, nil
+}
+
+// recheckDomain 按配置文件中的条目重新检测单个域名
+func recheckDomain(c *checker, config *Config, domain string) (domainJSON, error) {
+	for _, dc := range config.Domains {
+		if dc.Name != domain {
+			continue
+		}
+		cfg := *config
+		cfg.Domains = []DomainConfig{dc}
+		startedAt := time.Now()
+		results := c.Run(context.Background(), &cfg)
+		if len(results) == 0 {
+			return domainJSON{}, fmt.Errorf("重新检测 %s 没有结果", domain)
+		}
+		return newRunJSON("", startedAt, results).Results[0], nil
+	}
+	return domainJSON{}, fmt.Errorf("配置文件中没有 %s，无法重新检测", domain)
+}
+
+// ---------- 终端 ----------
+
+// rawTerminal 通过 stty 将终端切换为原始模式（不回显、逐键读取），返回恢复终端的函数
+func rawTerminal() (func(), error) {
+	saved, err := stty("-g")
+	if err != nil {
+		return nil, fmt.Errorf("标准输入不是终端或系统没有 stty: %w", err)
+	}
+	if _, err := stty("raw", "-echo"); err != nil {
+		return nil, fmt.Errorf("切换终端模式失败: %w", err)
+	}
+	return func() { _, _ = stty(strings.TrimSpace(saved)) }, nil
+}
+
+// stty 对标准输入所在的终端执行 stty
+func stty(args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = os.Stdin
+	out, err := cmd.Output()
+	return string(out), err
+}
+
+// terminalSize 返回终端的行数与列数，无法获取时为 24×80
+func terminalSize() (int, int) {
+	var rows, cols int
+	if out, err := stty("size"); err == nil {
+		_, _ = fmt.Sscan(out, &rows, &cols)
+	}
+	if rows <= 0 || cols <= 0 {
+		return 24, 80
+	}
+	return rows, cols
+}
+
+// readKeys 从 r 逐次读取按键，方向键等转义序列作为一个按键；读取失败时关闭 keys
+func readKeys(r io.Reader, keys chan<- string) {
+	defer close(keys)
+	buf := make([]byte, 16)
+	for {
+		n, err := r.Read(buf)
+		if err != nil {
+			return
+		}
+		keys <- string(buf[:n])
+	}
+}
+
+// displayWidth 返回 s 在终端中占用的列数，中文等全角字符占两列
+func displayWidth(s string) int {
+	w := 0
+	for _, r := range s {
+		w += runeWidth(r)
+	}
+	return w
+}
+
+func runeWidth(r rune) int {
+	switch {
+	case r < 0x20:
+		return 0
+	case unicode.Is(unicode.Han, r), r >= 0x3000 && r <= 0x303f, r >= 0xff00 && r <= 0xff60, r >= 0x2e80 && r <= 0xa4cf:
+		return 2
+	}
+	return 1
+}
+
+// wrapWidth 将 s 按显示宽度 width 折行，续行以 indent 开头
+func wrapWidth(s string, width int, indent string) []string {
+	var lines []string
+	var b strings.Builder
+	w := 0
+	for _, r := range s {
+		rw := runeWidth(r)
+		if w+rw > width && w > displayWidth(indent) {
+			lines = append(lines, b.String())
+			b.Reset()
+			b.WriteString(indent)
+			w = displayWidth(indent)
+		}
+		b.WriteRune(r)
+		w += rw
+	}
+	return append(lines, b.String())
+}
+
+// padWidth 在 s 之后补空格，使其显示宽度至少为 width
+func padWidth(s string, width int) string {
+	if w := displayWidth(s); w < width {
+		return s + strings.Repeat(" ", width-w)
+	}
+	return s
+}
+
+// truncateWidth 将 s 截断到显示宽度 width 以内，截断时以 … 结尾
+func truncateWidth(s string, width int) string {
+	if displayWidth(s) <= width {
+		return s
+	}
+	var b strings.Builder
+	w := 0
+	for _, r := range s {
+		if w+runeWidth(r) > width-1 {
+			break
+		}
+		b.WriteRune(r)
+		w += runeWidth(r)
+	}
+	return b.String() + "…"
+}
+
+// ---------- 界面状态 ----------
+
+// tuiState 是浏览界面的状态
+type tuiState struct {
+	run    runJSON
+	source string // 结果来源，显示在标题栏
+
+	filter   int             // tuiFilters 的下标
+	cursor   int             // 选中的域名在筛选结果中的位置
+	top      int             // 屏幕第一行对应的内容行
+	expanded map[string]bool // 已展开明细的域名
+	status   string          // 底部的状态信息，为空时显示按键说明
+
+	recheck  func(domain string) (domainJSON, error) // nil 表示无法重新检测
+	checking string                                  // 正在重新检测的域名
+	checked  map[string]time.Time                    // 重新检测过的域名及完成时间
+}
+
+// tuiRecheck 是一次重新检测的结果
+type tuiRecheck struct {
+	domain string
+	result domainJSON
+	err    error
+}
+
+func newTUIState(run runJSON, source string) *tuiState {
+	return &tuiState{run: run, source: source, expanded: make(map[string]bool), checked: make(map[string]time.Time)}
+}
+
+// visible 返回符合当前筛选的结果在 run.Results 中的下标
+func (t *tuiState) visible() []int {
+	var idx []int
+	for i, r := range t.run.Results {
+		if f := tuiFilters[t.filter]; f == "" || r.verdict() == f {
+			idx = append(idx, i)
+		}
+	}
+	return idx
+}
+
+// selected 返回选中的结果，筛选结果为空时返回 nil
+func (t *tuiState) selected() *domainJSON {
+	idx := t.visible()
+	if t.cursor >= len(idx) {
+		return nil
+	}
+	return &t.run.Results[idx[t.cursor]]
+}
+
+// Loop 读取按键并重绘界面，直到按下 q 或输入结束
+func (t *tuiState) Loop(in io.Reader, out io.Writer) {
+	keys := make(chan string)
+	go readKeys(in, keys)
+	done := make(chan tuiRecheck, 1)
+	for {
+		rows, cols := terminalSize()
+		fmt.Fprint(out, t.render(rows, cols))
+		select {
+		case key, ok := <-keys:
+			if !ok || !t.handleKey(key, rows, done) {
+				return
+			}
+		case r := <-done:
+			t.applyRecheck(r)
+		}
+	}
+}
+
+// handleKey 处理一次按键，返回 false 表示退出
+func (t *tuiState) handleKey(key string, rows int, done chan<- tuiRecheck) bool {
+	n := len(t.visible())
+	page := rows - 4
+	if page < 1 {
+		page = 1
+	}
+	t.status = ""
+	switch key {
+	case "q", "Q", "\x03", "\x1b":
+		return false
+	case "j", "\x1b[B", "\x1bOB":
+		t.cursor++
+	case "k", "\x1b[A", "\x1bOA":
+		t.cursor--
+	case "\x1b[6~", " ":
+		t.cursor += page
+	case "\x1b[5~", "b":
+		t.cursor -= page
+	case "g", "\x1b[H":
+		t.cursor = 0
+	case "G", "\x1b[F":
+		t.cursor = n - 1
+	case "\r", "\n", "l", "\x1b[C":
+		if d := t.selected(); d != nil {
+			t.expanded[d.Domain] = !t.expanded[d.Domain]
+		}
+	case "h", "\x1b[D":
+		if d := t.selected(); d != nil {
+			delete(t.expanded, d.Domain)
+		}
+	case "e":
+		// 全部展开；已全部展开时全部收起
+		all := true
+		for _, i := range t.visible() {
+			all = all && t.expanded[t.run.Results[i].Domain]
+		}
+		for _, i := range t.visible() {
+			t.expanded[t.run.Results[i].Domain] = !all
+		}
+	case "f":
+		t.filter = (t.filter + 1) % len(tuiFilters)
+		t.cursor, t.top = 0, 0
+	case "r":
+		t.startRecheck(done)
+	}
+	if t.cursor >= len(t.visible()) {
+		t.cursor = len(t.visible()) - 1
+	}
+	if t.cursor < 0 {
+		t.cursor = 0
+	}
+	return true
+}
+
+// startRecheck 在后台重新检测选中的域名，同一时间只检测一个
+func (t *tuiState) startRecheck(done chan<- tuiRecheck) {
+	d := t.selected()
+	switch {
+	case d == nil:
+		return
+	case t.recheck == nil:
+		t.status = "无法重新检测：配置文件或参数有误"
+		return
+	case t.checking != "":
+		t.status = fmt.Sprintf("%s 正在重新检测，请稍候", t.checking)
+		return
+	}
+	t.checking = d.Domain
+	go func(domain string) {
+		res, err := t.recheck(domain)
+		done <- tuiRecheck{domain: domain, result: res, err: err}
+	}(d.Domain)
+}
+
+// applyRecheck 用重新检测的结果替换原来的结果
+func (t *tuiState) applyRecheck(r tuiRecheck) {
+	t.checking = ""
+	if r.err != nil {
+		t.status = r.err.Error()
+		return
+	}
+	for i := range t.run.Results {
+		if t.run.Results[i].Domain == r.domain {
+			old := t.run.Results[i].verdict()
+			t.run.Results[i] = r.result
+			t.checked[r.domain] = time.Now()
+			t.status = fmt.Sprintf("%s 重新检测完成: %s → %s", r.domain, tuiVerdictNames[old], tuiVerdictNames[r.result.verdict()])
+			break
+		}
+	}
+}
+
+// ---------- 绘制 ----------
+
+// tuiLine 是内容区的一行，owner 为所属结果在筛选结果中的位置
+type tuiLine struct {
+	text  string
+	owner int
+	head  bool // 域名所在的行
+}
+
+// render 返回整个屏幕的内容
+func (t *tuiState) render(rows, cols int) string {
+	var b strings.Builder
+	b.WriteString("\x1b[H\x1b[2J")
+
+	counts := make(map[string]int)
+	for _, r := range t.run.Results {
+		counts[r.verdict()]++
+	}
+	title := fmt.Sprintf("dnscheck · %s · %s · %s", t.source, t.run.RunID, t.run.StartedAt.Local().Format("2006-01-02 15:04:05"))
+	b.WriteString("\x1b[1m" + truncateWidth(title, cols) + "\x1b[0m\r\n")
+	stats := fmt.Sprintf("%d 个域名：污染 %d，重绑定 %d，无法判定 %d，正常 %d · 筛选: %s",
+		len(t.run.Results), counts["polluted"], counts["rebinding"], counts[verdictError], counts["clean"], tuiVerdictNames[tuiFilters[t.filter]])
+	b.WriteString(truncateWidth(stats, cols) + "\r\n")
+
+	lines := t.lines(cols)
+	height := rows - 3
+	if height < 1 {
+		height = 1
+	}
+	// 滚动使选中的域名可见
+	first := 0
+	for i, l := range lines {
+		if l.head && l.owner == t.cursor {
+			first = i
+			break
+		}
+	}
+	if first < t.top {
+		t.top = first
+	}
+	if first >= t.top+height {
+		t.top = first - height + 1
+	}
+	for i := t.top; i < t.top+height; i++ {
+		if i < len(lines) {
+			l := lines[i]
+			if l.head && l.owner == t.cursor {
+				b.WriteString("\x1b[7m" + l.text + "\x1b[0m")
+			} else {
+				b.WriteString(l.text)
+			}
+		}
+		b.WriteString("\r\n")
+	}
+
+	status := t.status
+	if t.checking != "" && status == "" {
+		status = fmt.Sprintf("正在重新检测 %s …", t.checking)
+	}
+	if status == "" {
+		status = "↑↓ 移动  Enter 展开  e 全部展开  f 筛选  r 重新检测  q 退出"
+	}
+	b.WriteString("\x1b[2m" + truncateWidth(status, cols) + "\x1b[0m")
+	return b.String()
+}
+
+// lines 返回内容区的全部行：每个域名一行，展开的域名之后是明细
+func (t *tuiState) lines(cols int) []tuiLine {
+	var lines []tuiLine
+	idx := t.visible()
+	if len(idx) == 0 {
+		return []tuiLine{{text: "（没有符合筛选的域名）", owner: -1}}
+	}
+	for pos, i := range idx {
+		r := t.run.Results[i]
+		marker := "▸"
+		if t.expanded[r.Domain] {
+			marker = "▾"
+		}
+		verdict := r.verdict()
+		tag := padWidth(tuiVerdictNames[verdict], 8)
+		head := fmt.Sprintf("%s \x1b[%sm%s\x1b[0m %s  ", marker, tuiVerdictColors[verdict], tag, r.Domain)
+		plain := fmt.Sprintf("%s %s %s  ", marker, tag, r.Domain)
+		if room := cols - displayWidth(plain); room > 1 {
+			head += truncateWidth(r.Summary, room)
+		}
+		lines = append(lines, tuiLine{text: head, owner: pos, head: true})
+		if !t.expanded[r.Domain] {
+			continue
+		}
+		for _, text := range t.details(r) {
+			for _, w := range wrapWidth("    "+text, cols, "      ") {
+				lines = append(lines, tuiLine{text: w, owner: pos})
+			}
+		}
+	}
+	return lines
+}
+
+// details 返回一个域名展开后的明细
+func (t *tuiState) details(r domainJSON) []string {
+	out := []string{"汇总: " + r.Summary}
+	if at, ok := t.checked[r.Domain]; ok {
+		out = append(out, "已于 "+at.Format("15:04:05")+" 重新检测")
+	}
+	if len(r.Expected) > 0 {
+		out = append(out, "预期: "+strings.Join(r.Expected, ", "))
+	}
+	if r.Muted != "" {
+		out = append(out, "已静音: "+r.Muted)
+	}
+	if len(r.Trace) > 0 {
+		out = append(out, "解析路径: "+strings.Join(r.Trace, " → "))
+	}
+	if r.Failover != nil {
+		out = append(out, "解析器切换: "+r.Failover.String())
+	}
+	if len(r.CNAMEs) > 0 {
+		out = append(out, "CNAME: "+strings.Join(r.CNAMEs, " → "))
+	}
+	if r.Blockpage != "" {
+		out = append(out, "拦截页: "+r.Blockpage)
+	}
+	if len(r.Patterns) > 0 {
+		out = append(out, "干扰特征: "+strings.Join(r.Patterns, "；"))
+	}
+	if r.GeoHint != "" {
+		out = append(out, "国家提示: "+r.GeoHint)
+	}
+	if len(r.OffBaseline) > 0 {
+		out = append(out, "不在基线中: "+strings.Join(r.OffBaseline, ", "))
+	}
+	for _, ip := range r.IPs {
+		out = append(out, ipDetail(ip))
+	}
+	if len(r.IPs) == 0 {
+		out = append(out, "没有解析到 IP")
+	}
+	return out
+}
+
+// ipDetail 返回单个 IP 的明细
+func ipDetail(ip ipJSON) string {
+	parts := []string{"IP " + ip.IP}
+	switch {
+	case ip.Error != "":
+		parts = append(parts, "查询失败: "+ip.Error)
+	case ip.Private:
+		parts = append(parts, "私有地址")
+	default:
+		llc := ip.LLC
+		if ip.RawLLC != "" {
+			llc += "（" + ip.RawLLC + "）"
+		}
+		parts = append(parts, llc)
+		if ip.ASN != 0 {
+			parts = append(parts, fmt.Sprintf("AS%d", ip.ASN))
+		}
+		if ip.Country != "" {
+			parts = append(parts, ip.Country)
+		}
+		if ip.Matched {
+			parts = append(parts, "符合预期")
+		} else {
+			parts = append(parts, "不符合预期")
+		}
+	}
+	if ip.Forbidden != "" {
+		parts = append(parts, "黑名单: "+ip.Forbidden)
+	}
+	if len(ip.Listed) > 0 {
+		parts = append(parts, "被列入 "+strings.Join(ip.Listed, "、"))
+	}
+	if len(ip.Anomalies) > 0 {
+		parts = append(parts, fmt.Sprintf("响应异常（评分 %d）", ip.AnomalyScore))
+	}
+	return strings.Join(parts, "  ")
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ---------- Zabbix sender 输出 ----------
+
+// zabbixItem 对应 Zabbix trapper 监控项的一个值
+type zabbixItem struct {
+	Host  string `json:"host"`
+	Key   string `json:"key"`
+	Value string `json:"value"`
+	Clock int64  `json:"clock"`
+}
+
+// zabbixItems 将检测结果转换为 Zabbix 监控项：
+// dnscheck.polluted[域名]、dnscheck.domains_total、dnscheck.polluted_count、dnscheck.rebinding_count、
+// dnscheck.inconclusive_count、dnscheck.pollution_rate
+func zabbixItems(host string, results []DomainResult, at time.Time) []zabbixItem {
+	clock := at.Unix()
+	items := make([]zabbixItem, 0, len(results)+5)
+	polluted := 0
+	for _, r := range results {
+		v := "0"
+		if r.IsPolluted {
+			v = "1"
+			polluted++
+		}
+		// 主机名是 Zabbix 中配置的监控对象，不脱敏；监控项键中的域名随 -redact 脱敏
+		items = append(items, zabbixItem{Host: host, Key: redaction.Text(fmt.Sprintf("dnscheck.polluted[%s]", r.Domain)), Value: v, Clock: clock})
+	}
+	rate := 0.0
+	if len(results) > 0 {
+		rate = float64(polluted) / float64(len(results)) * 100
+	}
+	items = append(items,
+		zabbixItem{Host: host, Key: "dnscheck.domains_total", Value: strconv.Itoa(len(results)), Clock: clock},
+		zabbixItem{Host: host, Key: "dnscheck.polluted_count", Value: strconv.Itoa(polluted), Clock: clock},
+		zabbixItem{Host: host, Key: "dnscheck.rebinding_count", Value: strconv.Itoa(countRebinding(results)), Clock: clock},
+		zabbixItem{Host: host, Key: "dnscheck.inconclusive_count", Value: strconv.Itoa(countFailed(results)), Clock: clock},
+		zabbixItem{Host: host, Key: "dnscheck.pollution_rate", Value: strconv.FormatFloat(rate, 'f', 2, 64), Clock: clock},
+	)
+	return items
+}
+
+// sendZabbix 通过 Zabbix sender 协议（ZBXD 头 + JSON）推送监控项，返回服务端的处理信息
+func sendZabbix(server string, items []zabbixItem, timeout time.Duration) (string, error) {
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		server = net.JoinHostPort(server, "10051")
+	}
+	payload, err := json.Marshal(map[string]interface{}{
+		"request": "sender data",
+		"data":    items,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	conn, err := net.DialTimeout("tcp", server, timeout)
+	if err != nil {
+		return "", fmt.Errorf("连接 Zabbix 服务器 %s 失败: %w", server, err)
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(timeout))
+
+	var header [13]byte
+	copy(header[:], "ZBXD\x01")
+	binary.LittleEndian.PutUint64(header[5:], uint64(len(payload)))
+	if _, err := conn.Write(append(header[:], payload...)); err != nil {
+		return "", fmt.Errorf("发送 Zabbix 数据失败: %w", err)
+	}
+
+	var respHeader [13]byte
+	if _, err := io.ReadFull(conn, respHeader[:]); err != nil {
+		return "", fmt.Errorf("读取 Zabbix 响应失败: %w", err)
+	}
+	if !bytes.HasPrefix(respHeader[:], []byte("ZBXD")) {
+		return "", fmt.Errorf("无效的 Zabbix 响应")
+	}
+	n := binary.LittleEndian.Uint64(respHeader[5:])
+	if n > 1<<20 {
+		return "", fmt.Errorf("Zabbix 响应过大: %d 字节", n)
+	}
+	body := make([]byte, n)
+	if _, err := io.ReadFull(conn, body); err != nil {
+		return "", fmt.Errorf("读取 Zabbix 响应失败: %w", err)
+	}
+	var result struct {
+		Response string `json:"response"`
+		Info     string `json:"info"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return "", fmt.Errorf("解析 Zabbix 响应失败: %w", err)
+	}
+	if result.Response != "success" {
+		return result.Info, fmt.Errorf("Zabbix 服务器拒绝数据: %s", result.Info)
+	}
+	return result.Info, nil
+}
+
+// writeZabbixBatch 写出 `zabbix_sender -T -i <文件>` 可直接读取的批量文件
+func writeZabbixBatch(path string, items []zabbixItem) error {
+	var b strings.Builder
+	for _, it := range items {
+		b.WriteString(fmt.Sprintf("%s %s %d %s\n", zabbixQuote(it.Host), zabbixQuote(it.Key), it.Clock, zabbixQuote(it.Value)))
+	}
+	return os.WriteFile(path, []byte(b.String()), 0644)
+}
+
+// zabbixQuote 在字段包含空白或引号时加双引号并转义
+func zabbixQuote(s string) string {
+	if s != "" && !strings.ContainsAny(s, " \t\"\\") {
+		return s
+	}
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// exportZabbix 根据命令行参数推送或写出 Zabbix 数据，未启用时不做任何事
+func exportZabbix(results []DomainResult) error {
+	if *zbxServer == "" && *zbxBatch == "" {
+		return nil
+	}
+	host := *zbxHost
+	if host == "" {
+		host, _ = os.Hostname()
+	}
+	items := zabbixItems(host, results, time.Now())
+	if *zbxBatch != "" {
+		if err := writeZabbixBatch(*zbxBatch, items); err != nil {
+			return fmt.Errorf("写入 Zabbix 批量文件失败: %w", err)
+		}
+	}
+	if *zbxServer != "" {
+		info, err := sendZabbix(*zbxServer, items, *timeout)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "Zabbix: %s\n", info)
+	}
+	return nil
+}